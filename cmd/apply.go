@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Okira-E/patchi/pkg/config"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/migrator"
+	"github.com/Okira-E/patchi/pkg/safety"
+	"github.com/Okira-E/patchi/pkg/sequelizer"
+	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/vars/colors"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var ApplyCmd = &cobra.Command{
+	Use:   "apply [first-connection] [second-connection]",
+	Short: "Apply the migration between 2 databases to the second one.",
+	Long: `
+Compares 2 stored connections and runs the generated migration against the second one after confirmation.
+The first side can also be a snapshot file, but the second one must be a stored connection.
+On Postgres the whole migration runs in a single transaction. On Mysql, where DDL statements commit
+implicitly, the migration stops at the first failing statement and reports the statements that already ran.
+Changes that lose data or lock tables are listed before the confirmation. With --fail-on-destructive, nothing is
+applied and the command exits with code 3 if any change loses data.
+	`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		firstConnectionName, secondConnectionName, errOpt := getConnectionNamesFromArgsOrFlags(cmd, args)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+		isDryRun, _ := cmd.Flags().GetBool("dry-run")
+		skipConfirmation, _ := cmd.Flags().GetBool("yes")
+
+		userConfig, errOpt := config.GetUserConfig()
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+
+		ignoreRules, errOpt := getIgnoreRules(cmd, userConfig)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+
+		firstSide, secondSide, closeConnections, errOpt := loadComparisonSides(cmd.Context(), userConfig, firstConnectionName, secondConnectionName)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+		defer closeConnections()
+
+		if secondSide.db.IsNone() {
+			return fmt.Errorf("\"%s\" is a snapshot file. A migration can only be applied to a stored connection", secondConnectionName)
+		}
+		secondDb := secondSide.db.Unwrap()
+
+		printDialectMismatches(firstSide.mismatches)
+
+		dialect := firstSide.schema.Dialect
+
+		schemaDiff := difftool.GetSchemaDiff(firstSide.schema, secondSide.schema, ignoreRules)
+		confirmRenameCandidates(cmd, &schemaDiff)
+
+		statements, errMsgOpt := sequelizer.GenerateMigration(firstSide.schema, secondSide.schema, dialect, schemaDiff)
+		if errMsgOpt.IsSome() {
+			return fmt.Errorf("Error generating the migration: %s", errMsgOpt.Unwrap())
+		}
+
+		if len(statements) == 0 {
+			utils.PrintInColor(colors.Green, "No differences found. Nothing to apply.", false)
+			return nil
+		}
+
+		risks := safety.AnalyzeMigration(statements, firstSide.schema, secondSide.schema, loadRowCounts(cmd.Context(), secondSide))
+		printRisks(risks)
+		if err := checkDestructiveRisks(cmd, risks); err != nil {
+			return err
+		}
+
+		if isDryRun {
+			header := getMigrationScriptHeader("Dry run. Nothing was applied.", firstConnectionName, secondConnectionName, dialect)
+			fmt.Print(sequelizer.FormatMigrationScript(statements, dialect, header))
+			return nil
+		}
+
+		if !skipConfirmation {
+			confirmationPrmpt := promptui.Prompt{
+				Label:     fmt.Sprintf("Apply %d statements to \"%s\"", len(statements), secondConnectionName),
+				IsConfirm: true,
+			}
+			if _, err := confirmationPrmpt.Run(); err != nil {
+				return errors.New("Aborted. Nothing was applied.")
+			}
+		}
+
+		appliedStatements, errOpt := migrator.ApplyMigration(cmd.Context(), secondDb, statements)
+		if errOpt.IsSome() {
+			if len(appliedStatements) != 0 {
+				utils.PrintInColor(colors.Yellow, "The following statements were applied before the failure and were NOT rolled back:", true)
+				for i, statement := range appliedStatements {
+					utils.PrintInColor(colors.Yellow, fmt.Sprintf("  %d. %s %s", i+1, statement.EntityType, statement.EntityName), true)
+				}
+			}
+
+			return fmt.Errorf("Error applying the migration: %w", errOpt.Unwrap())
+		}
+
+		utils.PrintInColor(colors.Green, fmt.Sprintf("Applied %d statements to \"%s\" successfully.", len(appliedStatements), secondConnectionName), false)
+
+		return nil
+	},
+}
@@ -1,76 +1,73 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/Okira-E/patchi/pkg/config"
 	"github.com/Okira-E/patchi/pkg/prompts"
 	"github.com/Okira-E/patchi/pkg/tui"
 	"github.com/Okira-E/patchi/pkg/tui/patchi_renderer"
-	"github.com/Okira-E/patchi/pkg/types"
-	"github.com/Okira-E/patchi/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
 var StartCmd = &cobra.Command{
-	Use:   "compare",
+	Use:   "compare [first] [second]",
 	Short: "CompareRoot 2 databases.",
 	Long: `
 Patchi connects to 2 of your databases and shows you the differences between them. Useful for
-migrating database environments.
+migrating database environments. Either side can be a stored connection or a snapshot file taken
+with "patchi snapshot". Without arguments, you are prompted to pick 2 stored connections.
 	`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Args: cobra.MatchAll(cobra.MaximumNArgs(2), func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			return fmt.Errorf("expected either no arguments or 2 of them")
+		}
+
+		return nil
+	}),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		userConfig, errOpt := config.GetUserConfig()
 		if errOpt.IsSome() {
-			utils.Abort(errOpt.Unwrap().Error())
+			return errOpt.Unwrap()
 		}
 
-		firstDbConnectionInfo, secondDbConnectionInfo, errMsgOpt := prompts.PromptForDbConnections(userConfig)
-		if errMsgOpt.IsSome() {
-			utils.Abort(errOpt.Unwrap().Error())
-		}
+		var firstArg, secondArg string
+		if len(args) == 2 {
+			firstArg, secondArg = args[0], args[1]
+		} else {
+			firstDbConnectionInfo, secondDbConnectionInfo, errMsgOpt := prompts.PromptForDbConnections(userConfig)
+			if errMsgOpt.IsSome() {
+				return errors.New(errMsgOpt.Unwrap())
+			}
 
-		firstDbConnection, errOpt := firstDbConnectionInfo.Connect()
-		if errOpt.IsSome() {
-			utils.Abort(fmt.Sprintf("Error connecting to %s: %s", firstDbConnectionInfo.DatabaseName, errOpt.Unwrap()))
+			firstArg, secondArg = firstDbConnectionInfo.Name, secondDbConnectionInfo.Name
 		}
-		defer func() {
-			err := firstDbConnection.Close()
-			if err != nil {
-				utils.Abort(fmt.Sprintf("Error closing connection to %s: %s", firstDbConnectionInfo.DatabaseName, err))
-			}
-		}()
 
-		secondDbConnection, errOpt := secondDbConnectionInfo.Connect()
+		ignoreRules, errOpt := getIgnoreRules(cmd, userConfig)
 		if errOpt.IsSome() {
-			utils.Abort(fmt.Sprintf("Error connecting to %s: %s", secondDbConnectionInfo.DatabaseName, errOpt.Unwrap()))
+			return errOpt.Unwrap()
 		}
-		defer func() {
-			err := secondDbConnection.Close()
-			if err != nil {
-				utils.Abort(fmt.Sprintf("Error closing connection to %s: %s", secondDbConnectionInfo.DatabaseName, err))
-			}
-		}()
 
-		if err := firstDbConnection.Ping(); err != nil {
-			utils.Abort(fmt.Sprintf("Failed to ping the \"%s\" database", firstDbConnectionInfo.Name))
+		firstSide, secondSide, closeConnections, errOpt := loadComparisonSides(cmd.Context(), userConfig, firstArg, secondArg)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
 		}
+		defer closeConnections()
 
-		if err := secondDbConnection.Ping(); err != nil {
-			utils.Abort(fmt.Sprintf("Failed to ping the \"%s\" database", secondDbConnectionInfo.Name))
+		params := &patchi_renderer.PatchiRendererParams{
+			FirstSchema:       firstSide.schema,
+			SecondSchema:      secondSide.schema,
+			SecondDb:          secondSide.db,
+			IgnoreRules:       ignoreRules,
+			DialectMismatches: firstSide.mismatches,
 		}
 
-		params := &patchi_renderer.PatchiRendererParams {
-			FirstDb: types.DbConnection{
-				Info:          firstDbConnectionInfo,
-				SqlConnection: firstDbConnection,
-			},
-			SecondDb: types.DbConnection{
-				Info:          secondDbConnectionInfo,
-				SqlConnection: secondDbConnection,
-			},
+		errOpt = tui.RenderTui(params)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
 		}
 
-		tui.RenderTui(params)
+		return nil
 	},
 }
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Okira-E/patchi/pkg/config"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/sequelizer"
+	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/vars/colors"
+	"github.com/Okira-E/patchi/safego"
+	"github.com/spf13/cobra"
+)
+
+var DataDiffCmd = &cobra.Command{
+	Use:   "data-diff [first-connection] [second-connection]",
+	Short: "Compare the rows of tables between 2 databases.",
+	Long: `
+Compares the rows of the tables given by --tables between 2 stored connections, matching them by their primary key,
+and generates the INSERT, UPDATE and DELETE statements that bring the second database in line with the first one.
+Meant for lookup tables (countries, feature flags, permissions, etc.) that drift between environments.
+The rows are read in chunks of --chunk-size, so large tables don't have to fit in memory. The statements are written
+to the file given by --output, or to stdout otherwise, and a summary of each table is printed to stderr.
+It exits with code 2 if any rows differ.
+	`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		firstConnectionName, secondConnectionName, errOpt := getConnectionNamesFromArgsOrFlags(cmd, args)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+		tableNames, _ := cmd.Flags().GetStringSlice("tables")
+		chunkSize, _ := cmd.Flags().GetInt("chunk-size")
+		outputFilePath, _ := cmd.Flags().GetString("output")
+
+		if len(tableNames) == 0 {
+			return errors.New("At least one table is required. Pass them with --tables")
+		}
+
+		userConfig, errOpt := config.GetUserConfig()
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+
+		firstSide, secondSide, closeConnections, errOpt := loadComparisonSides(cmd.Context(), userConfig, firstConnectionName, secondConnectionName)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+		defer closeConnections()
+
+		if firstSide.db.IsNone() || secondSide.db.IsNone() {
+			return errors.New("Rows can only be compared between 2 stored connections. Snapshot files don't hold any rows")
+		}
+		firstDb, secondDb := firstSide.db.Unwrap(), secondSide.db.Unwrap()
+
+		// Values are read as each driver reports them, which differs between dialects (e.g. booleans.)
+		if firstDb.Info.Dialect != secondDb.Info.Dialect {
+			return errors.New("Rows can only be compared between 2 connections of the same dialect")
+		}
+
+		for _, tableName := range tableNames {
+			_, isInFirst := firstSide.schema.Tables[tableName]
+			_, isInSecond := secondSide.schema.Tables[tableName]
+			if !isInFirst || !isInSecond {
+				return fmt.Errorf("Table %s must exist in both databases to compare its rows", tableName)
+			}
+		}
+
+		output := os.Stdout
+		if outputFilePath != "" {
+			outputFile, err := os.Create(outputFilePath)
+			if err != nil {
+				return fmt.Errorf("Error creating %s: %w", outputFilePath, err)
+			}
+			// Closing it again once it is written only returns an error, which is ignored.
+			defer outputFile.Close()
+
+			output = outputFile
+		}
+		writer := bufio.NewWriter(output)
+
+		dialect := firstSide.schema.Dialect
+
+		header := getMigrationScriptHeader("Data migration generated by Patchi", firstConnectionName, secondConnectionName, dialect)
+		for _, line := range header {
+			fmt.Fprintln(writer, "-- "+line)
+		}
+
+		totalRowDiffs := 0
+		for _, tableName := range tableNames {
+			fmt.Fprintln(writer, "\n-- "+tableName)
+
+			rowDiffCounts := map[difftool.DiffType]int{}
+			errOpt := difftool.CompareTableData(cmd.Context(), firstDb, secondDb, firstSide.schema.Tables[tableName], secondSide.schema.Tables[tableName], chunkSize, func(rowDiff difftool.RowDiff) safego.Option[error] {
+				rowDiffCounts[rowDiff.DiffType] += 1
+
+				sql := sequelizer.GenerateSqlForRow(firstSide.schema, dialect, rowDiff)
+				if sql == "" {
+					return safego.None[error]()
+				}
+
+				if _, err := fmt.Fprintln(writer, sql); err != nil {
+					return safego.Some(err)
+				}
+
+				return safego.None[error]()
+			})
+			if errOpt.IsSome() {
+				return fmt.Errorf("Error comparing the rows of %s: %w", tableName, errOpt.Unwrap())
+			}
+
+			tableRowDiffs := rowDiffCounts[difftool.Created] + rowDiffCounts[difftool.Deleted] + rowDiffCounts[difftool.Modified]
+			totalRowDiffs += tableRowDiffs
+
+			if tableRowDiffs == 0 {
+				utils.PrintInColor(colors.Green, fmt.Sprintf("%s: in sync.", tableName), true)
+			} else {
+				utils.PrintInColor(colors.Yellow, fmt.Sprintf("%s: %d inserted, %d updated, %d deleted.", tableName, rowDiffCounts[difftool.Created], rowDiffCounts[difftool.Modified], rowDiffCounts[difftool.Deleted]), true)
+			}
+		}
+
+		if err := writer.Flush(); err != nil {
+			return fmt.Errorf("Error writing the statements: %w", err)
+		}
+
+		if outputFilePath != "" {
+			if err := output.Close(); err != nil {
+				return fmt.Errorf("Error writing the statements to %s: %w", outputFilePath, err)
+			}
+
+			utils.PrintInColor(colors.Green, fmt.Sprintf("Wrote the statements of %d rows to %s.", totalRowDiffs, outputFilePath), true)
+		}
+
+		if totalRowDiffs != 0 {
+			return errDrift
+		}
+
+		return nil
+	},
+}
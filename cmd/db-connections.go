@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/Okira-E/patchi/pkg/config"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/types"
+	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/vars/colors"
+	"github.com/Okira-E/patchi/safego"
+	"github.com/spf13/cobra"
+)
+
+// comparisonSide is one of the two sides of a comparison. Its schema is either read from a live database or from a
+// snapshot file, in which case there is no connection to it.
+type comparisonSide struct {
+	schema *schema.Schema
+	db     safego.Option[types.DbConnection]
+	// mismatches are what didn't translate exactly when the schema was translated into the dialect of the other side.
+	mismatches []difftool.DialectMismatch
+}
+
+// loadComparisonSides loads the schemas of both sides of a comparison. Each argument is either the name of a stored
+// connection or the path to a snapshot file. A Mysql side compared with a Postgres side is translated into the dialect
+// of the second side. It fails if either side can't be loaded or if the dialects can't be compared, and returns a
+// function that closes the open connections, which is meant to be deferred by the caller.
+func loadComparisonSides(ctx context.Context, userConfig types.UserConfig, firstArg string, secondArg string) (comparisonSide, comparisonSide, func(), safego.Option[error]) {
+	firstSide, errOpt := loadComparisonSide(ctx, userConfig, firstArg)
+	if errOpt.IsSome() {
+		return comparisonSide{}, comparisonSide{}, nil, errOpt
+	}
+
+	secondSide, errOpt := loadComparisonSide(ctx, userConfig, secondArg)
+	if errOpt.IsSome() {
+		closeComparisonSides(firstSide)
+		return comparisonSide{}, comparisonSide{}, nil, errOpt
+	}
+
+	closeConnections := func() {
+		closeComparisonSides(firstSide, secondSide)
+	}
+
+	if !difftool.CanCompareDialects(firstSide.schema.Dialect, secondSide.schema.Dialect) {
+		closeConnections()
+		return comparisonSide{}, comparisonSide{}, nil, safego.Some(fmt.Errorf("Cannot compare a %s database with a %s database", firstSide.schema.Dialect, secondSide.schema.Dialect))
+	}
+
+	if firstSide.schema.Dialect != secondSide.schema.Dialect {
+		utils.PrintInColor(colors.Blue, fmt.Sprintf("Translating the schema of \"%s\" from %s to %s...", firstArg, firstSide.schema.Dialect, secondSide.schema.Dialect), true)
+
+		firstSide.schema, firstSide.mismatches = difftool.TranslateSchema(firstSide.schema, secondSide.schema)
+	}
+
+	return firstSide, secondSide, closeConnections, safego.None[error]()
+}
+
+// closeComparisonSides closes the connections of the sides, along with their SSH tunnels. A connection that fails to
+// close is only reported, since whatever the command did is done by then.
+func closeComparisonSides(sides ...comparisonSide) {
+	for _, side := range sides {
+		if side.db.IsNone() {
+			continue
+		}
+
+		db := side.db.Unwrap()
+		if err := db.SqlConnection.Close(); err != nil {
+			utils.PrintInColor(colors.Red, fmt.Sprintf("Error closing connection to %s: %s", db.Info.DatabaseName, err), true)
+		}
+	}
+}
+
+// loadComparisonSide loads one side of a comparison. Stored connections take precedence over files with the same name.
+func loadComparisonSide(ctx context.Context, userConfig types.UserConfig, arg string) (comparisonSide, safego.Option[error]) {
+	if dbConnectionInfo, ok := userConfig.DbConnections[arg]; ok {
+		db, errOpt := connectToDb(&userConfig, dbConnectionInfo)
+		if errOpt.IsSome() {
+			return comparisonSide{}, errOpt
+		}
+
+		utils.PrintInColor(colors.Blue, fmt.Sprintf("Reading the schema of \"%s\"...", dbConnectionInfo.Name), true)
+
+		dbSchema, errOpt := difftool.LoadSchema(ctx, db)
+		if errOpt.IsSome() {
+			_ = db.SqlConnection.Close()
+			return comparisonSide{}, safego.Some(fmt.Errorf("Error reading the schema of \"%s\": %w", dbConnectionInfo.Name, errOpt.Unwrap()))
+		}
+
+		return comparisonSide{schema: dbSchema, db: safego.Some(db)}, safego.None[error]()
+	}
+
+	if _, err := os.Stat(arg); err == nil {
+		dbSchema, errOpt := schema.ReadSnapshot(arg)
+		if errOpt.IsSome() {
+			return comparisonSide{}, safego.Some(fmt.Errorf("Error reading the snapshot %s: %w", arg, errOpt.Unwrap()))
+		}
+
+		return comparisonSide{schema: dbSchema, db: safego.None[types.DbConnection]()}, safego.None[error]()
+	}
+
+	return comparisonSide{}, safego.Some(fmt.Errorf("No connection or snapshot file named \"%s\" was found", arg))
+}
+
+// connectToDb connects to a database and makes sure it is reachable. An encrypted password is unlocked with the master
+// passphrase first.
+func connectToDb(userConfig *types.UserConfig, dbConnectionInfo *types.DbConnectionInfo) (types.DbConnection, safego.Option[error]) {
+	errOpt := config.UnlockDbConnection(userConfig, dbConnectionInfo)
+	if errOpt.IsSome() {
+		return types.DbConnection{}, errOpt
+	}
+
+	sqlConnection, errOpt := dbConnectionInfo.Connect()
+	if errOpt.IsSome() {
+		return types.DbConnection{}, safego.Some(fmt.Errorf("Error connecting to %s: %w", dbConnectionInfo.DatabaseName, errOpt.Unwrap()))
+	}
+
+	if err := sqlConnection.Ping(); err != nil {
+		_ = sqlConnection.Close()
+		return types.DbConnection{}, safego.Some(fmt.Errorf("Failed to ping the \"%s\" database: %w", dbConnectionInfo.Name, err))
+	}
+
+	return types.DbConnection{
+		Info:          dbConnectionInfo,
+		SqlConnection: sqlConnection,
+	}, safego.None[error]()
+}
+
+// getIgnoreRules combines the ignore rules of the user config with the ones of the project's ignore file, which is
+// given by the `--ignore-file` flag. It fails if any of the patterns is invalid.
+func getIgnoreRules(cmd *cobra.Command, userConfig types.UserConfig) (*difftool.IgnoreRules, safego.Option[error]) {
+	ignoreFilePath, _ := cmd.Flags().GetString("ignore-file")
+
+	projectIgnoreRules, errOpt := config.ReadIgnoreFile(ignoreFilePath)
+	if errOpt.IsSome() {
+		return nil, safego.Some(fmt.Errorf("Error reading %s: %w", ignoreFilePath, errOpt.Unwrap()))
+	}
+
+	return difftool.NewIgnoreRules(userConfig.IgnoreRules.Merge(projectIgnoreRules))
+}
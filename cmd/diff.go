@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/config"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/safety"
+	"github.com/Okira-E/patchi/pkg/sequelizer"
+	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/vars/colors"
+	"github.com/Okira-E/patchi/safego"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var DiffCmd = &cobra.Command{
+	Use:   "diff [first] [second]",
+	Short: "Print the differences between 2 databases without the TUI.",
+	Long: `
+Compares 2 stored connections or snapshot files and prints the differences between them as text, JSON
+or YAML. Meant for CI pipelines: it never prompts, and it exits with code 2 if any differences are found.
+With --fail-on-destructive, it exits with code 3 instead if the migration would lose data.
+If the migration cannot be generated, the differences are still printed and the error is reported on stderr.
+	`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		firstConnectionName, secondConnectionName, errOpt := getConnectionNamesFromArgsOrFlags(cmd, args)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		if format != "text" && format != "json" && format != "yaml" {
+			return fmt.Errorf("Unsupported format \"%s\". Expected one of: text, json, yaml", format)
+		}
+
+		userConfig, errOpt := config.GetUserConfig()
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+
+		ignoreRules, errOpt := getIgnoreRules(cmd, userConfig)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+
+		firstSide, secondSide, closeConnections, errOpt := loadComparisonSides(cmd.Context(), userConfig, firstConnectionName, secondConnectionName)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+		rowCounts := loadRowCounts(cmd.Context(), secondSide)
+		closeConnections()
+
+		schemaDiff := difftool.GetSchemaDiff(firstSide.schema, secondSide.schema, ignoreRules)
+		schemaDiff.DialectMismatches = firstSide.mismatches
+
+		// The risks are those of the migration `generate` would write, where possible renames are dropped and created.
+		// The diff is printed even if the migration can't be generated, only without its risks.
+		var risks []safety.Risk
+		statements, errMsgOpt := sequelizer.GenerateMigration(firstSide.schema, secondSide.schema, firstSide.schema.Dialect, schemaDiff)
+		if errMsgOpt.IsNone() {
+			risks = safety.AnalyzeMigration(statements, firstSide.schema, secondSide.schema, rowCounts)
+		}
+
+		if format == "json" {
+			output, err := json.MarshalIndent(schemaDiff, "", "\t")
+			if err != nil {
+				return fmt.Errorf("Error formatting the diff: %w", err)
+			}
+
+			fmt.Println(string(output))
+		} else if format == "yaml" {
+			output, err := yaml.Marshal(schemaDiff)
+			if err != nil {
+				return fmt.Errorf("Error formatting the diff: %w", err)
+			}
+
+			fmt.Print(string(output))
+		} else {
+			fmt.Printf("Comparing \"%s\" against \"%s\" (%s)\n\n", firstConnectionName, secondConnectionName, firstSide.schema.Dialect)
+			printSchemaDiffAsText(schemaDiff, risks)
+		}
+
+		if errMsgOpt.IsSome() {
+			utils.PrintInColor(colors.Red, fmt.Sprintf("Error generating the migration, so its risks were not analyzed: %s", errMsgOpt.Unwrap()), true)
+
+			// Without the migration, there is no telling whether it is destructive.
+			if failOnDestructive, _ := cmd.Flags().GetBool("fail-on-destructive"); failOnDestructive {
+				return errors.New("Aborted because the risks of the migration could not be analyzed (--fail-on-destructive).")
+			}
+		}
+
+		if err := checkDestructiveRisks(cmd, risks); err != nil {
+			return err
+		}
+
+		if schemaDiff.Count() != 0 {
+			return errDrift
+		}
+
+		return nil
+	},
+}
+
+// getConnectionNamesFromArgsOrFlags reads the names of the two connections (or snapshot files) to compare. Positional
+// arguments take precedence over the `--first` and `--second` flags.
+func getConnectionNamesFromArgsOrFlags(cmd *cobra.Command, args []string) (string, string, safego.Option[error]) {
+	firstConnectionName, _ := cmd.Flags().GetString("first")
+	secondConnectionName, _ := cmd.Flags().GetString("second")
+
+	if len(args) > 0 {
+		firstConnectionName = args[0]
+	}
+	if len(args) > 1 {
+		secondConnectionName = args[1]
+	}
+
+	if firstConnectionName == "" || secondConnectionName == "" {
+		return "", "", safego.Some(errors.New("Two connection names or snapshot files are required. Pass them as arguments or with the --first and --second flags"))
+	}
+
+	return firstConnectionName, secondConnectionName, safego.None[error]()
+}
+
+// printSchemaDiffAsText prints the diff in a human-readable form. Created entities are prefixed with `+`, deleted
+// ones with `-`, modified ones with `~`, renamed ones with `>` and possible renames with `?`. The risks of the migration
+// follow, where destructive ones are prefixed with `!` and warnings with `*`. When the schemas are of different
+// dialects, what didn't translate exactly is listed first.
+func printSchemaDiffAsText(schemaDiff difftool.SchemaDiff, risks []safety.Risk) {
+	lines := []string{}
+	for _, mismatch := range schemaDiff.DialectMismatches {
+		lines = append(lines, "  "+mismatch.EntityName+": "+mismatch.Message)
+	}
+	printDiffSection("Dialect mismatches", lines)
+
+	if schemaDiff.Count() == 0 {
+		fmt.Println("No differences found.")
+		return
+	}
+
+	lines = []string{}
+	for _, diff := range schemaDiff.Tables {
+		lines = append(lines, formatDiffLine(diff.DiffType, diff.TableName, nil))
+	}
+	printDiffSection("Tables", lines)
+
+	lines = []string{}
+	for _, diff := range schemaDiff.Columns {
+		lines = append(lines, formatDiffLine(diff.DiffType, diff.TableName+" → "+diff.ColumnName, diff.ModifiedProperties))
+	}
+	printDiffSection("Columns", lines)
+
+	lines = []string{}
+	for _, diff := range schemaDiff.Indexes {
+		lines = append(lines, formatDiffLine(diff.DiffType, diff.TableName+" → "+diff.IndexName, diff.ModifiedProperties))
+	}
+	printDiffSection("Indexes", lines)
+
+	lines = []string{}
+	for _, diff := range schemaDiff.Constraints {
+		lines = append(lines, formatDiffLine(diff.DiffType, diff.TableName+" → "+diff.ConstraintName+" ["+diff.ConstraintType+"]", diff.ModifiedProperties))
+	}
+	printDiffSection("Constraints", lines)
+
+	lines = []string{}
+	for _, diff := range schemaDiff.Views {
+		lines = append(lines, formatDiffLine(diff.DiffType, diff.ViewName, nil))
+	}
+	printDiffSection("Views", lines)
+
+	lines = []string{}
+	for _, diff := range schemaDiff.Procedures {
+		lines = append(lines, formatDiffLine(diff.DiffType, diff.ProcedureName, nil))
+	}
+	printDiffSection("Procedures", lines)
+
+	lines = []string{}
+	for _, diff := range schemaDiff.Functions {
+		lines = append(lines, formatDiffLine(diff.DiffType, diff.FunctionName, nil))
+	}
+	printDiffSection("Functions", lines)
+
+	lines = []string{}
+	for _, diff := range schemaDiff.Triggers {
+		lines = append(lines, formatDiffLine(diff.DiffType, diff.TriggerName, nil))
+	}
+	printDiffSection("Triggers", lines)
+
+	lines = []string{}
+	for _, rename := range schemaDiff.Renames {
+		lines = append(lines, "  > "+formatRename(rename))
+	}
+	printDiffSection("Renames", lines)
+
+	// Candidates are also listed above as a deleted and a created entity, which is what they are until confirmed.
+	lines = []string{}
+	for _, candidate := range schemaDiff.RenameCandidates {
+		lines = append(lines, "  ? "+formatRename(candidate))
+	}
+	printDiffSection("Possible renames", lines)
+
+	lines = []string{}
+	for _, risk := range risks {
+		lines = append(lines, "  "+utils.Ternary(risk.Level == safety.Destructive, "!", "*")+" "+risk.Message)
+	}
+	printDiffSection("Risks", lines)
+
+	fmt.Printf("Found %d changes.\n", schemaDiff.Count())
+}
+
+// formatRename formats a rename as `old ⇢ new`. Renamed columns are prefixed with their table.
+func formatRename(rename difftool.RenameCandidate) string {
+	ret := rename.OldName + " ⇢ " + rename.NewName
+	if rename.TableName != "" {
+		ret = rename.TableName + " → " + ret
+	}
+
+	return ret
+}
+
+// formatDiffLine formats a single entity of the diff as a line of text.
+func formatDiffLine(diffType difftool.DiffType, entityName string, modifiedProperties []string) string {
+	prefix := "~"
+	if diffType == difftool.Created {
+		prefix = "+"
+	} else if diffType == difftool.Deleted {
+		prefix = "-"
+	}
+
+	line := "  " + prefix + " " + entityName
+	if len(modifiedProperties) != 0 {
+		line += " (" + strings.Join(modifiedProperties, ", ") + ")"
+	}
+
+	return line
+}
+
+// printDiffSection prints the lines of one entity type under a title. Empty sections are skipped.
+func printDiffSection(title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+
+	fmt.Printf("%s (%d)\n", title, len(lines))
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Okira-E/patchi/pkg/difftool"
+)
+
+func TestFormatDiffLine(t *testing.T) {
+	tests := []struct {
+		diffType           difftool.DiffType
+		entityName         string
+		modifiedProperties []string
+		expected           string
+	}{
+		{difftool.Created, "users", nil, "  + users"},
+		{difftool.Deleted, "users → email", nil, "  - users → email"},
+		{difftool.Modified, "users → email", []string{"type", "nullable"}, "  ~ users → email (type, nullable)"},
+	}
+
+	for _, test := range tests {
+		if line := formatDiffLine(test.diffType, test.entityName, test.modifiedProperties); line != test.expected {
+			t.Errorf("got %q, expected %q", line, test.expected)
+		}
+	}
+}
+
+func TestFormatRename(t *testing.T) {
+	if formatted := formatRename(difftool.RenameCandidate{EntityType: "tables", OldName: "user", NewName: "users"}); formatted != "user ⇢ users" {
+		t.Errorf("got %q", formatted)
+	}
+
+	if formatted := formatRename(difftool.RenameCandidate{EntityType: "columns", TableName: "users", OldName: "mail", NewName: "email"}); formatted != "users → mail ⇢ email" {
+		t.Errorf("got %q", formatted)
+	}
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Okira-E/patchi/pkg/config"
+	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/vars/colors"
+	"github.com/spf13/cobra"
+)
+
+var EncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt the passwords stored in plain text.",
+	Long: `
+Encrypts the passwords of the stored connections that are still in plain text, which is the case for connections
+added by older versions of Patchi. If the config doesn't have a master passphrase yet, you are asked to choose one.
+The passphrase is read from the PATCHI_PASSPHRASE environment variable when it is set.
+	`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		count, errOpt := config.EncryptStoredPasswords()
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+
+		utils.PrintInColor(colors.Green, fmt.Sprintf("%d password(s) encrypted.", count), false)
+
+		return nil
+	},
+}
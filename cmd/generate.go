@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Okira-E/patchi/pkg/config"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/safety"
+	"github.com/Okira-E/patchi/pkg/sequelizer"
+	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/vars/colors"
+	"github.com/spf13/cobra"
+)
+
+var GenerateCmd = &cobra.Command{
+	Use:   "generate [first] [second]",
+	Short: "Generate a migration script between 2 databases.",
+	Long: `
+Compares 2 stored connections or snapshot files and generates a single migration script that brings the second database
+in line with the first one. The script is written to the file given by --output, or to stdout otherwise.
+A rollback script that undoes the migration is written to the file given by --rollback-output.
+Changes that lose data or lock tables are listed on stderr. With --fail-on-destructive, nothing is written and the
+command exits with code 3 if any change loses data.
+	`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		firstConnectionName, secondConnectionName, errOpt := getConnectionNamesFromArgsOrFlags(cmd, args)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+		outputFilePath, _ := cmd.Flags().GetString("output")
+		rollbackOutputFilePath, _ := cmd.Flags().GetString("rollback-output")
+
+		userConfig, errOpt := config.GetUserConfig()
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+
+		ignoreRules, errOpt := getIgnoreRules(cmd, userConfig)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+
+		firstSide, secondSide, closeConnections, errOpt := loadComparisonSides(cmd.Context(), userConfig, firstConnectionName, secondConnectionName)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+		rowCounts := loadRowCounts(cmd.Context(), secondSide)
+		closeConnections()
+
+		printDialectMismatches(firstSide.mismatches)
+
+		dialect := firstSide.schema.Dialect
+
+		schemaDiff := difftool.GetSchemaDiff(firstSide.schema, secondSide.schema, ignoreRules)
+		confirmRenameCandidates(cmd, &schemaDiff)
+
+		statements, errMsgOpt := sequelizer.GenerateMigration(firstSide.schema, secondSide.schema, dialect, schemaDiff)
+		if errMsgOpt.IsSome() {
+			return fmt.Errorf("Error generating the migration: %s", errMsgOpt.Unwrap())
+		}
+
+		risks := safety.AnalyzeMigration(statements, firstSide.schema, secondSide.schema, rowCounts)
+		printRisks(risks)
+		if err := checkDestructiveRisks(cmd, risks); err != nil {
+			return err
+		}
+
+		header := getMigrationScriptHeader("Migration generated by Patchi", firstConnectionName, secondConnectionName, dialect)
+		script := sequelizer.FormatMigrationScript(statements, dialect, header)
+
+		if rollbackOutputFilePath != "" {
+			rollbackHeader := getMigrationScriptHeader("Rollback generated by Patchi. It undoes the migration below.", firstConnectionName, secondConnectionName, dialect)
+			rollbackScript := sequelizer.FormatMigrationScript(sequelizer.GetRollbackStatements(statements), dialect, rollbackHeader)
+
+			err := os.WriteFile(rollbackOutputFilePath, []byte(rollbackScript), 0644)
+			if err != nil {
+				return fmt.Errorf("Error writing the rollback to %s: %w", rollbackOutputFilePath, err)
+			}
+
+			utils.PrintInColor(colors.Green, fmt.Sprintf("Wrote %d rollback statements to %s.", len(statements), rollbackOutputFilePath), true)
+		}
+
+		if outputFilePath == "" {
+			fmt.Print(script)
+			return nil
+		}
+
+		err := os.WriteFile(outputFilePath, []byte(script), 0644)
+		if err != nil {
+			return fmt.Errorf("Error writing the migration to %s: %w", outputFilePath, err)
+		}
+
+		utils.PrintInColor(colors.Green, fmt.Sprintf("Wrote %d statements to %s.", len(statements), outputFilePath), false)
+
+		return nil
+	},
+}
+
+// confirmRenameCandidates turns the rename candidates into renames if `--accept-renames` is passed. Otherwise, it warns
+// that they are going to be dropped and created again.
+func confirmRenameCandidates(cmd *cobra.Command, schemaDiff *difftool.SchemaDiff) {
+	acceptRenames, _ := cmd.Flags().GetBool("accept-renames")
+
+	if acceptRenames {
+		schemaDiff.ConfirmRenames(schemaDiff.RenameCandidates)
+		return
+	}
+
+	for _, candidate := range schemaDiff.RenameCandidates {
+		utils.PrintInColor(colors.Yellow, fmt.Sprintf("Possible rename %s is generated as a drop and a create. Pass --accept-renames to rename it instead.", formatRename(candidate)), true)
+	}
+}
+
+// getMigrationScriptHeader returns the lines that are written as comments at the top of a generated migration script.
+func getMigrationScriptHeader(title string, firstConnectionName string, secondConnectionName string, dialect string) []string {
+	return []string{
+		title,
+		"Source: " + firstConnectionName,
+		"Target: " + secondConnectionName,
+		"Dialect: " + dialect,
+		"Generated at: " + time.Now().UTC().Format(time.RFC3339),
+	}
+}
@@ -9,7 +9,12 @@ var ListConnectionsCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all the connections.",
 	Long:  `List all database connections that are stored in the config file.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		config.PrintStoredConnections()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		errOpt := config.PrintStoredConnections()
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+
+		return nil
 	},
 }
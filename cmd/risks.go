@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/safety"
+	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/vars/colors"
+	"github.com/spf13/cobra"
+)
+
+// loadRowCounts reads the estimated number of rows of the tables of the second side. It is nil when the second side is
+// a snapshot file, or when the counts can't be read, in which case every table is assumed to hold data.
+func loadRowCounts(ctx context.Context, secondSide comparisonSide) map[string]int64 {
+	if secondSide.db.IsNone() {
+		return nil
+	}
+
+	rowCounts, errOpt := safety.LoadRowCounts(ctx, secondSide.db.Unwrap())
+	if errOpt.IsSome() {
+		utils.PrintInColor(colors.Yellow, fmt.Sprintf("Couldn't read the row counts of the second database: %s", errOpt.Unwrap()), true)
+		return nil
+	}
+
+	return rowCounts
+}
+
+// printRisks prints a summary of the risks of a migration to stderr so that it doesn't end up in a generated script.
+func printRisks(risks []safety.Risk) {
+	if len(risks) == 0 {
+		return
+	}
+
+	destructiveCount := safety.CountDestructive(risks)
+	utils.PrintInColor(colors.Yellow, fmt.Sprintf("The migration has %d destructive changes and %d warnings:", destructiveCount, len(risks)-destructiveCount), true)
+
+	for _, risk := range risks {
+		utils.PrintInColor(utils.Ternary(risk.Level == safety.Destructive, colors.Red, colors.Yellow), fmt.Sprintf("  [%s] %s", risk.Level, risk.Message), true)
+	}
+}
+
+// printDialectMismatches prints what didn't translate exactly from the dialect of the first side to stderr.
+func printDialectMismatches(mismatches []difftool.DialectMismatch) {
+	if len(mismatches) == 0 {
+		return
+	}
+
+	utils.PrintInColor(colors.Yellow, fmt.Sprintf("The schemas are of different dialects, and %d things don't translate exactly:", len(mismatches)), true)
+
+	for _, mismatch := range mismatches {
+		utils.PrintInColor(colors.Yellow, fmt.Sprintf("  %s: %s", mismatch.EntityName, mismatch.Message), true)
+	}
+}
+
+// checkDestructiveRisks returns errDestructive if `--fail-on-destructive` is passed and the migration would lose data.
+func checkDestructiveRisks(cmd *cobra.Command, risks []safety.Risk) error {
+	failOnDestructive, _ := cmd.Flags().GetBool("fail-on-destructive")
+	if failOnDestructive && safety.CountDestructive(risks) != 0 {
+		return errDestructive
+	}
+
+	return nil
+}
@@ -14,7 +14,7 @@ var RmConnectionCmd = &cobra.Command{
 	Use:   "rm",
 	Short: "Removes a connection from the config file.",
 	Long:  "Removes a stored database connection from the config file.",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// FEAT: Add a way to remove all connections.
 		// FEAT: Make removing a connection a selection prompt.
 
@@ -37,15 +37,17 @@ var RmConnectionCmd = &cobra.Command{
 
 			connectionName, err = namePrmpt.Run()
 			if err != nil {
-				utils.Abort(err.Error())
+				return err
 			}
 		}
 
 		errOpt := config.RmConnection(connectionName)
 		if errOpt.IsSome() {
-			utils.Abort(errOpt.Unwrap().Error())
+			return errOpt.Unwrap()
 		}
 
 		utils.PrintInColor(colors.Green, "Connection "+connectionName+" removed successfully.", false)
+
+		return nil
 	},
 }
@@ -1,11 +1,40 @@
 package cmd
 
 import (
-	"fmt"
+	"errors"
+	"os"
+
 	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/vars/colors"
 	"github.com/spf13/cobra"
 )
 
+var (
+	// errDrift is returned when differences are found between the two databases. The differences are printed already,
+	// so it isn't printed itself.
+	errDrift = errors.New("differences were found")
+	// errDestructive is returned with `--fail-on-destructive` when the migration would lose data.
+	errDestructive = errors.New("Aborted because the migration has destructive changes (--fail-on-destructive).")
+)
+
+// exitCodes are the exit codes of the errors returned by the commands. They are different from the exit code of a
+// failure (1) so that pipelines can tell them apart.
+var exitCodes = map[error]int{
+	errDrift:       2,
+	errDestructive: 3,
+}
+
+// getExitCode returns the exit code for an error returned by a command.
+func getExitCode(err error) int {
+	for exitErr, exitCode := range exitCodes {
+		if errors.Is(err, exitErr) {
+			return exitCode
+		}
+	}
+
+	return 1
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "patchi",
 	Short: "This is a tool for migrating database environments.",
@@ -13,24 +42,87 @@ var rootCmd = &cobra.Command{
 Patchi connects to 2 of your databases and shows you the differences between them. Useful for 
 migrating database environments.
 	`,
-	Run: func(cmd *cobra.Command, args []string) {
-		err := cmd.Help()
-		if err != nil {
-			utils.Abort(fmt.Sprintf("Error printing help: %s", err.Error()))
-		}
+	// Errors are printed by Execute, and the usage is only printed for invalid arguments and flags, which are reported
+	// before the commands run.
+	SilenceErrors: true,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cmd.SilenceUsage = true
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
 	},
 }
 
 func Execute() {
+	rootCmd.PersistentFlags().String("ignore-file", ".patchiignore", "File with the patterns of the entities to leave out of comparisons.")
+
+	AddConnectionCmd.Flags().String("url", "", "Connection URL or Go Mysql DSN to take the connection from.")
+	AddConnectionCmd.Flags().String("name", "", "Name of the connection.")
+	AddConnectionCmd.Flags().String("dialect", "", "Dialect of the database. One of: mysql, mariadb, postgres, cockroachdb, sqlite.")
+	AddConnectionCmd.Flags().String("host", "", "Host of the database.")
+	AddConnectionCmd.Flags().Int("port", 0, "Port of the database. Defaults to the one of the dialect.")
+	AddConnectionCmd.Flags().String("user", "", "User to connect as.")
+	AddConnectionCmd.Flags().String("password", "", "Password of the user. Can reference an environment variable, e.g. '${DB_PASSWORD}'.")
+	AddConnectionCmd.Flags().String("password-cmd", "", "Command whose output is the password, run when connecting.")
+	AddConnectionCmd.Flags().String("database", "", "Name of the database.")
+	AddConnectionCmd.Flags().String("file", "", "Path to the database file of a SQLite connection.")
+	AddConnectionCmd.Flags().String("tls-mode", "", "TLS mode. One of: disable, require, verify-ca, verify-full.")
+	AddConnectionCmd.Flags().String("tls-ca-cert", "", "Path to the CA certificate the certificate of the server is checked against.")
+	AddConnectionCmd.Flags().String("tls-client-cert", "", "Path to the TLS client certificate.")
+	AddConnectionCmd.Flags().String("tls-client-key", "", "Path to the key of the TLS client certificate.")
+	AddConnectionCmd.Flags().String("tls-server-name", "", "Name the certificate of the server is checked against, when it isn't the host.")
+	AddConnectionCmd.Flags().String("ssh-host", "", "Host of the SSH server (e.g. a bastion) to reach the database through.")
+	AddConnectionCmd.Flags().Int("ssh-port", 0, "Port of the SSH server. Defaults to 22.")
+	AddConnectionCmd.Flags().String("ssh-user", "", "User to connect to the SSH server as.")
+	AddConnectionCmd.Flags().String("ssh-key", "", "Path to the private SSH key. Defaults to the keys of the SSH agent.")
+	AddConnectionCmd.Flags().String("ssh-known-hosts", "", "Path to the known_hosts file the SSH server is checked against. Defaults to ~/.ssh/known_hosts.")
+	AddConnectionCmd.Flags().StringToString("option", map[string]string{}, "Extra driver option as key=value. Can be repeated.")
+
 	RmConnectionCmd.Flags().String("name", "", "Name of the connection to remove.")
 
+	DiffCmd.Flags().String("first", "", "Name of the first connection or snapshot file (the one with the desired state).")
+	DiffCmd.Flags().String("second", "", "Name of the second connection or snapshot file (the one to be migrated).")
+	DiffCmd.Flags().StringP("format", "f", "text", "Output format. One of: text, json, yaml.")
+	DiffCmd.Flags().Bool("fail-on-destructive", false, "Exit with code 3 if the migration would lose data.")
+
+	GenerateCmd.Flags().String("first", "", "Name of the first connection or snapshot file (the one with the desired state).")
+	GenerateCmd.Flags().String("second", "", "Name of the second connection or snapshot file (the one to be migrated).")
+	GenerateCmd.Flags().StringP("output", "o", "", "File to write the migration to. Defaults to stdout.")
+	GenerateCmd.Flags().StringP("rollback-output", "r", "", "File to write the rollback (down) migration to.")
+	GenerateCmd.Flags().Bool("accept-renames", false, "Rename the tables and columns that look renamed instead of dropping and creating them.")
+	GenerateCmd.Flags().Bool("fail-on-destructive", false, "Exit with code 3 without writing anything if the migration would lose data.")
+
+	ApplyCmd.Flags().String("first", "", "Name of the first connection or snapshot file (the one with the desired state).")
+	ApplyCmd.Flags().String("second", "", "Name of the second connection (the one to be migrated).")
+	ApplyCmd.Flags().Bool("dry-run", false, "Print the statements that would be applied without running them.")
+	ApplyCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt.")
+	ApplyCmd.Flags().Bool("accept-renames", false, "Rename the tables and columns that look renamed instead of dropping and creating them.")
+	ApplyCmd.Flags().Bool("fail-on-destructive", false, "Exit with code 3 without applying anything if the migration would lose data.")
+
+	DataDiffCmd.Flags().String("first", "", "Name of the first connection (the one with the desired rows).")
+	DataDiffCmd.Flags().String("second", "", "Name of the second connection (the one to be migrated).")
+	DataDiffCmd.Flags().StringSlice("tables", []string{}, "Comma separated tables to compare the rows of, in the order the statements are written.")
+	DataDiffCmd.Flags().Int("chunk-size", 1000, "Number of rows read from each database at a time.")
+	DataDiffCmd.Flags().StringP("output", "o", "", "File to write the statements to. Defaults to stdout.")
+
+	SnapshotCmd.Flags().StringP("output", "o", "", "File to write the snapshot to. Defaults to stdout.")
+
 	rootCmd.AddCommand(ListConnectionsCmd)
 	rootCmd.AddCommand(AddConnectionCmd)
 	rootCmd.AddCommand(RmConnectionCmd)
+	rootCmd.AddCommand(EncryptCmd)
 	rootCmd.AddCommand(StartCmd)
+	rootCmd.AddCommand(DiffCmd)
+	rootCmd.AddCommand(GenerateCmd)
+	rootCmd.AddCommand(ApplyCmd)
+	rootCmd.AddCommand(SnapshotCmd)
+	rootCmd.AddCommand(DataDiffCmd)
 
 	err := rootCmd.Execute()
 	if err != nil {
-		utils.Abort(fmt.Sprintf("Error executing root command: %s", err.Error()))
+		if !errors.Is(err, errDrift) {
+			utils.PrintInColor(colors.Red, err.Error(), true)
+		}
+		os.Exit(getExitCode(err))
 	}
 }
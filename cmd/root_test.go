@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestGetExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{"drift", errDrift, 2},
+		{"destructive", errDestructive, 3},
+		{"wrapped destructive", fmt.Errorf("generate: %w", errDestructive), 3},
+		{"failure", errors.New("Error connecting to app"), 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if exitCode := getExitCode(test.err); exitCode != test.expected {
+				t.Errorf("got %d, expected %d", exitCode, test.expected)
+			}
+		})
+	}
+}
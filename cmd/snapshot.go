@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Okira-E/patchi/pkg/config"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/vars/colors"
+	"github.com/spf13/cobra"
+)
+
+var SnapshotCmd = &cobra.Command{
+	Use:   "snapshot [connection]",
+	Short: "Save the schema of a database to a file.",
+	Long: `
+Reads the schema of a stored connection and writes it to a JSON snapshot file, or to stdout if --output
+is not given. Snapshot files can be passed instead of connection names to compare, diff and generate,
+so a database can be compared against a known state without connecting to a second one.
+	`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		connectionName := args[0]
+		outputFilePath, _ := cmd.Flags().GetString("output")
+
+		userConfig, errOpt := config.GetUserConfig()
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+
+		dbConnectionInfo, ok := userConfig.DbConnections[connectionName]
+		if !ok {
+			return fmt.Errorf("No connection named \"%s\" was found", connectionName)
+		}
+
+		db, errOpt := connectToDb(&userConfig, dbConnectionInfo)
+		if errOpt.IsSome() {
+			return errOpt.Unwrap()
+		}
+
+		dbSchema, errOpt := difftool.LoadSchema(cmd.Context(), db)
+		if closeErr := db.SqlConnection.Close(); closeErr != nil {
+			return fmt.Errorf("Error closing connection to %s: %w", dbConnectionInfo.DatabaseName, closeErr)
+		}
+		if errOpt.IsSome() {
+			return fmt.Errorf("Error reading the schema of \"%s\": %w", connectionName, errOpt.Unwrap())
+		}
+
+		snapshot, errOpt := schema.MarshalSnapshot(dbSchema)
+		if errOpt.IsSome() {
+			return fmt.Errorf("Error formatting the snapshot: %w", errOpt.Unwrap())
+		}
+
+		if outputFilePath == "" {
+			fmt.Print(string(snapshot))
+			return nil
+		}
+
+		err := os.WriteFile(outputFilePath, snapshot, 0644)
+		if err != nil {
+			return fmt.Errorf("Error writing the snapshot to %s: %w", outputFilePath, err)
+		}
+
+		utils.PrintInColor(colors.Green, fmt.Sprintf("Wrote the snapshot of \"%s\" to %s.", connectionName, outputFilePath), true)
+
+		return nil
+	},
+}
@@ -0,0 +1,72 @@
+// Package testutil holds the fixtures the tests of the other packages share.
+package testutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/types"
+)
+
+// NewSqliteDb creates a Sqlite database that enforces foreign keys on every connection, and runs the statements
+// against it. The name of the file has characters that need escaping in a connection string.
+func NewSqliteDb(t *testing.T, statements ...string) types.DbConnection {
+	t.Helper()
+
+	filePath := filepath.Join(t.TempDir(), "test db?#%.sqlite")
+	if err := os.WriteFile(filePath, nil, 0o600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	info := &types.DbConnectionInfo{Dialect: "sqlite", FilePath: filePath, Options: map[string]string{"_foreign_keys": "1"}}
+	db, errOpt := info.Connect()
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// A single connection makes sure the pragmas are read from the connection the statements ran on.
+	db.SetMaxOpenConns(1)
+
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("%s: %s", statement, err)
+		}
+	}
+
+	return types.DbConnection{Info: info, SqlConnection: db}
+}
+
+// NewSchema builds a schema out of the given tables, filling in their missing indexes and constraints.
+func NewSchema(dialect string, tables ...*schema.Table) *schema.Schema {
+	ret := schema.NewSchema(dialect, "test", "test")
+
+	for _, table := range tables {
+		if table.Indexes == nil {
+			table.Indexes = map[string]*schema.Index{}
+		}
+		if table.Constraints == nil {
+			table.Constraints = map[string]*schema.Constraint{}
+		}
+
+		ret.Tables[table.Name] = table
+	}
+
+	return ret
+}
+
+// NewTable builds a table out of the given columns, numbering them in order.
+func NewTable(tableName string, columns ...*schema.Column) *schema.Table {
+	for i, column := range columns {
+		column.OrdinalPosition = i + 1
+	}
+
+	return &schema.Table{Name: tableName, Columns: columns}
+}
+
+// StringPointer returns a pointer to a copy of str, for the optional properties of a schema.
+func StringPointer(str string) *string {
+	return &str
+}
@@ -10,6 +10,9 @@ import (
 	"github.com/Okira-E/patchi/pkg/types"
 )
 
+// configFileMode is the permissions of the config file, which is only readable by the user.
+const configFileMode = 0600
+
 // getCOnfigFilePathBasedOnOS returns the config file path based on the OS.
 func getConfigFilePathBasedOnOS() (string, safego.Option[error]) {
 	var osUserName string
@@ -52,16 +55,13 @@ func createConfigFile() safego.Option[error] {
 
 	// Create the directory.
 	dirPath := filePath[:len(filePath)-len("/config.json")]
-	err := os.MkdirAll(dirPath, os.ModePerm)
+	err := os.MkdirAll(dirPath, 0700)
 	if err != nil {
 		return safego.Some[error](err)
 	}
 
-	// Create the file inside the directory.
-	file, err := os.Create(filePath)
-	defer file.Close()
-
-	_, err = file.Write([]byte(`{}`))
+	// Create the file inside the directory. It holds credentials, so only the user can read it.
+	err = os.WriteFile(filePath, []byte(`{}`), configFileMode)
 	if err != nil {
 		return safego.Some(err)
 	}
@@ -81,6 +81,25 @@ func SetupUserConfig() safego.Option[error] {
 		if errOption.IsSome() {
 			return errOption
 		}
+
+		return safego.None[error]()
+	}
+
+	// Config files created by older versions are readable by others.
+	filePath, errOpt := getConfigFilePathBasedOnOS()
+	if errOpt.IsSome() {
+		return errOpt
+	}
+
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return safego.Some(err)
+	}
+
+	if fileInfo.Mode().Perm()&0077 != 0 {
+		if err := os.Chmod(filePath, configFileMode); err != nil {
+			return safego.Some(err)
+		}
 	}
 
 	return safego.None[error]()
@@ -103,16 +122,33 @@ func GetUserConfig() (types.UserConfig, safego.Option[error]) {
 	return userConfig, safego.None[error]()
 }
 
+// WriteUserConfig writes the user config to the config file. Passwords that were decrypted are left out, so they are
+// only stored encrypted.
 func WriteUserConfig(userConfig types.UserConfig) safego.Option[error] {
 	filePath, errOption := getConfigFilePathBasedOnOS()
 	if errOption.IsSome() {
 		return errOption
 	}
 
+	dbConnections := make(map[string]*types.DbConnectionInfo, len(userConfig.DbConnections))
+	for connectionName, dbConnectionInfo := range userConfig.DbConnections {
+		storedDbConnectionInfo := *dbConnectionInfo
+		if storedDbConnectionInfo.EncryptedPassword != "" {
+			storedDbConnectionInfo.Password = ""
+		}
+
+		dbConnections[connectionName] = &storedDbConnectionInfo
+	}
+	userConfig.DbConnections = dbConnections
+
 	errOpt := utils.WriteToJSONFile(filePath, userConfig)
 	if errOpt.IsSome() {
 		return errOpt
 	}
 
+	if err := os.Chmod(filePath, configFileMode); err != nil {
+		return safego.Some(err)
+	}
+
 	return safego.None[error]()
 }
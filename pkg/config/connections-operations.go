@@ -5,11 +5,15 @@ import (
 	"github.com/Okira-E/patchi/pkg/types"
 	"github.com/Okira-E/patchi/pkg/utils"
 	"github.com/Okira-E/patchi/pkg/vars"
+	"github.com/Okira-E/patchi/pkg/vars/colors"
 	"github.com/Okira-E/patchi/safego"
 	"github.com/jedib0t/go-pretty/table"
 	"github.com/manifoldco/promptui"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 // AddDbConnection adds a new connection to the config file.
@@ -52,6 +56,40 @@ func AddDbConnection() safego.Option[error] {
 		return safego.Some[error](fmt.Errorf("connection with name %s already exists", connectionName))
 	}
 
+	// Sqlite databases are files, so the path is all there is to ask for.
+	if dialect == "sqlite" {
+		filePathPrmpt := promptui.Prompt{
+			Label: "Database file path",
+			Validate: func(s string) error {
+				if s == "" {
+					return fmt.Errorf("file path cannot be empty")
+				}
+
+				if _, err := os.Stat(s); err != nil {
+					return fmt.Errorf("file %s cannot be read", s)
+				}
+
+				return nil
+			},
+		}
+		filePath, err := filePathPrmpt.Run()
+		if err != nil {
+			return safego.Some[error](err)
+		}
+
+		absoluteFilePath, err := filepath.Abs(filePath)
+		if err != nil {
+			return safego.Some[error](err)
+		}
+
+		return saveDbConnection(userConfig, &types.DbConnectionInfo{
+			Dialect:      dialect,
+			Name:         connectionName,
+			DatabaseName: "main",
+			FilePath:     absoluteFilePath,
+		})
+	}
+
 	// Host
 	HostPrmpt := promptui.Prompt{
 		Label: "Host",
@@ -146,13 +184,41 @@ func AddDbConnection() safego.Option[error] {
 		return safego.Some[error](err)
 	}
 
-	// -- Add the connection to the config file.
+	// TLS. Client certificates and a server name can be given with the flags of `patchi add`.
+	tlsModePrmpt := promptui.Select{
+		Label: "TLS mode",
+		Items: types.TlsModes,
+	}
+	_, tlsMode, err := tlsModePrmpt.Run()
+	if err != nil {
+		return safego.Some[error](err)
+	}
 
-	if len(userConfig.DbConnections) == 0 {
-		userConfig.DbConnections = make(map[string]*types.DbConnectionInfo)
+	var tlsSettings *types.TlsSettings
+	if tlsMode != "disable" {
+		caCertPrmpt := promptui.Prompt{
+			Label: "CA certificate path (empty for the CAs of the system)",
+			Validate: func(s string) error {
+				if s == "" {
+					return nil
+				}
+
+				if _, err := os.Stat(s); err != nil {
+					return fmt.Errorf("file %s cannot be read", s)
+				}
+
+				return nil
+			},
+		}
+		caCert, err := caCertPrmpt.Run()
+		if err != nil {
+			return safego.Some[error](err)
+		}
+
+		tlsSettings = &types.TlsSettings{Mode: tlsMode, CaCert: caCert}
 	}
 
-	userConfig.DbConnections[connectionName] = &types.DbConnectionInfo{
+	return saveDbConnection(userConfig, &types.DbConnectionInfo{
 		Dialect:      dialect,
 		Name:         connectionName,
 		Host:         host,
@@ -160,16 +226,111 @@ func AddDbConnection() safego.Option[error] {
 		User:         user,
 		Password:     password,
 		DatabaseName: database,
-	}
+		Tls:          tlsSettings,
+	})
+}
 
-	configFilePathBasedOnOs, errOpt := getConfigFilePathBasedOnOS()
+// AddDbConnectionInfo adds a connection whose details are already known, e.g. from the flags of `patchi add`, without
+// prompting for them. A missing port is the default one of the dialect. It returns an error if a required detail is
+// missing or the connection already exists.
+func AddDbConnectionInfo(dbConnectionInfo *types.DbConnectionInfo) safego.Option[error] {
+	userConfig, errOpt := GetUserConfig()
 	if errOpt.IsSome() {
 		return errOpt
 	}
 
-	utils.WriteToJSONFile(configFilePathBasedOnOs, userConfig)
+	if dbConnectionInfo.Name == "" {
+		return safego.Some[error](fmt.Errorf("connection name cannot be empty"))
+	}
 
-	return safego.None[error]()
+	if _, ok := userConfig.DbConnections[dbConnectionInfo.Name]; ok {
+		return safego.Some[error](fmt.Errorf("connection with name %s already exists", dbConnectionInfo.Name))
+	}
+
+	if !slices.Contains(vars.SupportedDatabases, dbConnectionInfo.Dialect) {
+		return safego.Some[error](fmt.Errorf("dialect must be one of: %s", strings.Join(vars.SupportedDatabases, ", ")))
+	}
+
+	if dbConnectionInfo.Dialect == "sqlite" {
+		if dbConnectionInfo.SshTunnel != nil {
+			return safego.Some[error](fmt.Errorf("SQLite connections can't go through an SSH tunnel"))
+		}
+
+		if dbConnectionInfo.FilePath == "" {
+			return safego.Some[error](fmt.Errorf("file path cannot be empty"))
+		}
+
+		// Paths that reference environment variables are only resolved when connecting.
+		if !types.HasEnvReference(dbConnectionInfo.FilePath) {
+			if _, err := os.Stat(dbConnectionInfo.FilePath); err != nil {
+				return safego.Some[error](fmt.Errorf("file %s cannot be read", dbConnectionInfo.FilePath))
+			}
+
+			absoluteFilePath, err := filepath.Abs(dbConnectionInfo.FilePath)
+			if err != nil {
+				return safego.Some[error](err)
+			}
+			dbConnectionInfo.FilePath = absoluteFilePath
+		}
+
+		dbConnectionInfo.DatabaseName = "main"
+
+		return saveDbConnection(userConfig, dbConnectionInfo)
+	}
+
+	if dbConnectionInfo.Port == 0 {
+		dbConnectionInfo.Port = types.DefaultPorts[dbConnectionInfo.Dialect]
+	}
+
+	if dbConnectionInfo.Host == "" {
+		return safego.Some[error](fmt.Errorf("host cannot be empty"))
+	} else if dbConnectionInfo.Port < 0 || dbConnectionInfo.Port > 65535 {
+		return safego.Some[error](fmt.Errorf("port must be between 0 and 65535"))
+	} else if dbConnectionInfo.User == "" {
+		return safego.Some[error](fmt.Errorf("user cannot be empty"))
+	} else if dbConnectionInfo.DatabaseName == "" {
+		return safego.Some[error](fmt.Errorf("database name cannot be empty"))
+	}
+
+	if dbConnectionInfo.Tls != nil {
+		errOpt = dbConnectionInfo.Tls.Validate()
+		if errOpt.IsSome() {
+			return errOpt
+		}
+	}
+
+	if dbConnectionInfo.SshTunnel != nil {
+		errOpt = dbConnectionInfo.SshTunnel.Validate()
+		if errOpt.IsSome() {
+			return errOpt
+		}
+	}
+
+	return saveDbConnection(userConfig, dbConnectionInfo)
+}
+
+// saveDbConnection adds a connection to the config file. Its password is encrypted along with any other password that
+// is still stored in plain text, and a master passphrase is chosen first if the config doesn't have one yet.
+func saveDbConnection(userConfig types.UserConfig, dbConnectionInfo *types.DbConnectionInfo) safego.Option[error] {
+	if len(userConfig.DbConnections) == 0 {
+		userConfig.DbConnections = make(map[string]*types.DbConnectionInfo)
+	}
+
+	userConfig.DbConnections[dbConnectionInfo.Name] = dbConnectionInfo
+
+	if isPlainTextPassword(dbConnectionInfo) {
+		key, errOpt := getEncryptionKey(&userConfig)
+		if errOpt.IsSome() {
+			return errOpt
+		}
+
+		_, errOpt = encryptPlainTextPasswords(&userConfig, key)
+		if errOpt.IsSome() {
+			return errOpt
+		}
+	}
+
+	return WriteUserConfig(userConfig)
 }
 
 // RmConnection removes a connection from the config file.
@@ -185,37 +346,67 @@ func RmConnection(connectionName string) safego.Option[error] {
 	}
 
 	// -- Remove the connection from the config file.
-	configFilePathBasedOnOs, errOpt := getConfigFilePathBasedOnOS()
-	if errOpt.IsSome() {
-		return errOpt
-	}
-
 	delete(userConfig.DbConnections, connectionName)
-	utils.WriteToJSONFile(configFilePathBasedOnOs, userConfig)
-
-	return safego.None[error]()
+	return WriteUserConfig(userConfig)
 }
 
-// PrintStoredConnections prints all the stored connections in the config file.
-func PrintStoredConnections() {
+// PrintStoredConnections prints all the stored connections in the config file. Encrypted passwords are unlocked with
+// the master passphrase first.
+func PrintStoredConnections() safego.Option[error] {
 	userConfig, errOpt := GetUserConfig()
 	if errOpt.IsSome() {
-		utils.Abort(fmt.Sprintf("Error getting user config: %s", errOpt.Unwrap()))
+		return safego.Some(fmt.Errorf("error getting user config: %w", errOpt.Unwrap()))
+	}
+
+	plainTextPasswordsCount := countPlainTextPasswords(userConfig)
+
+	errOpt = UnlockDbConnections(&userConfig)
+	if errOpt.IsSome() {
+		return errOpt
 	}
 
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
-	t.AppendHeader(table.Row{"Connection Name", "Dialect", "Host", "Port", "User", "Password", "Database Name"})
+	t.AppendHeader(table.Row{"Connection Name", "Dialect", "Host", "Port", "User", "Password", "Database Name", "TLS"})
 
 	if len(userConfig.DbConnections) != 0 {
 		for connectionName, connection := range userConfig.DbConnections {
+			if connection.Dialect == "sqlite" {
+				t.AppendRow([]any{connectionName, connection.Dialect, connection.FilePath, "", "", "", connection.DatabaseName, ""})
+				continue
+			}
+
+			// References are shown as they are, rather than the values they resolve to.
 			maskedPassword := utils.MaskString(connection.Password)
+			if connection.PasswordCmd != "" {
+				maskedPassword = "password_cmd: " + connection.PasswordCmd
+			} else if types.HasEnvReference(connection.Password) {
+				maskedPassword = connection.Password
+			} else if isPlainTextPassword(connection) {
+				maskedPassword += " (plain text)"
+			}
+
+			tlsMode := "disable"
+			if connection.Tls != nil && connection.Tls.Mode != "" {
+				tlsMode = connection.Tls.Mode
+			}
+
+			host := connection.Host
+			if connection.SshTunnel != nil {
+				host += " via " + connection.SshTunnel.String()
+			}
 
-			t.AppendRow([]any{connectionName, connection.Dialect, connection.Host, connection.Port, connection.User, maskedPassword, connection.DatabaseName})
+			t.AppendRow([]any{connectionName, connection.Dialect, host, connection.Port, connection.User, maskedPassword, connection.DatabaseName, tlsMode})
 		}
 	} else {
 		t.AppendRow([]any{"No connections stored"})
 	}
 
 	t.Render()
+
+	if plainTextPasswordsCount != 0 {
+		utils.PrintInColor(colors.Yellow, fmt.Sprintf("%d password(s) are stored in plain text. Run `patchi encrypt` to encrypt them.", plainTextPasswordsCount), true)
+	}
+
+	return safego.None[error]()
 }
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Okira-E/patchi/pkg/types"
+	"github.com/Okira-E/patchi/safego"
+	"github.com/manifoldco/promptui"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnvVar is the environment variable the master passphrase is read from before prompting for it, which is
+// meant for CI.
+const PassphraseEnvVar = "PATCHI_PASSPHRASE"
+
+const (
+	// scryptLogN is the work factor of new configs. N = 2^15 takes about 32MB and a fraction of a second.
+	scryptLogN = 15
+	scryptR    = 8
+	scryptP    = 1
+	// encryptionCheckValue is encrypted into the config to tell whether a passphrase is the right one.
+	encryptionCheckValue = "patchi"
+)
+
+// unlockedKey is the key derived from the master passphrase, kept so it is asked for once per run.
+var unlockedKey []byte
+
+// UnlockDbConnections decrypts the passwords of all the connections. The master passphrase is asked for only if
+// there are encrypted passwords.
+func UnlockDbConnections(userConfig *types.UserConfig) safego.Option[error] {
+	for _, dbConnectionInfo := range userConfig.DbConnections {
+		errOpt := UnlockDbConnection(userConfig, dbConnectionInfo)
+		if errOpt.IsSome() {
+			return errOpt
+		}
+	}
+
+	return safego.None[error]()
+}
+
+// UnlockDbConnection decrypts the password of a connection into its Password field.
+func UnlockDbConnection(userConfig *types.UserConfig, dbConnectionInfo *types.DbConnectionInfo) safego.Option[error] {
+	if dbConnectionInfo.EncryptedPassword == "" || dbConnectionInfo.Password != "" {
+		return safego.None[error]()
+	}
+
+	key, errOpt := unlockEncryptionKey(userConfig)
+	if errOpt.IsSome() {
+		return errOpt
+	}
+
+	password, errOpt := decryptString(key, dbConnectionInfo.EncryptedPassword)
+	if errOpt.IsSome() {
+		return safego.Some(fmt.Errorf("error decrypting the password of %s: %w", dbConnectionInfo.Name, errOpt.Unwrap()))
+	}
+
+	dbConnectionInfo.Password = password
+
+	return safego.None[error]()
+}
+
+// EncryptStoredPasswords encrypts the passwords that are stored in plain text, which is the migration path of
+// configs that predate encryption. A master passphrase is chosen first if the config doesn't have one yet. It returns
+// the number of passwords that were encrypted.
+func EncryptStoredPasswords() (int, safego.Option[error]) {
+	userConfig, errOpt := GetUserConfig()
+	if errOpt.IsSome() {
+		return 0, errOpt
+	}
+
+	key, errOpt := getEncryptionKey(&userConfig)
+	if errOpt.IsSome() {
+		return 0, errOpt
+	}
+
+	count, errOpt := encryptPlainTextPasswords(&userConfig, key)
+	if errOpt.IsSome() {
+		return 0, errOpt
+	}
+
+	return count, WriteUserConfig(userConfig)
+}
+
+// countPlainTextPasswords returns the number of connections whose password is stored in plain text.
+func countPlainTextPasswords(userConfig types.UserConfig) int {
+	ret := 0
+	for _, dbConnectionInfo := range userConfig.DbConnections {
+		if isPlainTextPassword(dbConnectionInfo) {
+			ret++
+		}
+	}
+
+	return ret
+}
+
+// isPlainTextPassword tells whether the password of a connection is stored in plain text. References to environment
+// variables aren't secrets, so they are kept as they are.
+func isPlainTextPassword(dbConnectionInfo *types.DbConnectionInfo) bool {
+	return dbConnectionInfo.Password != "" && dbConnectionInfo.EncryptedPassword == "" && !types.HasEnvReference(dbConnectionInfo.Password)
+}
+
+// encryptPlainTextPasswords encrypts the passwords of the connections that are in plain text, and returns their number.
+func encryptPlainTextPasswords(userConfig *types.UserConfig, key []byte) (int, safego.Option[error]) {
+	ret := 0
+	for _, dbConnectionInfo := range userConfig.DbConnections {
+		if !isPlainTextPassword(dbConnectionInfo) {
+			continue
+		}
+
+		encryptedPassword, errOpt := encryptString(key, dbConnectionInfo.Password)
+		if errOpt.IsSome() {
+			return 0, errOpt
+		}
+
+		dbConnectionInfo.EncryptedPassword = encryptedPassword
+		ret++
+	}
+
+	return ret, safego.None[error]()
+}
+
+// getEncryptionKey returns the key of the config, and asks for a new master passphrase if there isn't one yet.
+func getEncryptionKey(userConfig *types.UserConfig) ([]byte, safego.Option[error]) {
+	if userConfig.Encryption != nil {
+		return unlockEncryptionKey(userConfig)
+	}
+
+	passphrase, errOpt := getPassphrase(true)
+	if errOpt.IsSome() {
+		return nil, errOpt
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, safego.Some(err)
+	}
+
+	encryption := &types.ConfigEncryption{Salt: base64.StdEncoding.EncodeToString(salt), LogN: scryptLogN}
+
+	key, errOpt := deriveKey(passphrase, encryption)
+	if errOpt.IsSome() {
+		return nil, errOpt
+	}
+
+	encryption.Check, errOpt = encryptString(key, encryptionCheckValue)
+	if errOpt.IsSome() {
+		return nil, errOpt
+	}
+
+	userConfig.Encryption = encryption
+	unlockedKey = key
+
+	return key, safego.None[error]()
+}
+
+// unlockEncryptionKey derives the key of the config from the master passphrase. It returns an error if the passphrase
+// is wrong.
+func unlockEncryptionKey(userConfig *types.UserConfig) ([]byte, safego.Option[error]) {
+	if unlockedKey != nil {
+		return unlockedKey, safego.None[error]()
+	}
+
+	if userConfig.Encryption == nil {
+		return nil, safego.Some(errors.New("the config has encrypted passwords but no master passphrase"))
+	}
+
+	passphrase, errOpt := getPassphrase(false)
+	if errOpt.IsSome() {
+		return nil, errOpt
+	}
+
+	key, errOpt := deriveKey(passphrase, userConfig.Encryption)
+	if errOpt.IsSome() {
+		return nil, errOpt
+	}
+
+	checkValue, errOpt := decryptString(key, userConfig.Encryption.Check)
+	if errOpt.IsSome() || checkValue != encryptionCheckValue {
+		return nil, safego.Some(errors.New("wrong master passphrase"))
+	}
+
+	unlockedKey = key
+
+	return key, safego.None[error]()
+}
+
+// getPassphrase reads the master passphrase from the environment, or prompts for it. A new passphrase is asked for twice.
+func getPassphrase(isNew bool) (string, safego.Option[error]) {
+	if passphrase := os.Getenv(PassphraseEnvVar); passphrase != "" {
+		return passphrase, safego.None[error]()
+	}
+
+	passphrasePrmpt := promptui.Prompt{
+		Label: "Master passphrase",
+		Mask:  '*',
+		Validate: func(s string) error {
+			if s == "" {
+				return fmt.Errorf("passphrase cannot be empty")
+			}
+
+			return nil
+		},
+	}
+	if isNew {
+		passphrasePrmpt.Label = "New master passphrase (encrypts the stored passwords)"
+	}
+
+	passphrase, err := passphrasePrmpt.Run()
+	if err != nil {
+		return "", safego.Some(err)
+	}
+
+	if isNew {
+		confirmationPrmpt := promptui.Prompt{
+			Label: "Confirm the master passphrase",
+			Mask:  '*',
+			Validate: func(s string) error {
+				if s != passphrase {
+					return fmt.Errorf("passphrases don't match")
+				}
+
+				return nil
+			},
+		}
+
+		if _, err := confirmationPrmpt.Run(); err != nil {
+			return "", safego.Some(err)
+		}
+	}
+
+	return passphrase, safego.None[error]()
+}
+
+// deriveKey derives the 32 bytes key of AES-256 from the passphrase with scrypt.
+func deriveKey(passphrase string, encryption *types.ConfigEncryption) ([]byte, safego.Option[error]) {
+	salt, err := base64.StdEncoding.DecodeString(encryption.Salt)
+	if err != nil {
+		return nil, safego.Some(fmt.Errorf("invalid salt in the config: %w", err))
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<encryption.LogN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, safego.Some(err)
+	}
+
+	return key, safego.None[error]()
+}
+
+// encryptString encrypts a string with AES-GCM. The result is the nonce followed by the sealed string, in base64.
+func encryptString(key []byte, plainText string) (string, safego.Option[error]) {
+	gcm, errOpt := newGcm(key)
+	if errOpt.IsSome() {
+		return "", errOpt
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", safego.Some(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plainText), nil)), safego.None[error]()
+}
+
+// decryptString decrypts a string encrypted by encryptString.
+func decryptString(key []byte, cipherText string) (string, safego.Option[error]) {
+	gcm, errOpt := newGcm(key)
+	if errOpt.IsSome() {
+		return "", errOpt
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil {
+		return "", safego.Some(err)
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return "", safego.Some(errors.New("encrypted value is too short"))
+	}
+
+	plainText, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", safego.Some(err)
+	}
+
+	return string(plainText), safego.None[error]()
+}
+
+func newGcm(key []byte) (cipher.AEAD, safego.Option[error]) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, safego.Some(err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, safego.Some(err)
+	}
+
+	return gcm, safego.None[error]()
+}
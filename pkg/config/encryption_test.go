@@ -0,0 +1,153 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/Okira-E/patchi/pkg/types"
+)
+
+func TestEncryptStringRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+
+	tests := []struct {
+		name      string
+		plainText string
+	}{
+		{"empty", ""},
+		{"ascii", "s3cret!"},
+		{"unicode", "pässwörd ✓"},
+		{"long", string(bytes.Repeat([]byte("a"), 1000))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cipherText, errOpt := encryptString(key, test.plainText)
+			if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+			if test.plainText != "" && cipherText == base64.StdEncoding.EncodeToString([]byte(test.plainText)) {
+				t.Fatalf("the value isn't encrypted")
+			}
+
+			plainText, errOpt := decryptString(key, cipherText)
+			if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+			if plainText != test.plainText {
+				t.Errorf("got %q, expected %q", plainText, test.plainText)
+			}
+		})
+	}
+}
+
+func TestEncryptStringUsesANewNonce(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+
+	first, _ := encryptString(key, "s3cret")
+	second, _ := encryptString(key, "s3cret")
+	if first == second {
+		t.Errorf("the same value was encrypted twice into %s", first)
+	}
+}
+
+func TestDecryptStringFailures(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+
+	cipherText, errOpt := encryptString(key, "s3cret")
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+
+	sealed, _ := base64.StdEncoding.DecodeString(cipherText)
+	sealed[len(sealed)-1] ^= 1
+	tampered := base64.StdEncoding.EncodeToString(sealed)
+
+	tests := []struct {
+		name       string
+		key        []byte
+		cipherText string
+	}{
+		{"wrong key", bytes.Repeat([]byte{8}, 32), cipherText},
+		{"tampered", key, tampered},
+		{"too short", key, base64.StdEncoding.EncodeToString([]byte("short"))},
+		{"not base64", key, "not base64!"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if plainText, errOpt := decryptString(test.key, test.cipherText); errOpt.IsNone() {
+				t.Errorf("expected an error, got %q", plainText)
+			}
+		})
+	}
+}
+
+func TestUnlockDbConnection(t *testing.T) {
+	// The key is kept once it is unlocked, so it is reset to ask for the passphrase again.
+	unlockedKey = nil
+	t.Cleanup(func() {
+		unlockedKey = nil
+	})
+
+	t.Setenv(PassphraseEnvVar, "correct horse")
+
+	userConfig := &types.UserConfig{DbConnections: map[string]*types.DbConnectionInfo{
+		"production": {Name: "production", Password: "s3cret"},
+		"ci":         {Name: "ci", Password: "${DB_PASSWORD}"},
+	}}
+
+	key, errOpt := getEncryptionKey(userConfig)
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+
+	count, errOpt := encryptPlainTextPasswords(userConfig, key)
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+	if count != 1 {
+		t.Errorf("got %d encrypted passwords, expected 1", count)
+	}
+
+	// This is what is stored in the config file.
+	stored := func() *types.DbConnectionInfo {
+		return &types.DbConnectionInfo{Name: "production", EncryptedPassword: userConfig.DbConnections["production"].EncryptedPassword}
+	}
+
+	tests := []struct {
+		name             string
+		passphrase       string
+		expectedPassword string
+		expectedError    string
+	}{
+		{"wrong passphrase", "battery staple", "", "wrong master passphrase"},
+		{"right passphrase", "correct horse", "s3cret", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			unlockedKey = nil
+			t.Setenv(PassphraseEnvVar, test.passphrase)
+
+			dbConnectionInfo := stored()
+			errOpt := UnlockDbConnection(userConfig, dbConnectionInfo)
+
+			if test.expectedError != "" {
+				if errOpt.IsNone() || errOpt.Unwrap().Error() != test.expectedError {
+					t.Fatalf("expected error %q, got %v", test.expectedError, errOpt)
+				}
+				if unlockedKey != nil {
+					t.Errorf("the key was kept after a wrong passphrase")
+				}
+			} else if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+
+			if dbConnectionInfo.Password != test.expectedPassword {
+				t.Errorf("got password %q, expected %q", dbConnectionInfo.Password, test.expectedPassword)
+			}
+		})
+	}
+}
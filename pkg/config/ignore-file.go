@@ -0,0 +1,60 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/types"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// ReadIgnoreFile reads the ignore rules of a project from a `.patchiignore` file. Every line holds the type of entity
+// followed by a pattern, e.g. `columns *.updated_at`. Lines without a type are table patterns, and lines starting
+// with `#` are comments. A missing file has no rules.
+func ReadIgnoreFile(filePath string) (types.IgnoreRules, safego.Option[error]) {
+	var ret types.IgnoreRules
+
+	file, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return ret, safego.None[error]()
+	} else if err != nil {
+		return ret, safego.Some(err)
+	}
+	defer file.Close()
+
+	patternsPerEntityType := map[string]*[]string{
+		"tables":      &ret.Tables,
+		"columns":     &ret.Columns,
+		"indexes":     &ret.Indexes,
+		"constraints": &ret.Constraints,
+		"views":       &ret.Views,
+		"procedures":  &ret.Procedures,
+		"functions":   &ret.Functions,
+		"triggers":    &ret.Triggers,
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entityType, pattern, ok := strings.Cut(line, " ")
+		patterns, isEntityType := patternsPerEntityType[entityType]
+		if !ok || !isEntityType {
+			ret.Tables = append(ret.Tables, line)
+			continue
+		}
+
+		*patterns = append(*patterns, strings.TrimSpace(pattern))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return ret, safego.Some(err)
+	}
+
+	return ret, safego.None[error]()
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestReadIgnoreFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), ".patchiignore")
+	content := "# Lines without a type are table patterns.\ntmp_*\n\ntables flyway_schema_history\ncolumns  *.updated_at\ntriggers orders.trg_*\nunknown thing\n"
+	if err := os.WriteFile(filePath, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, errOpt := ReadIgnoreFile(filePath)
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+
+	if expected := []string{"tmp_*", "flyway_schema_history", "unknown thing"}; !slices.Equal(rules.Tables, expected) {
+		t.Errorf("got tables %v, expected %v", rules.Tables, expected)
+	}
+	if expected := []string{"*.updated_at"}; !slices.Equal(rules.Columns, expected) {
+		t.Errorf("got columns %v, expected %v", rules.Columns, expected)
+	}
+	if expected := []string{"orders.trg_*"}; !slices.Equal(rules.Triggers, expected) {
+		t.Errorf("got triggers %v, expected %v", rules.Triggers, expected)
+	}
+}
+
+func TestReadIgnoreFileMissing(t *testing.T) {
+	rules, errOpt := ReadIgnoreFile(filepath.Join(t.TempDir(), ".patchiignore"))
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+
+	if len(rules.Tables) != 0 {
+		t.Errorf("expected no rules, got %+v", rules)
+	}
+}
@@ -1,123 +1,307 @@
 package difftool
 
 import (
-	"encoding/json"
+	"context"
+	"database/sql"
+	"regexp"
 	"slices"
+	"strings"
 
+	"github.com/Okira-E/patchi/pkg/schema"
 	"github.com/Okira-E/patchi/pkg/types"
-	"github.com/Okira-E/patchi/pkg/utils"
 	"github.com/Okira-E/patchi/safego"
 )
 
-type columnDiff struct {
-	ColumnName string
+// ColumnDiff is a column out of sync between two schemas.
+type ColumnDiff struct {
+	ColumnName string `json:"column_name" yaml:"column_name"`
 	// The table the column belongs to.
-	TableName  string
-	// DiffType represents the type of change that has occurred to the entity.
-	// 0 -> Deleted.
-	// 1 -> Created.
-	DiffType int8
+	TableName string `json:"table_name" yaml:"table_name"`
+	// DiffType represents the type of change that has occurred to the entity (deleted, created or modified.)
+	DiffType DiffType `json:"diff_type" yaml:"diff_type"`
+	// ModifiedProperties holds the names of the properties that differ between the two environments. It is only
+	// populated for modified columns.
+	ModifiedProperties []string `json:"modified_properties,omitempty" yaml:"modified_properties,omitempty"`
 }
 
-// GetColumnsDiff returns the columns out of sync between two databases.
-func GetColumnsDiff(firstDb types.DbConnection, secondDb types.DbConnection, dialect string) ([]columnDiff, safego.Option[error]) {
-	ret := []columnDiff{}
-	errOpt := safego.None[error]()
-
-	// There has to be a more optimized version of this query that returns a better data-structer.
-	sql := `
-		SELECT JSON_OBJECTAGG(
-		               TABLE_NAME,
-		               (SELECT JSON_ARRAYAGG(JSON_OBJECT('COLUMN_NAME', COLUMN_NAME, 'ORDINAL_POSITION', ORDINAL_POSITION))
-		                FROM information_schema.COLUMNS t2
-		                WHERE t2.TABLE_SCHEMA = ?
-		                  AND t2.TABLE_NAME = t1.TABLE_NAME)
-		       )
-		FROM information_schema.TABLES t1
-		WHERE TABLE_SCHEMA = ?
-		  AND TABLE_TYPE = 'BASE TABLE'
-	`
+// GetColumnsDiff returns the columns out of sync between two schemas. Columns matching ignoreRules are left out.
+func GetColumnsDiff(firstSchema *schema.Schema, secondSchema *schema.Schema, ignoreRules *IgnoreRules) []ColumnDiff {
+	ret := []ColumnDiff{}
+
+	// Loop through the tables in the first schema and create the diff for the columns that are not in sync between
+	// the two databases. Obviously, we want to only check tables that exist in both of the environments.
+	for tableName, firstTable := range firstSchema.Tables {
+		secondTable, ok := secondSchema.Tables[tableName]
+		if !ok {
+			continue
+		}
+
+		// Columns that only exist in the first env must have been created. The ones that exist in both envs are
+		// compared property by property, and any difference marks the column as 'modified.'
+		for _, firstColumn := range firstTable.Columns {
+			secondColumnOpt := secondTable.GetColumn(firstColumn.Name)
+			if secondColumnOpt.IsNone() {
+				ret = append(ret, ColumnDiff{TableName: tableName, ColumnName: firstColumn.Name, DiffType: Created})
+				continue
+			}
+
+			modifiedProperties := GetModifiedColumnProperties(firstColumn, secondColumnOpt.Unwrap())
+			if len(modifiedProperties) != 0 {
+				ret = append(ret, ColumnDiff{
+					TableName:          tableName,
+					ColumnName:         firstColumn.Name,
+					DiffType:           Modified,
+					ModifiedProperties: modifiedProperties,
+				})
+			}
+		}
+
+		// Columns that only exist in the second env must have been deleted.
+		for _, secondColumn := range secondTable.Columns {
+			firstColumnOpt := firstTable.GetColumn(secondColumn.Name)
+			if firstColumnOpt.IsNone() {
+				ret = append(ret, ColumnDiff{TableName: tableName, ColumnName: secondColumn.Name, DiffType: Deleted})
+			}
+		}
+	}
+
+	return slices.DeleteFunc(ret, func(diff ColumnDiff) bool {
+		return ignoreRules.isIgnored("columns", diff.TableName, diff.ColumnName)
+	})
+}
+
+// GetModifiedColumnProperties returns the names of the properties that differ between two versions of the same
+// column. The ordinal position is deliberately left out since adding or dropping a column shifts every column after it.
+func GetModifiedColumnProperties(first *schema.Column, second *schema.Column) []string {
+	ret := []string{}
+
+	if first.Type != second.Type {
+		ret = append(ret, "type")
+	}
+	if first.IsNullable != second.IsNullable {
+		ret = append(ret, "nullable")
+	}
+	if !equalNullableStrings(first.Default, second.Default) {
+		ret = append(ret, "default")
+	}
+	if first.Extra != second.Extra {
+		ret = append(ret, "extra")
+	}
+	if first.GenerationExpression != second.GenerationExpression {
+		ret = append(ret, "expression")
+	}
+	if !equalNullableStrings(first.CharacterSet, second.CharacterSet) {
+		ret = append(ret, "charset")
+	}
+	if !equalNullableStrings(first.Collation, second.Collation) {
+		ret = append(ret, "collation")
+	}
+	if first.Comment != second.Comment {
+		ret = append(ret, "comment")
+	}
 
-	// Running the query for the first db.
-	rows, err := firstDb.SqlConnection.Query(sql, firstDb.Info.DatabaseName, firstDb.Info.DatabaseName)
+	return ret
+}
+
+// equalNullableStrings compares two strings that could be NULL in the database.
+func equalNullableStrings(first *string, second *string) bool {
+	if first == nil || second == nil {
+		return first == nil && second == nil
+	}
+
+	return *first == *second
+}
+
+// loadColumnsFromMysql reads the columns of every table in the schema.
+func loadColumnsFromMysql(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	rows, err := db.SqlConnection.QueryContext(ctx, `
+		SELECT TABLE_NAME,
+		       COLUMN_NAME,
+		       ORDINAL_POSITION,
+		       COLUMN_TYPE,
+		       IS_NULLABLE,
+		       COLUMN_DEFAULT,
+		       EXTRA,
+		       GENERATION_EXPRESSION,
+		       CHARACTER_SET_NAME,
+		       COLLATION_NAME,
+		       COLUMN_COMMENT
+		FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = ?
+		ORDER BY TABLE_NAME, ORDINAL_POSITION
+	`, db.Info.DatabaseName)
 	if err != nil {
-		return ret, safego.Some(err)
+		return safego.Some(err)
 	}
+	defer rows.Close()
 
-	firstDbTablesAndColumnsData := map[string][]columnInfo{}
 	for rows.Next() {
-		var buffer string
-		if err := rows.Scan(&buffer); err != nil {
-			utils.AbortTui(err.Error())
-		}
+		var tableName, isNullable string
+		var columnDefault, generationExpression, characterSetName, collationName sql.NullString
+		var column schema.Column
 
-		err := json.Unmarshal([]byte(buffer), &firstDbTablesAndColumnsData)
+		err := rows.Scan(
+			&tableName,
+			&column.Name,
+			&column.OrdinalPosition,
+			&column.Type,
+			&isNullable,
+			&columnDefault,
+			&column.Extra,
+			&generationExpression,
+			&characterSetName,
+			&collationName,
+			&column.Comment,
+		)
 		if err != nil {
-			utils.AbortTui(err.Error())
+			return safego.Some(err)
 		}
+
+		// information_schema.COLUMNS lists the columns of views as well.
+		table, ok := ret.Tables[tableName]
+		if !ok {
+			continue
+		}
+
+		column.IsNullable = isNullable == "YES"
+		column.Default = nullStringToPointer(columnDefault)
+		column.CharacterSet = nullStringToPointer(characterSetName)
+		column.Collation = nullStringToPointer(collationName)
+		// Mysql 8 escapes the quotes of the string literals in the expression.
+		column.GenerationExpression = strings.ReplaceAll(generationExpression.String, `\'`, "'")
+
+		table.Columns = append(table.Columns, &column)
 	}
 
-	// Running the query for the second db.
-	rows, err = secondDb.SqlConnection.Query(sql, secondDb.Info.DatabaseName, secondDb.Info.DatabaseName)
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	return safego.None[error]()
+}
+
+func loadColumnsFromPostgres(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	// Postgres has no notion of character sets per column, so CharacterSet is always left empty. Collations are only
+	// reported when they differ from the default collation of the column's type.
+	rows, err := db.SqlConnection.QueryContext(ctx, `
+		SELECT c.relname,
+		       a.attname,
+		       a.attnum,
+		       format_type(a.atttypid, a.atttypmod),
+		       NOT a.attnotnull,
+		       pg_get_expr(d.adbin, d.adrelid),
+		       CASE a.attidentity WHEN 'a' THEN 'GENERATED ALWAYS AS IDENTITY' WHEN 'd' THEN 'GENERATED BY DEFAULT AS IDENTITY' ELSE '' END,
+		       coll.collname,
+		       COALESCE(col_description(c.oid, a.attnum), '')
+		FROM pg_catalog.pg_attribute a
+		JOIN pg_catalog.pg_class c ON c.oid = a.attrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_catalog.pg_type t ON t.oid = a.atttypid
+		LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		LEFT JOIN pg_catalog.pg_collation coll ON coll.oid = a.attcollation AND a.attcollation <> t.typcollation
+		WHERE n.nspname = current_schema()
+		  AND c.relkind IN ('r', 'p')
+		  AND a.attnum > 0
+		  AND NOT a.attisdropped
+		ORDER BY c.relname, a.attnum
+	`)
 	if err != nil {
-		return ret, safego.Some(err)
+		return safego.Some(err)
 	}
+	defer rows.Close()
 
-	secondDbTablesAndColumnsData := map[string][]columnInfo{}
 	for rows.Next() {
-		var buffer string
-		if err := rows.Scan(&buffer); err != nil {
-			utils.AbortTui(err.Error())
-		}
+		var tableName string
+		var columnDefault, collationName sql.NullString
+		var column schema.Column
 
-		err := json.Unmarshal([]byte(buffer), &secondDbTablesAndColumnsData)
+		err := rows.Scan(
+			&tableName,
+			&column.Name,
+			&column.OrdinalPosition,
+			&column.Type,
+			&column.IsNullable,
+			&columnDefault,
+			&column.Extra,
+			&collationName,
+			&column.Comment,
+		)
 		if err != nil {
-			utils.AbortTui(err.Error())
+			return safego.Some(err)
+		}
+
+		table, ok := ret.Tables[tableName]
+		if !ok {
+			continue
 		}
+
+		column.Default = nullStringToPointer(columnDefault)
+		column.Collation = nullStringToPointer(collationName)
+
+		table.Columns = append(table.Columns, &column)
 	}
 
-	// Loop through the tables in the firstDb and create the diff for the columns that are not sync
-	// between the two databases. Obviously, we want to only check tables that exist in both of the
-	// environments.
-	for tableName, firstDbColumnsForCurrentTable := range firstDbTablesAndColumnsData {
-		// Operate only on tables that are in both databases.
-		if _, ok := secondDbTablesAndColumnsData[tableName]; ok {
-			secondDbColumnsForCurrentTable := secondDbTablesAndColumnsData[tableName]
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
 
-			// Populate a slice containing just the column names in for the first env.
-			columnsInFirstDbTable := []string{}
-			for _, columnInfo := range firstDbColumnsForCurrentTable {
-				columnsInFirstDbTable = append(columnsInFirstDbTable, columnInfo.ColumnName)
-			}
+	return safego.None[error]()
+}
 
-			// Populate a slice containing just the column names in for the second env.
-			columnsInSecondDbTable := []string{}
-			for _, columnInfo := range secondDbColumnsForCurrentTable {
-				columnsInSecondDbTable = append(columnsInSecondDbTable, columnInfo.ColumnName)
-			}
+// sqliteAutoincrementRegex matches the `AUTOINCREMENT` keyword of a `CREATE TABLE` statement, which Sqlite only
+// allows on an `INTEGER PRIMARY KEY` column.
+var sqliteAutoincrementRegex = regexp.MustCompile(`(?i)\bAUTOINCREMENT\b`)
 
-			// Loop through the columns in the first env. Columns that do exist in the second env 
-			// are discarded, while the rest are added to ret as 'created.'
-			for _, columnName := range columnsInFirstDbTable {
-				if !slices.Contains(columnsInSecondDbTable, columnName) {
-					ret = append(ret, columnDiff{TableName: tableName, ColumnName: columnName, DiffType: 1})
-				}
-			}
+func loadColumnsFromSqlite(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	// hidden is 2 for virtual generated columns and 3 for stored ones. The expressions of generated columns, like the
+	// collations of columns, are only kept in the `CREATE TABLE` statement, which is what tables are rebuilt from.
+	rows, err := db.SqlConnection.QueryContext(ctx, `
+		SELECT m.name, c.cid, c.name, c.type, c."notnull", c.dflt_value, c.hidden, c.pk
+		FROM sqlite_master m
+		JOIN pragma_table_xinfo(m.name) c
+		WHERE m.type = 'table'
+		ORDER BY m.name, c.cid
+	`)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
 
-			// Loop through the columns in the second env. Columns that do not exist in the first 
-			// are added to ret as 'deleted.'
-			for _, columnName := range columnsInSecondDbTable {
-				if !slices.Contains(columnsInFirstDbTable, columnName) {
-					ret = append(ret, columnDiff{TableName: tableName, ColumnName: columnName, DiffType: 0})
-				}
-			}
+	for rows.Next() {
+		var tableName string
+		var notNull, hidden, primaryKeyPosition int
+		var columnDefault sql.NullString
+		var column schema.Column
+
+		err := rows.Scan(&tableName, &column.OrdinalPosition, &column.Name, &column.Type, &notNull, &columnDefault, &hidden, &primaryKeyPosition)
+		if err != nil {
+			return safego.Some(err)
 		}
+
+		table, ok := ret.Tables[tableName]
+		if !ok {
+			continue
+		}
+
+		// cid starts at 0.
+		column.OrdinalPosition += 1
+		column.IsNullable = notNull == 0
+		column.Default = nullStringToPointer(columnDefault)
+
+		if hidden == 2 {
+			column.Extra = "VIRTUAL GENERATED"
+		} else if hidden == 3 {
+			column.Extra = "STORED GENERATED"
+		} else if primaryKeyPosition == 1 && sqliteAutoincrementRegex.MatchString(table.Definition) {
+			column.Extra = "AUTOINCREMENT"
+		}
+
+		table.Columns = append(table.Columns, &column)
 	}
 
-	return ret, errOpt
-}
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
 
-type columnInfo struct {
-	ColumnName      string `json:"COLUMN_NAME"`
-	OrdinalPosition int    `json:"ORDINAL_POSITION"`
+	return safego.None[error]()
 }
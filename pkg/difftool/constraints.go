@@ -0,0 +1,394 @@
+package difftool
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/types"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// ConstraintDiff is a constraint out of sync between two schemas.
+type ConstraintDiff struct {
+	ConstraintName string `json:"constraint_name" yaml:"constraint_name"`
+	// The table the constraint belongs to.
+	TableName string `json:"table_name" yaml:"table_name"`
+	// ConstraintType is one of PRIMARY KEY, FOREIGN KEY, UNIQUE, CHECK or EXCLUDE (Postgres only.)
+	ConstraintType string `json:"constraint_type" yaml:"constraint_type"`
+	// DiffType represents the type of change that has occurred to the entity (deleted, created or modified.)
+	DiffType DiffType `json:"diff_type" yaml:"diff_type"`
+	// ModifiedProperties holds the names of the properties that differ between the two environments. It is only
+	// populated for modified constraints.
+	ModifiedProperties []string `json:"modified_properties,omitempty" yaml:"modified_properties,omitempty"`
+}
+
+// GetConstraintsDiff returns the constraints out of sync between two schemas. Constraints matching ignoreRules are left
+// out. Like indexes, only the constraints of tables that exist in both databases are compared since a newly created
+// table brings its constraints with it.
+func GetConstraintsDiff(firstSchema *schema.Schema, secondSchema *schema.Schema, ignoreRules *IgnoreRules) []ConstraintDiff {
+	ret := []ConstraintDiff{}
+
+	for tableName, firstTable := range firstSchema.Tables {
+		secondTable, ok := secondSchema.Tables[tableName]
+		if !ok {
+			continue
+		}
+
+		// Constraints that exist in the first env but not in the second env must have been created. The ones that
+		// exist in both are compared property by property.
+		for constraintName, firstConstraint := range firstTable.Constraints {
+			secondConstraint, ok := secondTable.Constraints[constraintName]
+			if !ok {
+				ret = append(ret, ConstraintDiff{
+					TableName:      tableName,
+					ConstraintName: constraintName,
+					ConstraintType: firstConstraint.Type,
+					DiffType:       Created,
+				})
+				continue
+			}
+
+			modifiedProperties := getModifiedConstraintProperties(firstConstraint, secondConstraint)
+			if len(modifiedProperties) != 0 {
+				ret = append(ret, ConstraintDiff{
+					TableName:          tableName,
+					ConstraintName:     constraintName,
+					ConstraintType:     firstConstraint.Type,
+					DiffType:           Modified,
+					ModifiedProperties: modifiedProperties,
+				})
+			}
+		}
+
+		// Constraints that exist in the second env but not in the first env must have been deleted.
+		for constraintName, secondConstraint := range secondTable.Constraints {
+			if _, ok := firstTable.Constraints[constraintName]; !ok {
+				ret = append(ret, ConstraintDiff{
+					TableName:      tableName,
+					ConstraintName: constraintName,
+					ConstraintType: secondConstraint.Type,
+					DiffType:       Deleted,
+				})
+			}
+		}
+	}
+
+	return slices.DeleteFunc(ret, func(diff ConstraintDiff) bool {
+		return ignoreRules.isIgnored("constraints", diff.TableName, diff.ConstraintName)
+	})
+}
+
+// getModifiedConstraintProperties returns the names of the properties that differ between two versions of the same
+// constraint.
+func getModifiedConstraintProperties(first *schema.Constraint, second *schema.Constraint) []string {
+	ret := []string{}
+
+	if first.Type != second.Type {
+		ret = append(ret, "type")
+	}
+	if !slices.Equal(first.Columns, second.Columns) {
+		ret = append(ret, "columns")
+	}
+	if first.ReferencedTableName != second.ReferencedTableName || !slices.Equal(first.ReferencedColumns, second.ReferencedColumns) {
+		ret = append(ret, "references")
+	}
+	if first.DeleteRule != second.DeleteRule {
+		ret = append(ret, "on delete")
+	}
+	if first.UpdateRule != second.UpdateRule {
+		ret = append(ret, "on update")
+	}
+	if first.CheckClause != second.CheckClause {
+		ret = append(ret, "check")
+	}
+	if first.Definition != second.Definition {
+		ret = append(ret, "definition")
+	}
+
+	return ret
+}
+
+// loadConstraintsFromMysql reads the constraints of every table in the schema.
+func loadConstraintsFromMysql(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	// Check constraint names are unique per schema in Mysql but only per table in MariaDB.
+	checkConstraintsJoinCondition := "cc.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA AND cc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME"
+	if db.Info.Dialect == "mariadb" {
+		checkConstraintsJoinCondition += " AND cc.TABLE_NAME = tc.TABLE_NAME"
+	}
+
+	rows, err := db.SqlConnection.QueryContext(ctx, `
+		SELECT tc.TABLE_NAME,
+		       tc.CONSTRAINT_NAME,
+		       tc.CONSTRAINT_TYPE,
+		       kcu.COLUMN_NAME,
+		       kcu.REFERENCED_TABLE_NAME,
+		       kcu.REFERENCED_COLUMN_NAME,
+		       rc.UPDATE_RULE,
+		       rc.DELETE_RULE,
+		       cc.CHECK_CLAUSE
+		FROM information_schema.TABLE_CONSTRAINTS tc
+		LEFT JOIN information_schema.KEY_COLUMN_USAGE kcu
+		       ON kcu.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA
+		      AND kcu.TABLE_NAME = tc.TABLE_NAME
+		      AND kcu.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+		LEFT JOIN information_schema.REFERENTIAL_CONSTRAINTS rc
+		       ON rc.CONSTRAINT_SCHEMA = tc.CONSTRAINT_SCHEMA
+		      AND rc.TABLE_NAME = tc.TABLE_NAME
+		      AND rc.CONSTRAINT_NAME = tc.CONSTRAINT_NAME
+		LEFT JOIN information_schema.CHECK_CONSTRAINTS cc
+		       ON `+checkConstraintsJoinCondition+`
+		WHERE tc.TABLE_SCHEMA = ?
+		ORDER BY tc.TABLE_NAME, tc.CONSTRAINT_NAME, kcu.ORDINAL_POSITION
+	`, db.Info.DatabaseName)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, constraintName, constraintType string
+		var columnName, referencedTableName, referencedColumnName, updateRule, deleteRule, checkClause sql.NullString
+
+		err := rows.Scan(&tableName, &constraintName, &constraintType, &columnName, &referencedTableName, &referencedColumnName, &updateRule, &deleteRule, &checkClause)
+		if err != nil {
+			return safego.Some(err)
+		}
+
+		table, ok := ret.Tables[tableName]
+		if !ok {
+			continue
+		}
+
+		constraint, ok := table.Constraints[constraintName]
+		if !ok {
+			constraint = &schema.Constraint{Name: constraintName}
+			table.Constraints[constraintName] = constraint
+		}
+
+		constraint.Type = constraintType
+		constraint.ReferencedTableName = referencedTableName.String
+		constraint.UpdateRule = updateRule.String
+		constraint.DeleteRule = deleteRule.String
+		constraint.CheckClause = checkClause.String
+		if columnName.Valid {
+			constraint.Columns = append(constraint.Columns, columnName.String)
+		}
+		if referencedColumnName.Valid {
+			constraint.ReferencedColumns = append(constraint.ReferencedColumns, referencedColumnName.String)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	return safego.None[error]()
+}
+
+func loadConstraintsFromPostgres(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	// pg_get_constraintdef covers the columns, references, rules and check expressions all in one string, so it is
+	// the only thing compared for Postgres. The referenced table is kept on its own to order the generated statements.
+	rows, err := db.SqlConnection.QueryContext(ctx, `
+		SELECT c.relname,
+		       con.conname,
+		       CASE con.contype
+		           WHEN 'p' THEN 'PRIMARY KEY'
+		           WHEN 'f' THEN 'FOREIGN KEY'
+		           WHEN 'u' THEN 'UNIQUE'
+		           WHEN 'c' THEN 'CHECK'
+		           ELSE 'EXCLUDE'
+		       END,
+		       pg_get_constraintdef(con.oid),
+		       COALESCE(r.relname, '')
+		FROM pg_catalog.pg_constraint con
+		JOIN pg_catalog.pg_class c ON c.oid = con.conrelid
+		LEFT JOIN pg_catalog.pg_class r ON r.oid = con.confrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema()
+		  AND con.contype IN ('p', 'f', 'u', 'c', 'x')
+	`)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName string
+		var constraint schema.Constraint
+
+		err := rows.Scan(&tableName, &constraint.Name, &constraint.Type, &constraint.Definition, &constraint.ReferencedTableName)
+		if err != nil {
+			return safego.Some(err)
+		}
+
+		table, ok := ret.Tables[tableName]
+		if !ok {
+			continue
+		}
+
+		table.Constraints[constraint.Name] = &constraint
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	return safego.None[error]()
+}
+
+// loadConstraintsFromSqlite reads the primary keys, unique constraints and foreign keys of every table in the schema.
+// Sqlite doesn't report the names of constraints, so they are named after their table and columns the way Postgres
+// names them by default. e.g. `users_pkey`, `users_email_key` or `orders_user_id_fkey`. Check constraints are only
+// kept in the `CREATE TABLE` statement and aren't compared.
+func loadConstraintsFromSqlite(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	rows, err := db.SqlConnection.QueryContext(ctx, `
+		SELECT m.name, c.name
+		FROM sqlite_master m
+		JOIN pragma_table_info(m.name) c
+		WHERE m.type = 'table' AND c.pk > 0
+		ORDER BY m.name, c.pk
+	`)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, columnName string
+		if err := rows.Scan(&tableName, &columnName); err != nil {
+			return safego.Some(err)
+		}
+
+		table, ok := ret.Tables[tableName]
+		if !ok {
+			continue
+		}
+
+		constraintName := tableName + "_pkey"
+		constraint, ok := table.Constraints[constraintName]
+		if !ok {
+			constraint = &schema.Constraint{Name: constraintName, Type: "PRIMARY KEY"}
+			table.Constraints[constraintName] = constraint
+		}
+
+		constraint.Columns = append(constraint.Columns, columnName)
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	// Unique constraints are backed by indexes with an origin of 'u'. The constraints are keyed by the name of their
+	// index until all of their columns are read.
+	rows, err = db.SqlConnection.QueryContext(ctx, `
+		SELECT m.name, il.name, ii.name
+		FROM sqlite_master m
+		JOIN pragma_index_list(m.name) il
+		JOIN pragma_index_info(il.name) ii
+		WHERE m.type = 'table' AND il.origin = 'u'
+		ORDER BY m.name, il.name, ii.seqno
+	`)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	uniqueConstraints := map[string]*schema.Constraint{}
+	uniqueConstraintTables := map[string]*schema.Table{}
+	for rows.Next() {
+		var tableName, indexName, columnName string
+		if err := rows.Scan(&tableName, &indexName, &columnName); err != nil {
+			return safego.Some(err)
+		}
+
+		table, ok := ret.Tables[tableName]
+		if !ok {
+			continue
+		}
+
+		constraint, ok := uniqueConstraints[indexName]
+		if !ok {
+			constraint = &schema.Constraint{Type: "UNIQUE"}
+			uniqueConstraints[indexName] = constraint
+			uniqueConstraintTables[indexName] = table
+		}
+
+		constraint.Columns = append(constraint.Columns, columnName)
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	for indexName, constraint := range uniqueConstraints {
+		table := uniqueConstraintTables[indexName]
+		constraint.Name = table.Name + "_" + strings.Join(constraint.Columns, "_") + "_key"
+		table.Constraints[constraint.Name] = constraint
+	}
+
+	// Each foreign key has an id that is unique within its table, and a row for each of its columns. The referenced
+	// column is NULL when the foreign key references the primary key of the other table without naming its columns.
+	rows, err = db.SqlConnection.QueryContext(ctx, `
+		SELECT m.name, fk.id, fk."table", fk."from", fk."to", fk.on_update, fk.on_delete
+		FROM sqlite_master m
+		JOIN pragma_foreign_key_list(m.name) fk
+		WHERE m.type = 'table'
+		ORDER BY m.name, fk.id, fk.seq
+	`)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	foreignKeys := map[string]*schema.Constraint{}
+	foreignKeyTables := map[string]*schema.Table{}
+	for rows.Next() {
+		var tableName, referencedTableName, columnName, updateRule, deleteRule string
+		var id int
+		var referencedColumnName sql.NullString
+
+		err := rows.Scan(&tableName, &id, &referencedTableName, &columnName, &referencedColumnName, &updateRule, &deleteRule)
+		if err != nil {
+			return safego.Some(err)
+		}
+
+		table, ok := ret.Tables[tableName]
+		if !ok {
+			continue
+		}
+
+		key := tableName + "\x00" + strconv.Itoa(id)
+		constraint, ok := foreignKeys[key]
+		if !ok {
+			constraint = &schema.Constraint{
+				Type:                "FOREIGN KEY",
+				ReferencedTableName: referencedTableName,
+				UpdateRule:          updateRule,
+				DeleteRule:          deleteRule,
+			}
+			foreignKeys[key] = constraint
+			foreignKeyTables[key] = table
+		}
+
+		constraint.Columns = append(constraint.Columns, columnName)
+		if referencedColumnName.Valid {
+			constraint.ReferencedColumns = append(constraint.ReferencedColumns, referencedColumnName.String)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	for key, constraint := range foreignKeys {
+		table := foreignKeyTables[key]
+		constraint.Name = table.Name + "_" + strings.Join(constraint.Columns, "_") + "_fkey"
+		table.Constraints[constraint.Name] = constraint
+	}
+
+	return safego.None[error]()
+}
@@ -0,0 +1,64 @@
+package difftool
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+func TestGetConstraintsDiff(t *testing.T) {
+	foreignKey := func() *schema.Constraint {
+		return &schema.Constraint{
+			Name:                "fk_user",
+			Type:                "FOREIGN KEY",
+			Columns:             []string{"user_id"},
+			ReferencedTableName: "users",
+			ReferencedColumns:   []string{"id"},
+			UpdateRule:          "NO ACTION",
+			DeleteRule:          "NO ACTION",
+		}
+	}
+
+	cascadingForeignKey := foreignKey()
+	cascadingForeignKey.DeleteRule = "CASCADE"
+
+	firstSchema := testutil.NewSchema("mysql",
+		&schema.Table{Name: "orders", Constraints: map[string]*schema.Constraint{
+			"fk_user":  cascadingForeignKey,
+			"PRIMARY":  {Name: "PRIMARY", Type: "PRIMARY KEY", Columns: []string{"id"}},
+			"chk_paid": {Name: "chk_paid", Type: "CHECK", CheckClause: "(`paid` >= 0)"},
+		}},
+	)
+	secondSchema := testutil.NewSchema("mysql",
+		&schema.Table{Name: "orders", Constraints: map[string]*schema.Constraint{
+			"fk_user":    foreignKey(),
+			"PRIMARY":    {Name: "PRIMARY", Type: "PRIMARY KEY", Columns: []string{"id"}},
+			"uq_number":  {Name: "uq_number", Type: "UNIQUE", Columns: []string{"number"}},
+			"chk_paid":   {Name: "chk_paid", Type: "CHECK", CheckClause: "(`paid` >= 0)"},
+			"chk_unused": {Name: "chk_unused", Type: "CHECK", CheckClause: "(1 = 1)"},
+		}},
+	)
+
+	diffs := GetConstraintsDiff(firstSchema, secondSchema, nil)
+	slices.SortFunc(diffs, func(a ConstraintDiff, b ConstraintDiff) int {
+		return strings.Compare(a.ConstraintName, b.ConstraintName)
+	})
+
+	expected := []ConstraintDiff{
+		{TableName: "orders", ConstraintName: "chk_unused", ConstraintType: "CHECK", DiffType: Deleted},
+		{TableName: "orders", ConstraintName: "fk_user", ConstraintType: "FOREIGN KEY", DiffType: Modified, ModifiedProperties: []string{"on delete"}},
+		{TableName: "orders", ConstraintName: "uq_number", ConstraintType: "UNIQUE", DiffType: Deleted},
+	}
+
+	if len(diffs) != len(expected) {
+		t.Fatalf("got %d diffs, expected %d: %+v", len(diffs), len(expected), diffs)
+	}
+	for i := range expected {
+		if diffs[i].TableName != expected[i].TableName || diffs[i].ConstraintName != expected[i].ConstraintName || diffs[i].ConstraintType != expected[i].ConstraintType || diffs[i].DiffType != expected[i].DiffType || !slices.Equal(diffs[i].ModifiedProperties, expected[i].ModifiedProperties) {
+			t.Errorf("got %+v, expected %+v", diffs[i], expected[i])
+		}
+	}
+}
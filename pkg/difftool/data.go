@@ -0,0 +1,291 @@
+package difftool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/types"
+	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// RowDiff is a row out of sync between the same table in two databases. Rows are matched by their primary key.
+type RowDiff struct {
+	TableName string `json:"table_name" yaml:"table_name"`
+	// DiffType represents the type of change that has occurred to the row (deleted, created or modified.)
+	DiffType DiffType `json:"diff_type" yaml:"diff_type"`
+	// PrimaryKeyColumns are the names of the columns of the primary key, in the order of PrimaryKey.
+	PrimaryKeyColumns []string `json:"primary_key_columns" yaml:"primary_key_columns"`
+	// PrimaryKey holds the values of the primary key of the row.
+	PrimaryKey []string `json:"primary_key" yaml:"primary_key"`
+	// Columns are the names of the compared columns, in the order of Values.
+	Columns []string `json:"columns" yaml:"columns"`
+	// Values holds the row as it is in the first database, or as it is in the second one for deleted rows. NULL values
+	// are nil.
+	Values []*string `json:"values" yaml:"values"`
+	// ModifiedColumns holds the names of the columns whose values differ. It is only populated for modified rows.
+	ModifiedColumns []string `json:"modified_columns,omitempty" yaml:"modified_columns,omitempty"`
+}
+
+// CompareTableData compares the rows of a table in two databases and calls onRowDiff for every row out of sync. Only
+// the columns that exist in both tables are compared, and both tables must have the same primary key.
+//
+// The rows are read in chunks of chunkSize ordered by the primary key, so tables of any size can be compared. Each
+// chunk of the first table is compared against the rows of the second table that fall in the same range of keys,
+// which are read in chunks as well. The ranges are decided by the database itself, with text keys ordered byte by byte
+// whatever their collation, so that both databases agree on them and keys equal in a case insensitive collation are
+// still told apart when they are matched here. The queries are canceled along with ctx.
+func CompareTableData(ctx context.Context, firstDb types.DbConnection, secondDb types.DbConnection, firstTable *schema.Table, secondTable *schema.Table, chunkSize int, onRowDiff func(RowDiff) safego.Option[error]) safego.Option[error] {
+	if chunkSize <= 0 {
+		return safego.Some(errors.New("the chunk size must be greater than 0"))
+	}
+
+	firstPrimaryKeyOpt := firstTable.GetPrimaryKey()
+	secondPrimaryKeyOpt := secondTable.GetPrimaryKey()
+	if firstPrimaryKeyOpt.IsNone() || secondPrimaryKeyOpt.IsNone() {
+		return safego.Some(errors.New("table " + firstTable.Name + " needs a primary key in both databases to match its rows"))
+	}
+	primaryKeyColumns := firstPrimaryKeyOpt.Unwrap().Columns
+	if !slices.Equal(primaryKeyColumns, secondPrimaryKeyOpt.Unwrap().Columns) {
+		return safego.Some(errors.New("table " + firstTable.Name + " has a different primary key in each database"))
+	}
+
+	columns := []string{}
+	for _, column := range firstTable.Columns {
+		if secondColumnOpt := secondTable.GetColumn(column.Name); secondColumnOpt.IsSome() {
+			columns = append(columns, column.Name)
+		}
+	}
+
+	primaryKeyIndexes := []int{}
+	for _, columnName := range primaryKeyColumns {
+		primaryKeyIndexes = append(primaryKeyIndexes, slices.Index(columns, columnName))
+	}
+
+	getPrimaryKey := func(row []*string) []string {
+		ret := []string{}
+		for _, index := range primaryKeyIndexes {
+			ret = append(ret, *row[index])
+		}
+
+		return ret
+	}
+
+	newRowDiff := func(diffType DiffType, row []*string, modifiedColumns []string) RowDiff {
+		return RowDiff{
+			TableName:         firstTable.Name,
+			DiffType:          diffType,
+			PrimaryKeyColumns: primaryKeyColumns,
+			PrimaryKey:        getPrimaryKey(row),
+			Columns:           columns,
+			Values:            row,
+			ModifiedColumns:   modifiedColumns,
+		}
+	}
+
+	firstQuery := dataQuery{db: firstDb, table: firstTable, columns: columns, primaryKeyColumns: primaryKeyColumns}
+	secondQuery := dataQuery{db: secondDb, table: secondTable, columns: columns, primaryKeyColumns: primaryKeyColumns}
+
+	// The chunk of the first table covers the keys after lowerKey, up to the last key it holds, or every remaining
+	// key if it is the last chunk.
+	var lowerKey []string
+	for {
+		firstRows, errOpt := firstQuery.fetchChunk(ctx, lowerKey, nil, chunkSize)
+		if errOpt.IsSome() {
+			return errOpt
+		}
+
+		var upperKey []string
+		isLastChunk := len(firstRows) < chunkSize
+		if !isLastChunk {
+			upperKey = getPrimaryKey(firstRows[len(firstRows)-1])
+		}
+
+		// The rows of the chunk that are left once the second table is read are the created ones.
+		pendingRows := map[string][]*string{}
+		for _, row := range firstRows {
+			pendingRows[strings.Join(getPrimaryKey(row), "\x00")] = row
+		}
+
+		secondLowerKey := lowerKey
+		for {
+			secondRows, errOpt := secondQuery.fetchChunk(ctx, secondLowerKey, upperKey, chunkSize)
+			if errOpt.IsSome() {
+				return errOpt
+			}
+
+			for _, secondRow := range secondRows {
+				key := strings.Join(getPrimaryKey(secondRow), "\x00")
+
+				firstRow, ok := pendingRows[key]
+				if !ok {
+					if errOpt := onRowDiff(newRowDiff(Deleted, secondRow, nil)); errOpt.IsSome() {
+						return errOpt
+					}
+					continue
+				}
+				delete(pendingRows, key)
+
+				modifiedColumns := []string{}
+				for i, columnName := range columns {
+					if !equalNullableStrings(firstRow[i], secondRow[i]) {
+						modifiedColumns = append(modifiedColumns, columnName)
+					}
+				}
+
+				if len(modifiedColumns) != 0 {
+					if errOpt := onRowDiff(newRowDiff(Modified, firstRow, modifiedColumns)); errOpt.IsSome() {
+						return errOpt
+					}
+				}
+			}
+
+			if len(secondRows) < chunkSize {
+				break
+			}
+			secondLowerKey = getPrimaryKey(secondRows[len(secondRows)-1])
+		}
+
+		// Going through the rows of the chunk instead of the map keeps them ordered by their key.
+		for _, row := range firstRows {
+			if _, ok := pendingRows[strings.Join(getPrimaryKey(row), "\x00")]; ok {
+				if errOpt := onRowDiff(newRowDiff(Created, row, nil)); errOpt.IsSome() {
+					return errOpt
+				}
+			}
+		}
+
+		if isLastChunk {
+			break
+		}
+		lowerKey = upperKey
+	}
+
+	return safego.None[error]()
+}
+
+// dataQuery reads the rows of a table in chunks ordered by its primary key.
+type dataQuery struct {
+	db                types.DbConnection
+	table             *schema.Table
+	columns           []string
+	primaryKeyColumns []string
+}
+
+// fetchChunk reads up to limit rows whose primary key is greater than lowerKey and lower than or equal to upperKey.
+// Either bound is left out when it is nil.
+func (self *dataQuery) fetchChunk(ctx context.Context, lowerKey []string, upperKey []string, limit int) ([][]*string, safego.Option[error]) {
+	isMysql := self.db.Info.Dialect == "mysql" || self.db.Info.Dialect == "mariadb"
+
+	quote := quotePostgresIdentifier
+	if isMysql {
+		quote = quoteMysqlIdentifier
+	}
+
+	quotedColumns := []string{}
+	for _, columnName := range self.columns {
+		quotedColumns = append(quotedColumns, quote(columnName))
+	}
+
+	binaryPrimaryKeyColumns := []string{}
+	for _, columnName := range self.primaryKeyColumns {
+		binaryPrimaryKeyColumns = append(binaryPrimaryKeyColumns, self.getBinaryKeyColumn(columnName, quote(columnName)))
+	}
+	primaryKey := "(" + strings.Join(binaryPrimaryKeyColumns, ", ") + ")"
+
+	// Sqlite quotes identifiers like Postgres, but takes the same placeholders as Mysql.
+	usesQuestionMarks := isMysql || self.db.Info.Dialect == "sqlite"
+
+	args := []any{}
+	// getPlaceholders returns the placeholders of a key in the form of `(?, ?)` for Mysql and Sqlite or `($1, $2)` for
+	// Postgres.
+	getPlaceholders := func(key []string) string {
+		placeholders := []string{}
+		for _, value := range key {
+			args = append(args, value)
+			placeholders = append(placeholders, utils.Ternary(usesQuestionMarks, "?", "$"+strconv.Itoa(len(args))))
+		}
+
+		return "(" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	conditions := []string{}
+	if lowerKey != nil {
+		conditions = append(conditions, primaryKey+" > "+getPlaceholders(lowerKey))
+	}
+	if upperKey != nil {
+		conditions = append(conditions, primaryKey+" <= "+getPlaceholders(upperKey))
+	}
+
+	query := "SELECT " + strings.Join(quotedColumns, ", ") + " FROM " + quote(self.table.Name)
+	if len(conditions) != 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + strings.Join(binaryPrimaryKeyColumns, ", ") + " LIMIT " + strconv.Itoa(limit)
+
+	rows, err := self.db.SqlConnection.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, safego.Some(err)
+	}
+	defer rows.Close()
+
+	ret := [][]*string{}
+	for rows.Next() {
+		values := make([]sql.NullString, len(self.columns))
+		valueRefs := make([]any, len(self.columns))
+		for i := range values {
+			valueRefs[i] = &values[i]
+		}
+
+		if err := rows.Scan(valueRefs...); err != nil {
+			return nil, safego.Some(err)
+		}
+
+		row := []*string{}
+		for _, value := range values {
+			row = append(row, nullStringToPointer(value))
+		}
+		ret = append(ret, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, safego.Some(err)
+	}
+
+	return ret, safego.None[error]()
+}
+
+// getBinaryKeyColumn returns the column of the primary key as it is ordered and compared in the queries. Text columns
+// are compared byte by byte, the same way Go compares the keys, instead of by their collation. Cockroach already
+// compares strings that way.
+func (self *dataQuery) getBinaryKeyColumn(columnName string, quotedColumnName string) string {
+	dialect := self.db.Info.Dialect
+
+	// Sqlite collations only apply to text, so every column can be given one.
+	if dialect == "sqlite" {
+		return quotedColumnName + " COLLATE BINARY"
+	}
+
+	columnOpt := self.table.GetColumn(columnName)
+	if columnOpt.IsNone() {
+		return quotedColumnName
+	}
+
+	kind := parseNeutralType(dialect, columnOpt.Unwrap().Type).Kind
+	if kind != "char" && kind != "varchar" && kind != "text" {
+		return quotedColumnName
+	}
+
+	if dialect == "mysql" || dialect == "mariadb" {
+		return "CAST(" + quotedColumnName + " AS BINARY)"
+	} else if dialect == "postgres" {
+		return quotedColumnName + ` COLLATE "C"`
+	}
+
+	return quotedColumnName
+}
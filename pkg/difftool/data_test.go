@@ -0,0 +1,133 @@
+package difftool
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/types"
+	"github.com/Okira-E/patchi/safego"
+)
+
+func TestCompareTableData(t *testing.T) {
+	table := &schema.Table{
+		Name:        "users",
+		Columns:     []*schema.Column{{Name: "id", Type: "TEXT"}, {Name: "email", Type: "TEXT"}},
+		Constraints: map[string]*schema.Constraint{"pk": {Name: "pk", Type: "PRIMARY KEY", Columns: []string{"id"}}},
+	}
+
+	// The first table ignores the case of its keys while the second one doesn't, so the rows must be ordered byte by
+	// byte for the chunks of both tables to line up.
+	firstDb := testutil.NewSqliteDb(t,
+		"CREATE TABLE users (id TEXT COLLATE NOCASE PRIMARY KEY, email TEXT)",
+		"INSERT INTO users VALUES ('a', 'a@x'), ('B', 'B@x'), ('c', 'c@x'), ('d', 'd@x'), ('e', NULL)",
+	)
+	secondDb := testutil.NewSqliteDb(t,
+		"CREATE TABLE users (id TEXT PRIMARY KEY, email TEXT)",
+		"INSERT INTO users VALUES ('A', 'A@x'), ('B', 'B@x'), ('b', 'b@x'), ('c', 'changed@x'), ('e', 'e@x'), ('f', 'f@x')",
+	)
+
+	for _, chunkSize := range []int{1, 2, 3, 100} {
+		rowDiffs := []string{}
+		errOpt := CompareTableData(context.Background(), firstDb, secondDb, table, table, chunkSize, func(rowDiff RowDiff) safego.Option[error] {
+			rowDiffs = append(rowDiffs, rowDiff.DiffType.String()+" "+strings.Join(rowDiff.PrimaryKey, ","))
+			return safego.None[error]()
+		})
+		if errOpt.IsSome() {
+			t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+		}
+
+		expected := map[string]bool{
+			"deleted A":  true,
+			"created a":  true,
+			"deleted b":  true,
+			"modified c": true,
+			"created d":  true,
+			"modified e": true,
+			"deleted f":  true,
+		}
+		if len(rowDiffs) != len(expected) {
+			t.Errorf("chunk size %d: got %v", chunkSize, rowDiffs)
+			continue
+		}
+		for _, rowDiff := range rowDiffs {
+			if !expected[rowDiff] {
+				t.Errorf("chunk size %d: unexpected row diff %s in %v", chunkSize, rowDiff, rowDiffs)
+			}
+		}
+	}
+}
+
+func TestCompareTableDataCompositeKey(t *testing.T) {
+	table := &schema.Table{
+		Name:        "order_items",
+		Columns:     []*schema.Column{{Name: "order_id", Type: "INTEGER"}, {Name: "line", Type: "INTEGER"}, {Name: "quantity", Type: "INTEGER"}},
+		Constraints: map[string]*schema.Constraint{"pk": {Name: "pk", Type: "PRIMARY KEY", Columns: []string{"order_id", "line"}}},
+	}
+
+	firstDb := testutil.NewSqliteDb(t,
+		"CREATE TABLE order_items (order_id INTEGER, line INTEGER, quantity INTEGER, PRIMARY KEY (order_id, line))",
+		"INSERT INTO order_items VALUES (1, 1, 1), (1, 2, 2), (2, 1, 3), (10, 1, 4)",
+	)
+	secondDb := testutil.NewSqliteDb(t,
+		"CREATE TABLE order_items (order_id INTEGER, line INTEGER, quantity INTEGER, PRIMARY KEY (order_id, line))",
+		"INSERT INTO order_items VALUES (1, 1, 1), (1, 2, 5), (2, 2, 3), (10, 1, 4)",
+	)
+
+	rowDiffs := []string{}
+	errOpt := CompareTableData(context.Background(), firstDb, secondDb, table, table, 2, func(rowDiff RowDiff) safego.Option[error] {
+		rowDiffs = append(rowDiffs, rowDiff.DiffType.String()+" "+strings.Join(rowDiff.PrimaryKey, ","))
+		return safego.None[error]()
+	})
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+
+	expected := "modified 1,2\ndeleted 2,2\ncreated 2,1"
+	if strings.Join(rowDiffs, "\n") != expected {
+		t.Errorf("got\n%s\nexpected\n%s", strings.Join(rowDiffs, "\n"), expected)
+	}
+}
+
+func TestCompareTableDataWithoutPrimaryKey(t *testing.T) {
+	table := &schema.Table{Name: "logs", Columns: []*schema.Column{{Name: "message", Type: "TEXT"}}}
+
+	errOpt := CompareTableData(context.Background(), types.DbConnection{}, types.DbConnection{}, table, table, 10, nil)
+	if errOpt.IsNone() {
+		t.Fatal("expected an error for a table without a primary key")
+	}
+}
+
+func TestGetBinaryKeyColumn(t *testing.T) {
+	table := &schema.Table{Name: "t", Columns: []*schema.Column{{Name: "id", Type: "int"}, {Name: "code", Type: "varchar(10)"}}}
+
+	tests := []struct {
+		dialect    string
+		columnName string
+		expected   string
+	}{
+		{"mysql", "code", "CAST(`code` AS BINARY)"},
+		{"mysql", "id", "`id`"},
+		{"postgres", "code", `"code" COLLATE "C"`},
+		{"cockroachdb", "code", `"code"`},
+		{"sqlite", "id", `"id" COLLATE BINARY`},
+	}
+
+	for _, test := range tests {
+		if test.dialect == "postgres" || test.dialect == "cockroachdb" {
+			table.Columns[1].Type = "character varying(10)"
+		}
+
+		query := dataQuery{db: types.DbConnection{Info: &types.DbConnectionInfo{Dialect: test.dialect}}, table: table}
+		quote := quotePostgresIdentifier
+		if test.dialect == "mysql" {
+			quote = quoteMysqlIdentifier
+		}
+
+		if column := query.getBinaryKeyColumn(test.columnName, quote(test.columnName)); column != test.expected {
+			t.Errorf("%s: got %s, expected %s", test.dialect, column, test.expected)
+		}
+	}
+}
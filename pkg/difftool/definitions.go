@@ -0,0 +1,113 @@
+package difftool
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// definerRegex matches the `DEFINER=...` clause Mysql adds to the definitions of views, routines and triggers. The
+// definer is the user that created the object, which usually differs between environments.
+var definerRegex = regexp.MustCompile("(?i)\\s*\\bDEFINER\\s*=\\s*(CURRENT_USER(\\s*\\(\\s*\\))?|(`[^`]*`|'[^']*'|[^\\s@]+)@(`[^`]*`|'[^']*'|\\S+))")
+
+// normalizeDefinition brings the definition of a view, routine or trigger to a form in which two definitions can be
+// compared. Comments are removed, runs of whitespace are collapsed into a single space, and the `DEFINER` clause is
+// dropped. String literals and quoted identifiers are kept as they are.
+func normalizeDefinition(definition string, dialect string) string {
+	var ret strings.Builder
+
+	// Mysql dumps wrap parts of the definitions in version comments like `/*!50013 ... */`. Their content is kept
+	// and only the comment markers are dropped.
+	isInVersionComment := false
+	hasPendingSpace := false
+	var quote rune
+
+	runes := []rune(definition)
+	for i := 0; i < len(runes); i += 1 {
+		char := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		if quote != 0 {
+			ret.WriteRune(char)
+
+			if char == '\\' && quote != '`' && next != 0 {
+				ret.WriteRune(next)
+				i += 1
+			} else if char == quote {
+				quote = 0
+			}
+
+			continue
+		}
+
+		if (char == '-' && next == '-') || (char == '#' && (dialect == "mysql" || dialect == "mariadb")) {
+			for i < len(runes) && runes[i] != '\n' {
+				i += 1
+			}
+			hasPendingSpace = true
+
+			continue
+		}
+
+		if char == '/' && next == '*' {
+			if i+2 < len(runes) && runes[i+2] == '!' {
+				isInVersionComment = true
+				i += 2
+				for i+1 < len(runes) && unicode.IsDigit(runes[i+1]) {
+					i += 1
+				}
+			} else {
+				i += 2
+				for i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/') {
+					i += 1
+				}
+				i += 1
+			}
+			hasPendingSpace = true
+
+			continue
+		}
+
+		if char == '*' && next == '/' && isInVersionComment {
+			isInVersionComment = false
+			hasPendingSpace = true
+			i += 1
+
+			continue
+		}
+
+		if unicode.IsSpace(char) {
+			hasPendingSpace = true
+
+			continue
+		}
+
+		if hasPendingSpace && ret.Len() != 0 {
+			ret.WriteRune(' ')
+		}
+		hasPendingSpace = false
+
+		if char == '\'' || char == '"' || char == '`' {
+			quote = char
+		}
+
+		ret.WriteRune(char)
+	}
+
+	return strings.TrimSpace(strings.TrimSuffix(definerRegex.ReplaceAllString(ret.String(), ""), ";"))
+}
+
+// RemoveDefiner drops the `DEFINER` clause from the definition of a Mysql view, routine or trigger, so that it can be
+// created on a database where the definer doesn't exist. The user running the statement becomes the definer instead.
+// Only the first clause is dropped since the clause is part of the header of the definition.
+func RemoveDefiner(definition string) string {
+	location := definerRegex.FindStringIndex(definition)
+	if location == nil {
+		return definition
+	}
+
+	return definition[:location[0]] + definition[location[1]:]
+}
@@ -0,0 +1,98 @@
+package difftool
+
+import "testing"
+
+func TestNormalizeDefinition(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		dialect    string
+		expected   string
+	}{
+		{
+			name:       "whitespace is collapsed",
+			definition: "CREATE VIEW v AS\n\tSELECT  id\n  FROM users;",
+			dialect:    "postgres",
+			expected:   "CREATE VIEW v AS SELECT id FROM users",
+		},
+		{
+			name:       "comments are removed",
+			definition: "SELECT 1 -- one\n/* block\ncomment */ + 2 # hash",
+			dialect:    "mysql",
+			expected:   "SELECT 1 + 2",
+		},
+		{
+			name:       "hash is not a comment in postgres",
+			definition: "SELECT a #> '{b}' FROM t",
+			dialect:    "postgres",
+			expected:   "SELECT a #> '{b}' FROM t",
+		},
+		{
+			name:       "version comments keep their content",
+			definition: "CREATE /*!50013 DEFINER=`root`@`%` SQL SECURITY DEFINER */ VIEW `v` AS select 1",
+			dialect:    "mysql",
+			expected:   "CREATE SQL SECURITY DEFINER VIEW `v` AS select 1",
+		},
+		{
+			name:       "string literals and quoted identifiers are kept",
+			definition: "SELECT 'a  -- b', \"c  d\", `e  f`, 'it''s', 'back\\'slash'  FROM t",
+			dialect:    "mysql",
+			expected:   "SELECT 'a  -- b', \"c  d\", `e  f`, 'it''s', 'back\\'slash' FROM t",
+		},
+		{
+			name:       "definers are dropped",
+			definition: "CREATE DEFINER=`admin`@`10.0.0.%` PROCEDURE `p`() BEGIN SELECT 1; END",
+			dialect:    "mysql",
+			expected:   "CREATE PROCEDURE `p`() BEGIN SELECT 1; END",
+		},
+		{
+			name:       "current user definers are dropped",
+			definition: "CREATE DEFINER = CURRENT_USER() TRIGGER t BEFORE INSERT ON u FOR EACH ROW SET @a = 1",
+			dialect:    "mysql",
+			expected:   "CREATE TRIGGER t BEFORE INSERT ON u FOR EACH ROW SET @a = 1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if normalized := normalizeDefinition(test.definition, test.dialect); normalized != test.expected {
+				t.Errorf("got\n%s\nexpected\n%s", normalized, test.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeDefinitionMakesEquivalentDefinitionsEqual(t *testing.T) {
+	first := "CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`localhost` SQL SECURITY DEFINER VIEW `v` AS select `id` from `users`"
+	second := "CREATE ALGORITHM=UNDEFINED DEFINER=`deploy`@`%` SQL SECURITY DEFINER VIEW `v` AS\n  select `id`\n  from `users`;"
+
+	if normalizeDefinition(first, "mysql") != normalizeDefinition(second, "mysql") {
+		t.Errorf("expected the definitions to be equal:\n%s\n%s", normalizeDefinition(first, "mysql"), normalizeDefinition(second, "mysql"))
+	}
+}
+
+func TestRemoveDefiner(t *testing.T) {
+	tests := []struct {
+		definition string
+		expected   string
+	}{
+		{
+			definition: "CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `v` AS select 1",
+			expected:   "CREATE ALGORITHM=UNDEFINED SQL SECURITY DEFINER VIEW `v` AS select 1",
+		},
+		{
+			definition: "CREATE DEFINER=root@localhost FUNCTION `f`() RETURNS int RETURN 'DEFINER=x@y'",
+			expected:   "CREATE FUNCTION `f`() RETURNS int RETURN 'DEFINER=x@y'",
+		},
+		{
+			definition: "CREATE VIEW v AS SELECT 1",
+			expected:   "CREATE VIEW v AS SELECT 1",
+		},
+	}
+
+	for _, test := range tests {
+		if definition := RemoveDefiner(test.definition); definition != test.expected {
+			t.Errorf("got\n%s\nexpected\n%s", definition, test.expected)
+		}
+	}
+}
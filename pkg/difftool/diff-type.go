@@ -0,0 +1,28 @@
+package difftool
+
+// DiffType represents the type of change that has occurred to an entity.
+type DiffType int8
+
+const (
+	Deleted  DiffType = 0
+	Created  DiffType = 1
+	Modified DiffType = 2
+)
+
+// String returns the name of the diff type as it is shown to the user.
+func (self DiffType) String() string {
+	if self == Deleted {
+		return "deleted"
+	} else if self == Created {
+		return "created"
+	} else if self == Modified {
+		return "modified"
+	}
+
+	return "unknown"
+}
+
+// MarshalText makes the diff type show up by its name instead of its number in JSON and YAML output.
+func (self DiffType) MarshalText() ([]byte, error) {
+	return []byte(self.String()), nil
+}
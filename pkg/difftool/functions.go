@@ -1,94 +1,51 @@
 package difftool
 
 import (
-	"fmt"
+	"slices"
 
-	"github.com/Okira-E/patchi/pkg/types"
-	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/schema"
 )
 
-type functionDiff struct {
-	FunctionName string
-	// DiffType represents the type of change that has occurred to the entity.
-	// 0 -> Deleted.
-	// 1 -> Created.
-	DiffType int8
+// FunctionDiff is a function out of sync between two schemas.
+type FunctionDiff struct {
+	FunctionName string `json:"function_name" yaml:"function_name"`
+	// DiffType represents the type of change that has occurred to the entity (deleted, created or modified.)
+	DiffType DiffType `json:"diff_type" yaml:"diff_type"`
 }
 
-// GetFunctionsDiff returns the functions out of sync between two databases.
-func GetFunctionsDiff(firstDb types.DbConnection, secondDb types.DbConnection, dialect string) []functionDiff {
-	ret := []functionDiff{}
+// GetFunctionsDiff returns the functions out of sync between two schemas. Functions matching ignoreRules are left out.
+func GetFunctionsDiff(firstSchema *schema.Schema, secondSchema *schema.Schema, ignoreRules *IgnoreRules) []FunctionDiff {
+	ret := []FunctionDiff{}
 
-	functionsInFirstDb := getAllFunctionsNamesInDb(firstDb, dialect)
-	functionsInSecondDb := getAllFunctionsNamesInDb(secondDb, dialect)
-
-	// Compare the two arrays of function names and return the difference.
 	// Functions that exist in the first database but not in the second database must have been created.
 	// Functions that exist in the second database but not in the first database must have been deleted.
+	// Functions that exist in both databases but whose definitions differ must have been modified.
 
-	firstDbFunctionsBookKeeping := map[string]bool{}
-	for _, functionName := range functionsInFirstDb {
-		firstDbFunctionsBookKeeping[functionName] = true
-	}
-
-	secondDbFunctionsBookKeeping := map[string]bool{}
-	for _, functionName := range functionsInSecondDb {
-		secondDbFunctionsBookKeeping[functionName] = true
-	}
-
-	for _, functionName := range functionsInFirstDb {
-		if _, ok := secondDbFunctionsBookKeeping[functionName]; !ok {
-			ret = append(ret, functionDiff{
+	for functionName, firstFunction := range firstSchema.Functions {
+		secondFunction, ok := secondSchema.Functions[functionName]
+		if !ok {
+			ret = append(ret, FunctionDiff{
 				FunctionName: functionName,
-				DiffType:  1,
+				DiffType:     Created,
 			})
-		}
-	}
-
-	for _, functionName := range functionsInSecondDb {
-		if _, ok := firstDbFunctionsBookKeeping[functionName]; !ok {
-			ret = append(ret, functionDiff{
+		} else if normalizeDefinition(firstFunction.Definition, firstSchema.Dialect) != normalizeDefinition(secondFunction.Definition, secondSchema.Dialect) {
+			ret = append(ret, FunctionDiff{
 				FunctionName: functionName,
-				DiffType:  0,
+				DiffType:     Modified,
 			})
 		}
 	}
 
-	return ret
-}
-
-// getAllFunctionsNamesInDb returns an array of function names retrieved from given database connection.
-func getAllFunctionsNamesInDb(db types.DbConnection, dialect string) []string {
-	ret := []string{}
-
-	if dialect == "mysql" || dialect == "mariadb" {
-		ret = getAllFunctionsNamesInMysql(db, dialect)
-	} else if dialect == "postgres" || dialect == "cockroachdb" {
-		utils.AbortTui("UNIMPLEMENTED")
-	}
-	return ret
-}
-
-func getAllFunctionsNamesInMysql(db types.DbConnection, dialect string) []string {
-	ret := []string{}
-
-	rows, err := db.SqlConnection.Query(
-		"SELECT ROUTINE_NAME FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA = ? AND ROUTINE_TYPE = 'FUNCTION'",
-		db.Info.DatabaseName,
-	)
-	if err != nil {
-		utils.Abort(fmt.Sprintf("Error querying database: %s", err.Error()))
-	}
-
-	for rows.Next() {
-		var functionName string
-		err := rows.Scan(&functionName)
-		if err != nil {
-			utils.Abort(fmt.Sprintf("Error scanning row: %s", err.Error()))
+	for functionName := range secondSchema.Functions {
+		if _, ok := firstSchema.Functions[functionName]; !ok {
+			ret = append(ret, FunctionDiff{
+				FunctionName: functionName,
+				DiffType:     Deleted,
+			})
 		}
-
-		ret = append(ret, functionName)
 	}
 
-	return ret
+	return slices.DeleteFunc(ret, func(diff FunctionDiff) bool {
+		return ignoreRules.isIgnored("functions", "", diff.FunctionName)
+	})
 }
@@ -0,0 +1,102 @@
+package difftool
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/types"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// IgnoreRules decides which entities are left out of a diff. A nil *IgnoreRules ignores nothing.
+type IgnoreRules struct {
+	// patterns holds the compiled patterns per type of entity (tables, columns, ..etc.)
+	patterns map[string][]ignorePattern
+}
+
+// ignorePattern is either a glob or a regular expression.
+type ignorePattern struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+// NewIgnoreRules compiles the patterns of the rules. It fails on the first invalid glob or regular expression.
+func NewIgnoreRules(rules types.IgnoreRules) (*IgnoreRules, safego.Option[error]) {
+	ret := &IgnoreRules{patterns: map[string][]ignorePattern{}}
+
+	patternsPerEntityType := map[string][]string{
+		"tables":      rules.Tables,
+		"columns":     rules.Columns,
+		"indexes":     rules.Indexes,
+		"constraints": rules.Constraints,
+		"views":       rules.Views,
+		"procedures":  rules.Procedures,
+		"functions":   rules.Functions,
+		"triggers":    rules.Triggers,
+	}
+
+	for entityType, patterns := range patternsPerEntityType {
+		for _, pattern := range patterns {
+			if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+				regex, err := regexp.Compile(pattern[1 : len(pattern)-1])
+				if err != nil {
+					return nil, safego.Some(fmt.Errorf("invalid ignore pattern %s for %s: %w", pattern, entityType, err))
+				}
+
+				ret.patterns[entityType] = append(ret.patterns[entityType], ignorePattern{regex: regex})
+				continue
+			}
+
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, safego.Some(fmt.Errorf("invalid ignore pattern %s for %s: %w", pattern, entityType, err))
+			}
+
+			ret.patterns[entityType] = append(ret.patterns[entityType], ignorePattern{glob: pattern})
+		}
+	}
+
+	return ret, safego.None[error]()
+}
+
+// isIgnored checks if an entity matches any of the rules of its type. Entities that belong to an ignored table are
+// ignored as well. tableName is only used by entities that belong to a table (columns, indexes, etc.)
+func (self *IgnoreRules) isIgnored(entityType string, tableName string, entityName string) bool {
+	if self == nil {
+		return false
+	}
+
+	if tableName != "" && self.isIgnored("tables", "", tableName) {
+		return true
+	}
+
+	for _, pattern := range self.patterns[entityType] {
+		if pattern.matches(tableName, entityName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches checks if an entity matches the pattern. Regular expressions match either the name of the entity or its
+// name qualified by its table (`table.entity`). Globs are only compared to the table's name if they are qualified.
+func (self ignorePattern) matches(tableName string, entityName string) bool {
+	if self.regex != nil {
+		return self.regex.MatchString(entityName) || (tableName != "" && self.regex.MatchString(tableName+"."+entityName))
+	}
+
+	if tableName != "" {
+		if tableGlob, entityGlob, ok := strings.Cut(self.glob, "."); ok {
+			isTableMatched, _ := path.Match(tableGlob, tableName)
+			isEntityMatched, _ := path.Match(entityGlob, entityName)
+
+			return isTableMatched && isEntityMatched
+		}
+	}
+
+	isMatched, _ := path.Match(self.glob, entityName)
+
+	return isMatched
+}
@@ -0,0 +1,97 @@
+package difftool
+
+import (
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/types"
+)
+
+func TestIgnoreRulesIsIgnored(t *testing.T) {
+	ignoreRules := newTestIgnoreRules(t, types.IgnoreRules{
+		Tables:      []string{"tmp_*", `/^_backup_\d+$/`},
+		Columns:     []string{"*.updated_at", "audit_*.created_by", "legacy"},
+		Indexes:     []string{`/^users\.idx_old/`},
+		Constraints: []string{"fk_*"},
+		Triggers:    []string{"orders.trg_*"},
+		Views:       []string{"v_report_?"},
+	})
+
+	tests := []struct {
+		name       string
+		entityType string
+		tableName  string
+		entityName string
+		expected   bool
+	}{
+		{"table glob", "tables", "", "tmp_users", true},
+		{"table glob mismatch", "tables", "", "users_tmp", false},
+		{"table regex", "tables", "", "_backup_12", true},
+		{"table regex is anchored", "tables", "", "_backup_12_old", false},
+		{"qualified column glob in any table", "columns", "users", "updated_at", true},
+		{"qualified column glob with a table glob", "columns", "audit_users", "created_by", true},
+		{"qualified column glob in another table", "columns", "users", "created_by", false},
+		{"unqualified column glob", "columns", "orders", "legacy", true},
+		{"column of an ignored table", "columns", "tmp_orders", "id", true},
+		{"qualified index regex", "indexes", "users", "idx_old_email", true},
+		{"qualified index regex in another table", "indexes", "orders", "idx_old_email", false},
+		{"unqualified constraint glob", "constraints", "orders", "fk_orders_users", true},
+		{"constraint of an ignored table", "constraints", "_backup_1", "pk", true},
+		{"index of an ignored table", "indexes", "tmp_orders", "idx_id", true},
+		{"qualified trigger glob", "triggers", "orders", "trg_audit", true},
+		{"qualified trigger glob in another table", "triggers", "users", "trg_audit", false},
+		{"trigger of an ignored table", "triggers", "tmp_orders", "audit", true},
+		{"view glob", "views", "", "v_report_1", true},
+		{"view glob mismatch", "views", "", "v_report_10", false},
+		{"rules of another type", "views", "", "tmp_report", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if isIgnored := ignoreRules.isIgnored(test.entityType, test.tableName, test.entityName); isIgnored != test.expected {
+				t.Errorf("got %t, expected %t", isIgnored, test.expected)
+			}
+		})
+	}
+}
+
+func TestIgnoreRulesNil(t *testing.T) {
+	var ignoreRules *IgnoreRules
+	if ignoreRules.isIgnored("tables", "", "users") {
+		t.Errorf("expected nil rules to ignore nothing")
+	}
+}
+
+func TestNewIgnoreRulesInvalidPatterns(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules types.IgnoreRules
+	}{
+		{"invalid regex", types.IgnoreRules{Tables: []string{"/^(users$/"}}},
+		{"invalid glob", types.IgnoreRules{Columns: []string{"users.[id"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, errOpt := NewIgnoreRules(test.rules); errOpt.IsNone() {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestGetTriggersDiffIgnoresTriggersOfIgnoredTables(t *testing.T) {
+	firstSchema := testutil.NewSchema("mysql")
+	firstSchema.Triggers["audit_insert"] = &schema.Trigger{Name: "audit_insert", TableName: "tmp_orders", Definition: "CREATE TRIGGER audit_insert"}
+	firstSchema.Triggers["users_insert"] = &schema.Trigger{Name: "users_insert", TableName: "users", Definition: "CREATE TRIGGER users_insert"}
+	secondSchema := testutil.NewSchema("mysql")
+	secondSchema.Triggers["audit_delete"] = &schema.Trigger{Name: "audit_delete", TableName: "tmp_orders", Definition: "CREATE TRIGGER audit_delete"}
+
+	ignoreRules := newTestIgnoreRules(t, types.IgnoreRules{Tables: []string{"tmp_*"}})
+
+	diffs := GetTriggersDiff(firstSchema, secondSchema, ignoreRules)
+	if len(diffs) != 1 || diffs[0].TriggerName != "users_insert" {
+		t.Errorf("got %+v, expected only users_insert", diffs)
+	}
+}
@@ -0,0 +1,258 @@
+package difftool
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/types"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// IndexDiff is an index out of sync between two schemas.
+type IndexDiff struct {
+	IndexName string `json:"index_name" yaml:"index_name"`
+	// The table the index belongs to.
+	TableName string `json:"table_name" yaml:"table_name"`
+	// DiffType represents the type of change that has occurred to the entity (deleted, created or modified.)
+	DiffType DiffType `json:"diff_type" yaml:"diff_type"`
+	// ModifiedProperties holds the names of the properties that differ between the two environments. It is only
+	// populated for modified indexes.
+	ModifiedProperties []string `json:"modified_properties,omitempty" yaml:"modified_properties,omitempty"`
+}
+
+// GetIndexesDiff returns the indexes out of sync between two schemas. Indexes matching ignoreRules are left out.
+// Indexes that back a constraint (primary keys, unique keys, etc.) are left to GetConstraintsDiff.
+func GetIndexesDiff(firstSchema *schema.Schema, secondSchema *schema.Schema, ignoreRules *IgnoreRules) []IndexDiff {
+	ret := []IndexDiff{}
+
+	// Only tables that exist in both environments are checked. The indexes of a newly created table are part of its
+	// `CREATE TABLE` statement.
+	for tableName, firstTable := range firstSchema.Tables {
+		secondTable, ok := secondSchema.Tables[tableName]
+		if !ok {
+			continue
+		}
+
+		// Indexes that exist in the first env but not in the second env must have been created. The ones that exist
+		// in both are compared property by property.
+		for indexName, firstIndex := range firstTable.Indexes {
+			secondIndex, ok := secondTable.Indexes[indexName]
+			if !ok {
+				ret = append(ret, IndexDiff{TableName: tableName, IndexName: indexName, DiffType: Created})
+				continue
+			}
+
+			modifiedProperties := getModifiedIndexProperties(firstIndex, secondIndex)
+			if len(modifiedProperties) != 0 {
+				ret = append(ret, IndexDiff{
+					TableName:          tableName,
+					IndexName:          indexName,
+					DiffType:           Modified,
+					ModifiedProperties: modifiedProperties,
+				})
+			}
+		}
+
+		// Indexes that exist in the second env but not in the first env must have been deleted.
+		for indexName := range secondTable.Indexes {
+			if _, ok := firstTable.Indexes[indexName]; !ok {
+				ret = append(ret, IndexDiff{TableName: tableName, IndexName: indexName, DiffType: Deleted})
+			}
+		}
+	}
+
+	return slices.DeleteFunc(ret, func(diff IndexDiff) bool {
+		return ignoreRules.isIgnored("indexes", diff.TableName, diff.IndexName)
+	})
+}
+
+// getModifiedIndexProperties returns the names of the properties that differ between two versions of the same index.
+func getModifiedIndexProperties(first *schema.Index, second *schema.Index) []string {
+	ret := []string{}
+
+	if !slices.Equal(first.Columns, second.Columns) {
+		ret = append(ret, "columns")
+	}
+	if first.IsUnique != second.IsUnique {
+		ret = append(ret, "uniqueness")
+	}
+	if first.Type != second.Type {
+		ret = append(ret, "type")
+	}
+	if first.IsVisible != second.IsVisible {
+		ret = append(ret, "visibility")
+	}
+	if first.Definition != second.Definition {
+		ret = append(ret, "definition")
+	}
+
+	return ret
+}
+
+// loadIndexesFromMysql reads the indexes of every table in the schema.
+func loadIndexesFromMysql(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	// MariaDB has neither invisible nor functional indexes.
+	visibilityAndExpressionColumns := "IS_VISIBLE, EXPRESSION"
+	if db.Info.Dialect == "mariadb" {
+		visibilityAndExpressionColumns = "'YES', NULL"
+	}
+
+	// Unique indexes are unique constraints in Mysql.
+	rows, err := db.SqlConnection.QueryContext(ctx, `
+		SELECT TABLE_NAME, INDEX_NAME, NON_UNIQUE, COLUMN_NAME, SUB_PART, COLLATION, INDEX_TYPE, `+visibilityAndExpressionColumns+`
+		FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = ?
+		  AND NON_UNIQUE = 1
+		ORDER BY TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX
+	`, db.Info.DatabaseName)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, indexName, indexType, isVisible string
+		var nonUnique int
+		var columnName, collation, expression sql.NullString
+		var subPart sql.NullInt64
+
+		err := rows.Scan(&tableName, &indexName, &nonUnique, &columnName, &subPart, &collation, &indexType, &isVisible, &expression)
+		if err != nil {
+			return safego.Some(err)
+		}
+
+		table, ok := ret.Tables[tableName]
+		if !ok {
+			continue
+		}
+
+		index, ok := table.Indexes[indexName]
+		if !ok {
+			index = &schema.Index{Name: indexName, IsUnique: nonUnique == 0, Type: indexType, IsVisible: isVisible == "YES"}
+			table.Indexes[indexName] = index
+		}
+
+		index.Columns = append(index.Columns, schema.IndexColumn{
+			ColumnName: columnName.String,
+			Expression: expression.String,
+			SubPart:    subPart.Int64,
+			IsDesc:     collation.String == "D",
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	return safego.None[error]()
+}
+
+func loadIndexesFromPostgres(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	// pg_get_indexdef with a column number returns the column name or the expression of that key. The full definition
+	// is kept as well since it is the only place that holds things like partial index predicates.
+	rows, err := db.SqlConnection.QueryContext(ctx, `
+		SELECT t.relname,
+		       i.relname,
+		       ix.indisunique,
+		       am.amname,
+		       pg_get_indexdef(ix.indexrelid),
+		       k.ordinality,
+		       pg_get_indexdef(ix.indexrelid, k.ordinality::int, true)
+		FROM pg_catalog.pg_index ix
+		JOIN pg_catalog.pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_catalog.pg_class t ON t.oid = ix.indrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = t.relnamespace
+		JOIN pg_catalog.pg_am am ON am.oid = i.relam
+		CROSS JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ordinality)
+		WHERE n.nspname = current_schema()
+		  AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_constraint con WHERE con.conindid = ix.indexrelid)
+		ORDER BY t.relname, i.relname, k.ordinality
+	`)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, indexName, indexType, definition, columnDefinition string
+		var isUnique bool
+		var ordinality int
+
+		err := rows.Scan(&tableName, &indexName, &isUnique, &indexType, &definition, &ordinality, &columnDefinition)
+		if err != nil {
+			return safego.Some(err)
+		}
+
+		table, ok := ret.Tables[tableName]
+		if !ok {
+			continue
+		}
+
+		index, ok := table.Indexes[indexName]
+		if !ok {
+			index = &schema.Index{Name: indexName, IsUnique: isUnique, Type: indexType, IsVisible: true, Definition: definition}
+			table.Indexes[indexName] = index
+		}
+
+		index.Columns = append(index.Columns, schema.IndexColumn{ColumnName: columnDefinition})
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	return safego.None[error]()
+}
+
+func loadIndexesFromSqlite(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	// Indexes created by `CREATE INDEX` have an origin of 'c'. The others back a primary key or a unique constraint.
+	// The name of a key is NULL when it is an expression, which is only found in the definition of the index.
+	rows, err := db.SqlConnection.QueryContext(ctx, `
+		SELECT m.name, il.name, il."unique", ix.name, ix."desc", im.sql
+		FROM sqlite_master m
+		JOIN pragma_index_list(m.name) il
+		JOIN pragma_index_xinfo(il.name) ix
+		JOIN sqlite_master im ON im.type = 'index' AND im.name = il.name
+		WHERE m.type = 'table'
+		  AND il.origin = 'c'
+		  AND ix.key = 1
+		ORDER BY m.name, il.name, ix.seqno
+	`)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, indexName, definition string
+		var isUnique, isDesc bool
+		var columnName sql.NullString
+
+		err := rows.Scan(&tableName, &indexName, &isUnique, &columnName, &isDesc, &definition)
+		if err != nil {
+			return safego.Some(err)
+		}
+
+		table, ok := ret.Tables[tableName]
+		if !ok {
+			continue
+		}
+
+		// Sqlite keeps the definition exactly as it was written, so it is normalized to be compared.
+		index, ok := table.Indexes[indexName]
+		if !ok {
+			index = &schema.Index{Name: indexName, IsUnique: isUnique, Type: "btree", IsVisible: true, Definition: normalizeDefinition(definition, "sqlite")}
+			table.Indexes[indexName] = index
+		}
+
+		index.Columns = append(index.Columns, schema.IndexColumn{ColumnName: columnName.String, IsDesc: isDesc})
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	return safego.None[error]()
+}
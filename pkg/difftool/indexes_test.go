@@ -0,0 +1,75 @@
+package difftool
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/types"
+)
+
+func newTestIgnoreRules(t *testing.T, rules types.IgnoreRules) *IgnoreRules {
+	t.Helper()
+
+	ret, errOpt := NewIgnoreRules(rules)
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+
+	return ret
+}
+
+func TestGetIndexesDiff(t *testing.T) {
+	emailIndex := func() *schema.Index {
+		return &schema.Index{Name: "idx_email", Type: "BTREE", IsVisible: true, Columns: []schema.IndexColumn{{ColumnName: "email"}}}
+	}
+
+	modifiedIndex := emailIndex()
+	modifiedIndex.Columns = append(modifiedIndex.Columns, schema.IndexColumn{ColumnName: "name", IsDesc: true})
+	modifiedIndex.IsVisible = false
+
+	firstSchema := testutil.NewSchema("mysql",
+		&schema.Table{Name: "users", Indexes: map[string]*schema.Index{
+			"idx_email":   modifiedIndex,
+			"idx_created": {Name: "idx_created", Type: "BTREE", IsVisible: true, Columns: []schema.IndexColumn{{ColumnName: "created_at"}}},
+		}},
+		&schema.Table{Name: "orders", Indexes: map[string]*schema.Index{"idx_email": emailIndex()}},
+		&schema.Table{Name: "new_table", Indexes: map[string]*schema.Index{"idx_new": emailIndex()}},
+	)
+	secondSchema := testutil.NewSchema("mysql",
+		&schema.Table{Name: "users", Indexes: map[string]*schema.Index{
+			"idx_email": emailIndex(),
+			"idx_old":   emailIndex(),
+		}},
+		&schema.Table{Name: "orders", Indexes: map[string]*schema.Index{"idx_email": emailIndex()}},
+	)
+
+	diffs := GetIndexesDiff(firstSchema, secondSchema, nil)
+	slices.SortFunc(diffs, func(a IndexDiff, b IndexDiff) int {
+		return strings.Compare(a.IndexName, b.IndexName)
+	})
+
+	expected := []IndexDiff{
+		{TableName: "users", IndexName: "idx_created", DiffType: Created},
+		{TableName: "users", IndexName: "idx_email", DiffType: Modified, ModifiedProperties: []string{"columns", "visibility"}},
+		{TableName: "users", IndexName: "idx_old", DiffType: Deleted},
+	}
+
+	if len(diffs) != len(expected) {
+		t.Fatalf("got %d diffs, expected %d: %+v", len(diffs), len(expected), diffs)
+	}
+	for i := range expected {
+		if diffs[i].TableName != expected[i].TableName || diffs[i].IndexName != expected[i].IndexName || diffs[i].DiffType != expected[i].DiffType || !slices.Equal(diffs[i].ModifiedProperties, expected[i].ModifiedProperties) {
+			t.Errorf("got %+v, expected %+v", diffs[i], expected[i])
+		}
+	}
+
+	ignoreRules := newTestIgnoreRules(t, types.IgnoreRules{Indexes: []string{"users.idx_o*"}})
+	for _, diff := range GetIndexesDiff(firstSchema, secondSchema, ignoreRules) {
+		if diff.IndexName == "idx_old" {
+			t.Errorf("expected idx_old to be ignored")
+		}
+	}
+}
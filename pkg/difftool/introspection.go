@@ -0,0 +1,124 @@
+package difftool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/types"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// LoadSchema introspects everything Patchi compares in a database into an in-memory schema. All the queries against a
+// database happen here, so the diff and the SQL generation work the same way for live connections and snapshots.
+// The queries are canceled along with ctx.
+func LoadSchema(ctx context.Context, db types.DbConnection) (*schema.Schema, safego.Option[error]) {
+	ret := schema.NewSchema(db.Info.Dialect, db.Info.Name, db.Info.DatabaseName)
+	dialect := db.Info.Dialect
+
+	// Tables are loaded first since columns, indexes and constraints are attached to them.
+	var loaders []func(context.Context, types.DbConnection, *schema.Schema) safego.Option[error]
+	if dialect == "mysql" || dialect == "mariadb" {
+		loaders = []func(context.Context, types.DbConnection, *schema.Schema) safego.Option[error]{
+			loadTablesFromMysql,
+			loadColumnsFromMysql,
+			loadIndexesFromMysql,
+			loadConstraintsFromMysql,
+			loadViewsFromMysql,
+			loadRoutinesFromMysql,
+			loadTriggersFromMysql,
+		}
+	} else if dialect == "postgres" || dialect == "cockroachdb" {
+		loaders = []func(context.Context, types.DbConnection, *schema.Schema) safego.Option[error]{
+			loadTablesFromPostgres,
+			loadColumnsFromPostgres,
+			loadIndexesFromPostgres,
+			loadConstraintsFromPostgres,
+			loadViewsFromPostgres,
+			loadRoutinesFromPostgres,
+			loadTriggersFromPostgres,
+		}
+	} else if dialect == "sqlite" {
+		// Sqlite has neither procedures nor functions.
+		loaders = []func(context.Context, types.DbConnection, *schema.Schema) safego.Option[error]{
+			loadTablesFromSqlite,
+			loadColumnsFromSqlite,
+			loadIndexesFromSqlite,
+			loadConstraintsFromSqlite,
+			loadViewsFromSqlite,
+			loadTriggersFromSqlite,
+		}
+	} else {
+		return ret, safego.Some(errors.New("unsupported dialect " + dialect))
+	}
+
+	for _, load := range loaders {
+		if errOpt := load(ctx, db, ret); errOpt.IsSome() {
+			return ret, errOpt
+		}
+	}
+
+	return ret, safego.None[error]()
+}
+
+// showCreate runs one of Mysql's `SHOW CREATE ...` statements and returns the value of the column that holds the
+// definition. Each type of entity comes back with a different set of columns, hence the lookup by name.
+func showCreate(ctx context.Context, db types.DbConnection, query string, definitionColumnName string) (string, safego.Option[error]) {
+	rows, err := db.SqlConnection.QueryContext(ctx, query)
+	if err != nil {
+		return "", safego.Some(err)
+	}
+	defer rows.Close()
+
+	columnNames, err := rows.Columns()
+	if err != nil {
+		return "", safego.Some(err)
+	}
+
+	values := make([]sql.NullString, len(columnNames))
+	valueRefs := make([]any, len(columnNames))
+	for i := range values {
+		valueRefs[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(valueRefs...); err != nil {
+			return "", safego.Some(err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", safego.Some(err)
+	}
+
+	for i, columnName := range columnNames {
+		// Mysql hides the definition of routines from users that don't own them or lack privileges to read them.
+		if columnName == definitionColumnName && values[i].Valid {
+			return values[i].String, safego.None[error]()
+		}
+	}
+
+	return "", safego.Some(errors.New("`" + query + "` returned no definition. The user might be missing privileges to read it"))
+}
+
+// quoteMysqlIdentifier wraps an identifier in backticks so that names with special characters can be used in queries.
+func quoteMysqlIdentifier(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+// quotePostgresIdentifier wraps an identifier in double quotes so that names with upper case letters or reserved words
+// survive being sent back to Postgres.
+func quotePostgresIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// nullStringToPointer converts a nullable string read from the database into a pointer that is nil for NULL.
+func nullStringToPointer(str sql.NullString) *string {
+	if !str.Valid {
+		return nil
+	}
+
+	return &str.String
+}
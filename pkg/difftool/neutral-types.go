@@ -0,0 +1,455 @@
+package difftool
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/utils"
+)
+
+// neutralType is a column type broken down into a form that belongs to no dialect. It is what lets a Mysql column be
+// compared with a Postgres one, and be written again in the other dialect.
+type neutralType struct {
+	// Kind is one of boolean, integer, decimal, float, char, varchar, text, binary, date, time, timestamp, timestamptz,
+	// year, json, uuid, enum, set, bit or unknown.
+	Kind string
+	// Size is the size in bytes of integers and floats.
+	Size       int
+	IsUnsigned bool
+	// Length is the length of char, varchar and bit types. It is 0 for a varchar without a limit.
+	Length int64
+	// Precision and Scale are those of decimals. Precision is 0 for a Postgres numeric without a limit. For time
+	// types, Precision is the number of fractional digits of the seconds, or -1 when it isn't given.
+	Precision int64
+	Scale     int64
+	// Values are the values of an enum or a set, quoted as they are in the type.
+	Values []string
+	// Raw is the type as reported by its database.
+	Raw string
+}
+
+var (
+	mysqlIntegerSizes    = map[string]int{"tinyint": 1, "smallint": 2, "mediumint": 3, "int": 4, "integer": 4, "bigint": 8}
+	postgresIntegerSizes = map[string]int{"smallint": 2, "integer": 4, "bigint": 8}
+	mysqlTextTypes       = []string{"tinytext", "text", "mediumtext", "longtext"}
+	mysqlBinaryTypes     = []string{"binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob"}
+)
+
+// parseNeutralType breaks down a column type as reported by Mysql (COLUMN_TYPE) or Postgres (format_type).
+func parseNeutralType(dialect string, rawType string) neutralType {
+	ret := neutralType{Kind: "unknown", Precision: -1, Raw: rawType}
+
+	base, params, isUnsigned := splitColumnType(rawType)
+	ret.IsUnsigned = isUnsigned
+
+	if dialect == "mysql" || dialect == "mariadb" {
+		if size, ok := mysqlIntegerSizes[base]; ok {
+			// `tinyint(1)` is how Mysql spells a boolean.
+			if base == "tinyint" && slices.Equal(params, []string{"1"}) && !isUnsigned {
+				ret.Kind = "boolean"
+			} else {
+				ret.Kind, ret.Size = "integer", size
+			}
+		} else if base == "bool" || base == "boolean" {
+			ret.Kind = "boolean"
+		} else if base == "decimal" || base == "numeric" || base == "dec" || base == "fixed" {
+			ret.Kind, ret.Precision, ret.Scale = "decimal", getIntParam(params, 0, 10), getIntParam(params, 1, 0)
+		} else if base == "float" {
+			ret.Kind, ret.Size = "float", utils.Ternary(getIntParam(params, 0, 0) > 24, 8, 4)
+		} else if base == "double" || base == "real" || base == "double precision" {
+			ret.Kind, ret.Size = "float", 8
+		} else if base == "char" {
+			ret.Kind, ret.Length = "char", getIntParam(params, 0, 1)
+		} else if base == "varchar" {
+			ret.Kind, ret.Length = "varchar", getIntParam(params, 0, 0)
+		} else if slices.Contains(mysqlTextTypes, base) {
+			ret.Kind = "text"
+		} else if slices.Contains(mysqlBinaryTypes, base) {
+			ret.Kind = "binary"
+		} else if base == "date" {
+			ret.Kind = "date"
+		} else if base == "time" || base == "datetime" || base == "timestamp" {
+			ret.Kind = map[string]string{"time": "time", "datetime": "timestamp", "timestamp": "timestamptz"}[base]
+			ret.Precision = getIntParam(params, 0, -1)
+		} else if base == "year" {
+			ret.Kind = "year"
+		} else if base == "json" {
+			ret.Kind = "json"
+		} else if base == "enum" || base == "set" {
+			ret.Kind, ret.Values = base, params
+		} else if base == "bit" {
+			ret.Kind, ret.Length = "bit", getIntParam(params, 0, 1)
+		}
+	} else if dialect == "postgres" || dialect == "cockroachdb" {
+		if size, ok := postgresIntegerSizes[base]; ok {
+			ret.Kind, ret.Size = "integer", size
+		} else if base == "boolean" {
+			ret.Kind = "boolean"
+		} else if base == "numeric" {
+			ret.Kind, ret.Precision, ret.Scale = "decimal", getIntParam(params, 0, 0), getIntParam(params, 1, 0)
+		} else if base == "real" || base == "double precision" {
+			ret.Kind, ret.Size = "float", utils.Ternary(base == "real", 4, 8)
+		} else if base == "character" || base == "bpchar" {
+			ret.Kind, ret.Length = "char", getIntParam(params, 0, 1)
+		} else if base == "character varying" {
+			ret.Kind, ret.Length = "varchar", getIntParam(params, 0, 0)
+		} else if base == "text" {
+			ret.Kind = "text"
+		} else if base == "bytea" {
+			ret.Kind = "binary"
+		} else if base == "date" {
+			ret.Kind = "date"
+		} else if strings.HasPrefix(base, "time ") || base == "time" {
+			ret.Kind, ret.Precision = "time", getIntParam(params, 0, -1)
+		} else if base == "timestamp without time zone" || base == "timestamp" {
+			ret.Kind, ret.Precision = "timestamp", getIntParam(params, 0, -1)
+		} else if base == "timestamp with time zone" {
+			ret.Kind, ret.Precision = "timestamptz", getIntParam(params, 0, -1)
+		} else if base == "json" || base == "jsonb" {
+			ret.Kind = "json"
+		} else if base == "uuid" {
+			ret.Kind = "uuid"
+		} else if base == "bit" {
+			ret.Kind, ret.Length = "bit", getIntParam(params, 0, 1)
+		}
+	}
+
+	return ret
+}
+
+// formatNeutralType writes a neutral type in the given dialect. Types that can't be written without changing what
+// they hold come with a message describing the mismatch.
+func formatNeutralType(dialect string, columnType neutralType) (string, string) {
+	var ret, mismatch string
+
+	if dialect == "mysql" || dialect == "mariadb" {
+		if columnType.Kind == "boolean" {
+			ret = "tinyint(1)"
+		} else if columnType.Kind == "integer" {
+			ret = map[int]string{1: "tinyint", 2: "smallint", 3: "mediumint", 4: "int", 8: "bigint"}[columnType.Size] + utils.Ternary(columnType.IsUnsigned, " unsigned", "")
+		} else if columnType.Kind == "decimal" {
+			if columnType.Precision == 0 {
+				ret, mismatch = "decimal(65,30)", "numeric without a precision is limited to decimal(65,30) in Mysql"
+			} else {
+				ret = "decimal(" + strconv.FormatInt(columnType.Precision, 10) + "," + strconv.FormatInt(columnType.Scale, 10) + ")"
+			}
+		} else if columnType.Kind == "float" {
+			ret = utils.Ternary(columnType.Size == 4, "float", "double")
+		} else if columnType.Kind == "char" {
+			ret = "char(" + strconv.FormatInt(columnType.Length, 10) + ")"
+		} else if columnType.Kind == "varchar" {
+			ret = utils.Ternary(columnType.Length == 0, "longtext", "varchar("+strconv.FormatInt(columnType.Length, 10)+")")
+		} else if columnType.Kind == "text" {
+			ret = "longtext"
+		} else if columnType.Kind == "binary" {
+			ret = "longblob"
+		} else if columnType.Kind == "date" || columnType.Kind == "year" || columnType.Kind == "json" {
+			ret = columnType.Kind
+		} else if columnType.Kind == "time" || columnType.Kind == "timestamp" || columnType.Kind == "timestamptz" {
+			ret = map[string]string{"time": "time", "timestamp": "datetime", "timestamptz": "timestamp"}[columnType.Kind]
+			if columnType.Precision > 0 {
+				ret += "(" + strconv.FormatInt(columnType.Precision, 10) + ")"
+			}
+			if columnType.Kind == "timestamptz" {
+				mismatch = "timestamp with time zone becomes timestamp, which only holds dates between 1970 and 2038 in Mysql"
+			}
+		} else if columnType.Kind == "uuid" {
+			ret, mismatch = "char(36)", "uuid is stored as char(36) in Mysql"
+		} else if columnType.Kind == "enum" || columnType.Kind == "set" {
+			ret = columnType.Kind + "(" + strings.Join(columnType.Values, ",") + ")"
+		} else if columnType.Kind == "bit" {
+			ret = "bit(" + strconv.FormatInt(columnType.Length, 10) + ")"
+		} else {
+			ret, mismatch = columnType.Raw, columnType.Raw+" has no Mysql equivalent and is copied as it is"
+		}
+	} else if dialect == "postgres" || dialect == "cockroachdb" {
+		if columnType.Kind == "boolean" {
+			ret = "boolean"
+		} else if columnType.Kind == "integer" {
+			// Unsigned integers need the next bigger type to hold their upper half.
+			size := columnType.Size
+			if columnType.IsUnsigned {
+				size = map[int]int{1: 2, 2: 4, 3: 4, 4: 8, 8: 16}[size]
+			}
+
+			if size == 16 {
+				ret, mismatch = "numeric(20,0)", "bigint unsigned has no Postgres equivalent and becomes numeric(20,0)"
+			} else {
+				ret = map[int]string{1: "smallint", 2: "smallint", 3: "integer", 4: "integer", 8: "bigint"}[size]
+			}
+		} else if columnType.Kind == "decimal" {
+			ret = "numeric(" + strconv.FormatInt(columnType.Precision, 10) + "," + strconv.FormatInt(columnType.Scale, 10) + ")"
+		} else if columnType.Kind == "float" {
+			ret = utils.Ternary(columnType.Size == 4, "real", "double precision")
+		} else if columnType.Kind == "char" {
+			ret = "character(" + strconv.FormatInt(columnType.Length, 10) + ")"
+		} else if columnType.Kind == "varchar" {
+			ret = "character varying" + utils.Ternary(columnType.Length == 0, "", "("+strconv.FormatInt(columnType.Length, 10)+")")
+		} else if columnType.Kind == "text" {
+			ret = "text"
+		} else if columnType.Kind == "binary" {
+			ret = "bytea"
+		} else if columnType.Kind == "date" || columnType.Kind == "uuid" {
+			ret = columnType.Kind
+		} else if columnType.Kind == "time" || columnType.Kind == "timestamp" || columnType.Kind == "timestamptz" {
+			ret = utils.Ternary(columnType.Kind == "time", "time", "timestamp")
+			if columnType.Precision >= 0 {
+				ret += "(" + strconv.FormatInt(columnType.Precision, 10) + ")"
+			}
+			ret += utils.Ternary(columnType.Kind == "timestamptz", " with time zone", " without time zone")
+		} else if columnType.Kind == "year" {
+			ret, mismatch = "smallint", "year is stored as smallint in Postgres"
+		} else if columnType.Kind == "json" {
+			ret = "jsonb"
+		} else if columnType.Kind == "enum" || columnType.Kind == "set" {
+			ret, mismatch = "text", columnType.Raw+" becomes text in Postgres, which doesn't enforce its values"
+		} else if columnType.Kind == "bit" {
+			ret = "bit(" + strconv.FormatInt(columnType.Length, 10) + ")"
+		} else {
+			ret, mismatch = columnType.Raw, columnType.Raw+" has no Postgres equivalent and is copied as it is"
+		}
+	}
+
+	return ret, mismatch
+}
+
+// isSameNeutralType tells whether two types hold the same values. The Mysql text and blob types are all taken as
+// text and binary, and the fractional digits of time types are left out since each dialect has its own default.
+func isSameNeutralType(first neutralType, second neutralType) bool {
+	if first.Kind != second.Kind || first.Kind == "unknown" {
+		return first.Kind == second.Kind && strings.EqualFold(first.Raw, second.Raw)
+	}
+
+	if first.Kind == "integer" || first.Kind == "float" {
+		return first.Size == second.Size && first.IsUnsigned == second.IsUnsigned
+	} else if first.Kind == "decimal" {
+		return first.Precision == second.Precision && first.Scale == second.Scale
+	} else if first.Kind == "char" || first.Kind == "varchar" || first.Kind == "bit" {
+		return first.Length == second.Length
+	} else if first.Kind == "enum" || first.Kind == "set" {
+		return slices.Equal(first.Values, second.Values)
+	}
+
+	return true
+}
+
+// splitColumnType splits a column type into its lower case base name, its parameters and whether it is unsigned.
+// e.g. `decimal(10,2) unsigned` -> `decimal`, [10 2], true.
+func splitColumnType(rawType string) (string, []string, bool) {
+	rawType = strings.TrimSpace(rawType)
+	lowerType := strings.ToLower(rawType)
+
+	isUnsigned := strings.Contains(lowerType, " unsigned")
+
+	params := []string{}
+	openIndex := strings.Index(rawType, "(")
+	closeIndex := strings.LastIndex(rawType, ")")
+	if openIndex != -1 && closeIndex > openIndex {
+		// Enum values keep their case.
+		params = splitQuotedList(rawType[openIndex+1 : closeIndex])
+		lowerType = lowerType[:openIndex] + " " + lowerType[closeIndex+1:]
+	}
+
+	lowerType = strings.ReplaceAll(lowerType, " unsigned", "")
+	lowerType = strings.ReplaceAll(lowerType, " zerofill", "")
+
+	return strings.Join(strings.Fields(lowerType), " "), params, isUnsigned
+}
+
+// splitQuotedList splits a list on the commas that aren't within quotes.
+func splitQuotedList(list string) []string {
+	ret := []string{}
+
+	current := strings.Builder{}
+	var quote rune
+	for _, char := range list {
+		if quote == 0 && (char == '\'' || char == '"' || char == '`') {
+			quote = char
+		} else if char == quote {
+			quote = 0
+		}
+
+		if char == ',' && quote == 0 {
+			ret = append(ret, strings.TrimSpace(current.String()))
+			current.Reset()
+			continue
+		}
+
+		current.WriteRune(char)
+	}
+	ret = append(ret, strings.TrimSpace(current.String()))
+
+	return ret
+}
+
+// getIntParam returns the parameter of a type at the given position as a number, or defaultValue if it isn't there.
+func getIntParam(params []string, position int, defaultValue int64) int64 {
+	if position >= len(params) {
+		return defaultValue
+	}
+
+	ret, err := strconv.ParseInt(strings.TrimSpace(params[position]), 10, 64)
+	if err != nil {
+		return defaultValue
+	}
+
+	return ret
+}
+
+// neutralDefault is the default value of a column in a form that belongs to no dialect.
+type neutralDefault struct {
+	Value string
+	// IsExpression is true for defaults that are computed, e.g. `CURRENT_TIMESTAMP`, rather than literal values.
+	IsExpression bool
+}
+
+var (
+	postgresCastRegex     = regexp.MustCompile(`::[a-zA-Z_][a-zA-Z0-9_ ]*(\(\d+(,\s*\d+)?\))?(\[\])?$`)
+	currentTimestampRegex = regexp.MustCompile(`(?i)^(CURRENT_TIMESTAMP|now|LOCALTIMESTAMP)\s*(\(\s*\d*\s*\))?$`)
+	postgresSequenceRegex = regexp.MustCompile(`(?i)^nextval\(`)
+	numericLiteralRegex   = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][-+]?\d+)?$`)
+	mysqlBitLiteralRegex  = regexp.MustCompile(`^b'([01]+)'$`)
+	simpleIdentifierRegex = regexp.MustCompile(`^[a-z_][a-z0-9_$]*$`)
+	quotedIdentifierRegex = regexp.MustCompile("^(`[^`]*`|\"[^\"]*\")$")
+)
+
+// parseNeutralDefault reads the default value of a column as reported by Mysql or Postgres. It is nil for columns
+// without a default, and for Postgres columns whose default comes from a sequence, which are auto incremented.
+func parseNeutralDefault(dialect string, columnType neutralType, rawDefault *string, extra string) *neutralDefault {
+	if rawDefault == nil {
+		return nil
+	}
+
+	value := strings.TrimSpace(*rawDefault)
+	if strings.EqualFold(value, "NULL") || postgresSequenceRegex.MatchString(value) {
+		return nil
+	}
+
+	ret := &neutralDefault{}
+
+	if dialect == "postgres" || dialect == "cockroachdb" {
+		// e.g. `'draft'::character varying` or `(-1)`.
+		for postgresCastRegex.MatchString(value) {
+			value = strings.TrimSpace(postgresCastRegex.ReplaceAllString(value, ""))
+		}
+		if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") && numericLiteralRegex.MatchString(value[1:len(value)-1]) {
+			value = value[1 : len(value)-1]
+		}
+
+		if strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") && len(value) >= 2 {
+			ret.Value = strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		} else if numericLiteralRegex.MatchString(value) || strings.EqualFold(value, "true") || strings.EqualFold(value, "false") {
+			ret.Value = strings.ToLower(value)
+		} else {
+			ret.Value, ret.IsExpression = value, true
+		}
+	} else {
+		// Mysql reports literals unquoted and flags expressions with DEFAULT_GENERATED, while MariaDB quotes its literals.
+		if strings.Contains(extra, "DEFAULT_GENERATED") || (dialect == "mariadb" && !strings.HasPrefix(value, "'") && !numericLiteralRegex.MatchString(value)) {
+			ret.Value, ret.IsExpression = value, true
+		} else if strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") && len(value) >= 2 {
+			ret.Value = strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+		} else if matches := mysqlBitLiteralRegex.FindStringSubmatch(value); matches != nil {
+			ret.Value = matches[1]
+		} else {
+			ret.Value = value
+		}
+
+		// Mysql reports `CURRENT_TIMESTAMP` as a literal on versions that don't flag expressions.
+		if currentTimestampRegex.MatchString(value) {
+			ret.IsExpression = true
+		}
+	}
+
+	if ret.IsExpression && currentTimestampRegex.MatchString(ret.Value) {
+		ret.Value = "CURRENT_TIMESTAMP"
+	}
+
+	if columnType.Kind == "boolean" && !ret.IsExpression {
+		if ret.Value == "1" || ret.Value == "true" {
+			ret.Value = "true"
+		} else if ret.Value == "0" || ret.Value == "false" {
+			ret.Value = "false"
+		}
+	}
+
+	return ret
+}
+
+// formatNeutralDefault writes a default value in the given dialect. Expressions other than `CURRENT_TIMESTAMP` are
+// copied as they are, and come with a message describing the mismatch.
+func formatNeutralDefault(dialect string, columnType neutralType, columnDefault neutralDefault) (string, string) {
+	mismatch := ""
+	if columnDefault.IsExpression && columnDefault.Value != "CURRENT_TIMESTAMP" {
+		mismatch = "the default " + columnDefault.Value + " is an expression that is copied as it is"
+	}
+
+	if dialect == "mysql" || dialect == "mariadb" {
+		if columnType.Kind == "boolean" && !columnDefault.IsExpression {
+			return utils.Ternary(columnDefault.Value == "true", "1", "0"), mismatch
+		}
+
+		// Mysql requires the precision of `CURRENT_TIMESTAMP` to match the one of the column.
+		if columnDefault.Value == "CURRENT_TIMESTAMP" && columnType.Precision > 0 && (columnType.Kind == "timestamp" || columnType.Kind == "timestamptz") {
+			return "CURRENT_TIMESTAMP(" + strconv.FormatInt(columnType.Precision, 10) + ")", mismatch
+		}
+
+		// The value is quoted by the sequelizer based on the type of the column, just like defaults read from Mysql.
+		return columnDefault.Value, mismatch
+	}
+
+	isNumeric := columnType.Kind == "integer" || columnType.Kind == "decimal" || columnType.Kind == "float" || columnType.Kind == "boolean"
+	if columnDefault.IsExpression || (isNumeric && numericLiteralRegex.MatchString(columnDefault.Value)) || columnType.Kind == "boolean" {
+		return columnDefault.Value, mismatch
+	}
+
+	return "'" + strings.ReplaceAll(columnDefault.Value, "'", "''") + "'", mismatch
+}
+
+// isSameNeutralDefault tells whether two defaults give a column the same value.
+func isSameNeutralDefault(first *neutralDefault, second *neutralDefault) bool {
+	if first == nil || second == nil {
+		return first == nil && second == nil
+	}
+
+	if first.IsExpression != second.IsExpression {
+		return false
+	}
+
+	if first.IsExpression {
+		return strings.EqualFold(first.Value, second.Value)
+	}
+
+	// Numbers are compared by value. e.g. `0.00` in Mysql and `0` in Postgres.
+	firstNumber, firstErr := strconv.ParseFloat(first.Value, 64)
+	secondNumber, secondErr := strconv.ParseFloat(second.Value, 64)
+	if firstErr == nil && secondErr == nil {
+		return firstNumber == secondNumber
+	}
+
+	return first.Value == second.Value
+}
+
+// unquoteIdentifier removes the backticks or double quotes around an identifier.
+func unquoteIdentifier(identifier string) string {
+	identifier = strings.TrimSpace(identifier)
+	if quotedIdentifierRegex.MatchString(identifier) {
+		quote := identifier[:1]
+
+		return strings.ReplaceAll(identifier[1:len(identifier)-1], quote+quote, quote)
+	}
+
+	return identifier
+}
+
+// quotePostgresIdentifierIfNeeded quotes an identifier the way Postgres does in the definitions it reports: only when
+// it isn't all lower case letters, digits and underscores.
+func quotePostgresIdentifierIfNeeded(identifier string) string {
+	if simpleIdentifierRegex.MatchString(identifier) {
+		return identifier
+	}
+
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
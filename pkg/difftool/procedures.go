@@ -1,94 +1,51 @@
 package difftool
 
 import (
-	"fmt"
+	"slices"
 
-	"github.com/Okira-E/patchi/pkg/types"
-	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/schema"
 )
 
-type procedureDiff struct {
-	ProcedureName string
-	// DiffType represents the type of change that has occurred to the entity.
-	// 0 -> Deleted.
-	// 1 -> Created.
-	DiffType int8
+// ProcedureDiff is a procedure out of sync between two schemas.
+type ProcedureDiff struct {
+	ProcedureName string `json:"procedure_name" yaml:"procedure_name"`
+	// DiffType represents the type of change that has occurred to the entity (deleted, created or modified.)
+	DiffType DiffType `json:"diff_type" yaml:"diff_type"`
 }
 
-// GetProceduresDiff returns the procedures out of sync between two databases.
-func GetProceduresDiff(firstDb types.DbConnection, secondDb types.DbConnection, dialect string) []procedureDiff {
-	ret := []procedureDiff{}
+// GetProceduresDiff returns the procedures out of sync between two schemas. Procedures matching ignoreRules are left out.
+func GetProceduresDiff(firstSchema *schema.Schema, secondSchema *schema.Schema, ignoreRules *IgnoreRules) []ProcedureDiff {
+	ret := []ProcedureDiff{}
 
-	proceduresInFirstDb := getAllProceduresNamesInDb(firstDb, dialect)
-	proceduresInSecondDb := getAllProceduresNamesInDb(secondDb, dialect)
-
-	// Compare the two arrays of procedure names and return the difference.
 	// Procedures that exist in the first database but not in the second database must have been created.
 	// Procedures that exist in the second database but not in the first database must have been deleted.
+	// Procedures that exist in both databases but whose definitions differ must have been modified.
 
-	firstDbProceduresBookKeeping := map[string]bool{}
-	for _, procedureName := range proceduresInFirstDb {
-		firstDbProceduresBookKeeping[procedureName] = true
-	}
-
-	secondDbProceduresBookKeeping := map[string]bool{}
-	for _, procedureName := range proceduresInSecondDb {
-		secondDbProceduresBookKeeping[procedureName] = true
-	}
-
-	for _, procedureName := range proceduresInFirstDb {
-		if _, ok := secondDbProceduresBookKeeping[procedureName]; !ok {
-			ret = append(ret, procedureDiff{
+	for procedureName, firstProcedure := range firstSchema.Procedures {
+		secondProcedure, ok := secondSchema.Procedures[procedureName]
+		if !ok {
+			ret = append(ret, ProcedureDiff{
 				ProcedureName: procedureName,
-				DiffType:  1,
+				DiffType:      Created,
 			})
-		}
-	}
-
-	for _, procedureName := range proceduresInSecondDb {
-		if _, ok := firstDbProceduresBookKeeping[procedureName]; !ok {
-			ret = append(ret, procedureDiff{
+		} else if normalizeDefinition(firstProcedure.Definition, firstSchema.Dialect) != normalizeDefinition(secondProcedure.Definition, secondSchema.Dialect) {
+			ret = append(ret, ProcedureDiff{
 				ProcedureName: procedureName,
-				DiffType:  0,
+				DiffType:      Modified,
 			})
 		}
 	}
 
-	return ret
-}
-
-// getAllProceduresNamesInDb returns an array of procedure names retrieved from given database connection.
-func getAllProceduresNamesInDb(db types.DbConnection, dialect string) []string {
-	ret := []string{}
-
-	if dialect == "mysql" || dialect == "mariadb" {
-		ret = getAllProceduresNamesInMysql(db, dialect)
-	} else if dialect == "postgres" || dialect == "cockroachdb" {
-		utils.AbortTui("UNIMPLEMENTED")
-	}
-	return ret
-}
-
-func getAllProceduresNamesInMysql(db types.DbConnection, dialect string) []string {
-	ret := []string{}
-
-	rows, err := db.SqlConnection.Query(
-		"SELECT ROUTINE_NAME FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA = ? AND ROUTINE_TYPE = 'PROCEDURE'",
-		db.Info.DatabaseName,
-	)
-	if err != nil {
-		utils.Abort(fmt.Sprintf("Error querying database: %s", err.Error()))
-	}
-
-	for rows.Next() {
-		var procedureName string
-		err := rows.Scan(&procedureName)
-		if err != nil {
-			utils.Abort(fmt.Sprintf("Error scanning row: %s", err.Error()))
+	for procedureName := range secondSchema.Procedures {
+		if _, ok := firstSchema.Procedures[procedureName]; !ok {
+			ret = append(ret, ProcedureDiff{
+				ProcedureName: procedureName,
+				DiffType:      Deleted,
+			})
 		}
-
-		ret = append(ret, procedureName)
 	}
 
-	return ret
+	return slices.DeleteFunc(ret, func(diff ProcedureDiff) bool {
+		return ignoreRules.isIgnored("procedures", "", diff.ProcedureName)
+	})
 }
@@ -0,0 +1,206 @@
+package difftool
+
+import (
+	"sort"
+
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/utils"
+)
+
+const (
+	// minTableRenameScore is the lowest score a pair of tables needs to be considered a rename.
+	minTableRenameScore = 0.7
+	// minColumnRenameScore is the lowest score a pair of columns needs to be considered a rename.
+	minColumnRenameScore = 0.6
+	// minColumnNameSimilarity is the lowest similarity the names of a pair of columns need to be considered a rename,
+	// so that two unrelated columns of the same type at the same position (e.g. `email` and `phone`) aren't paired.
+	minColumnNameSimilarity = 0.3
+)
+
+// RenameCandidate is a deleted entity and a created entity that are likely the same entity under a new name. Only
+// tables and columns are checked for renames.
+type RenameCandidate struct {
+	// EntityType is either tables or columns.
+	EntityType string `json:"entity_type" yaml:"entity_type"`
+	// The table the column belongs to. Empty for tables.
+	TableName string `json:"table_name,omitempty" yaml:"table_name,omitempty"`
+	// OldName is the name of the entity in the second database.
+	OldName string `json:"old_name" yaml:"old_name"`
+	// NewName is the name of the entity in the first database.
+	NewName string `json:"new_name" yaml:"new_name"`
+	// Score is how likely the pair is a rename, between 0 and 1.
+	Score float64 `json:"score" yaml:"score"`
+	// ModifiedProperties holds the properties of a renamed column that changed along with its name.
+	ModifiedProperties []string `json:"modified_properties,omitempty" yaml:"modified_properties,omitempty"`
+}
+
+// Key identifies the candidate among the others.
+func (self RenameCandidate) Key() string {
+	return self.EntityType + ":" + self.TableName + ":" + self.OldName + ":" + self.NewName
+}
+
+// GetRenameCandidates pairs the deleted tables and columns with the created ones that look the most like them. Tables
+// are paired based on their columns, and columns based on their type, position and properties. The similarity of
+// the names is used on top of that. Every entity is part of one candidate at most.
+func GetRenameCandidates(firstSchema *schema.Schema, secondSchema *schema.Schema, ignoreRules *IgnoreRules) []RenameCandidate {
+	ret := []RenameCandidate{}
+
+	createdTables, deletedTables := []string{}, []string{}
+	for _, diff := range GetTablesDiff(firstSchema, secondSchema, ignoreRules) {
+		if diff.DiffType == Created {
+			createdTables = append(createdTables, diff.TableName)
+		} else if diff.DiffType == Deleted {
+			deletedTables = append(deletedTables, diff.TableName)
+		}
+	}
+
+	tableCandidates := []RenameCandidate{}
+	for _, newName := range createdTables {
+		for _, oldName := range deletedTables {
+			score := getTableRenameScore(firstSchema.Tables[newName], secondSchema.Tables[oldName])
+			if score >= minTableRenameScore {
+				tableCandidates = append(tableCandidates, RenameCandidate{EntityType: "tables", OldName: oldName, NewName: newName, Score: score})
+			}
+		}
+	}
+	ret = append(ret, pickBestRenameCandidates(tableCandidates)...)
+
+	// Columns are grouped by their table since a column can only be renamed within its table.
+	createdColumns, deletedColumns := map[string][]string{}, map[string][]string{}
+	for _, diff := range GetColumnsDiff(firstSchema, secondSchema, ignoreRules) {
+		if diff.DiffType == Created {
+			createdColumns[diff.TableName] = append(createdColumns[diff.TableName], diff.ColumnName)
+		} else if diff.DiffType == Deleted {
+			deletedColumns[diff.TableName] = append(deletedColumns[diff.TableName], diff.ColumnName)
+		}
+	}
+
+	for tableName, newNames := range createdColumns {
+		columnCandidates := []RenameCandidate{}
+
+		for _, newName := range newNames {
+			for _, oldName := range deletedColumns[tableName] {
+				newColumnOpt := firstSchema.Tables[tableName].GetColumn(newName)
+				oldColumnOpt := secondSchema.Tables[tableName].GetColumn(oldName)
+				newColumn, oldColumn := newColumnOpt.Unwrap(), oldColumnOpt.Unwrap()
+
+				score := getColumnRenameScore(newColumn, oldColumn)
+				if score >= minColumnRenameScore {
+					columnCandidates = append(columnCandidates, RenameCandidate{
+						EntityType:         "columns",
+						TableName:          tableName,
+						OldName:            oldName,
+						NewName:            newName,
+						Score:              score,
+						ModifiedProperties: GetModifiedColumnProperties(newColumn, oldColumn),
+					})
+				}
+			}
+		}
+
+		ret = append(ret, pickBestRenameCandidates(columnCandidates)...)
+	}
+
+	return ret
+}
+
+// getTableRenameScore scores two tables by the share of their columns that match by name and type. Tables without
+// columns are never considered a rename.
+func getTableRenameScore(newTable *schema.Table, oldTable *schema.Table) float64 {
+	if len(newTable.Columns) == 0 || len(oldTable.Columns) == 0 {
+		return 0
+	}
+
+	matchingColumns := 0
+	for _, newColumn := range newTable.Columns {
+		oldColumnOpt := oldTable.GetColumn(newColumn.Name)
+		if oldColumnOpt.IsSome() && oldColumnOpt.Unwrap().Type == newColumn.Type {
+			matchingColumns += 1
+		}
+	}
+
+	columnsScore := float64(matchingColumns) / float64(utils.Ternary(len(newTable.Columns) > len(oldTable.Columns), len(newTable.Columns), len(oldTable.Columns)))
+
+	return 0.8*columnsScore + 0.2*getNameSimilarity(newTable.Name, oldTable.Name)
+}
+
+// getColumnRenameScore scores two columns of the same table. Columns of different types, or whose names have little in
+// common, are never considered a rename.
+func getColumnRenameScore(newColumn *schema.Column, oldColumn *schema.Column) float64 {
+	nameSimilarity := getNameSimilarity(newColumn.Name, oldColumn.Name)
+	if newColumn.Type != oldColumn.Type || nameSimilarity < minColumnNameSimilarity {
+		return 0
+	}
+
+	score := 0.4
+	if newColumn.OrdinalPosition == oldColumn.OrdinalPosition {
+		score += 0.3
+	}
+	if newColumn.IsNullable == oldColumn.IsNullable && equalNullableStrings(newColumn.Default, oldColumn.Default) {
+		score += 0.1
+	}
+
+	return score + 0.2*nameSimilarity
+}
+
+// pickBestRenameCandidates keeps the candidates with the highest scores such that every entity is renamed once at most.
+func pickBestRenameCandidates(candidates []RenameCandidate) []RenameCandidate {
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+
+		return candidates[i].Key() < candidates[j].Key()
+	})
+
+	ret := []RenameCandidate{}
+	usedOldNames, usedNewNames := map[string]bool{}, map[string]bool{}
+	for _, candidate := range candidates {
+		if usedOldNames[candidate.OldName] || usedNewNames[candidate.NewName] {
+			continue
+		}
+
+		usedOldNames[candidate.OldName] = true
+		usedNewNames[candidate.NewName] = true
+		ret = append(ret, candidate)
+	}
+
+	return ret
+}
+
+// getNameSimilarity returns how similar two names are based on their edit distance, between 0 and 1.
+func getNameSimilarity(first string, second string) float64 {
+	firstRunes, secondRunes := []rune(first), []rune(second)
+	if len(firstRunes) == 0 && len(secondRunes) == 0 {
+		return 1
+	}
+
+	// The Levenshtein distance, keeping only the previous row of the matrix.
+	previous := make([]int, len(secondRunes)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(firstRunes); i += 1 {
+		current := make([]int, len(secondRunes)+1)
+		current[0] = i
+
+		for j := 1; j <= len(secondRunes); j += 1 {
+			substitutionCost := 1
+			if firstRunes[i-1] == secondRunes[j-1] {
+				substitutionCost = 0
+			}
+
+			current[j] = previous[j-1] + substitutionCost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+
+		previous = current
+	}
+
+	return 1 - float64(previous[len(secondRunes)])/float64(utils.Ternary(len(firstRunes) > len(secondRunes), len(firstRunes), len(secondRunes)))
+}
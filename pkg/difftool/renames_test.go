@@ -0,0 +1,156 @@
+package difftool
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+func TestGetNameSimilarity(t *testing.T) {
+	tests := []struct {
+		first    string
+		second   string
+		expected float64
+	}{
+		{"", "", 1},
+		{"email", "email", 1},
+		{"email", "phone", 0},
+		{"fname", "first_name", 0.5},
+		{"user", "users", 0.8},
+		{"naïve", "naive", 0.8},
+	}
+
+	for _, test := range tests {
+		if similarity := getNameSimilarity(test.first, test.second); math.Abs(similarity-test.expected) > 1e-9 {
+			t.Errorf("%s, %s: got %f, expected %f", test.first, test.second, similarity, test.expected)
+		}
+	}
+}
+
+func TestGetColumnRenameScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		newColumn schema.Column
+		oldColumn schema.Column
+		isRename  bool
+	}{
+		{
+			name:      "similar name, same type and position",
+			newColumn: schema.Column{Name: "email_address", Type: "text", OrdinalPosition: 2},
+			oldColumn: schema.Column{Name: "email", Type: "text", OrdinalPosition: 2},
+			isRename:  true,
+		},
+		{
+			name:      "similar name at another position",
+			newColumn: schema.Column{Name: "first_name", Type: "text", OrdinalPosition: 3},
+			oldColumn: schema.Column{Name: "fname", Type: "text", OrdinalPosition: 2},
+			isRename:  true,
+		},
+		{
+			name:      "unrelated name, same type and position",
+			newColumn: schema.Column{Name: "phone", Type: "text", OrdinalPosition: 2},
+			oldColumn: schema.Column{Name: "email", Type: "text", OrdinalPosition: 2},
+			isRename:  false,
+		},
+		{
+			name:      "same name of another type",
+			newColumn: schema.Column{Name: "email", Type: "integer", OrdinalPosition: 2},
+			oldColumn: schema.Column{Name: "email", Type: "text", OrdinalPosition: 2},
+			isRename:  false,
+		},
+		{
+			name:      "similar name at another position with other properties",
+			newColumn: schema.Column{Name: "city_name", Type: "text", OrdinalPosition: 4, IsNullable: true},
+			oldColumn: schema.Column{Name: "city", Type: "text", OrdinalPosition: 2},
+			isRename:  false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			score := getColumnRenameScore(&test.newColumn, &test.oldColumn)
+			if isRename := score >= minColumnRenameScore; isRename != test.isRename {
+				t.Errorf("got a score of %f, expected it to be a rename: %t", score, test.isRename)
+			}
+		})
+	}
+}
+
+func TestGetTableRenameScore(t *testing.T) {
+	columns := func(names ...string) []*schema.Column {
+		ret := []*schema.Column{}
+		for _, name := range names {
+			ret = append(ret, &schema.Column{Name: name, Type: "text"})
+		}
+
+		return ret
+	}
+
+	tests := []struct {
+		name     string
+		newTable *schema.Table
+		oldTable *schema.Table
+		isRename bool
+	}{
+		{
+			name:     "same columns",
+			newTable: &schema.Table{Name: "customers", Columns: columns("id", "email", "name")},
+			oldTable: &schema.Table{Name: "users", Columns: columns("id", "email", "name")},
+			isRename: true,
+		},
+		{
+			name:     "most columns differ",
+			newTable: &schema.Table{Name: "users_v2", Columns: columns("id", "login", "full_name")},
+			oldTable: &schema.Table{Name: "users", Columns: columns("id", "email", "name")},
+			isRename: false,
+		},
+		{
+			name:     "no columns",
+			newTable: &schema.Table{Name: "users_v2"},
+			oldTable: &schema.Table{Name: "users"},
+			isRename: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			score := getTableRenameScore(test.newTable, test.oldTable)
+			if isRename := score >= minTableRenameScore; isRename != test.isRename {
+				t.Errorf("got a score of %f, expected it to be a rename: %t", score, test.isRename)
+			}
+		})
+	}
+}
+
+func TestGetRenameCandidates(t *testing.T) {
+	firstSchema := testutil.NewSchema("postgres", &schema.Table{Name: "users", Columns: []*schema.Column{
+		{Name: "id", Type: "integer", OrdinalPosition: 1},
+		{Name: "email_address", Type: "text", OrdinalPosition: 2},
+		{Name: "phone", Type: "text", OrdinalPosition: 3, IsNullable: true},
+	}})
+	secondSchema := testutil.NewSchema("postgres", &schema.Table{Name: "users", Columns: []*schema.Column{
+		{Name: "id", Type: "integer", OrdinalPosition: 1},
+		{Name: "email", Type: "text", OrdinalPosition: 2},
+		{Name: "fax", Type: "text", OrdinalPosition: 3, IsNullable: true},
+	}})
+
+	candidates := GetRenameCandidates(firstSchema, secondSchema, nil)
+	if len(candidates) != 1 || candidates[0].OldName != "email" || candidates[0].NewName != "email_address" {
+		t.Errorf("got %+v, expected only email ⇢ email_address", candidates)
+	}
+}
+
+func TestPickBestRenameCandidates(t *testing.T) {
+	candidates := pickBestRenameCandidates([]RenameCandidate{
+		{EntityType: "columns", OldName: "a", NewName: "x", Score: 0.7},
+		{EntityType: "columns", OldName: "a", NewName: "y", Score: 0.9},
+		{EntityType: "columns", OldName: "b", NewName: "y", Score: 0.8},
+		{EntityType: "columns", OldName: "b", NewName: "x", Score: 0.6},
+	})
+
+	if len(candidates) != 2 || candidates[0].Key() != "columns::a:y" || candidates[1].Key() != "columns::b:x" {
+		t.Errorf("got %+v, expected a ⇢ y and b ⇢ x", candidates)
+	}
+}
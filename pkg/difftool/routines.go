@@ -0,0 +1,96 @@
+package difftool
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/types"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// loadRoutinesFromMysql reads the procedures and functions of the database along with the statements that create them.
+func loadRoutinesFromMysql(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	rows, err := db.SqlConnection.QueryContext(
+		ctx,
+		"SELECT ROUTINE_NAME, ROUTINE_TYPE FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA = ?",
+		db.Info.DatabaseName,
+	)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var routineName, routineType string
+		if err := rows.Scan(&routineName, &routineType); err != nil {
+			return safego.Some(err)
+		}
+
+		if routineType == "PROCEDURE" {
+			ret.Procedures[routineName] = &schema.Routine{Name: routineName}
+		} else if routineType == "FUNCTION" {
+			ret.Functions[routineName] = &schema.Routine{Name: routineName}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	for procedureName, procedure := range ret.Procedures {
+		definition, errOpt := showCreate(ctx, db, "SHOW CREATE PROCEDURE "+quoteMysqlIdentifier(procedureName), "Create Procedure")
+		if errOpt.IsSome() {
+			return errOpt
+		}
+
+		procedure.Definition = definition
+	}
+
+	for functionName, function := range ret.Functions {
+		definition, errOpt := showCreate(ctx, db, "SHOW CREATE FUNCTION "+quoteMysqlIdentifier(functionName), "Create Function")
+		if errOpt.IsSome() {
+			return errOpt
+		}
+
+		function.Definition = definition
+	}
+
+	return safego.None[error]()
+}
+
+// Since Postgres allows overloading, the name of each routine includes its identity arguments. e.g. `add(a integer, b integer)`.
+func loadRoutinesFromPostgres(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	rows, err := db.SqlConnection.QueryContext(ctx, `
+		SELECT p.proname || '(' || pg_get_function_identity_arguments(p.oid) || ')',
+		       p.prokind,
+		       pg_get_functiondef(p.oid)
+		FROM pg_catalog.pg_proc p
+		JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = current_schema() AND p.prokind IN ('p', 'f')
+	`)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var routineName, routineKind, definition string
+		if err := rows.Scan(&routineName, &routineKind, &definition); err != nil {
+			return safego.Some(err)
+		}
+
+		routine := &schema.Routine{Name: routineName, Definition: strings.TrimSpace(definition)}
+		if routineKind == "p" {
+			ret.Procedures[routineName] = routine
+		} else {
+			ret.Functions[routineName] = routine
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	return safego.None[error]()
+}
@@ -0,0 +1,125 @@
+package difftool
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+// SchemaDiff holds the diff of every type of entity between two databases.
+type SchemaDiff struct {
+	Tables      []TableDiff      `json:"tables" yaml:"tables"`
+	Columns     []ColumnDiff     `json:"columns" yaml:"columns"`
+	Indexes     []IndexDiff      `json:"indexes" yaml:"indexes"`
+	Constraints []ConstraintDiff `json:"constraints" yaml:"constraints"`
+	Views       []ViewDiff       `json:"views" yaml:"views"`
+	Procedures  []ProcedureDiff  `json:"procedures" yaml:"procedures"`
+	Functions   []FunctionDiff   `json:"functions" yaml:"functions"`
+	Triggers    []TriggerDiff    `json:"triggers" yaml:"triggers"`
+	// RenameCandidates holds the deleted and created tables and columns that are likely renames. They are still part of
+	// Tables and Columns until they are confirmed with ConfirmRenames.
+	RenameCandidates []RenameCandidate `json:"rename_candidates" yaml:"rename_candidates"`
+	// Renames holds the confirmed renames.
+	Renames []RenameCandidate `json:"renames" yaml:"renames"`
+	// DialectMismatches holds what didn't translate exactly when schemas of different dialects are compared. See
+	// TranslateSchema. They aren't changes and aren't counted as such.
+	DialectMismatches []DialectMismatch `json:"dialect_mismatches,omitempty" yaml:"dialect_mismatches,omitempty"`
+}
+
+// GetSchemaDiff runs every comparison between two schemas. Unlike the TUI, which fetches the diff of each tab on
+// demand, this is meant for the commands that need the whole picture at once. The results are sorted by name so that
+// the output is stable between runs.
+func GetSchemaDiff(firstSchema *schema.Schema, secondSchema *schema.Schema, ignoreRules *IgnoreRules) SchemaDiff {
+	var ret SchemaDiff
+
+	ret.Tables = GetTablesDiff(firstSchema, secondSchema, ignoreRules)
+	sort.Slice(ret.Tables, func(i, j int) bool {
+		return ret.Tables[i].TableName < ret.Tables[j].TableName
+	})
+
+	ret.Columns = GetColumnsDiff(firstSchema, secondSchema, ignoreRules)
+	sort.Slice(ret.Columns, func(i, j int) bool {
+		if ret.Columns[i].TableName != ret.Columns[j].TableName {
+			return ret.Columns[i].TableName < ret.Columns[j].TableName
+		}
+
+		return ret.Columns[i].ColumnName < ret.Columns[j].ColumnName
+	})
+
+	ret.Indexes = GetIndexesDiff(firstSchema, secondSchema, ignoreRules)
+	sort.Slice(ret.Indexes, func(i, j int) bool {
+		if ret.Indexes[i].TableName != ret.Indexes[j].TableName {
+			return ret.Indexes[i].TableName < ret.Indexes[j].TableName
+		}
+
+		return ret.Indexes[i].IndexName < ret.Indexes[j].IndexName
+	})
+
+	ret.Constraints = GetConstraintsDiff(firstSchema, secondSchema, ignoreRules)
+	sort.Slice(ret.Constraints, func(i, j int) bool {
+		if ret.Constraints[i].TableName != ret.Constraints[j].TableName {
+			return ret.Constraints[i].TableName < ret.Constraints[j].TableName
+		}
+
+		return ret.Constraints[i].ConstraintName < ret.Constraints[j].ConstraintName
+	})
+
+	ret.Views = GetViewsDiff(firstSchema, secondSchema, ignoreRules)
+	sort.Slice(ret.Views, func(i, j int) bool {
+		return ret.Views[i].ViewName < ret.Views[j].ViewName
+	})
+
+	ret.Procedures = GetProceduresDiff(firstSchema, secondSchema, ignoreRules)
+	sort.Slice(ret.Procedures, func(i, j int) bool {
+		return ret.Procedures[i].ProcedureName < ret.Procedures[j].ProcedureName
+	})
+
+	ret.Functions = GetFunctionsDiff(firstSchema, secondSchema, ignoreRules)
+	sort.Slice(ret.Functions, func(i, j int) bool {
+		return ret.Functions[i].FunctionName < ret.Functions[j].FunctionName
+	})
+
+	ret.Triggers = GetTriggersDiff(firstSchema, secondSchema, ignoreRules)
+	sort.Slice(ret.Triggers, func(i, j int) bool {
+		return ret.Triggers[i].TriggerName < ret.Triggers[j].TriggerName
+	})
+
+	ret.RenameCandidates = GetRenameCandidates(firstSchema, secondSchema, ignoreRules)
+	sort.Slice(ret.RenameCandidates, func(i, j int) bool {
+		return ret.RenameCandidates[i].Key() < ret.RenameCandidates[j].Key()
+	})
+
+	ret.Renames = []RenameCandidate{}
+
+	return ret
+}
+
+// ConfirmRenames turns rename candidates into renames. The deleted and created entities of each rename are taken out
+// of the diff, so they are renamed instead of being dropped and created again. renames may be RenameCandidates
+// itself, which shrinks as they are confirmed, so a copy of it is iterated.
+func (self *SchemaDiff) ConfirmRenames(renames []RenameCandidate) {
+	for _, rename := range slices.Clone(renames) {
+		if rename.EntityType == "tables" {
+			self.Tables = slices.DeleteFunc(self.Tables, func(diff TableDiff) bool {
+				return (diff.DiffType == Deleted && diff.TableName == rename.OldName) || (diff.DiffType == Created && diff.TableName == rename.NewName)
+			})
+		} else if rename.EntityType == "columns" {
+			self.Columns = slices.DeleteFunc(self.Columns, func(diff ColumnDiff) bool {
+				return diff.TableName == rename.TableName &&
+					((diff.DiffType == Deleted && diff.ColumnName == rename.OldName) || (diff.DiffType == Created && diff.ColumnName == rename.NewName))
+			})
+		}
+
+		self.RenameCandidates = slices.DeleteFunc(self.RenameCandidates, func(candidate RenameCandidate) bool {
+			return candidate.Key() == rename.Key()
+		})
+		self.Renames = append(self.Renames, rename)
+	}
+}
+
+// Count returns the total number of changes across all entity types.
+func (self *SchemaDiff) Count() int {
+	return len(self.Tables) + len(self.Columns) + len(self.Indexes) + len(self.Constraints) +
+		len(self.Views) + len(self.Procedures) + len(self.Functions) + len(self.Triggers) + len(self.Renames)
+}
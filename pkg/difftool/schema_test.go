@@ -0,0 +1,109 @@
+package difftool
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"gopkg.in/yaml.v3"
+)
+
+func TestGetSchemaDiffIsSortedAndCounted(t *testing.T) {
+	firstSchema := testutil.NewSchema("mysql",
+		&schema.Table{Name: "b_table", Columns: []*schema.Column{{Name: "id", Type: "int"}}},
+		&schema.Table{Name: "a_table", Columns: []*schema.Column{{Name: "id", Type: "bigint"}, {Name: "name", Type: "text"}}},
+	)
+	secondSchema := testutil.NewSchema("mysql",
+		&schema.Table{Name: "a_table", Columns: []*schema.Column{{Name: "id", Type: "int"}}},
+		&schema.Table{Name: "c_table", Columns: []*schema.Column{{Name: "id", Type: "int"}}},
+	)
+
+	schemaDiff := GetSchemaDiff(firstSchema, secondSchema, nil)
+
+	if schemaDiff.Count() != 4 {
+		t.Errorf("got %d changes, expected 4: %+v", schemaDiff.Count(), schemaDiff)
+	}
+
+	tableNames := []string{}
+	for _, diff := range schemaDiff.Tables {
+		tableNames = append(tableNames, diff.TableName+" "+diff.DiffType.String())
+	}
+	if strings.Join(tableNames, ", ") != "b_table created, c_table deleted" {
+		t.Errorf("got tables %v", tableNames)
+	}
+
+	columnNames := []string{}
+	for _, diff := range schemaDiff.Columns {
+		columnNames = append(columnNames, diff.ColumnName+" "+diff.DiffType.String())
+	}
+	if strings.Join(columnNames, ", ") != "id modified, name created" {
+		t.Errorf("got columns %v", columnNames)
+	}
+
+	// Confirmed renames count as a single change instead of a deletion and a creation.
+	schemaDiff.ConfirmRenames([]RenameCandidate{{EntityType: "tables", OldName: "c_table", NewName: "b_table"}})
+	if schemaDiff.Count() != 3 || len(schemaDiff.Tables) != 0 {
+		t.Errorf("got %d changes after the rename, expected 3: %+v", schemaDiff.Count(), schemaDiff)
+	}
+}
+
+func TestDiffTypeIsMarshalledByName(t *testing.T) {
+	diff := ColumnDiff{TableName: "users", ColumnName: "email", DiffType: Modified, ModifiedProperties: []string{"type"}}
+
+	jsonOutput, err := json.Marshal(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(jsonOutput), `"diff_type":"modified"`) {
+		t.Errorf("got %s", jsonOutput)
+	}
+
+	yamlOutput, err := yaml.Marshal(TableDiff{TableName: "users", DiffType: Deleted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(yamlOutput), "diff_type: deleted") {
+		t.Errorf("got %s", yamlOutput)
+	}
+}
+
+func TestConfirmRenamesOfAllCandidates(t *testing.T) {
+	columns := func(names ...string) []*schema.Column {
+		ret := []*schema.Column{}
+		for i, name := range names {
+			ret = append(ret, &schema.Column{Name: name, OrdinalPosition: i + 1, Type: "int"})
+		}
+
+		return ret
+	}
+
+	firstSchema := testutil.NewSchema("mysql",
+		&schema.Table{Name: "orders_new", Columns: columns("id", "order_total", "customer_id")},
+		&schema.Table{Name: "users_new", Columns: columns("id", "login_count", "invite_count")},
+	)
+	secondSchema := testutil.NewSchema("mysql",
+		&schema.Table{Name: "orders_old", Columns: columns("id", "order_total", "customer_id")},
+		&schema.Table{Name: "users_old", Columns: columns("id", "login_count", "invite_count")},
+	)
+
+	schemaDiff := GetSchemaDiff(firstSchema, secondSchema, nil)
+	if len(schemaDiff.RenameCandidates) != 2 {
+		t.Fatalf("got %d rename candidates, expected 2: %+v", len(schemaDiff.RenameCandidates), schemaDiff.RenameCandidates)
+	}
+
+	// The candidates are confirmed while they are taken out of the slice that is passed.
+	schemaDiff.ConfirmRenames(schemaDiff.RenameCandidates)
+
+	renames := []string{}
+	for _, rename := range schemaDiff.Renames {
+		renames = append(renames, rename.OldName+" ⇢ "+rename.NewName)
+	}
+	if strings.Join(renames, ", ") != "orders_old ⇢ orders_new, users_old ⇢ users_new" {
+		t.Errorf("got renames %v", renames)
+	}
+	if len(schemaDiff.Tables) != 0 || len(schemaDiff.RenameCandidates) != 0 {
+		t.Errorf("expected no tables or candidates left, got %+v and %+v", schemaDiff.Tables, schemaDiff.RenameCandidates)
+	}
+}
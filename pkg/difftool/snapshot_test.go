@@ -0,0 +1,80 @@
+package difftool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	sqliteDb := testutil.NewSqliteDb(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, email TEXT NOT NULL DEFAULT '', total INTEGER GENERATED ALWAYS AS (id * 2) VIRTUAL)",
+		"CREATE UNIQUE INDEX users_email ON users (email)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users (id) ON DELETE CASCADE, CHECK (id > 0))",
+		"CREATE VIEW user_orders AS SELECT users.email, orders.id FROM users JOIN orders ON orders.user_id = users.id",
+		"CREATE TRIGGER orders_audit AFTER INSERT ON orders BEGIN SELECT 1; END",
+	)
+	sqliteSchema, errOpt := LoadSchema(context.Background(), sqliteDb)
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+	if len(sqliteSchema.Tables) != 2 || len(sqliteSchema.Views) != 1 || len(sqliteSchema.Triggers) != 1 {
+		t.Fatalf("expected the whole schema to be loaded, got %+v", sqliteSchema)
+	}
+
+	mysqlSchema := testutil.NewSchema("mysql", &schema.Table{
+		Name:       "users",
+		Definition: "CREATE TABLE `users` (\n  `id` int NOT NULL AUTO_INCREMENT\n)",
+		Columns: []*schema.Column{
+			{Name: "id", OrdinalPosition: 1, Type: "int", Extra: "auto_increment"},
+			{Name: "name", OrdinalPosition: 2, Type: "varchar(20)", IsNullable: true, Default: testutil.StringPointer("a  b"), CharacterSet: testutil.StringPointer("utf8mb4"), Collation: testutil.StringPointer("utf8mb4_bin"), Comment: "it's"},
+			{Name: "label", OrdinalPosition: 3, Type: "varchar(40)", IsNullable: true, Extra: "STORED GENERATED", GenerationExpression: "concat(`name`,_utf8mb4' ')"},
+		},
+		Indexes: map[string]*schema.Index{
+			"users_name": {Name: "users_name", Type: "BTREE", Columns: []schema.IndexColumn{{ColumnName: "name", SubPart: 10, IsDesc: true}}},
+		},
+		Constraints: map[string]*schema.Constraint{
+			"PRIMARY": {Name: "PRIMARY", Type: "PRIMARY KEY", Columns: []string{"id"}},
+		},
+	})
+	mysqlSchema.Procedures["touch"] = &schema.Routine{Name: "touch", Definition: "CREATE PROCEDURE `touch`()\nBEGIN\n\tSELECT 1;\nEND"}
+	mysqlSchema.Functions["one"] = &schema.Routine{Name: "one", Definition: "CREATE FUNCTION `one`() RETURNS int\nRETURN 1"}
+
+	tests := []struct {
+		name     string
+		dbSchema *schema.Schema
+	}{
+		{name: "sqlite", dbSchema: sqliteSchema},
+		{name: "mysql", dbSchema: mysqlSchema},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, errOpt := schema.MarshalSnapshot(test.dbSchema)
+			if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+
+			filePath := filepath.Join(t.TempDir(), "snapshot.json")
+			if err := os.WriteFile(filePath, content, 0o600); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			snapshot, errOpt := schema.ReadSnapshot(filePath)
+			if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+
+			if schemaDiff := GetSchemaDiff(test.dbSchema, snapshot, nil); schemaDiff.Count() != 0 || len(schemaDiff.RenameCandidates) != 0 {
+				t.Errorf("expected no differences, got %+v", schemaDiff)
+			}
+			if schemaDiff := GetSchemaDiff(snapshot, test.dbSchema, nil); schemaDiff.Count() != 0 || len(schemaDiff.RenameCandidates) != 0 {
+				t.Errorf("expected no differences the other way around, got %+v", schemaDiff)
+			}
+		})
+	}
+}
@@ -1,94 +1,144 @@
 package difftool
 
 import (
-	"fmt"
+	"context"
+	"slices"
 
+	"github.com/Okira-E/patchi/pkg/schema"
 	"github.com/Okira-E/patchi/pkg/types"
-	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/safego"
 )
 
-type tableDiff struct {
-	TableName string
-	// DiffType represents the type of change that has occurred to the entity.
-	// 0 -> Deleted.
-	// 1 -> Created.
-	DiffType int8
+// TableDiff is a table that exists in only one of two schemas.
+type TableDiff struct {
+	TableName string `json:"table_name" yaml:"table_name"`
+	// DiffType represents the type of change that has occurred to the entity (deleted or created.)
+	DiffType DiffType `json:"diff_type" yaml:"diff_type"`
 }
 
-// GetTablesDiff returns the tables out of sync between two databases.
-func GetTablesDiff(firstDb types.DbConnection, secondDb types.DbConnection, dialect string) []tableDiff {
-	ret := []tableDiff{}
+// GetTablesDiff returns the tables out of sync between two schemas. Tables matching ignoreRules are left out.
+func GetTablesDiff(firstSchema *schema.Schema, secondSchema *schema.Schema, ignoreRules *IgnoreRules) []TableDiff {
+	ret := []TableDiff{}
 
-	tablesInFirstDb := getAllTablesNamesInDb(firstDb, dialect)
-	tablesInSecondDb := getAllTablesNamesInDb(secondDb, dialect)
-
-	// Compare the two arrays of table names and return the difference.
 	// Tables that exist in the first database but not in the second database must have been created.
 	// Tables that exist in the second database but not in the first database must have been deleted.
 
-	firstDbTablesBookKeeping := map[string]bool{}
-	for _, tableName := range tablesInFirstDb {
-		firstDbTablesBookKeeping[tableName] = true
-	}
-
-	secondDbTablesBookKeeping := map[string]bool{}
-	for _, tableName := range tablesInSecondDb {
-		secondDbTablesBookKeeping[tableName] = true
-	}
-
-	for _, tableName := range tablesInFirstDb {
-		if _, ok := secondDbTablesBookKeeping[tableName]; !ok {
-			ret = append(ret, tableDiff{
+	for tableName := range firstSchema.Tables {
+		if _, ok := secondSchema.Tables[tableName]; !ok {
+			ret = append(ret, TableDiff{
 				TableName: tableName,
-				DiffType:  1,
+				DiffType:  Created,
 			})
 		}
 	}
 
-	for _, tableName := range tablesInSecondDb {
-		if _, ok := firstDbTablesBookKeeping[tableName]; !ok {
-			ret = append(ret, tableDiff{
+	for tableName := range secondSchema.Tables {
+		if _, ok := firstSchema.Tables[tableName]; !ok {
+			ret = append(ret, TableDiff{
 				TableName: tableName,
-				DiffType:  0,
+				DiffType:  Deleted,
 			})
 		}
 	}
 
-	return ret
+	return slices.DeleteFunc(ret, func(diff TableDiff) bool {
+		return ignoreRules.isIgnored("tables", "", diff.TableName)
+	})
 }
 
-// getAllTablesNamesInDb returns an array of table names retrieved from given database connection.
-func getAllTablesNamesInDb(db types.DbConnection, dialect string) []string {
-	ret := []string{}
+// loadTablesFromMysql reads the tables of the database along with their `CREATE TABLE` statements.
+func loadTablesFromMysql(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	rows, err := db.SqlConnection.QueryContext(
+		ctx,
+		"SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'",
+		db.Info.DatabaseName,
+	)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
 
-	if dialect == "mysql" || dialect == "mariadb" {
-		ret = getAllTablesNamesInMysql(db, dialect)
-	} else if dialect == "postgres" || dialect == "cockroachdb" {
-		utils.AbortTui("UNIMPLEMENTED")
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			return safego.Some(err)
+		}
+
+		ret.Tables[tableName] = newTable(tableName)
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
 	}
-	return ret
-}
 
-func getAllTablesNamesInMysql(db types.DbConnection, dialect string) []string {
-	ret := []string{}
+	for tableName, table := range ret.Tables {
+		definition, errOpt := showCreate(ctx, db, "SHOW CREATE TABLE "+quoteMysqlIdentifier(tableName), "Create Table")
+		if errOpt.IsSome() {
+			return errOpt
+		}
 
-	rows, err := db.SqlConnection.Query(
-		"SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'",
-		db.Info.DatabaseName,
-	)
+		table.Definition = definition
+	}
+
+	return safego.None[error]()
+}
+
+func loadTablesFromPostgres(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	rows, err := db.SqlConnection.QueryContext(ctx, "SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'")
 	if err != nil {
-		utils.Abort(fmt.Sprintf("Error querying database: %s", err.Error()))
+		return safego.Some(err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var tableName string
-		err := rows.Scan(&tableName)
-		if err != nil {
-			utils.Abort(fmt.Sprintf("Error scanning row: %s", err.Error()))
+		if err := rows.Scan(&tableName); err != nil {
+			return safego.Some(err)
 		}
 
-		ret = append(ret, tableName)
+		ret.Tables[tableName] = newTable(tableName)
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	return safego.None[error]()
+}
+
+// loadTablesFromSqlite reads the tables of the database along with their `CREATE TABLE` statements. Sqlite's own
+// tables (e.g. sqlite_sequence) are left out.
+func loadTablesFromSqlite(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	rows, err := db.SqlConnection.QueryContext(ctx, "SELECT name, sql FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite\\_%' ESCAPE '\\'")
+	if err != nil {
+		return safego.Some(err)
 	}
+	defer rows.Close()
 
-	return ret
+	for rows.Next() {
+		var tableName, definition string
+		if err := rows.Scan(&tableName, &definition); err != nil {
+			return safego.Some(err)
+		}
+
+		table := newTable(tableName)
+		table.Definition = definition
+		ret.Tables[tableName] = table
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	return safego.None[error]()
+}
+
+// newTable creates an empty table with its maps initialized.
+func newTable(tableName string) *schema.Table {
+	return &schema.Table{
+		Name:        tableName,
+		Columns:     []*schema.Column{},
+		Indexes:     map[string]*schema.Index{},
+		Constraints: map[string]*schema.Constraint{},
+	}
 }
@@ -0,0 +1,545 @@
+package difftool
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/utils"
+)
+
+// DialectMismatch is something in a schema that can't be written the same way in the dialect it is compared with.
+// e.g. an enum turned into text, which no longer enforces its values.
+type DialectMismatch struct {
+	EntityType string `json:"entity_type" yaml:"entity_type"`
+	// EntityName is the name of the entity as shown in the TUI. e.g. `users` or `users → email`.
+	EntityName string `json:"entity_name" yaml:"entity_name"`
+	Message    string `json:"message" yaml:"message"`
+}
+
+// CanCompareDialects tells whether schemas of the two dialects can be compared. Apart from schemas of the same
+// dialect, a Mysql (or MariaDB) schema can be compared with a Postgres (or CockroachDB) one once it is translated
+// with TranslateSchema.
+func CanCompareDialects(firstDialect string, secondDialect string) bool {
+	if firstDialect == secondDialect {
+		return true
+	}
+
+	isFirstMysql := firstDialect == "mysql" || firstDialect == "mariadb"
+	isSecondMysql := secondDialect == "mysql" || secondDialect == "mariadb"
+	isFirstPostgres := firstDialect == "postgres" || firstDialect == "cockroachdb"
+	isSecondPostgres := secondDialect == "postgres" || secondDialect == "cockroachdb"
+
+	return (isFirstMysql && isSecondPostgres) || (isFirstPostgres && isSecondMysql)
+}
+
+// TranslateSchema writes the tables of sourceSchema in the dialect of targetSchema, so that the two can be compared and
+// the SQL that brings targetSchema in line with sourceSchema is generated in its own dialect. e.g. `AUTO_INCREMENT`
+// becomes `GENERATED BY DEFAULT AS IDENTITY` and `tinyint(1)` becomes `boolean`.
+//
+// Wherever a type, default, constraint or index means the same thing in both schemas, the one of targetSchema is kept
+// as it is, so that only actual differences show up in the diff. The constraints and indexes of both schemas are
+// matched by what they do rather than by their names, which are rarely the same across dialects. Views, routines and
+// triggers are written in the SQL of their dialect and can't be translated, so the ones of targetSchema are used and
+// they are left out of the comparison. Everything that doesn't translate exactly is returned as a mismatch.
+func TranslateSchema(sourceSchema *schema.Schema, targetSchema *schema.Schema) (*schema.Schema, []DialectMismatch) {
+	ret := schema.NewSchema(targetSchema.Dialect, sourceSchema.ConnectionName, sourceSchema.DatabaseName)
+	ret.CreatedAt = sourceSchema.CreatedAt
+
+	translator := &schemaTranslator{
+		sourceDialect: sourceSchema.Dialect,
+		targetDialect: targetSchema.Dialect,
+		mismatches:    []DialectMismatch{},
+	}
+
+	for _, tableName := range getSortedKeys(sourceSchema.Tables) {
+		ret.Tables[tableName] = translator.translateTable(sourceSchema.Tables[tableName], targetSchema.Tables[tableName])
+	}
+
+	ret.Views = targetSchema.Views
+	ret.Procedures = targetSchema.Procedures
+	ret.Functions = targetSchema.Functions
+	ret.Triggers = targetSchema.Triggers
+
+	message := "is written in the SQL of " + sourceSchema.Dialect + " and is left out of the comparison"
+	for _, viewName := range getSortedKeys(sourceSchema.Views) {
+		translator.addMismatch("views", viewName, "View "+message)
+	}
+	for _, procedureName := range getSortedKeys(sourceSchema.Procedures) {
+		translator.addMismatch("procedures", procedureName, "Procedure "+message)
+	}
+	for _, functionName := range getSortedKeys(sourceSchema.Functions) {
+		translator.addMismatch("functions", functionName, "Function "+message)
+	}
+	for _, triggerName := range getSortedKeys(sourceSchema.Triggers) {
+		translator.addMismatch("triggers", triggerName, "Trigger "+message)
+	}
+
+	return ret, translator.mismatches
+}
+
+// schemaTranslator holds the state of a translation between two dialects.
+type schemaTranslator struct {
+	sourceDialect string
+	targetDialect string
+	mismatches    []DialectMismatch
+}
+
+func (self *schemaTranslator) addMismatch(entityType string, entityName string, message string) {
+	self.mismatches = append(self.mismatches, DialectMismatch{EntityType: entityType, EntityName: entityName, Message: message})
+}
+
+func (self *schemaTranslator) isTargetMysql() bool {
+	return self.targetDialect == "mysql" || self.targetDialect == "mariadb"
+}
+
+// translateTable translates a table along with its columns, constraints and indexes. targetTable is the table of the
+// same name in the target schema, or nil if there is none. The definition of the table is left empty, so it is put
+// together from its parts when the table is created.
+func (self *schemaTranslator) translateTable(table *schema.Table, targetTable *schema.Table) *schema.Table {
+	ret := newTable(table.Name)
+
+	for _, column := range table.Columns {
+		var targetColumn *schema.Column
+		if targetTable != nil {
+			if targetColumnOpt := targetTable.GetColumn(column.Name); targetColumnOpt.IsSome() {
+				targetColumn = targetColumnOpt.Unwrap()
+			}
+		}
+
+		ret.Columns = append(ret.Columns, self.translateColumn(table.Name, column, targetColumn))
+	}
+
+	usedTargetNames := map[string]bool{}
+	for _, constraintName := range getSortedKeys(table.Constraints) {
+		constraint := self.translateConstraint(table.Name, table.Constraints[constraintName], targetTable, usedTargetNames)
+		if constraint != nil {
+			ret.Constraints[constraint.Name] = constraint
+		}
+	}
+
+	usedTargetNames = map[string]bool{}
+	for _, indexName := range getSortedKeys(table.Indexes) {
+		index := self.translateIndex(ret, table.Indexes[indexName], targetTable, usedTargetNames)
+		if index != nil {
+			ret.Indexes[index.Name] = index
+		}
+	}
+
+	return ret
+}
+
+// translateColumn translates the type, default and extra of a column. targetColumn is the column of the same name in
+// the target schema, or nil if there is none. Its character set and collation are kept since they can't be translated.
+func (self *schemaTranslator) translateColumn(tableName string, column *schema.Column, targetColumn *schema.Column) *schema.Column {
+	entityName := tableName + " → " + column.Name
+
+	ret := &schema.Column{
+		Name:            column.Name,
+		OrdinalPosition: column.OrdinalPosition,
+		IsNullable:      column.IsNullable,
+		Comment:         column.Comment,
+	}
+
+	columnType := parseNeutralType(self.sourceDialect, column.Type)
+	translatedType, mismatch := formatNeutralType(self.targetDialect, columnType)
+	if mismatch != "" {
+		self.addMismatch("columns", entityName, mismatch)
+	}
+	ret.Type = translatedType
+
+	isAutoIncrement := isAutoIncrementColumn(self.sourceDialect, column)
+	columnDefault := parseNeutralDefault(self.sourceDialect, columnType, column.Default, column.Extra)
+
+	extraParts := []string{}
+	if isAutoIncrement {
+		extraParts = append(extraParts, utils.Ternary(self.isTargetMysql(), "auto_increment", "GENERATED BY DEFAULT AS IDENTITY"))
+	} else if columnDefault != nil {
+		translatedDefault, mismatch := formatNeutralDefault(self.targetDialect, columnType, *columnDefault)
+		if mismatch != "" {
+			self.addMismatch("columns", entityName, mismatch)
+		}
+		ret.Default = &translatedDefault
+
+		if columnDefault.IsExpression && self.isTargetMysql() {
+			extraParts = append(extraParts, "DEFAULT_GENERATED")
+		}
+	}
+
+	upperExtra := strings.ToUpper(column.Extra)
+	if strings.Contains(upperExtra, "ON UPDATE") {
+		self.addMismatch("columns", entityName, "ON UPDATE CURRENT_TIMESTAMP has no "+self.targetDialect+" equivalent and needs a trigger")
+	}
+	if strings.Contains(upperExtra, "VIRTUAL GENERATED") || strings.Contains(upperExtra, "STORED GENERATED") {
+		self.addMismatch("columns", entityName, "The expression of the generated column isn't translated, so it becomes a regular column")
+	}
+
+	ret.Extra = strings.Join(extraParts, " ")
+
+	if targetColumn == nil {
+		return ret
+	}
+
+	// What means the same in both dialects is kept as it is in the target schema.
+	targetType := parseNeutralType(self.targetDialect, targetColumn.Type)
+	if isSameNeutralType(parseNeutralType(self.targetDialect, ret.Type), targetType) {
+		ret.Type = targetColumn.Type
+	}
+
+	isTargetAutoIncrement := isAutoIncrementColumn(self.targetDialect, targetColumn)
+	targetDefault := parseNeutralDefault(self.targetDialect, targetType, targetColumn.Default, targetColumn.Extra)
+	if isAutoIncrement {
+		columnDefault = nil
+	}
+	if isAutoIncrement == isTargetAutoIncrement && isSameNeutralDefault(columnDefault, targetDefault) && !strings.Contains(strings.ToUpper(targetColumn.Extra), "ON UPDATE") {
+		ret.Default = targetColumn.Default
+		ret.Extra = targetColumn.Extra
+	}
+
+	ret.CharacterSet = targetColumn.CharacterSet
+	ret.Collation = targetColumn.Collation
+
+	return ret
+}
+
+// isAutoIncrementColumn tells whether a column gets its values from a counter: `AUTO_INCREMENT` in Mysql, and an
+// identity or a sequence (serial) in Postgres.
+func isAutoIncrementColumn(dialect string, column *schema.Column) bool {
+	if dialect == "mysql" || dialect == "mariadb" {
+		return strings.Contains(strings.ToLower(column.Extra), "auto_increment")
+	}
+
+	return strings.Contains(column.Extra, "IDENTITY") || (column.Default != nil && postgresSequenceRegex.MatchString(*column.Default))
+}
+
+// neutralConstraint is a constraint in a form that belongs to no dialect.
+type neutralConstraint struct {
+	Type                string
+	Columns             []string
+	ReferencedTableName string
+	ReferencedColumns   []string
+	UpdateRule          string
+	DeleteRule          string
+	// CheckClause is the expression of a check constraint, without the parentheses around it.
+	CheckClause string
+}
+
+var (
+	postgresPrimaryKeyRegex = regexp.MustCompile(`^PRIMARY KEY \((.+)\)`)
+	postgresUniqueRegex     = regexp.MustCompile(`^UNIQUE (NULLS NOT DISTINCT )?\((.+)\)`)
+	postgresForeignKeyRegex = regexp.MustCompile(`^FOREIGN KEY \((.+?)\) REFERENCES (.+?)\((.+?)\)(.*)$`)
+	postgresRuleRegex       = regexp.MustCompile(`ON (UPDATE|DELETE) (CASCADE|SET NULL|SET DEFAULT|RESTRICT|NO ACTION)`)
+	postgresCheckRegex      = regexp.MustCompile(`^CHECK \((.*)\)( NOT VALID)?$`)
+	// postgresInlineCastRegex matches the casts Postgres adds to the expressions it reports. e.g. `'a'::text`.
+	postgresInlineCastRegex = regexp.MustCompile(`::[a-zA-Z_][a-zA-Z0-9_]*( varying| precision| without time zone| with time zone)?(\[\])?`)
+	mysqlCharsetIntroducer  = regexp.MustCompile(`_[a-z0-9]+'`)
+)
+
+// parseNeutralConstraint reads a constraint of the given dialect. The second value is false for constraints that
+// have no equivalent in other dialects, such as Postgres' exclusion constraints.
+func parseNeutralConstraint(dialect string, constraint *schema.Constraint) (neutralConstraint, bool) {
+	ret := neutralConstraint{Type: constraint.Type}
+
+	if dialect == "mysql" || dialect == "mariadb" {
+		ret.Columns = constraint.Columns
+		ret.ReferencedTableName = constraint.ReferencedTableName
+		ret.ReferencedColumns = constraint.ReferencedColumns
+		ret.UpdateRule = constraint.UpdateRule
+		ret.DeleteRule = constraint.DeleteRule
+		ret.CheckClause = strings.TrimSpace(constraint.CheckClause)
+	} else {
+		definition := strings.TrimSpace(constraint.Definition)
+
+		if matches := postgresPrimaryKeyRegex.FindStringSubmatch(definition); matches != nil && constraint.Type == "PRIMARY KEY" {
+			ret.Columns = splitIdentifierList(matches[1])
+		} else if matches := postgresUniqueRegex.FindStringSubmatch(definition); matches != nil && constraint.Type == "UNIQUE" {
+			ret.Columns = splitIdentifierList(matches[2])
+		} else if matches := postgresForeignKeyRegex.FindStringSubmatch(definition); matches != nil && constraint.Type == "FOREIGN KEY" {
+			tableNameParts := splitQuotedList(strings.ReplaceAll(matches[2], ".", ","))
+
+			ret.Columns = splitIdentifierList(matches[1])
+			ret.ReferencedTableName = unquoteIdentifier(tableNameParts[len(tableNameParts)-1])
+			ret.ReferencedColumns = splitIdentifierList(matches[3])
+
+			for _, ruleMatches := range postgresRuleRegex.FindAllStringSubmatch(matches[4], -1) {
+				if ruleMatches[1] == "UPDATE" {
+					ret.UpdateRule = ruleMatches[2]
+				} else {
+					ret.DeleteRule = ruleMatches[2]
+				}
+			}
+		} else if matches := postgresCheckRegex.FindStringSubmatch(definition); matches != nil && constraint.Type == "CHECK" {
+			ret.CheckClause = matches[1]
+		} else {
+			return ret, false
+		}
+	}
+
+	ret.UpdateRule = normalizeForeignKeyRule(ret.UpdateRule)
+	ret.DeleteRule = normalizeForeignKeyRule(ret.DeleteRule)
+
+	return ret, true
+}
+
+// normalizeForeignKeyRule returns the rule of a foreign key with the default spelled out. RESTRICT is taken as
+// NO ACTION, which only differs in Postgres by when it is checked.
+func normalizeForeignKeyRule(rule string) string {
+	rule = strings.ToUpper(strings.TrimSpace(rule))
+	if rule == "" || rule == "RESTRICT" {
+		return "NO ACTION"
+	}
+
+	return rule
+}
+
+// isSameNeutralConstraint tells whether two constraints enforce the same thing. Check clauses are compared without
+// their quotes, parentheses, casts and whitespace since each dialect reports them in its own way.
+func isSameNeutralConstraint(first neutralConstraint, second neutralConstraint) bool {
+	return first.Type == second.Type &&
+		slices.Equal(first.Columns, second.Columns) &&
+		first.ReferencedTableName == second.ReferencedTableName &&
+		slices.Equal(first.ReferencedColumns, second.ReferencedColumns) &&
+		first.UpdateRule == second.UpdateRule &&
+		first.DeleteRule == second.DeleteRule &&
+		normalizeCheckClause(first.CheckClause) == normalizeCheckClause(second.CheckClause)
+}
+
+func normalizeCheckClause(clause string) string {
+	clause = postgresInlineCastRegex.ReplaceAllString(clause, "")
+	clause = mysqlCharsetIntroducer.ReplaceAllString(clause, "'")
+
+	return strings.Map(func(char rune) rune {
+		if char == '`' || char == '"' || char == '(' || char == ')' || char == ' ' || char == '\t' || char == '\n' {
+			return -1
+		}
+
+		return char
+	}, strings.ToLower(clause))
+}
+
+// translateConstraint translates a constraint. A constraint of targetTable that enforces the same thing is used as
+// it is, whatever its name. usedTargetNames keeps track of the ones already used. It returns nil for constraints that
+// can't be translated.
+func (self *schemaTranslator) translateConstraint(tableName string, constraint *schema.Constraint, targetTable *schema.Table, usedTargetNames map[string]bool) *schema.Constraint {
+	entityName := tableName + " → " + constraint.Name
+
+	sourceConstraint, ok := parseNeutralConstraint(self.sourceDialect, constraint)
+	if !ok {
+		self.addMismatch("constraints", entityName, constraint.Type+" constraints have no "+self.targetDialect+" equivalent, so it is left out")
+		return nil
+	}
+
+	if targetTable != nil {
+		for _, targetConstraintName := range getSortedKeys(targetTable.Constraints) {
+			targetConstraint := targetTable.Constraints[targetConstraintName]
+
+			targetNeutralConstraint, ok := parseNeutralConstraint(self.targetDialect, targetConstraint)
+			if ok && !usedTargetNames[targetConstraintName] && isSameNeutralConstraint(sourceConstraint, targetNeutralConstraint) {
+				usedTargetNames[targetConstraintName] = true
+
+				ret := *targetConstraint
+				return &ret
+			}
+		}
+	}
+
+	ret := &schema.Constraint{
+		Name:                constraint.Name,
+		Type:                constraint.Type,
+		ReferencedTableName: sourceConstraint.ReferencedTableName,
+	}
+
+	if self.isTargetMysql() {
+		if constraint.Type == "PRIMARY KEY" {
+			ret.Name = "PRIMARY"
+		}
+
+		ret.Columns = sourceConstraint.Columns
+		ret.ReferencedColumns = sourceConstraint.ReferencedColumns
+		if constraint.Type == "FOREIGN KEY" {
+			ret.UpdateRule = sourceConstraint.UpdateRule
+			ret.DeleteRule = sourceConstraint.DeleteRule
+		}
+		if constraint.Type == "CHECK" {
+			ret.CheckClause = strings.ReplaceAll(postgresInlineCastRegex.ReplaceAllString(sourceConstraint.CheckClause, ""), `"`, "`")
+		}
+
+		return ret
+	}
+
+	if constraint.Type == "PRIMARY KEY" {
+		ret.Name = tableName + "_pkey"
+		ret.Definition = "PRIMARY KEY (" + joinPostgresIdentifiers(sourceConstraint.Columns) + ")"
+	} else if constraint.Type == "UNIQUE" {
+		ret.Definition = "UNIQUE (" + joinPostgresIdentifiers(sourceConstraint.Columns) + ")"
+	} else if constraint.Type == "FOREIGN KEY" {
+		ret.Definition = "FOREIGN KEY (" + joinPostgresIdentifiers(sourceConstraint.Columns) + ") REFERENCES " +
+			quotePostgresIdentifierIfNeeded(sourceConstraint.ReferencedTableName) + "(" + joinPostgresIdentifiers(sourceConstraint.ReferencedColumns) + ")"
+		if sourceConstraint.UpdateRule != "NO ACTION" {
+			ret.Definition += " ON UPDATE " + sourceConstraint.UpdateRule
+		}
+		if sourceConstraint.DeleteRule != "NO ACTION" {
+			ret.Definition += " ON DELETE " + sourceConstraint.DeleteRule
+		}
+	} else if constraint.Type == "CHECK" {
+		clause := mysqlCharsetIntroducer.ReplaceAllString(sourceConstraint.CheckClause, "'")
+		ret.Definition = "CHECK (" + strings.ReplaceAll(clause, "`", `"`) + ")"
+	}
+
+	return ret
+}
+
+// neutralIndex is an index in a form that belongs to no dialect.
+type neutralIndex struct {
+	// Columns are the unquoted names of the columns of the index, or their expressions wrapped in parentheses.
+	Columns  []string
+	IsUnique bool
+	// Method is the lower case access method of the index. e.g. btree, hash, fulltext or gin.
+	Method       string
+	HasPredicate bool
+}
+
+var postgresIndexPredicateRegex = regexp.MustCompile(`\) WHERE .+$`)
+
+func parseNeutralIndex(dialect string, index *schema.Index) neutralIndex {
+	ret := neutralIndex{IsUnique: index.IsUnique, Method: strings.ToLower(index.Type), Columns: []string{}}
+
+	for _, column := range index.Columns {
+		if column.Expression != "" {
+			ret.Columns = append(ret.Columns, "("+column.Expression+")")
+		} else if dialect == "mysql" || dialect == "mariadb" {
+			ret.Columns = append(ret.Columns, column.ColumnName)
+		} else {
+			ret.Columns = append(ret.Columns, unquoteIdentifier(column.ColumnName))
+		}
+	}
+
+	if dialect == "postgres" || dialect == "cockroachdb" {
+		ret.HasPredicate = postgresIndexPredicateRegex.MatchString(index.Definition)
+	}
+
+	return ret
+}
+
+func isSameNeutralIndex(first neutralIndex, second neutralIndex) bool {
+	return slices.Equal(first.Columns, second.Columns) && first.IsUnique == second.IsUnique && first.Method == second.Method && first.HasPredicate == second.HasPredicate
+}
+
+// translateIndex translates an index of a table. Like constraints, an index of targetTable that does the same thing is
+// used as it is, preferably the one of the same name. It returns nil for indexes that can't be translated.
+func (self *schemaTranslator) translateIndex(table *schema.Table, index *schema.Index, targetTable *schema.Table, usedTargetNames map[string]bool) *schema.Index {
+	entityName := table.Name + " → " + index.Name
+	sourceIndex := parseNeutralIndex(self.sourceDialect, index)
+
+	if !self.isTargetMysql() {
+		for _, column := range index.Columns {
+			if column.SubPart != 0 {
+				self.addMismatch("indexes", entityName, "Postgres has no prefix indexes, so all of "+column.ColumnName+" is indexed")
+			}
+		}
+	}
+
+	if targetTable != nil {
+		targetIndexNames := getSortedKeys(targetTable.Indexes)
+		if slices.Contains(targetIndexNames, index.Name) {
+			targetIndexNames = append([]string{index.Name}, targetIndexNames...)
+		}
+
+		for _, targetIndexName := range targetIndexNames {
+			targetIndex := targetTable.Indexes[targetIndexName]
+			if !usedTargetNames[targetIndexName] && isSameNeutralIndex(sourceIndex, parseNeutralIndex(self.targetDialect, targetIndex)) {
+				usedTargetNames[targetIndexName] = true
+
+				ret := *targetIndex
+				return &ret
+			}
+		}
+	}
+
+	if sourceIndex.Method != "btree" && sourceIndex.Method != "hash" {
+		self.addMismatch("indexes", entityName, sourceIndex.Method+" indexes have no "+self.targetDialect+" equivalent, so it is left out")
+		return nil
+	}
+
+	ret := &schema.Index{Name: index.Name, IsUnique: index.IsUnique, IsVisible: true, Columns: []schema.IndexColumn{}}
+
+	if self.isTargetMysql() {
+		if sourceIndex.HasPredicate {
+			self.addMismatch("indexes", entityName, "Partial indexes have no Mysql equivalent, so it is left out")
+			return nil
+		}
+
+		ret.Type = strings.ToUpper(sourceIndex.Method)
+
+		for _, columnName := range sourceIndex.Columns {
+			columnOpt := table.GetColumn(columnName)
+			if strings.HasPrefix(columnName, "(") || columnOpt.IsNone() {
+				ret.Columns = append(ret.Columns, schema.IndexColumn{Expression: strings.ReplaceAll(postgresInlineCastRegex.ReplaceAllString(columnName, ""), `"`, "`")})
+				continue
+			}
+
+			// Mysql only indexes a prefix of text and blob columns.
+			indexColumn := schema.IndexColumn{ColumnName: columnName}
+			columnKind := parseNeutralType(self.targetDialect, columnOpt.Unwrap().Type).Kind
+			if columnKind == "text" || columnKind == "binary" {
+				indexColumn.SubPart = 255
+				self.addMismatch("indexes", entityName, "Mysql only indexes the first 255 characters of "+columnName)
+			}
+
+			ret.Columns = append(ret.Columns, indexColumn)
+		}
+
+		return ret
+	}
+
+	ret.Type = sourceIndex.Method
+
+	keyParts := []string{}
+	for i, columnName := range sourceIndex.Columns {
+		keyPart := utils.Ternary(strings.HasPrefix(columnName, "("), strings.ReplaceAll(columnName, "`", `"`), quotePostgresIdentifierIfNeeded(columnName))
+		ret.Columns = append(ret.Columns, schema.IndexColumn{ColumnName: keyPart})
+
+		if index.Columns[i].IsDesc {
+			keyPart += " DESC"
+		}
+
+		keyParts = append(keyParts, keyPart)
+	}
+
+	ret.Definition = "CREATE " + utils.Ternary(index.IsUnique, "UNIQUE ", "") + "INDEX " + quotePostgresIdentifierIfNeeded(index.Name) +
+		" ON " + quotePostgresIdentifierIfNeeded(table.Name) + " USING " + sourceIndex.Method + " (" + strings.Join(keyParts, ", ") + ")"
+
+	return ret
+}
+
+// splitIdentifierList splits a list of identifiers as reported by Postgres and unquotes them. e.g. `id, "userId"`.
+func splitIdentifierList(list string) []string {
+	ret := []string{}
+	for _, identifier := range splitQuotedList(list) {
+		ret = append(ret, unquoteIdentifier(identifier))
+	}
+
+	return ret
+}
+
+func joinPostgresIdentifiers(identifiers []string) string {
+	quotedIdentifiers := []string{}
+	for _, identifier := range identifiers {
+		quotedIdentifiers = append(quotedIdentifiers, quotePostgresIdentifierIfNeeded(identifier))
+	}
+
+	return strings.Join(quotedIdentifiers, ", ")
+}
+
+// getSortedKeys returns the keys of a map in order, so that translations are stable between runs.
+func getSortedKeys[T any](entities map[string]T) []string {
+	ret := []string{}
+	for key := range entities {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
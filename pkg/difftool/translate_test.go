@@ -0,0 +1,129 @@
+package difftool
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+func TestTranslateColumn(t *testing.T) {
+	tests := []struct {
+		name               string
+		sourceDialect      string
+		targetDialect      string
+		column             *schema.Column
+		targetColumn       *schema.Column
+		expected           *schema.Column
+		expectedMismatches []string
+	}{
+		{
+			name:          "auto increment to identity",
+			sourceDialect: "mysql",
+			targetDialect: "postgres",
+			column:        &schema.Column{Name: "id", Type: "int", Extra: "auto_increment"},
+			expected:      &schema.Column{Name: "id", Type: "integer", Extra: "GENERATED BY DEFAULT AS IDENTITY"},
+		},
+		{
+			name:          "tinyint(1) to boolean",
+			sourceDialect: "mysql",
+			targetDialect: "postgres",
+			column:        &schema.Column{Name: "is_active", Type: "tinyint(1)", IsNullable: true, Default: testutil.StringPointer("1")},
+			expected:      &schema.Column{Name: "is_active", Type: "boolean", IsNullable: true, Default: testutil.StringPointer("true")},
+		},
+		{
+			name:               "enum to text",
+			sourceDialect:      "mysql",
+			targetDialect:      "postgres",
+			column:             &schema.Column{Name: "status", Type: "enum('a','b')", Default: testutil.StringPointer("a")},
+			expected:           &schema.Column{Name: "status", Type: "text", Default: testutil.StringPointer("'a'")},
+			expectedMismatches: []string{"enum('a','b') becomes text in Postgres, which doesn't enforce its values"},
+		},
+		{
+			name:               "on update current timestamp",
+			sourceDialect:      "mysql",
+			targetDialect:      "postgres",
+			column:             &schema.Column{Name: "updated_at", Type: "timestamp", Default: testutil.StringPointer("CURRENT_TIMESTAMP"), Extra: "DEFAULT_GENERATED on update CURRENT_TIMESTAMP"},
+			expected:           &schema.Column{Name: "updated_at", Type: "timestamp with time zone", Default: testutil.StringPointer("CURRENT_TIMESTAMP")},
+			expectedMismatches: []string{"ON UPDATE CURRENT_TIMESTAMP has no postgres equivalent and needs a trigger"},
+		},
+		{
+			name:               "generated column",
+			sourceDialect:      "mysql",
+			targetDialect:      "postgres",
+			column:             &schema.Column{Name: "total", Type: "int", Extra: "VIRTUAL GENERATED"},
+			expected:           &schema.Column{Name: "total", Type: "integer"},
+			expectedMismatches: []string{"The expression of the generated column isn't translated, so it becomes a regular column"},
+		},
+		{
+			name:          "same type as the target column",
+			sourceDialect: "mysql",
+			targetDialect: "postgres",
+			column:        &schema.Column{Name: "name", Type: "varchar(255)"},
+			targetColumn:  &schema.Column{Name: "name", Type: "character varying(255)", Collation: testutil.StringPointer("C")},
+			expected:      &schema.Column{Name: "name", Type: "character varying(255)", Collation: testutil.StringPointer("C")},
+		},
+		{
+			name:          "auto increment matching a serial target column",
+			sourceDialect: "mysql",
+			targetDialect: "postgres",
+			column:        &schema.Column{Name: "id", Type: "int", Extra: "auto_increment"},
+			targetColumn:  &schema.Column{Name: "id", Type: "integer", Default: testutil.StringPointer("nextval('users_id_seq'::regclass)")},
+			expected:      &schema.Column{Name: "id", Type: "integer", Default: testutil.StringPointer("nextval('users_id_seq'::regclass)")},
+		},
+		{
+			name:          "identity to auto increment",
+			sourceDialect: "postgres",
+			targetDialect: "mysql",
+			column:        &schema.Column{Name: "id", Type: "bigint", Extra: "GENERATED BY DEFAULT AS IDENTITY"},
+			expected:      &schema.Column{Name: "id", Type: "bigint", Extra: "auto_increment"},
+		},
+		{
+			name:          "boolean to tinyint(1)",
+			sourceDialect: "postgres",
+			targetDialect: "mysql",
+			column:        &schema.Column{Name: "is_active", Type: "boolean", Default: testutil.StringPointer("true")},
+			expected:      &schema.Column{Name: "is_active", Type: "tinyint(1)", Default: testutil.StringPointer("1")},
+		},
+		{
+			name:          "expression default",
+			sourceDialect: "postgres",
+			targetDialect: "mysql",
+			column:        &schema.Column{Name: "created_at", Type: "timestamp without time zone", Default: testutil.StringPointer("now()")},
+			expected:      &schema.Column{Name: "created_at", Type: "datetime", Default: testutil.StringPointer("CURRENT_TIMESTAMP"), Extra: "DEFAULT_GENERATED"},
+		},
+		{
+			name:          "cast literal default",
+			sourceDialect: "postgres",
+			targetDialect: "mysql",
+			column:        &schema.Column{Name: "name", Type: "text", Default: testutil.StringPointer("'x'::text")},
+			expected:      &schema.Column{Name: "name", Type: "longtext", Default: testutil.StringPointer("x")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			translator := &schemaTranslator{sourceDialect: test.sourceDialect, targetDialect: test.targetDialect, mismatches: []DialectMismatch{}}
+
+			column := translator.translateColumn("users", test.column, test.targetColumn)
+			if !reflect.DeepEqual(column, test.expected) {
+				t.Errorf("got\n%+v\nexpected\n%+v", *column, *test.expected)
+			}
+
+			mismatches := []string{}
+			for _, mismatch := range translator.mismatches {
+				if mismatch.EntityType != "columns" || mismatch.EntityName != "users → "+test.column.Name {
+					t.Errorf("unexpected mismatch entity %s %s", mismatch.EntityType, mismatch.EntityName)
+				}
+				mismatches = append(mismatches, mismatch.Message)
+			}
+			if test.expectedMismatches == nil {
+				test.expectedMismatches = []string{}
+			}
+			if !reflect.DeepEqual(mismatches, test.expectedMismatches) {
+				t.Errorf("got mismatches %q, expected %q", mismatches, test.expectedMismatches)
+			}
+		})
+	}
+}
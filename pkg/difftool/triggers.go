@@ -1,94 +1,151 @@
 package difftool
 
 import (
-	"fmt"
+	"context"
+	"slices"
 
+	"github.com/Okira-E/patchi/pkg/schema"
 	"github.com/Okira-E/patchi/pkg/types"
-	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/safego"
 )
 
-type triggerDiff struct {
-	TriggerName string
-	// DiffType represents the type of change that has occurred to the entity.
-	// 0 -> Deleted.
-	// 1 -> Created.
-	DiffType int8
+// TriggerDiff is a trigger out of sync between two schemas.
+type TriggerDiff struct {
+	TriggerName string `json:"trigger_name" yaml:"trigger_name"`
+	// DiffType represents the type of change that has occurred to the entity (deleted, created or modified.)
+	DiffType DiffType `json:"diff_type" yaml:"diff_type"`
 }
 
-// GetTriggersDiff returns the triggers out of sync between two databases.
-func GetTriggersDiff(firstDb types.DbConnection, secondDb types.DbConnection, dialect string) []triggerDiff {
-	ret := []triggerDiff{}
+// GetTriggersDiff returns the triggers out of sync between two schemas. Triggers matching ignoreRules, or defined on
+// an ignored table, are left out.
+func GetTriggersDiff(firstSchema *schema.Schema, secondSchema *schema.Schema, ignoreRules *IgnoreRules) []TriggerDiff {
+	ret := []TriggerDiff{}
 
-	triggersInFirstDb := getAllTriggersNamesInDb(firstDb, dialect)
-	triggersInSecondDb := getAllTriggersNamesInDb(secondDb, dialect)
-
-	// Compare the two arrays of trigger names and return the difference.
 	// Triggers that exist in the first database but not in the second database must have been created.
 	// Triggers that exist in the second database but not in the first database must have been deleted.
+	// Triggers that exist in both databases but whose definitions differ must have been modified.
 
-	firstDbTriggersBookKeeping := map[string]bool{}
-	for _, triggerName := range triggersInFirstDb {
-		firstDbTriggersBookKeeping[triggerName] = true
-	}
-
-	secondDbTriggersBookKeeping := map[string]bool{}
-	for _, triggerName := range triggersInSecondDb {
-		secondDbTriggersBookKeeping[triggerName] = true
-	}
-
-	for _, triggerName := range triggersInFirstDb {
-		if _, ok := secondDbTriggersBookKeeping[triggerName]; !ok {
-			ret = append(ret, triggerDiff{
+	for triggerName, firstTrigger := range firstSchema.Triggers {
+		secondTrigger, ok := secondSchema.Triggers[triggerName]
+		if !ok {
+			ret = append(ret, TriggerDiff{
 				TriggerName: triggerName,
-				DiffType:    1,
+				DiffType:    Created,
+			})
+		} else if normalizeDefinition(firstTrigger.Definition, firstSchema.Dialect) != normalizeDefinition(secondTrigger.Definition, secondSchema.Dialect) {
+			ret = append(ret, TriggerDiff{
+				TriggerName: triggerName,
+				DiffType:    Modified,
 			})
 		}
 	}
 
-	for _, triggerName := range triggersInSecondDb {
-		if _, ok := firstDbTriggersBookKeeping[triggerName]; !ok {
-			ret = append(ret, triggerDiff{
+	for triggerName := range secondSchema.Triggers {
+		if _, ok := firstSchema.Triggers[triggerName]; !ok {
+			ret = append(ret, TriggerDiff{
 				TriggerName: triggerName,
-				DiffType:    0,
+				DiffType:    Deleted,
 			})
 		}
 	}
 
-	return ret
+	return slices.DeleteFunc(ret, func(diff TriggerDiff) bool {
+		trigger, ok := firstSchema.Triggers[diff.TriggerName]
+		if !ok {
+			trigger = secondSchema.Triggers[diff.TriggerName]
+		}
+
+		return ignoreRules.isIgnored("triggers", trigger.TableName, diff.TriggerName)
+	})
 }
 
-// getAllTriggersNamesInDb returns an array of trigger names retrieved from given database connection.
-func getAllTriggersNamesInDb(db types.DbConnection, dialect string) []string {
-	ret := []string{}
+// loadTriggersFromMysql reads the triggers of the database along with their `CREATE TRIGGER` statements.
+func loadTriggersFromMysql(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	rows, err := db.SqlConnection.QueryContext(
+		ctx,
+		"SELECT TRIGGER_NAME, EVENT_OBJECT_TABLE FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA = ?",
+		db.Info.DatabaseName,
+	)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var trigger schema.Trigger
+		if err := rows.Scan(&trigger.Name, &trigger.TableName); err != nil {
+			return safego.Some(err)
+		}
+
+		ret.Triggers[trigger.Name] = &trigger
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	for triggerName, trigger := range ret.Triggers {
+		definition, errOpt := showCreate(ctx, db, "SHOW CREATE TRIGGER "+quoteMysqlIdentifier(triggerName), "SQL Original Statement")
+		if errOpt.IsSome() {
+			return errOpt
+		}
 
-	if dialect == "mysql" || dialect == "mariadb" {
-		ret = getAllTriggersNamesInMysql(db, dialect)
-	} else if dialect == "postgres" || dialect == "cockroachdb" {
-		utils.AbortTui("UNIMPLEMENTED")
+		trigger.Definition = definition
 	}
-	return ret
+
+	return safego.None[error]()
 }
 
-func getAllTriggersNamesInMysql(db types.DbConnection, dialect string) []string {
-	ret := []string{}
+// Trigger names in Postgres are only unique per table, so the name of each trigger is suffixed with the table it is
+// defined on. e.g. `audit_trigger ON users`.
+func loadTriggersFromPostgres(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	rows, err := db.SqlConnection.QueryContext(ctx, `
+		SELECT t.tgname || ' ON ' || c.relname, c.relname, pg_get_triggerdef(t.oid, true)
+		FROM pg_catalog.pg_trigger t
+		JOIN pg_catalog.pg_class c ON c.oid = t.tgrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema() AND NOT t.tgisinternal
+	`)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
 
-	rows, err := db.SqlConnection.Query(
-		"SELECT TRIGGER_NAME FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA = ?",
-		db.Info.DatabaseName,
-	)
+	for rows.Next() {
+		var trigger schema.Trigger
+		if err := rows.Scan(&trigger.Name, &trigger.TableName, &trigger.Definition); err != nil {
+			return safego.Some(err)
+		}
+
+		ret.Triggers[trigger.Name] = &trigger
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	return safego.None[error]()
+}
+
+func loadTriggersFromSqlite(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	rows, err := db.SqlConnection.QueryContext(ctx, "SELECT name, tbl_name, sql FROM sqlite_master WHERE type = 'trigger'")
 	if err != nil {
-		utils.Abort(fmt.Sprintf("Error querying database: %s", err.Error()))
+		return safego.Some(err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var triggerName string
-		err := rows.Scan(&triggerName)
-		if err != nil {
-			utils.Abort(fmt.Sprintf("Error scanning row: %s", err.Error()))
+		var trigger schema.Trigger
+		if err := rows.Scan(&trigger.Name, &trigger.TableName, &trigger.Definition); err != nil {
+			return safego.Some(err)
 		}
 
-		ret = append(ret, triggerName)
+		ret.Triggers[trigger.Name] = &trigger
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
 	}
 
-	return ret
+	return safego.None[error]()
 }
@@ -1,94 +1,243 @@
 package difftool
 
 import (
-	"fmt"
+	"context"
+	"regexp"
+	"slices"
+	"strings"
 
+	"github.com/Okira-E/patchi/pkg/schema"
 	"github.com/Okira-E/patchi/pkg/types"
-	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/safego"
 )
 
-type viewDiff struct {
-	ViewName string
-	// DiffType represents the type of change that has occurred to the entity.
-	// 0 -> Deleted.
-	// 1 -> Created.
-	DiffType int8
+// ViewDiff is a view out of sync between two schemas.
+type ViewDiff struct {
+	ViewName string `json:"view_name" yaml:"view_name"`
+	// DiffType represents the type of change that has occurred to the entity (deleted, created or modified.)
+	DiffType DiffType `json:"diff_type" yaml:"diff_type"`
 }
 
-// GetViewsDiff returns the views out of sync between two databases.
-func GetViewsDiff(firstDb types.DbConnection, secondDb types.DbConnection, dialect string) []viewDiff {
-	ret := []viewDiff{}
+// GetViewsDiff returns the views out of sync between two schemas. Views matching ignoreRules are left out.
+func GetViewsDiff(firstSchema *schema.Schema, secondSchema *schema.Schema, ignoreRules *IgnoreRules) []ViewDiff {
+	ret := []ViewDiff{}
 
-	viewsInFirstDb := getAllViewsNamesInDb(firstDb, dialect)
-	viewsInSecondDb := getAllViewsNamesInDb(secondDb, dialect)
-
-	// Compare the two arrays of view names and return the difference.
 	// Views that exist in the first database but not in the second database must have been created.
 	// Views that exist in the second database but not in the first database must have been deleted.
+	// Views that exist in both databases but whose definitions differ must have been modified.
 
-	firstDbViewsBookKeeping := map[string]bool{}
-	for _, viewName := range viewsInFirstDb {
-		firstDbViewsBookKeeping[viewName] = true
-	}
-
-	secondDbViewsBookKeeping := map[string]bool{}
-	for _, viewName := range viewsInSecondDb {
-		secondDbViewsBookKeeping[viewName] = true
-	}
-
-	for _, viewName := range viewsInFirstDb {
-		if _, ok := secondDbViewsBookKeeping[viewName]; !ok {
-			ret = append(ret, viewDiff{
+	for viewName, firstView := range firstSchema.Views {
+		secondView, ok := secondSchema.Views[viewName]
+		if !ok {
+			ret = append(ret, ViewDiff{
 				ViewName: viewName,
-				DiffType:  1,
+				DiffType: Created,
+			})
+		} else if normalizeDefinition(firstView.Definition, firstSchema.Dialect) != normalizeDefinition(secondView.Definition, secondSchema.Dialect) {
+			ret = append(ret, ViewDiff{
+				ViewName: viewName,
+				DiffType: Modified,
 			})
 		}
 	}
 
-	for _, viewName := range viewsInSecondDb {
-		if _, ok := firstDbViewsBookKeeping[viewName]; !ok {
-			ret = append(ret, viewDiff{
+	for viewName := range secondSchema.Views {
+		if _, ok := firstSchema.Views[viewName]; !ok {
+			ret = append(ret, ViewDiff{
 				ViewName: viewName,
-				DiffType:  0,
+				DiffType: Deleted,
 			})
 		}
 	}
 
-	return ret
+	return slices.DeleteFunc(ret, func(diff ViewDiff) bool {
+		return ignoreRules.isIgnored("views", "", diff.ViewName)
+	})
 }
 
-// getAllViewsNamesInDb returns an array of view names retrieved from given database connection.
-func getAllViewsNamesInDb(db types.DbConnection, dialect string) []string {
-	ret := []string{}
+// loadViewsFromMysql reads the views of the database along with their `CREATE VIEW` statements and the relations
+// they select from.
+func loadViewsFromMysql(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	rows, err := db.SqlConnection.QueryContext(
+		ctx,
+		"SELECT TABLE_NAME, VIEW_DEFINITION FROM information_schema.VIEWS WHERE TABLE_SCHEMA = ?",
+		db.Info.DatabaseName,
+	)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	// VIEW_TABLE_USAGE doesn't exist in MariaDB, so the tables a view uses are taken from its definition instead.
+	// Mysql always stores fully qualified names in there. e.g. `db`.`users`.
+	qualifiedNameRegex := regexp.MustCompile("`" + regexp.QuoteMeta(db.Info.DatabaseName) + "`\\.`([^`]+)`")
+	for rows.Next() {
+		var viewName, viewDefinition string
+		if err := rows.Scan(&viewName, &viewDefinition); err != nil {
+			return safego.Some(err)
+		}
+
+		view := &schema.View{Name: viewName}
+		for _, match := range qualifiedNameRegex.FindAllStringSubmatch(viewDefinition, -1) {
+			if match[1] != viewName {
+				view.Dependencies = append(view.Dependencies, match[1])
+			}
+		}
 
-	if dialect == "mysql" || dialect == "mariadb" {
-		ret = getAllViewsNamesInMysql(db, dialect)
-	} else if dialect == "postgres" || dialect == "cockroachdb" {
-		utils.AbortTui("UNIMPLEMENTED")
+		ret.Views[viewName] = view
 	}
-	return ret
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	for viewName, view := range ret.Views {
+		definition, errOpt := showCreate(ctx, db, "SHOW CREATE VIEW "+quoteMysqlIdentifier(viewName), "Create View")
+		if errOpt.IsSome() {
+			return errOpt
+		}
+
+		view.Definition = definition
+	}
+
+	return safego.None[error]()
 }
 
-func getAllViewsNamesInMysql(db types.DbConnection, dialect string) []string {
-	ret := []string{}
+func loadViewsFromPostgres(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	rows, err := db.SqlConnection.QueryContext(ctx, `
+		SELECT c.relname, pg_get_viewdef(c.oid, true)
+		FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema() AND c.relkind = 'v'
+	`)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var viewName, viewDefinition string
+		if err := rows.Scan(&viewName, &viewDefinition); err != nil {
+			return safego.Some(err)
+		}
 
-	rows, err := db.SqlConnection.Query(
-		"SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'VIEW'",
-		db.Info.DatabaseName,
-	)
+		ret.Views[viewName] = &schema.View{
+			Name:       viewName,
+			Definition: "CREATE OR REPLACE VIEW " + quotePostgresIdentifier(viewName) + " AS\n" + strings.TrimSuffix(strings.TrimSpace(viewDefinition), ";"),
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	rows, err = db.SqlConnection.QueryContext(ctx, `
+		SELECT c.relname, a.attname, a.attnum, format_type(a.atttypid, a.atttypmod)
+		FROM pg_catalog.pg_attribute a
+		JOIN pg_catalog.pg_class c ON c.oid = a.attrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema()
+		  AND c.relkind = 'v'
+		  AND a.attnum > 0
+		  AND NOT a.attisdropped
+		ORDER BY c.relname, a.attnum
+	`)
 	if err != nil {
-		utils.Abort(fmt.Sprintf("Error querying database: %s", err.Error()))
+		return safego.Some(err)
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var viewName string
-		err := rows.Scan(&viewName)
-		if err != nil {
-			utils.Abort(fmt.Sprintf("Error scanning row: %s", err.Error()))
+		var column schema.Column
+		if err := rows.Scan(&viewName, &column.Name, &column.OrdinalPosition, &column.Type); err != nil {
+			return safego.Some(err)
 		}
 
-		ret = append(ret, viewName)
+		if view, ok := ret.Views[viewName]; ok {
+			view.Columns = append(view.Columns, &column)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	// A view depends on the relations its rewrite rule references.
+	rows, err = db.SqlConnection.QueryContext(ctx, `
+		SELECT DISTINCT v.relname, t.relname
+		FROM pg_catalog.pg_depend d
+		JOIN pg_catalog.pg_rewrite r ON r.oid = d.objid
+		JOIN pg_catalog.pg_class v ON v.oid = r.ev_class
+		JOIN pg_catalog.pg_class t ON t.oid = d.refobjid
+		JOIN pg_catalog.pg_namespace n ON n.oid = v.relnamespace
+		WHERE d.classid = 'pg_catalog.pg_rewrite'::regclass
+		  AND d.refclassid = 'pg_catalog.pg_class'::regclass
+		  AND v.oid <> t.oid
+		  AND v.relkind = 'v'
+		  AND n.nspname = current_schema()
+	`)
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var viewName, relationName string
+		if err := rows.Scan(&viewName, &relationName); err != nil {
+			return safego.Some(err)
+		}
+
+		if view, ok := ret.Views[viewName]; ok {
+			view.Dependencies = append(view.Dependencies, relationName)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	return safego.None[error]()
+}
+
+func loadViewsFromSqlite(ctx context.Context, db types.DbConnection, ret *schema.Schema) safego.Option[error] {
+	rows, err := db.SqlConnection.QueryContext(ctx, "SELECT name, sql FROM sqlite_master WHERE type = 'view'")
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var view schema.View
+		if err := rows.Scan(&view.Name, &view.Definition); err != nil {
+			return safego.Some(err)
+		}
+
+		ret.Views[view.Name] = &view
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	// Sqlite doesn't track what a view selects from, so the tables and views it uses are looked up in its definition.
+	relationNames := []string{}
+	for tableName := range ret.Tables {
+		relationNames = append(relationNames, tableName)
+	}
+	for viewName := range ret.Views {
+		relationNames = append(relationNames, viewName)
+	}
+	slices.Sort(relationNames)
+
+	for viewName, view := range ret.Views {
+		for _, relationName := range relationNames {
+			relationNameRegex := regexp.MustCompile("(?i)(^|[^\\w$])[\"`\\[]?" + regexp.QuoteMeta(relationName) + "[\"`\\]]?($|[^\\w$])")
+			if relationName != viewName && relationNameRegex.MatchString(view.Definition) {
+				view.Dependencies = append(view.Dependencies, relationName)
+			}
+		}
 	}
 
-	return ret
+	return safego.None[error]()
 }
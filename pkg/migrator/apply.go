@@ -0,0 +1,163 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/sequelizer"
+	"github.com/Okira-E/patchi/pkg/types"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// ApplyMigration runs the statements against the given database, which is expected to be the second database of the
+// comparison. It returns the statements that were applied successfully.
+//
+// Postgres and Sqlite support transactional DDL, so the statements are all run in a single transaction and nothing is
+// applied if one of them fails. Mysql commits every DDL statement implicitly, so the statements are run one by one and
+// the migration stops at the first failing statement. The statements before it stay applied, and so do the queries of
+// the failing statement that ran before the failing query, which the error lists. Sqlite runs the migration
+// with foreign keys turned off, and only commits it if no row is left referencing a missing row. The statements are
+// canceled along with ctx, which rolls back the transaction.
+func ApplyMigration(ctx context.Context, db types.DbConnection, statements []sequelizer.Statement) ([]sequelizer.Statement, safego.Option[error]) {
+	dialect := db.Info.Dialect
+
+	if dialect == "postgres" || dialect == "cockroachdb" {
+		return applyMigrationInTransaction(ctx, db, statements)
+	} else if dialect == "sqlite" {
+		return applySqliteMigration(ctx, db, statements)
+	}
+
+	return applyMigrationSequentially(ctx, db, statements)
+}
+
+func applyMigrationSequentially(ctx context.Context, db types.DbConnection, statements []sequelizer.Statement) ([]sequelizer.Statement, safego.Option[error]) {
+	applied := []sequelizer.Statement{}
+
+	for i, statement := range statements {
+		for j, query := range statement.Queries {
+			if _, err := db.SqlConnection.ExecContext(ctx, query); err != nil {
+				return applied, safego.Some(newStatementError(i, len(statements), statement, statement.Queries[:j], err))
+			}
+		}
+
+		applied = append(applied, statement)
+	}
+
+	return applied, safego.None[error]()
+}
+
+func applyMigrationInTransaction(ctx context.Context, db types.DbConnection, statements []sequelizer.Statement) ([]sequelizer.Statement, safego.Option[error]) {
+	tx, err := db.SqlConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return []sequelizer.Statement{}, safego.Some(err)
+	}
+
+	return commitStatements(ctx, tx, db.Info.Dialect, statements)
+}
+
+// applySqliteMigration runs the statements in a transaction with foreign keys turned off, since rebuilding a table
+// drops it and would otherwise delete or reject the rows that reference it. Sqlite ignores the pragma within a
+// transaction, so it is set on a connection of its own before the transaction begins, and restored once it ends. The
+// foreign keys are checked before committing instead.
+func applySqliteMigration(ctx context.Context, db types.DbConnection, statements []sequelizer.Statement) ([]sequelizer.Statement, safego.Option[error]) {
+	conn, err := db.SqlConnection.Conn(ctx)
+	if err != nil {
+		return []sequelizer.Statement{}, safego.Some(err)
+	}
+	defer conn.Close()
+
+	var foreignKeys int
+	if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return []sequelizer.Statement{}, safego.Some(err)
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return []sequelizer.Statement{}, safego.Some(err)
+	}
+	// A rebuild that fails halfway leaves `legacy_alter_table` on, as pragmas aren't rolled back. The connection goes
+	// back to the pool afterwards, so the pragmas are restored even if ctx is canceled.
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), "PRAGMA foreign_keys = "+strconv.Itoa(foreignKeys))
+		_, _ = conn.ExecContext(context.Background(), "PRAGMA legacy_alter_table = OFF")
+	}()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return []sequelizer.Statement{}, safego.Some(err)
+	}
+
+	return commitStatements(ctx, tx, db.Info.Dialect, statements)
+}
+
+// commitStatements runs the statements in the transaction and commits it, or rolls it back at the first failing
+// statement. With Sqlite, the transaction is also rolled back if it leaves rows referencing missing rows.
+func commitStatements(ctx context.Context, tx *sql.Tx, dialect string, statements []sequelizer.Statement) ([]sequelizer.Statement, safego.Option[error]) {
+	rollback := func(err error) ([]sequelizer.Statement, safego.Option[error]) {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return []sequelizer.Statement{}, safego.Some(fmt.Errorf("%w. Rolling back also failed: %s", err, rollbackErr))
+		}
+
+		return []sequelizer.Statement{}, safego.Some(err)
+	}
+
+	for i, statement := range statements {
+		for _, query := range statement.Queries {
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return rollback(newStatementError(i, len(statements), statement, []string{}, err))
+			}
+		}
+	}
+
+	if dialect == "sqlite" {
+		if errOpt := checkSqliteForeignKeys(ctx, tx); errOpt.IsSome() {
+			return rollback(errOpt.Unwrap())
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return []sequelizer.Statement{}, safego.Some(err)
+	}
+
+	return statements, safego.None[error]()
+}
+
+// checkSqliteForeignKeys fails on the first row that references a missing row, which Sqlite doesn't check while
+// foreign keys are turned off.
+func checkSqliteForeignKeys(ctx context.Context, tx *sql.Tx) safego.Option[error] {
+	rows, err := tx.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return safego.Some(err)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var tableName, referencedTableName string
+		var rowId sql.NullInt64
+		var foreignKeyId int
+		if err := rows.Scan(&tableName, &rowId, &referencedTableName, &foreignKeyId); err != nil {
+			return safego.Some(err)
+		}
+
+		return safego.Some(fmt.Errorf("the migration leaves rows of table %s that reference missing rows of table %s", tableName, referencedTableName))
+	}
+
+	if err := rows.Err(); err != nil {
+		return safego.Some(err)
+	}
+
+	return safego.None[error]()
+}
+
+// newStatementError wraps the error of a failing statement with its position in the migration and the entity it was
+// generated for. executedQueries are the queries of the statement that ran before the failing one and weren't rolled
+// back.
+func newStatementError(index int, total int, statement sequelizer.Statement, executedQueries []string, err error) error {
+	if len(executedQueries) != 0 {
+		return fmt.Errorf("statement %d of %d (%s %s) was partially applied, the following queries ran before it failed and were NOT rolled back:\n%s\nError: %w",
+			index+1, total, statement.EntityType, statement.EntityName, strings.Join(executedQueries, "\n"), err)
+	}
+
+	return fmt.Errorf("statement %d of %d (%s %s) failed: %w", index+1, total, statement.EntityType, statement.EntityName, err)
+}
@@ -0,0 +1,169 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/sequelizer"
+	"github.com/Okira-E/patchi/pkg/types"
+)
+
+// generateTestMigration generates the migration that turns the schema of secondDb into the one of firstDb.
+func generateTestMigration(t *testing.T, firstDb types.DbConnection, secondDb types.DbConnection) []sequelizer.Statement {
+	t.Helper()
+
+	firstSchema, errOpt := difftool.LoadSchema(context.Background(), firstDb)
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+	secondSchema, errOpt := difftool.LoadSchema(context.Background(), secondDb)
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+
+	statements, errMsgOpt := sequelizer.GenerateMigration(firstSchema, secondSchema, "sqlite", difftool.GetSchemaDiff(firstSchema, secondSchema, nil))
+	if errMsgOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errMsgOpt.Unwrap())
+	}
+
+	return statements
+}
+
+func getPragma(t *testing.T, db types.DbConnection, name string) int {
+	t.Helper()
+
+	var ret int
+	if err := db.SqlConnection.QueryRow("PRAGMA " + name).Scan(&ret); err != nil {
+		t.Fatal(err)
+	}
+
+	return ret
+}
+
+func TestApplyMigrationSqliteRebuild(t *testing.T) {
+	firstDb := testutil.NewSqliteDb(t,
+		"CREATE TABLE parents (id INTEGER PRIMARY KEY, name TEXT NOT NULL DEFAULT '')",
+		"CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parents (id) ON DELETE CASCADE)",
+		"CREATE INDEX children_parent_id ON children (parent_id)",
+	)
+	secondDb := testutil.NewSqliteDb(t,
+		"CREATE TABLE parents (id INTEGER PRIMARY KEY, name TEXT, nickname TEXT)",
+		"CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parents (id) ON DELETE CASCADE)",
+		"CREATE INDEX children_parent_id ON children (parent_id)",
+		"INSERT INTO parents VALUES (1, 'a', 'x'), (2, 'b', NULL)",
+		"INSERT INTO children VALUES (1, 1), (2, 1), (3, 2)",
+	)
+
+	statements := generateTestMigration(t, firstDb, secondDb)
+	if !strings.Contains(statements[0].Sql, "PRAGMA legacy_alter_table = ON;") || !strings.HasSuffix(statements[0].Sql, "PRAGMA legacy_alter_table = OFF;") {
+		t.Fatalf("expected parents to be rebuilt, got\n%s", statements[0].Sql)
+	}
+
+	if _, errOpt := ApplyMigration(context.Background(), secondDb, statements); errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+
+	var parentCount, childCount int
+	if err := secondDb.SqlConnection.QueryRow("SELECT (SELECT COUNT(*) FROM parents), (SELECT COUNT(*) FROM children)").Scan(&parentCount, &childCount); err != nil {
+		t.Fatal(err)
+	}
+	if parentCount != 2 || childCount != 3 {
+		t.Errorf("got %d parents and %d children, expected the rows to be kept", parentCount, childCount)
+	}
+
+	if generatedStatements := generateTestMigration(t, firstDb, secondDb); len(generatedStatements) != 0 {
+		t.Errorf("expected the schemas to be in sync, got %+v", generatedStatements)
+	}
+
+	if foreignKeys := getPragma(t, secondDb, "foreign_keys"); foreignKeys != 1 {
+		t.Errorf("expected foreign keys to be turned on again")
+	}
+	if legacyAlterTable := getPragma(t, secondDb, "legacy_alter_table"); legacyAlterTable != 0 {
+		t.Errorf("expected legacy_alter_table to be turned off")
+	}
+}
+
+func TestApplyMigrationSqliteForeignKeyCheck(t *testing.T) {
+	firstDb := testutil.NewSqliteDb(t,
+		"CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parents (id))",
+	)
+	secondDb := testutil.NewSqliteDb(t,
+		"CREATE TABLE parents (id INTEGER PRIMARY KEY)",
+		"CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parents (id))",
+		"INSERT INTO parents VALUES (1)",
+		"INSERT INTO children VALUES (1, 1)",
+	)
+
+	applied, errOpt := ApplyMigration(context.Background(), secondDb, generateTestMigration(t, firstDb, secondDb))
+	if errOpt.IsNone() || !strings.Contains(errOpt.Unwrap().Error(), "reference missing rows of table parents") {
+		t.Fatalf("expected the foreign key check to fail, got %v", errOpt)
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected nothing to be applied, got %+v", applied)
+	}
+
+	var parentCount int
+	if err := secondDb.SqlConnection.QueryRow("SELECT COUNT(*) FROM parents").Scan(&parentCount); err != nil {
+		t.Fatalf("expected parents to be kept: %s", err)
+	}
+	if foreignKeys := getPragma(t, secondDb, "foreign_keys"); foreignKeys != 1 {
+		t.Errorf("expected foreign keys to be turned on again")
+	}
+}
+
+func TestApplyMigrationSequentiallyReportsPartiallyAppliedStatements(t *testing.T) {
+	db := testutil.NewSqliteDb(t, "CREATE TABLE users (id INTEGER PRIMARY KEY)")
+
+	// The body of the trigger holds a `;\n`, which must not be taken for the end of a query.
+	createTrigger := "CREATE TRIGGER users_audit AFTER INSERT ON users BEGIN\n\tSELECT 1;\n\tSELECT 2;\nEND;"
+	statements := []sequelizer.Statement{
+		{EntityType: "triggers", EntityName: "users_audit", Status: "created", Queries: []string{createTrigger}},
+		{EntityType: "indexes", EntityName: "users → users_id", Status: "modified", Queries: []string{
+			"CREATE INDEX users_id ON users (id);",
+			"CREATE INDEX users_id ON users (missing);",
+		}},
+	}
+
+	applied, errOpt := applyMigrationSequentially(context.Background(), db, statements)
+	if errOpt.IsNone() {
+		t.Fatalf("expected the second statement to fail")
+	}
+	if len(applied) != 1 || applied[0].EntityName != "users_audit" {
+		t.Errorf("expected only the trigger to be applied, got %+v", applied)
+	}
+
+	err := errOpt.Unwrap()
+	if !strings.Contains(err.Error(), "statement 2 of 2 (indexes users → users_id) was partially applied") ||
+		!strings.Contains(err.Error(), "\nCREATE INDEX users_id ON users (id);\n") {
+		t.Errorf("expected the executed queries to be reported, got\n%s", err)
+	}
+}
+
+func TestApplyMigrationCanceled(t *testing.T) {
+	firstDb := testutil.NewSqliteDb(t, "CREATE TABLE users (id INTEGER PRIMARY KEY)")
+	secondDb := testutil.NewSqliteDb(t)
+	statements := generateTestMigration(t, firstDb, secondDb)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, errOpt := difftool.LoadSchema(ctx, secondDb); errOpt.IsNone() || !errors.Is(errOpt.Unwrap(), context.Canceled) {
+		t.Errorf("expected reading the schema to be canceled, got %v", errOpt)
+	}
+
+	applied, errOpt := ApplyMigration(ctx, secondDb, statements)
+	if errOpt.IsNone() || !errors.Is(errOpt.Unwrap(), context.Canceled) {
+		t.Errorf("expected the migration to be canceled, got %v", errOpt)
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected nothing to be applied, got %+v", applied)
+	}
+
+	if foreignKeys := getPragma(t, secondDb, "foreign_keys"); foreignKeys != 1 {
+		t.Errorf("expected foreign keys to be left on")
+	}
+}
@@ -0,0 +1,103 @@
+package patchi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/types"
+)
+
+// DiffType is the type of change that has occurred to an entity.
+type DiffType = difftool.DiffType
+
+const (
+	Deleted  = difftool.Deleted
+	Created  = difftool.Created
+	Modified = difftool.Modified
+)
+
+type (
+	TableDiff       = difftool.TableDiff
+	ColumnDiff      = difftool.ColumnDiff
+	IndexDiff       = difftool.IndexDiff
+	ConstraintDiff  = difftool.ConstraintDiff
+	ViewDiff        = difftool.ViewDiff
+	ProcedureDiff   = difftool.ProcedureDiff
+	FunctionDiff    = difftool.FunctionDiff
+	TriggerDiff     = difftool.TriggerDiff
+	RenameCandidate = difftool.RenameCandidate
+	// DialectMismatch is something that didn't translate exactly between the dialects of the compared schemas.
+	DialectMismatch = difftool.DialectMismatch
+	// IgnoreRules holds the patterns of the entities to leave out of a diff, per type of entity. A pattern is either a
+	// glob (e.g. `tmp_*`) or a regular expression wrapped in slashes (e.g. `/^_backup_\d+$/`).
+	IgnoreRules = types.IgnoreRules
+)
+
+// DiffOptions are the options of Diff.
+type DiffOptions struct {
+	// IgnoreRules leave the entities they match out of the diff.
+	IgnoreRules IgnoreRules
+	// AcceptRenames renames the tables and columns that look renamed instead of dropping and creating them again. The
+	// candidates are left in DiffResult.RenameCandidates otherwise.
+	AcceptRenames bool
+}
+
+// DiffResult is every difference between two schemas. Entities are created, modified or deleted from the point of view
+// of the second schema: a created table exists in the first schema only.
+type DiffResult struct {
+	difftool.SchemaDiff
+
+	FirstSchema  *Schema
+	SecondSchema *Schema
+}
+
+// Diff loads two schemas and compares them. The results are sorted by name. Loading the schemas is canceled along with
+// ctx.
+//
+// A Mysql schema can be compared with a Postgres one, and vice versa. The first schema is then translated into the
+// dialect of the second one, which DiffResult.FirstSchema holds, and what doesn't translate exactly is listed in
+// DiffResult.DialectMismatches. Views, routines and triggers can't be translated and are left out of the comparison.
+func Diff(ctx context.Context, first Source, second Source, opts DiffOptions) (*DiffResult, error) {
+	firstSchema, err := first.loadSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	secondSchema, err := second.loadSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !difftool.CanCompareDialects(firstSchema.Dialect, secondSchema.Dialect) {
+		return nil, errors.New("cannot compare a " + firstSchema.Dialect + " schema with a " + secondSchema.Dialect + " schema")
+	}
+
+	mismatches := []DialectMismatch{}
+	if firstSchema.Dialect != secondSchema.Dialect {
+		firstSchema, mismatches = difftool.TranslateSchema(firstSchema, secondSchema)
+	}
+
+	ignoreRules, errOpt := difftool.NewIgnoreRules(opts.IgnoreRules)
+	if errOpt.IsSome() {
+		return nil, errOpt.Unwrap()
+	}
+
+	ret := &DiffResult{
+		SchemaDiff:   difftool.GetSchemaDiff(firstSchema, secondSchema, ignoreRules),
+		FirstSchema:  firstSchema,
+		SecondSchema: secondSchema,
+	}
+	ret.DialectMismatches = mismatches
+
+	if opts.AcceptRenames {
+		ret.ConfirmRenames(ret.RenameCandidates)
+	}
+
+	return ret, nil
+}
+
+// IsEmpty tells whether the schemas are in sync.
+func (self *DiffResult) IsEmpty() bool {
+	return self.Count() == 0
+}
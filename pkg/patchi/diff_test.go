@@ -0,0 +1,84 @@
+package patchi
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+)
+
+func TestDiffAndGenerateMigration(t *testing.T) {
+	ctx := context.Background()
+	firstDb := testutil.NewSqliteDb(t, "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT)").SqlConnection
+	secondDb := testutil.NewSqliteDb(t, "CREATE TABLE users (id INTEGER PRIMARY KEY)", "INSERT INTO users (id) VALUES (1)").SqlConnection
+
+	result, err := Diff(ctx, FromDatabase(firstDb, "sqlite", ""), FromDatabase(secondDb, "sqlite", ""), DiffOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(result.Columns) != 1 || result.Columns[0].ColumnName != "email" || result.Columns[0].DiffType != Created {
+		t.Fatalf("expected users → email to be created, got %+v", result.Columns)
+	}
+
+	migration, err := GenerateMigration(result, MigrationOptions{FailOnDestructive: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(migration.Statements) != 1 || migration.Statements[0].Sql != `ALTER TABLE "users" ADD COLUMN "email" TEXT;` {
+		t.Fatalf("unexpected statements %+v", migration.Statements)
+	}
+
+	if _, err := Apply(ctx, secondDb, migration); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	result, err = Diff(ctx, FromDatabase(firstDb, "sqlite", ""), FromDatabase(secondDb, "sqlite", ""), DiffOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !result.IsEmpty() {
+		t.Errorf("expected the databases to be in sync, got %+v", result.SchemaDiff)
+	}
+}
+
+func TestGenerateMigrationFailsOnDestructiveChanges(t *testing.T) {
+	firstDb := testutil.NewSqliteDb(t).SqlConnection
+	secondDb := testutil.NewSqliteDb(t, "CREATE TABLE users (id INTEGER PRIMARY KEY)").SqlConnection
+
+	result, err := Diff(context.Background(), FromDatabase(firstDb, "sqlite", ""), FromDatabase(secondDb, "sqlite", ""), DiffOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	migration, err := GenerateMigration(result, MigrationOptions{FailOnDestructive: true})
+	if !errors.Is(err, ErrDestructive) {
+		t.Fatalf("expected ErrDestructive, got %v", err)
+	}
+	if migration == nil || len(migration.Risks) == 0 {
+		t.Errorf("expected the migration to be returned along with its risks")
+	}
+}
+
+func TestDiffCanceled(t *testing.T) {
+	firstDb := testutil.NewSqliteDb(t, "CREATE TABLE users (id INTEGER PRIMARY KEY)").SqlConnection
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		source Source
+	}{
+		{name: "database", source: FromDatabase(firstDb, "sqlite", "")},
+		{name: "schema", source: FromSchema(&Schema{Dialect: "sqlite"})},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Diff(ctx, test.source, test.source, DiffOptions{}); !errors.Is(err, context.Canceled) {
+				t.Errorf("expected the diff to be canceled, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package patchi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Okira-E/patchi/pkg/migrator"
+	"github.com/Okira-E/patchi/pkg/safety"
+	"github.com/Okira-E/patchi/pkg/sequelizer"
+)
+
+// ErrDestructive is returned by GenerateMigration when MigrationOptions.FailOnDestructive is set and the migration
+// would lose data.
+var ErrDestructive = errors.New("the migration has destructive changes")
+
+type (
+	// Statement is a piece of generated SQL along with the entity it was generated for.
+	Statement = sequelizer.Statement
+	// Risk is a change of a migration that loses data or locks a table.
+	Risk      = safety.Risk
+	RiskLevel = safety.RiskLevel
+)
+
+const (
+	Warning     = safety.Warning
+	Destructive = safety.Destructive
+)
+
+// MigrationOptions are the options of GenerateMigration.
+type MigrationOptions struct {
+	// RowCounts holds the number of rows of the tables of the second database. Changes to empty tables aren't risky.
+	// Every table is assumed to hold data when it is nil. See LoadRowCounts.
+	RowCounts map[string]int64
+	// FailOnDestructive makes GenerateMigration return ErrDestructive, along with the migration, when any change would
+	// lose data.
+	FailOnDestructive bool
+}
+
+// Migration is the SQL that brings the second database of a diff in line with the first one.
+type Migration struct {
+	Dialect string
+	// Statements are ordered by the dependencies between the entities. Everything that is deleted comes first.
+	Statements []Statement
+	// Risks are the changes that lose data or lock tables.
+	Risks []Risk
+
+	firstSchema  *Schema
+	secondSchema *Schema
+}
+
+// GenerateMigration generates the statements of a diff and analyzes their risks.
+func GenerateMigration(result *DiffResult, opts MigrationOptions) (*Migration, error) {
+	dialect := result.FirstSchema.Dialect
+
+	statements, errOpt := sequelizer.GenerateMigration(result.FirstSchema, result.SecondSchema, dialect, result.SchemaDiff)
+	if errOpt.IsSome() {
+		return nil, errors.New(errOpt.Unwrap())
+	}
+
+	ret := &Migration{
+		Dialect:      dialect,
+		Statements:   statements,
+		Risks:        safety.AnalyzeMigration(statements, result.FirstSchema, result.SecondSchema, opts.RowCounts),
+		firstSchema:  result.FirstSchema,
+		secondSchema: result.SecondSchema,
+	}
+
+	if opts.FailOnDestructive && safety.CountDestructive(ret.Risks) != 0 {
+		return ret, ErrDestructive
+	}
+
+	return ret, nil
+}
+
+// Rollback returns the migration that undoes this one. The row counts of the first database aren't known, so every
+// table is assumed to hold data by its risks.
+func (self *Migration) Rollback() *Migration {
+	statements := sequelizer.GetRollbackStatements(self.Statements)
+
+	return &Migration{
+		Dialect:      self.Dialect,
+		Statements:   statements,
+		Risks:        safety.AnalyzeMigration(statements, self.secondSchema, self.firstSchema, nil),
+		firstSchema:  self.secondSchema,
+		secondSchema: self.firstSchema,
+	}
+}
+
+// Script joins the statements into a single script that can be run by the database's CLI client. The title is added
+// at the top of the script as a comment, along with the time it was generated at.
+func (self *Migration) Script(title string) string {
+	header := []string{title, "Dialect: " + self.Dialect, "Generated at: " + time.Now().UTC().Format(time.RFC3339)}
+
+	return sequelizer.FormatMigrationScript(self.Statements, self.Dialect, header)
+}
+
+// Apply runs the migration against the second database of the diff it was generated from. It returns the statements
+// that were applied, which are all or none of them on Postgres and Sqlite since the migration runs in a transaction.
+// On Mysql, the statements before the failing one stay applied. The statements are canceled along with ctx.
+func Apply(ctx context.Context, db *sql.DB, migration *Migration) ([]Statement, error) {
+	applied, errOpt := migrator.ApplyMigration(ctx, newDbConnection(db, migration.Dialect, ""), migration.Statements)
+	if errOpt.IsSome() {
+		return applied, errOpt.Unwrap()
+	}
+
+	return applied, nil
+}
+
+// LoadRowCounts returns the estimated number of rows of every table in a database, which is what
+// MigrationOptions.RowCounts expects. The queries are canceled along with ctx.
+func LoadRowCounts(ctx context.Context, db *sql.DB, dialect string, databaseName string) (map[string]int64, error) {
+	ret, errOpt := safety.LoadRowCounts(ctx, newDbConnection(db, dialect, databaseName))
+	if errOpt.IsSome() {
+		return nil, errOpt.Unwrap()
+	}
+
+	return ret, nil
+}
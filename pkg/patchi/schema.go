@@ -0,0 +1,129 @@
+// Package patchi is the library behind the Patchi CLI. It loads the schemas of two databases, compares them and
+// generates the migration that brings the second database in line with the first one, without any of the prompts,
+// colors or output of the CLI:
+//
+//	first := patchi.FromDatabase(stagingDb, "postgres", "app")
+//	second := patchi.FromDatabase(productionDb, "postgres", "app")
+//
+//	result, err := patchi.Diff(ctx, first, second, patchi.DiffOptions{AcceptRenames: true})
+//	migration, err := patchi.GenerateMigration(result, patchi.MigrationOptions{FailOnDestructive: true})
+//
+//	fmt.Print(migration.Script("Migration of production"))
+package patchi
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/types"
+)
+
+// Schema is an in-memory model of everything Patchi compares in a database.
+type Schema = schema.Schema
+
+type (
+	Table       = schema.Table
+	Column      = schema.Column
+	Index       = schema.Index
+	IndexColumn = schema.IndexColumn
+	Constraint  = schema.Constraint
+	View        = schema.View
+	Routine     = schema.Routine
+	Trigger     = schema.Trigger
+)
+
+// LoadSchema introspects the schema of a database. The dialect is one of mysql, mariadb, postgres, cockroachdb or
+// sqlite. databaseName is the name of the database for Mysql and MariaDB, and is only informative for the others,
+// which are read from the current schema of the connection. The queries are canceled along with ctx.
+func LoadSchema(ctx context.Context, db *sql.DB, dialect string, databaseName string) (*Schema, error) {
+	ret, errOpt := difftool.LoadSchema(ctx, newDbConnection(db, dialect, databaseName))
+	if errOpt.IsSome() {
+		return nil, errOpt.Unwrap()
+	}
+
+	return ret, nil
+}
+
+// Source is where Diff reads a schema from. See FromDatabase, FromSnapshot and FromSchema.
+type Source interface {
+	loadSchema(ctx context.Context) (*Schema, error)
+}
+
+type databaseSource struct {
+	db           *sql.DB
+	dialect      string
+	databaseName string
+}
+
+func (self databaseSource) loadSchema(ctx context.Context) (*Schema, error) {
+	return LoadSchema(ctx, self.db, self.dialect, self.databaseName)
+}
+
+type snapshotSource struct {
+	filePath string
+}
+
+func (self snapshotSource) loadSchema(ctx context.Context) (*Schema, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return ReadSnapshot(self.filePath)
+}
+
+type schemaSource struct {
+	dbSchema *Schema
+}
+
+func (self schemaSource) loadSchema(ctx context.Context) (*Schema, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return self.dbSchema, nil
+}
+
+// FromDatabase is a schema that Diff introspects with LoadSchema.
+func FromDatabase(db *sql.DB, dialect string, databaseName string) Source {
+	return databaseSource{db: db, dialect: dialect, databaseName: databaseName}
+}
+
+// FromSnapshot is a schema that Diff reads from a snapshot file with ReadSnapshot.
+func FromSnapshot(filePath string) Source {
+	return snapshotSource{filePath: filePath}
+}
+
+// FromSchema is a schema that is already loaded, e.g. with LoadSchema.
+func FromSchema(dbSchema *Schema) Source {
+	return schemaSource{dbSchema: dbSchema}
+}
+
+// ReadSnapshot reads a schema from a snapshot file written by `patchi snapshot` or MarshalSnapshot.
+func ReadSnapshot(filePath string) (*Schema, error) {
+	ret, errOpt := schema.ReadSnapshot(filePath)
+	if errOpt.IsSome() {
+		return nil, errOpt.Unwrap()
+	}
+
+	return ret, nil
+}
+
+// MarshalSnapshot serializes a schema into the snapshot format.
+func MarshalSnapshot(dbSchema *Schema) ([]byte, error) {
+	ret, errOpt := schema.MarshalSnapshot(dbSchema)
+	if errOpt.IsSome() {
+		return nil, errOpt.Unwrap()
+	}
+
+	return ret, nil
+}
+
+// newDbConnection wraps a database handle the way the rest of Patchi expects it.
+func newDbConnection(db *sql.DB, dialect string, databaseName string) types.DbConnection {
+	return types.DbConnection{
+		Info:          &types.DbConnectionInfo{Dialect: dialect, DatabaseName: databaseName},
+		SqlConnection: db,
+	}
+}
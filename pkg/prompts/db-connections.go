@@ -1,6 +1,7 @@
 package prompts
 
 import (
+	"github.com/Okira-E/patchi/pkg/difftool"
 	"github.com/Okira-E/patchi/pkg/utils"
 	"github.com/Okira-E/patchi/safego"
 	"sort"
@@ -37,21 +38,21 @@ func PromptForDbConnections(userConfig types.UserConfig) (*types.DbConnectionInf
 		return &types.DbConnectionInfo{}, &types.DbConnectionInfo{}, safego.Some(err.Error())
 	}
 
-	utils.PrintInColor(colors.Cyan, "Choose the second database (The dialect must be the same as the first one):", false)
+	utils.PrintInColor(colors.Cyan, "Choose the second database (Mysql and Postgres can be compared with each other):", false)
 
 	// Filter out the first selected connection from the list of connections for the second
 	// prompt (We don't want to compare a database with itself.)
-	// Also, filter out the connections that have a different dialect than the first selected connection.
+	// Also, filter out the connections with a dialect that can't be compared with the first selected connection.
 
 	filteredConnectionNamesForSecondPrompt := []string{}
 	for connectionName, connection := range userConfig.DbConnections {
-		if connectionName != firstSelectedConnectionName && connection.Dialect == userConfig.DbConnections[firstSelectedConnectionName].Dialect {
+		if connectionName != firstSelectedConnectionName && difftool.CanCompareDialects(userConfig.DbConnections[firstSelectedConnectionName].Dialect, connection.Dialect) {
 			filteredConnectionNamesForSecondPrompt = append(filteredConnectionNamesForSecondPrompt, connectionName)
 		}
 	}
 
 	if len(filteredConnectionNamesForSecondPrompt) == 0 {
-		return &types.DbConnectionInfo{}, &types.DbConnectionInfo{}, safego.Some("no connections with the same dialect remaining")
+		return &types.DbConnectionInfo{}, &types.DbConnectionInfo{}, safego.Some("no connections with a comparable dialect remaining")
 	}
 
 	secondSelectedConnectionPrmpt := promptui.Select{
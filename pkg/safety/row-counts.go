@@ -0,0 +1,83 @@
+package safety
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/types"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// LoadRowCounts returns the estimated number of rows of every table in the database, based on the statistics the
+// database keeps, since counting the rows of large tables can take a long time. The estimates can be stale, so tables
+// that are reported as empty are checked for a row, and left out of the counts if they have one. The queries are
+// canceled along with ctx.
+func LoadRowCounts(ctx context.Context, db types.DbConnection) (map[string]int64, safego.Option[error]) {
+	var query string
+	var args []any
+	if db.Info.Dialect == "mysql" || db.Info.Dialect == "mariadb" {
+		query = "SELECT TABLE_NAME, COALESCE(TABLE_ROWS, -1) FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'"
+		args = []any{db.Info.DatabaseName}
+	} else if db.Info.Dialect == "postgres" || db.Info.Dialect == "cockroachdb" {
+		// reltuples is -1 for tables that were never analyzed.
+		query = `
+			SELECT c.relname, c.reltuples::bigint
+			FROM pg_catalog.pg_class c
+			JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p')
+		`
+	} else if db.Info.Dialect == "sqlite" {
+		// Sqlite keeps no estimates, so every table is reported as empty and only checked for a row below.
+		query = "SELECT name, 0 FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite\\_%' ESCAPE '\\'"
+	}
+
+	rows, err := db.SqlConnection.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, safego.Some(err)
+	}
+	defer rows.Close()
+
+	ret := map[string]int64{}
+	for rows.Next() {
+		var tableName string
+		var rowCount int64
+		if err := rows.Scan(&tableName, &rowCount); err != nil {
+			return nil, safego.Some(err)
+		}
+
+		if rowCount >= 0 {
+			ret[tableName] = rowCount
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, safego.Some(err)
+	}
+
+	for tableName, rowCount := range ret {
+		if rowCount != 0 {
+			continue
+		}
+
+		var hasRows bool
+		err := db.SqlConnection.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM "+quoteIdentifier(db.Info.Dialect, tableName)+")").Scan(&hasRows)
+		if err != nil {
+			return nil, safego.Some(err)
+		}
+
+		if hasRows {
+			delete(ret, tableName)
+		}
+	}
+
+	return ret, safego.None[error]()
+}
+
+// quoteIdentifier quotes a table name for the dialect.
+func quoteIdentifier(dialect string, identifier string) string {
+	if dialect == "mysql" || dialect == "mariadb" {
+		return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+	}
+
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
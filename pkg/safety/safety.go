@@ -0,0 +1,141 @@
+package safety
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/sequelizer"
+)
+
+// RiskLevel represents how dangerous a statement is.
+type RiskLevel int8
+
+const (
+	// Warning is a statement that could fail or lock a table for a long time, but doesn't lose any data.
+	Warning RiskLevel = 0
+	// Destructive is a statement that loses data.
+	Destructive RiskLevel = 1
+)
+
+// String returns the name of the risk level as it is shown to the user.
+func (self RiskLevel) String() string {
+	if self == Destructive {
+		return "destructive"
+	}
+
+	return "warning"
+}
+
+// MarshalText makes the risk level show up by its name instead of its number in JSON and YAML output.
+func (self RiskLevel) MarshalText() ([]byte, error) {
+	return []byte(self.String()), nil
+}
+
+// Risk is a danger of running a statement of a migration against the second database.
+type Risk struct {
+	EntityType string    `json:"entity_type" yaml:"entity_type"`
+	EntityName string    `json:"entity_name" yaml:"entity_name"`
+	Level      RiskLevel `json:"level" yaml:"level"`
+	Message    string    `json:"message" yaml:"message"`
+}
+
+// AnalyzeMigration flags the statements of a migration that lose data, that could fail on a non-empty table or that
+// lock a table while it is rewritten or scanned. rowCounts holds the (estimated) number of rows of the tables of the
+// second database, and is used to tell empty tables apart and to report how many rows are affected. It can be nil when
+// the counts are unknown, e.g. when the second side is a snapshot.
+func AnalyzeMigration(statements []sequelizer.Statement, firstSchema *schema.Schema, secondSchema *schema.Schema, rowCounts map[string]int64) []Risk {
+	ret := []Risk{}
+
+	for _, statement := range statements {
+		tableName, entityName, isTableEntity := strings.Cut(statement.EntityName, " → ")
+		if !isTableEntity {
+			tableName, entityName = statement.EntityName, statement.EntityName
+		}
+
+		rowCount, isRowCountKnown := rowCounts[tableName]
+		rowsSuffix := ""
+		if isRowCountKnown {
+			rowsSuffix = fmt.Sprintf(" (~%d rows)", rowCount)
+		}
+
+		addRisk := func(level RiskLevel, message string) {
+			ret = append(ret, Risk{
+				EntityType: statement.EntityType,
+				EntityName: statement.EntityName,
+				Level:      level,
+				Message:    message + rowsSuffix,
+			})
+		}
+
+		// Existing tables that are empty can't lose data, and can't be locked for long.
+		if isRowCountKnown && rowCount == 0 {
+			continue
+		}
+
+		if statement.EntityType == "tables" && statement.Status == "deleted" {
+			addRisk(Destructive, "Dropping table "+tableName+" deletes all of its data")
+		} else if statement.EntityType == "columns" && statement.Status == "deleted" {
+			addRisk(Destructive, "Dropping column "+statement.EntityName+" deletes all of its data")
+		} else if statement.EntityType == "columns" && statement.Status == "created" {
+			if _, ok := secondSchema.Tables[tableName]; !ok {
+				continue
+			}
+
+			columnOpt := firstSchema.Tables[tableName].GetColumn(entityName)
+			if columnOpt.IsNone() {
+				continue
+			}
+			column := columnOpt.Unwrap()
+
+			// Identity, auto increment and generated columns get their values from the database.
+			if !column.IsNullable && column.Default == nil && column.Extra == "" {
+				addRisk(Warning, "Adding NOT NULL column "+statement.EntityName+" without a default fails on a non-empty table")
+			}
+		} else if statement.EntityType == "columns" && statement.Status == "modified" {
+			firstColumnOpt := firstSchema.Tables[tableName].GetColumn(entityName)
+			secondColumnOpt := secondSchema.Tables[tableName].GetColumn(entityName)
+			if firstColumnOpt.IsNone() || secondColumnOpt.IsNone() {
+				continue
+			}
+			firstColumn, secondColumn := firstColumnOpt.Unwrap(), secondColumnOpt.Unwrap()
+
+			if firstColumn.Type != secondColumn.Type {
+				if isTypeNarrowing(firstSchema.Dialect, secondColumn.Type, firstColumn.Type) {
+					addRisk(Destructive, "Changing the type of "+statement.EntityName+" from "+secondColumn.Type+" to "+firstColumn.Type+" can truncate or lose data")
+				} else {
+					addRisk(Warning, "Changing the type of "+statement.EntityName+" rewrites table "+tableName+", locking it until it is done")
+				}
+			}
+
+			if !firstColumn.IsNullable && secondColumn.IsNullable {
+				addRisk(Warning, "Making "+statement.EntityName+" NOT NULL fails if it holds NULL values, and scans table "+tableName)
+			}
+		} else if statement.EntityType == "indexes" && (statement.Status == "created" || statement.Status == "modified") {
+			// Mysql builds indexes online, while Postgres blocks writes unless the index is built concurrently.
+			_, isExistingTable := secondSchema.Tables[tableName]
+			if isExistingTable && (firstSchema.Dialect == "postgres" || firstSchema.Dialect == "cockroachdb") {
+				addRisk(Warning, "Building index "+statement.EntityName+" blocks writes to table "+tableName+" until it is done")
+			}
+		} else if statement.EntityType == "constraints" && (statement.Status == "created" || statement.Status == "modified") {
+			if _, ok := secondSchema.Tables[tableName]; ok {
+				addRisk(Warning, "Adding constraint "+statement.EntityName+" scans table "+tableName+" to validate it, locking it until it is done")
+			}
+		}
+	}
+
+	return ret
+}
+
+// CountDestructive returns the number of risks that lose data.
+func CountDestructive(risks []Risk) int {
+	ret := 0
+
+	for _, risk := range risks {
+		if risk.Level == Destructive {
+			ret += 1
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,263 @@
+package safety
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/utils"
+)
+
+// columnType is a column type broken down into the parts that decide how much it can hold.
+type columnType struct {
+	// Base is the name of the type without its parameters. e.g. `varchar` or `timestamp without time zone`.
+	Base string
+	// Params are the parameters of the type. e.g. the length of a varchar or the values of an enum.
+	Params     []string
+	IsUnsigned bool
+}
+
+var (
+	integerSizes = map[string]int{
+		"tinyint": 1, "smallint": 2, "int2": 2, "mediumint": 3, "int": 4, "integer": 4, "int4": 4, "bigint": 8, "int8": 8,
+	}
+	floatSizes = map[string]int{
+		"float": 4, "real": 4, "float4": 4, "double": 8, "double precision": 8, "float8": 8,
+	}
+	// The number of digits of the largest value of an integer type, by its size.
+	integerDigits = map[int]int64{1: 3, 2: 5, 3: 8, 4: 10, 8: 20}
+	decimalTypes  = []string{"decimal", "numeric", "dec", "fixed"}
+
+	// Types that hold text or bytes up to the length given as their first parameter.
+	sizedTextTypes   = []string{"char", "character", "bpchar", "nchar", "varchar", "character varying", "nvarchar"}
+	sizedBinaryTypes = []string{"binary", "varbinary"}
+
+	// The capacity in bytes of the text and binary types that don't take a length.
+	mysqlTextCapacities = map[string]int64{
+		"tinytext": 255, "text": 65535, "mediumtext": 16777215, "longtext": 4294967295,
+	}
+	mysqlBlobCapacities = map[string]int64{
+		"tinyblob": 255, "blob": 65535, "mediumblob": 16777215, "longblob": 4294967295,
+	}
+)
+
+// unboundedCapacity is the capacity of types without a size limit, such as `text` in Postgres.
+const unboundedCapacity = math.MaxInt64
+
+// isTypeNarrowing reports whether changing a column from oldType to newType can truncate, round or reject the values
+// it holds. Types that can't be compared, e.g. a date turned into an integer, are assumed to be narrowing.
+func isTypeNarrowing(dialect string, oldType string, newType string) bool {
+	oldColumnType, newColumnType := parseColumnType(oldType), parseColumnType(newType)
+
+	// Anything can be cast to text without losing data.
+	newTextCapacity, isNewText := getTextCapacity(dialect, newColumnType)
+	if isNewText && newTextCapacity == unboundedCapacity {
+		return false
+	}
+
+	oldIntegerSize, isOldInteger := integerSizes[oldColumnType.Base]
+	newIntegerSize, isNewInteger := integerSizes[newColumnType.Base]
+	if isOldInteger && isNewInteger {
+		// Signed to unsigned loses the negative values, and unsigned to signed loses the upper half of the range
+		// unless the new type is bigger.
+		if oldColumnType.IsUnsigned == newColumnType.IsUnsigned || oldColumnType.IsUnsigned {
+			return newIntegerSize < oldIntegerSize || (oldColumnType.IsUnsigned != newColumnType.IsUnsigned && newIntegerSize == oldIntegerSize)
+		}
+
+		return true
+	}
+
+	oldFloatSize, isOldFloat := floatSizes[oldColumnType.Base]
+	newFloatSize, isNewFloat := floatSizes[newColumnType.Base]
+	if isOldFloat && isNewFloat {
+		return newFloatSize < oldFloatSize
+	}
+
+	isOldDecimal := slices.Contains(decimalTypes, oldColumnType.Base)
+	isNewDecimal := slices.Contains(decimalTypes, newColumnType.Base)
+	if isOldDecimal && isNewDecimal {
+		oldPrecision, oldScale := getDecimalPrecision(dialect, oldColumnType)
+		newPrecision, newScale := getDecimalPrecision(dialect, newColumnType)
+		if newPrecision == unboundedCapacity {
+			// A `numeric` without a precision keeps any scale as well.
+			return false
+		}
+
+		return newPrecision-newScale < oldPrecision-oldScale || newScale < oldScale
+	}
+
+	// Integers fit in floats, even if very large ones could be rounded, and in decimals with enough digits.
+	if isOldInteger && isNewFloat {
+		return false
+	} else if isOldInteger && isNewDecimal {
+		newPrecision, newScale := getDecimalPrecision(dialect, newColumnType)
+
+		return newPrecision-newScale < integerDigits[oldIntegerSize]
+	}
+
+	oldTextCapacity, isOldText := getTextCapacity(dialect, oldColumnType)
+	if isOldText && isNewText {
+		return newTextCapacity < oldTextCapacity
+	}
+
+	oldBinaryCapacity, isOldBinary := getBinaryCapacity(dialect, oldColumnType)
+	newBinaryCapacity, isNewBinary := getBinaryCapacity(dialect, newColumnType)
+	if isOldBinary && isNewBinary {
+		return newBinaryCapacity < oldBinaryCapacity
+	}
+
+	if (oldColumnType.Base == "enum" || oldColumnType.Base == "set") && oldColumnType.Base == newColumnType.Base {
+		for _, value := range oldColumnType.Params {
+			if !slices.Contains(newColumnType.Params, value) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if oldColumnType.Base != newColumnType.Base {
+		return true
+	}
+
+	// Anything else with the same base type only differs by its parameters, such as the fractional seconds of a
+	// timestamp or the length of a bit string. Mysql defaults those to their lowest value, and Postgres to its highest.
+	for i := range oldColumnType.Params {
+		if i >= len(newColumnType.Params) {
+			return dialect == "mysql" || dialect == "mariadb"
+		}
+
+		oldParam, oldErr := strconv.ParseInt(strings.TrimSpace(oldColumnType.Params[i]), 10, 64)
+		newParam, newErr := strconv.ParseInt(strings.TrimSpace(newColumnType.Params[i]), 10, 64)
+		if oldErr != nil || newErr != nil {
+			return oldColumnType.Params[i] != newColumnType.Params[i]
+		}
+		if newParam < oldParam {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseColumnType breaks down a column type as reported by Mysql (COLUMN_TYPE) or Postgres (format_type.)
+func parseColumnType(rawType string) columnType {
+	ret := columnType{}
+
+	rawType = strings.ToLower(strings.TrimSpace(rawType))
+
+	ret.IsUnsigned = strings.Contains(rawType, " unsigned")
+	rawType = strings.ReplaceAll(rawType, " unsigned", "")
+	rawType = strings.ReplaceAll(rawType, " zerofill", "")
+
+	openIndex := strings.Index(rawType, "(")
+	closeIndex := strings.LastIndex(rawType, ")")
+	if openIndex != -1 && closeIndex > openIndex {
+		ret.Params = splitTypeParams(rawType[openIndex+1 : closeIndex])
+		rawType = rawType[:openIndex] + " " + rawType[closeIndex+1:]
+	}
+
+	ret.Base = strings.Join(strings.Fields(rawType), " ")
+
+	return ret
+}
+
+// splitTypeParams splits the parameters of a type on the commas that aren't within quotes, as in the values of an enum.
+func splitTypeParams(params string) []string {
+	ret := []string{}
+
+	current := strings.Builder{}
+	isInQuotes := false
+	for _, char := range params {
+		if char == '\'' {
+			isInQuotes = !isInQuotes
+		}
+
+		if char == ',' && !isInQuotes {
+			ret = append(ret, strings.TrimSpace(current.String()))
+			current.Reset()
+			continue
+		}
+
+		current.WriteRune(char)
+	}
+	ret = append(ret, strings.TrimSpace(current.String()))
+
+	return ret
+}
+
+// getTextCapacity returns the number of characters (or bytes for the Mysql text types) a text type can hold. The
+// second return value is false if the type doesn't hold text.
+func getTextCapacity(dialect string, columnType columnType) (int64, bool) {
+	if dialect == "mysql" || dialect == "mariadb" {
+		if capacity, ok := mysqlTextCapacities[columnType.Base]; ok {
+			return capacity, true
+		}
+	} else if columnType.Base == "text" {
+		return unboundedCapacity, true
+	}
+
+	if slices.Contains(sizedTextTypes, columnType.Base) {
+		return getLengthParam(columnType, strings.Contains(columnType.Base, "var")), true
+	}
+
+	return 0, false
+}
+
+// getBinaryCapacity returns the number of bytes a binary type can hold. The second return value is false if the type
+// doesn't hold bytes.
+func getBinaryCapacity(dialect string, columnType columnType) (int64, bool) {
+	if dialect == "mysql" || dialect == "mariadb" {
+		if capacity, ok := mysqlBlobCapacities[columnType.Base]; ok {
+			return capacity, true
+		}
+	} else if columnType.Base == "bytea" {
+		return unboundedCapacity, true
+	}
+
+	if slices.Contains(sizedBinaryTypes, columnType.Base) {
+		return getLengthParam(columnType, columnType.Base == "varbinary"), true
+	}
+
+	return 0, false
+}
+
+// getLengthParam returns the length a type is declared with. A `varchar` without a length is unbounded in Postgres,
+// while a `char` without a length holds a single character.
+func getLengthParam(columnType columnType, isVarying bool) int64 {
+	if len(columnType.Params) == 0 {
+		return utils.Ternary[int64](isVarying, unboundedCapacity, 1)
+	}
+
+	length, err := strconv.ParseInt(columnType.Params[0], 10, 64)
+	if err != nil {
+		return unboundedCapacity
+	}
+
+	return length
+}
+
+// getDecimalPrecision returns the precision and scale of a decimal type. A `numeric` without a precision is unbounded
+// in Postgres, while a `decimal` without a precision is `decimal(10, 0)` in Mysql.
+func getDecimalPrecision(dialect string, columnType columnType) (int64, int64) {
+	if len(columnType.Params) == 0 {
+		if dialect == "mysql" || dialect == "mariadb" {
+			return 10, 0
+		}
+
+		return unboundedCapacity, 0
+	}
+
+	precision, err := strconv.ParseInt(columnType.Params[0], 10, 64)
+	if err != nil {
+		return unboundedCapacity, 0
+	}
+
+	var scale int64
+	if len(columnType.Params) > 1 {
+		scale, _ = strconv.ParseInt(columnType.Params[1], 10, 64)
+	}
+
+	return precision, scale
+}
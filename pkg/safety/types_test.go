@@ -0,0 +1,94 @@
+package safety
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIsTypeNarrowing(t *testing.T) {
+	tests := []struct {
+		dialect  string
+		oldType  string
+		newType  string
+		expected bool
+	}{
+		// Integers.
+		{"mysql", "int(11)", "bigint(20)", false},
+		{"mysql", "bigint", "int", true},
+		{"mysql", "int unsigned", "bigint", false},
+		{"mysql", "int unsigned", "int", true},
+		{"mysql", "int", "int unsigned", true},
+		{"mysql", "tinyint(3) unsigned zerofill", "smallint unsigned", false},
+		{"postgres", "integer", "smallint", true},
+		{"postgres", "int4", "int8", false},
+
+		// Floats and decimals.
+		{"postgres", "double precision", "real", true},
+		{"postgres", "real", "double precision", false},
+		{"mysql", "decimal(10,2)", "decimal(12,2)", false},
+		{"mysql", "decimal(10,2)", "decimal(10,1)", true},
+		{"mysql", "decimal(10,2)", "decimal(11,3)", false},
+		{"mysql", "decimal(10,2)", "decimal(10,3)", true},
+		{"mysql", "decimal(12,0)", "decimal", true},
+		{"postgres", "numeric(12,2)", "numeric", false},
+		{"postgres", "integer", "double precision", false},
+		{"mysql", "int", "decimal(10,0)", false},
+		{"mysql", "bigint", "decimal(10,0)", true},
+		{"postgres", "numeric(10,2)", "integer", true},
+
+		// Text and bytes.
+		{"postgres", "character varying(100)", "character varying(255)", false},
+		{"postgres", "character varying(100)", "character varying(50)", true},
+		{"postgres", "character varying(100)", "character varying", false},
+		{"postgres", "character varying", "character varying(100)", true},
+		{"postgres", "character(10)", "character", true},
+		{"postgres", "timestamp without time zone", "text", false},
+		{"mysql", "varchar(255)", "text", false},
+		{"mysql", "mediumtext", "text", true},
+		{"mysql", "text", "varchar(100)", true},
+		{"mysql", "varbinary(16)", "blob", false},
+		{"mysql", "longblob", "varbinary(255)", true},
+		{"postgres", "bytea", "bytea", false},
+
+		// Enums and sets.
+		{"mysql", "enum('a','b')", "enum('a','b','c')", false},
+		{"mysql", "enum('a','b')", "enum('a')", true},
+		{"mysql", "set('a,b','c')", "set('c','a,b')", false},
+
+		// Other types.
+		{"mysql", "datetime(6)", "datetime(3)", true},
+		{"mysql", "datetime(3)", "datetime", true},
+		{"postgres", "timestamp(3) without time zone", "timestamp without time zone", false},
+		{"postgres", "bit(8)", "bit(16)", false},
+		{"postgres", "date", "integer", true},
+		{"postgres", "uuid", "uuid", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.dialect+" "+test.oldType+" to "+test.newType, func(t *testing.T) {
+			if isNarrowing := isTypeNarrowing(test.dialect, test.oldType, test.newType); isNarrowing != test.expected {
+				t.Errorf("got %t, expected %t", isNarrowing, test.expected)
+			}
+		})
+	}
+}
+
+func TestParseColumnType(t *testing.T) {
+	tests := []struct {
+		rawType  string
+		expected columnType
+	}{
+		{"INT(10) UNSIGNED ZEROFILL", columnType{Base: "int", Params: []string{"10"}, IsUnsigned: true}},
+		{"timestamp(3) without time zone", columnType{Base: "timestamp without time zone", Params: []string{"3"}}},
+		{"numeric(10, 2)", columnType{Base: "numeric", Params: []string{"10", "2"}}},
+		{"enum('a,b','c')", columnType{Base: "enum", Params: []string{"'a,b'", "'c'"}}},
+		{"text", columnType{Base: "text"}},
+	}
+
+	for _, test := range tests {
+		parsed := parseColumnType(test.rawType)
+		if parsed.Base != test.expected.Base || parsed.IsUnsigned != test.expected.IsUnsigned || !slices.Equal(parsed.Params, test.expected.Params) {
+			t.Errorf("%s: got %+v, expected %+v", test.rawType, parsed, test.expected)
+		}
+	}
+}
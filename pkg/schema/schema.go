@@ -0,0 +1,169 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/Okira-E/patchi/safego"
+)
+
+// Schema is an in-memory model of everything Patchi compares in a database. It is either introspected from a live
+// connection (see difftool.LoadSchema) or read from a snapshot file, and the diff and SQL generation only ever work
+// against it.
+type Schema struct {
+	// Version is the version of the snapshot format the schema was written with.
+	Version int    `json:"version"`
+	Dialect string `json:"dialect"`
+	// ConnectionName is the name of the stored connection the schema was introspected from.
+	ConnectionName string    `json:"connection_name"`
+	DatabaseName   string    `json:"database_name"`
+	CreatedAt      time.Time `json:"created_at"`
+
+	Tables     map[string]*Table   `json:"tables"`
+	Views      map[string]*View    `json:"views"`
+	Procedures map[string]*Routine `json:"procedures"`
+	Functions  map[string]*Routine `json:"functions"`
+	// Triggers are keyed by their name. In Postgres, where trigger names are only unique per table, the name is in the
+	// form of `trigger ON table`.
+	Triggers map[string]*Trigger `json:"triggers"`
+}
+
+type Table struct {
+	Name string `json:"name"`
+	// Definition is the `CREATE TABLE` statement as reported by Mysql or Sqlite. It is empty for Postgres, where the
+	// statement is put together from the columns, constraints and indexes instead.
+	Definition string `json:"definition,omitempty"`
+	// Columns are ordered by their position in the table.
+	Columns     []*Column              `json:"columns"`
+	Indexes     map[string]*Index      `json:"indexes"`
+	Constraints map[string]*Constraint `json:"constraints"`
+}
+
+type Column struct {
+	Name            string  `json:"name"`
+	OrdinalPosition int     `json:"ordinal_position"`
+	Type            string  `json:"type"`
+	IsNullable      bool    `json:"is_nullable"`
+	Default         *string `json:"default"`
+	// Extra holds things like `auto_increment` or `on update CURRENT_TIMESTAMP` in Mysql, the identity clause
+	// (e.g. `GENERATED ALWAYS AS IDENTITY`) in Postgres, and `AUTOINCREMENT` or the kind of generated column in Sqlite.
+	Extra string `json:"extra,omitempty"`
+	// GenerationExpression is the expression of a generated column in Mysql, whose Extra is `VIRTUAL GENERATED` or
+	// `STORED GENERATED`.
+	GenerationExpression string  `json:"generation_expression,omitempty"`
+	CharacterSet         *string `json:"character_set,omitempty"`
+	Collation            *string `json:"collation,omitempty"`
+	Comment              string  `json:"comment,omitempty"`
+}
+
+// Index is an index that doesn't back a constraint. Primary keys, unique keys, etc. are Constraints.
+type Index struct {
+	Name     string `json:"name"`
+	IsUnique bool   `json:"is_unique"`
+	// Type is the access method of the index. e.g. BTREE, HASH, FULLTEXT, gin, gist.
+	Type string `json:"type"`
+	// IsVisible is false for invisible indexes in Mysql.
+	IsVisible bool          `json:"is_visible"`
+	Columns   []IndexColumn `json:"columns"`
+	// Definition is the full `CREATE INDEX` statement as reported by Postgres or Sqlite. It is empty for Mysql.
+	Definition string `json:"definition,omitempty"`
+}
+
+type IndexColumn struct {
+	ColumnName string `json:"column_name,omitempty"`
+	// Expression is set instead of ColumnName for functional indexes.
+	Expression string `json:"expression,omitempty"`
+	// SubPart is the prefix length of the column. It is 0 if the whole column is indexed.
+	SubPart int64 `json:"sub_part,omitempty"`
+	IsDesc  bool  `json:"is_desc,omitempty"`
+}
+
+type Constraint struct {
+	Name string `json:"name"`
+	// Type is one of PRIMARY KEY, FOREIGN KEY, UNIQUE, CHECK or EXCLUDE (Postgres only.)
+	Type                string   `json:"type"`
+	Columns             []string `json:"columns,omitempty"`
+	ReferencedTableName string   `json:"referenced_table_name,omitempty"`
+	ReferencedColumns   []string `json:"referenced_columns,omitempty"`
+	UpdateRule          string   `json:"update_rule,omitempty"`
+	DeleteRule          string   `json:"delete_rule,omitempty"`
+	CheckClause         string   `json:"check_clause,omitempty"`
+	// Definition is the constraint definition as reported by Postgres. It is empty for Mysql.
+	Definition string `json:"definition,omitempty"`
+}
+
+type View struct {
+	Name string `json:"name"`
+	// Definition is the statement that creates the view.
+	Definition string `json:"definition"`
+	// Dependencies are the tables and views that the view selects from.
+	Dependencies []string `json:"dependencies,omitempty"`
+	// Columns are the columns of the view, ordered by their position. Only Postgres reports them, as it can't replace a
+	// view whose columns are dropped, renamed or retyped.
+	Columns []*Column `json:"columns,omitempty"`
+}
+
+// Routine is either a procedure or a function. In Postgres, where routines can be overloaded, the name includes the
+// identity arguments. e.g. `add(a integer, b integer)`.
+type Routine struct {
+	Name string `json:"name"`
+	// Definition is the statement that creates the routine.
+	Definition string `json:"definition"`
+}
+
+type Trigger struct {
+	Name string `json:"name"`
+	// TableName is the table the trigger is defined on.
+	TableName string `json:"table_name"`
+	// Definition is the statement that creates the trigger.
+	Definition string `json:"definition"`
+}
+
+// NewSchema creates an empty schema for a database.
+func NewSchema(dialect string, connectionName string, databaseName string) *Schema {
+	return &Schema{
+		Version:        SnapshotVersion,
+		Dialect:        dialect,
+		ConnectionName: connectionName,
+		DatabaseName:   databaseName,
+		CreatedAt:      time.Now().UTC(),
+		Tables:         map[string]*Table{},
+		Views:          map[string]*View{},
+		Procedures:     map[string]*Routine{},
+		Functions:      map[string]*Routine{},
+		Triggers:       map[string]*Trigger{},
+	}
+}
+
+// GetColumn looks up a column of the table by its name.
+func (self *Table) GetColumn(columnName string) safego.Option[*Column] {
+	for _, column := range self.Columns {
+		if column.Name == columnName {
+			return safego.Some(column)
+		}
+	}
+
+	return safego.None[*Column]()
+}
+
+// GetPreviousColumn returns the column that comes right before the given one in the table. It is None for the first
+// column.
+func (self *Table) GetPreviousColumn(columnName string) safego.Option[*Column] {
+	for i, column := range self.Columns {
+		if column.Name == columnName && i > 0 {
+			return safego.Some(self.Columns[i-1])
+		}
+	}
+
+	return safego.None[*Column]()
+}
+
+// GetPrimaryKey returns the primary key of the table. It is None for tables without one.
+func (self *Table) GetPrimaryKey() safego.Option[*Constraint] {
+	for _, constraint := range self.Constraints {
+		if constraint.Type == "PRIMARY KEY" {
+			return safego.Some(constraint)
+		}
+	}
+
+	return safego.None[*Constraint]()
+}
@@ -0,0 +1,73 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Okira-E/patchi/safego"
+)
+
+// SnapshotVersion is the version of the snapshot format. It has to be bumped whenever the format changes in a way that
+// older versions of Patchi can't read.
+const SnapshotVersion = 1
+
+// MarshalSnapshot serializes a schema into the snapshot format.
+func MarshalSnapshot(schema *Schema) ([]byte, safego.Option[error]) {
+	schema.Version = SnapshotVersion
+
+	ret, err := json.MarshalIndent(schema, "", "\t")
+	if err != nil {
+		return ret, safego.Some(err)
+	}
+
+	return append(ret, '\n'), safego.None[error]()
+}
+
+// ReadSnapshot reads a schema from a snapshot file. Snapshots written by a newer version of Patchi are refused rather
+// than being compared with missing information.
+func ReadSnapshot(filePath string) (*Schema, safego.Option[error]) {
+	ret := &Schema{}
+
+	fileContent, err := os.ReadFile(filePath)
+	if err != nil {
+		return ret, safego.Some(err)
+	}
+
+	if err := json.Unmarshal(fileContent, ret); err != nil {
+		return ret, safego.Some(fmt.Errorf("%s is not a valid snapshot: %w", filePath, err))
+	}
+
+	if ret.Version == 0 || ret.Dialect == "" {
+		return ret, safego.Some(fmt.Errorf("%s is not a Patchi snapshot", filePath))
+	} else if ret.Version > SnapshotVersion {
+		return ret, safego.Some(fmt.Errorf("%s was written with snapshot version %d, but this version of Patchi only reads up to version %d", filePath, ret.Version, SnapshotVersion))
+	}
+
+	// Maps that are missing from the file (or are `null`) are read back as nil, and writing to them later on would panic.
+	if ret.Tables == nil {
+		ret.Tables = map[string]*Table{}
+	}
+	if ret.Views == nil {
+		ret.Views = map[string]*View{}
+	}
+	if ret.Procedures == nil {
+		ret.Procedures = map[string]*Routine{}
+	}
+	if ret.Functions == nil {
+		ret.Functions = map[string]*Routine{}
+	}
+	if ret.Triggers == nil {
+		ret.Triggers = map[string]*Trigger{}
+	}
+	for _, table := range ret.Tables {
+		if table.Indexes == nil {
+			table.Indexes = map[string]*Index{}
+		}
+		if table.Constraints == nil {
+			table.Constraints = map[string]*Constraint{}
+		}
+	}
+
+	return ret, safego.None[error]()
+}
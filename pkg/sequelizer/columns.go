@@ -1,122 +1,282 @@
 package sequelizer
 
 import (
-	"database/sql"
-	"fmt"
+	"slices"
 	"strings"
 
-	"github.com/Okira-E/patchi/pkg/types"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
 	"github.com/Okira-E/patchi/pkg/utils"
 	"github.com/Okira-E/patchi/safego"
 )
 
-// GenerateSqlForColumns generates the SQL for a column based on it's status (created or deleted.)
-func GenerateSqlForColumns(firstDb types.DbConnection, dialect string, columnName string, tableName string, status string) (string, safego.Option[string]) {
-	var ret string
+// GenerateSqlForColumns generates the SQL for a column based on it's status (created, deleted or modified.)
+// The second schema is only used by Sqlite, which has to know what the table looks like before it is rebuilt.
+func GenerateSqlForColumns(firstSchema *schema.Schema, secondSchema *schema.Schema, dialect string, columnName string, tableName string, status string) ([]string, safego.Option[string]) {
+	ret := []string{}
 	errOpt := safego.None[string]()
 
 	if dialect == "mysql" || dialect == "mariadb" {
-		ret, errOpt = generateSqlForColumnsMysql(firstDb, columnName, tableName, status)
+		ret, errOpt = generateSqlForColumnsMysql(firstSchema, columnName, tableName, status)
 	} else if dialect == "postgres" || dialect == "cockroachdb" {
-		utils.AbortTui("UNIMPLEMENTED")
+		ret, errOpt = generateSqlForColumnsPostgres(firstSchema, secondSchema, dialect, columnName, tableName, status)
+	} else if dialect == "sqlite" {
+		ret, errOpt = generateSqlForColumnsSqlite(firstSchema, secondSchema, columnName, tableName, status)
 	}
 
 	return ret, errOpt
 }
 
-// UNFINISHED
-func generateSqlForColumnsMysql(firstDb types.DbConnection, columnName string, tableName string, status string) (string, safego.Option[string]) {
-	var result string
+// generateSqlForColumnsMysql is responsible for generating SQL for columns in Mysql. Created and modified columns are
+// placed right after the column that comes before them in the first schema.
+func generateSqlForColumnsMysql(firstSchema *schema.Schema, columnName string, tableName string, status string) ([]string, safego.Option[string]) {
+	quotedTableName := quoteMysqlIdentifier(tableName)
+	quotedColumnName := quoteMysqlIdentifier(columnName)
 
 	if status == "deleted" {
-		result = "ALTER TABLE " + tableName + " DROP COLUMN " + columnName
-	} else if status == "created" {
-		query := `
-			SELECT
-			    C.ORDINAL_POSITION,
-			    C.COLUMN_DEFAULT,
-			    C.EXTRA,
-			    C.COLUMN_KEY,
-			    C.IS_NULLABLE,
-			    C.COLUMN_TYPE,
-			    KCU.REFERENCED_TABLE_NAME,
-			    KCU.REFERENCED_COLUMN_NAME
-			FROM
-			    information_schema.COLUMNS C
-			LEFT JOIN
-			    information_schema.KEY_COLUMN_USAGE KCU
-			ON
-			    C.TABLE_SCHEMA = KCU.TABLE_SCHEMA
-			    AND C.TABLE_NAME = KCU.TABLE_NAME
-			    AND C.COLUMN_NAME = KCU.COLUMN_NAME
-			WHERE
-			    C.TABLE_SCHEMA = ?
-			    AND C.TABLE_NAME = ?
-				AND C.COLUMN_NAME = ?
-		`
-
-		rows, err := firstDb.SqlConnection.Query(query, firstDb.Info.DatabaseName, tableName, columnName)
-		if err != nil {
-			return result, safego.Some("Failed to get info on column: " + err.Error())
+		return []string{"ALTER TABLE " + quotedTableName + " DROP COLUMN " + quotedColumnName + ";"}, safego.None[string]()
+	}
+
+	column, prevColumnOpt, errOpt := getColumnFromSchema(firstSchema, columnName, tableName)
+	if errOpt.IsSome() {
+		return []string{}, errOpt
+	}
+
+	// NOTE: Keys (primary, foreign, unique) are left out on purpose. They are generated by GenerateSqlForConstraints.
+	ret := "ALTER TABLE " + quotedTableName + utils.Ternary(status == "created", " ADD COLUMN ", " MODIFY COLUMN ") + quotedColumnName + " " + buildMysqlColumnDefinition(column)
+
+	if prevColumnOpt.IsSome() {
+		ret += " AFTER " + quoteMysqlIdentifier(prevColumnOpt.Unwrap().Name)
+	} else {
+		ret += " FIRST"
+	}
+
+	return []string{ret + ";"}, safego.None[string]()
+}
+
+// buildMysqlColumnDefinition builds the definition of a column, apart from its name, as it would appear in a
+// `CREATE TABLE` or an `ADD COLUMN` statement.
+func buildMysqlColumnDefinition(column *schema.Column) string {
+	// The parts are joined rather than the whole definition split on spaces, which would squash the spaces inside of
+	// string literals.
+	parts := []string{column.Type}
+	if column.CharacterSet != nil {
+		parts = append(parts, "CHARACTER SET "+*column.CharacterSet)
+	}
+	if column.Collation != nil {
+		parts = append(parts, "COLLATE "+*column.Collation)
+	}
+
+	// EXTRA names the kind of a generated column, e.g. `STORED GENERATED`, which isn't valid in a definition.
+	extra := column.Extra
+	for _, kind := range []string{"VIRTUAL", "STORED"} {
+		if strings.Contains(extra, kind+" GENERATED") {
+			parts = append(parts, "GENERATED ALWAYS AS ("+column.GenerationExpression+") "+kind)
+			extra = strings.ReplaceAll(extra, kind+" GENERATED", "")
 		}
+	}
 
-		var ordinalPos sql.NullInt32
-		var columnDefault sql.NullString
-		var columnExtra sql.NullString
-		var columnKey sql.NullString
-		var isNullable sql.NullString
-		var columnType sql.NullString
-		var referencedTableName sql.NullString
-		var referencedColumnName sql.NullString
-		for rows.Next() {
-			err = rows.Scan(&ordinalPos, &columnDefault, &columnExtra, &columnKey, &isNullable, &columnType, &referencedTableName, &referencedColumnName)
-			if err != nil {
-				return result, safego.Some("Failed to scan results on column: " + err.Error())
-			}
+	parts = append(parts, utils.Ternary(column.IsNullable, "NULL", "NOT NULL"))
+	if column.Default != nil {
+		parts = append(parts, "DEFAULT "+formatMysqlColumnDefault(column.Type, *column.Default, column.Extra))
+	}
+	// Mysql 8 flags expression defaults, e.g. `CURRENT_TIMESTAMP`, with DEFAULT_GENERATED, which isn't valid either.
+	parts = append(parts, strings.Fields(strings.ReplaceAll(extra, "DEFAULT_GENERATED", ""))...)
+	if column.Comment != "" {
+		parts = append(parts, "COMMENT "+quoteMysqlString(column.Comment))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// formatMysqlColumnDefault returns the default value of a column in a form that can be used in a column definition.
+// information_schema stores string literals unquoted, while expressions are flagged with DEFAULT_GENERATED in EXTRA.
+func formatMysqlColumnDefault(columnType string, columnDefault string, columnExtra string) string {
+	upperDefault := strings.ToUpper(columnDefault)
+
+	if strings.HasPrefix(columnDefault, "'") || upperDefault == "NULL" { // MariaDB already quotes its literals.
+		return columnDefault
+	}
+
+	if strings.Contains(columnExtra, "DEFAULT_GENERATED") {
+		if strings.HasPrefix(upperDefault, "CURRENT_TIMESTAMP") || strings.HasPrefix(upperDefault, "NOW(") {
+			return columnDefault
 		}
 
-		query = `
-    		SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND ORDINAL_POSITION = ?
-		`
+		return "(" + columnDefault + ")"
+	}
 
-		rows, err = firstDb.SqlConnection.Query(query, firstDb.Info.DatabaseName, tableName, ordinalPos.Int32-1)
-		if err != nil {
-			return result, safego.Some("Failed to get previous column name: " + err.Error())
+	numericTypes := []string{"tinyint", "smallint", "mediumint", "int", "bigint", "decimal", "numeric", "float", "double", "bit"}
+	for _, numericType := range numericTypes {
+		if strings.HasPrefix(strings.ToLower(columnType), numericType) {
+			return columnDefault
 		}
+	}
 
-		var prevColumnName sql.NullString
-		for rows.Next() {
-			err = rows.Scan(&prevColumnName)
-			if err != nil {
-				return result, safego.Some("Failed to scan previous column name: " + err.Error())
-			}
+	return quoteMysqlString(columnDefault)
+}
+
+// generateSqlForColumnsPostgres is responsible for generating SQL for columns in Postgres. Since Postgres has no
+// `MODIFY COLUMN`, a modified column is altered one property at a time.
+func generateSqlForColumnsPostgres(firstSchema *schema.Schema, secondSchema *schema.Schema, dialect string, columnName string, tableName string, status string) ([]string, safego.Option[string]) {
+	ret := []string{}
+
+	quotedTableName := quotePostgresIdentifier(tableName)
+	quotedColumnName := quotePostgresIdentifier(columnName)
+
+	if status == "deleted" {
+		return []string{"ALTER TABLE " + quotedTableName + " DROP COLUMN " + quotedColumnName + ";"}, safego.None[string]()
+	}
+
+	column, _, errOpt := getColumnFromSchema(firstSchema, columnName, tableName)
+	if errOpt.IsSome() {
+		return ret, errOpt
+	}
+
+	if status == "created" {
+		ret = getPostgresSequenceStatements(dialect, tableName, []*schema.Column{column})
+		ret = append(ret, "ALTER TABLE "+quotedTableName+" ADD COLUMN "+buildPostgresColumnDefinition(dialect, tableName, column)+";")
+		if column.Comment != "" {
+			ret = append(ret, "COMMENT ON COLUMN "+quotedTableName+"."+quotedColumnName+" IS "+quotePostgresString(column.Comment)+";")
+		}
+	} else if status == "modified" {
+		previousColumn, _, errOpt := getColumnFromSchema(secondSchema, columnName, tableName)
+		if errOpt.IsSome() {
+			return ret, errOpt
+		}
+
+		ret = generatePostgresColumnAlterations(tableName, column, previousColumn)
+	}
+
+	return ret, safego.None[string]()
+}
+
+// generatePostgresColumnAlterations alters the properties of a column that differ from its previous version, and
+// nothing else. Changing the type takes an ACCESS EXCLUSIVE lock and can rewrite the table, so it is only done when
+// the type or the collation changed.
+func generatePostgresColumnAlterations(tableName string, column *schema.Column, previousColumn *schema.Column) []string {
+	quotedTableName := quotePostgresIdentifier(tableName)
+	quotedColumnName := quotePostgresIdentifier(column.Name)
+	modifiedProperties := difftool.GetModifiedColumnProperties(column, previousColumn)
+
+	alterations := []string{}
+	if slices.Contains(modifiedProperties, "type") || slices.Contains(modifiedProperties, "collation") {
+		typeAlteration := "ALTER COLUMN " + quotedColumnName + " TYPE " + column.Type
+		if column.Collation != nil {
+			typeAlteration += " COLLATE " + quotePostgresIdentifier(*column.Collation)
+		}
+		alterations = append(alterations, typeAlteration)
+	}
+
+	if slices.Contains(modifiedProperties, "nullable") {
+		alterations = append(alterations, "ALTER COLUMN "+quotedColumnName+utils.Ternary(column.IsNullable, " DROP NOT NULL", " SET NOT NULL"))
+	}
+
+	// The default is dropped before the column becomes an identity column, which can't have one.
+	if slices.Contains(modifiedProperties, "default") {
+		if column.Default != nil {
+			alterations = append(alterations, "ALTER COLUMN "+quotedColumnName+" SET DEFAULT "+*column.Default)
+		} else {
+			alterations = append(alterations, "ALTER COLUMN "+quotedColumnName+" DROP DEFAULT")
 		}
+	}
 
-		// Golang parsing sucks.
-		result = "ALTER TABLE " + tableName + " ADD COLUMN " + columnName + " " + columnType.String + " " + utils.Ternary(isNullable.String == "YES", "NULL", "NOT NULL") + " "
-		result += utils.Ternary(columnDefault.Valid, "DEFAULT "+columnDefault.String, "") + " "
-		result += utils.Ternary(columnExtra.Valid, strings.ReplaceAll(columnExtra.String, "DEFAULT_GENERATED", ""), "") + " "
-		result += utils.Ternary(columnKey.String == "PRI", "PRIMARY KEY", "") + " "
-		// Handle if the column is a foreign key to a different table.
-		// NOTE: This doesn't guarantee that the table it references exists in the other database env.
-		if referencedTableName.Valid && referencedColumnName.Valid {
-			result += fmt.Sprintf("REFERENCES %s(%s) ", referencedTableName.String, referencedColumnName.String)
+	if slices.Contains(modifiedProperties, "extra") {
+		if previousColumn.Extra == "" {
+			alterations = append(alterations, "ALTER COLUMN "+quotedColumnName+" ADD "+column.Extra)
+		} else if column.Extra == "" {
+			alterations = append(alterations, "ALTER COLUMN "+quotedColumnName+" DROP IDENTITY")
+		} else {
+			alterations = append(alterations, "ALTER COLUMN "+quotedColumnName+" SET "+strings.TrimSuffix(column.Extra, " AS IDENTITY"))
 		}
+	}
 
-		result += utils.Ternary(prevColumnName.Valid, "AFTER "+prevColumnName.String, "FIRST") + " "
+	ret := []string{}
+
+	// A new default can take its values from a sequence that only exists in the first database. Serial columns aren't
+	// added here, so the sequence is always created if it is missing.
+	if slices.Contains(modifiedProperties, "default") {
+		ret = append(ret, getPostgresSequenceStatements("", tableName, []*schema.Column{column})...)
+	}
+
+	if len(alterations) != 0 {
+		ret = append(ret, "ALTER TABLE "+quotedTableName+"\n\t"+strings.Join(alterations, ",\n\t")+";")
+	}
+
+	if slices.Contains(modifiedProperties, "comment") {
+		ret = append(ret, "COMMENT ON COLUMN "+quotedTableName+"."+quotedColumnName+" IS "+utils.Ternary(column.Comment != "", quotePostgresString(column.Comment), "NULL")+";")
 	}
 
-	// Remove long spaces to make the query look nicer.
-	ret := ""
-	for i, word := range strings.Fields(result) { // `Fields()` returns a slice of words, nullifying any spaces or tabs between them.
-		if i == 0 {
-			ret += word
-			continue
+	return ret
+}
+
+// generateSqlForColumnsSqlite is responsible for generating SQL for columns in Sqlite. Columns are added with
+// `ADD COLUMN` when Sqlite allows it, and every other change rebuilds the table.
+func generateSqlForColumnsSqlite(firstSchema *schema.Schema, secondSchema *schema.Schema, columnName string, tableName string, status string) ([]string, safego.Option[string]) {
+	firstTable, isInFirst := firstSchema.Tables[tableName]
+	secondTable, isInSecond := secondSchema.Tables[tableName]
+	if !isInFirst || !isInSecond {
+		return []string{}, safego.Some("Table " + tableName + " was not found")
+	}
+
+	if status == "created" {
+		column, _, errOpt := getColumnFromSchema(firstSchema, columnName, tableName)
+		if errOpt.IsSome() {
+			return []string{}, errOpt
+		}
+
+		if canAddSqliteColumn(column) && !needsSqliteTableRebuild(firstTable, secondTable) {
+			columnDefinition := quoteSqliteIdentifier(column.Name)
+			if column.Type != "" {
+				columnDefinition += " " + column.Type
+			}
+			if !column.IsNullable {
+				columnDefinition += " NOT NULL"
+			}
+			if column.Default != nil {
+				columnDefinition += " DEFAULT " + *column.Default
+			}
+
+			return []string{"ALTER TABLE " + quoteSqliteIdentifier(tableName) + " ADD COLUMN " + columnDefinition + ";"}, safego.None[string]()
 		}
+	}
 
-		ret += " " + word
+	existingColumnNames := []string{}
+	for _, column := range secondTable.Columns {
+		existingColumnNames = append(existingColumnNames, column.Name)
 	}
-	ret += ";"
 
-	return ret, safego.None[string]()
+	return generateSqliteTableRebuild(firstSchema, tableName, existingColumnNames)
+}
+
+// canAddSqliteColumn tells whether a column can be added with `ADD COLUMN`. Sqlite only adds columns that are either
+// nullable or have a default, as long as the default is a constant. Generated columns are left to table rebuilds as
+// their expressions are only found in the `CREATE TABLE` statement.
+func canAddSqliteColumn(column *schema.Column) bool {
+	if column.Extra != "" {
+		return false
+	}
+
+	if column.Default == nil {
+		return column.IsNullable
+	}
+
+	upperDefault := strings.ToUpper(strings.TrimSpace(*column.Default))
+
+	return !strings.HasPrefix(upperDefault, "(") && !strings.HasPrefix(upperDefault, "CURRENT_")
+}
+
+// getColumnFromSchema looks up a column in the schema along with the column that comes right before it in its table.
+func getColumnFromSchema(firstSchema *schema.Schema, columnName string, tableName string) (*schema.Column, safego.Option[*schema.Column], safego.Option[string]) {
+	table, ok := firstSchema.Tables[tableName]
+	if !ok {
+		return nil, safego.None[*schema.Column](), safego.Some("Table " + tableName + " was not found")
+	}
+
+	columnOpt := table.GetColumn(columnName)
+	if columnOpt.IsNone() {
+		return nil, safego.None[*schema.Column](), safego.Some("Column " + columnName + " was not found on table " + tableName)
+	}
+
+	return columnOpt.Unwrap(), table.GetPreviousColumn(columnName), safego.None[string]()
 }
@@ -0,0 +1,154 @@
+package sequelizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+func TestGenerateSqlForColumnsMysql(t *testing.T) {
+	firstSchema := testutil.NewSchema("mysql", testutil.NewTable("Order",
+		&schema.Column{Name: "id", Type: "int", Extra: "auto_increment"},
+		&schema.Column{Name: "select", Type: "varchar(20)", IsNullable: true, Default: testutil.StringPointer("a  b"), Comment: "it's"},
+		&schema.Column{Name: "total", Type: "int", IsNullable: true, Extra: "STORED GENERATED", GenerationExpression: "(`id` * 2)"},
+		&schema.Column{Name: "label", Type: "varchar(40)", IsNullable: true, Extra: "VIRTUAL GENERATED INVISIBLE", GenerationExpression: "concat(`select`,_utf8mb4' ')"},
+		&schema.Column{Name: "updated_at", Type: "timestamp", Default: testutil.StringPointer("CURRENT_TIMESTAMP"), Extra: "DEFAULT_GENERATED on update CURRENT_TIMESTAMP"},
+	))
+	secondSchema := testutil.NewSchema("mysql", testutil.NewTable("Order", &schema.Column{Name: "id", Type: "int", Extra: "auto_increment"}))
+
+	tests := []struct {
+		name       string
+		columnName string
+		status     string
+		expected   string
+	}{
+		{
+			name:       "created after the previous column",
+			columnName: "select",
+			status:     "created",
+			expected:   "ALTER TABLE `Order` ADD COLUMN `select` varchar(20) NULL DEFAULT 'a  b' COMMENT 'it''s' AFTER `id`;",
+		},
+		{
+			name:       "created stored generated column",
+			columnName: "total",
+			status:     "created",
+			expected:   "ALTER TABLE `Order` ADD COLUMN `total` int GENERATED ALWAYS AS ((`id` * 2)) STORED NULL AFTER `select`;",
+		},
+		{
+			name:       "created virtual generated column",
+			columnName: "label",
+			status:     "created",
+			expected:   "ALTER TABLE `Order` ADD COLUMN `label` varchar(40) GENERATED ALWAYS AS (concat(`select`,_utf8mb4' ')) VIRTUAL NULL INVISIBLE AFTER `total`;",
+		},
+		{
+			name:       "created column with an expression default",
+			columnName: "updated_at",
+			status:     "created",
+			expected:   "ALTER TABLE `Order` ADD COLUMN `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP on update CURRENT_TIMESTAMP AFTER `label`;",
+		},
+		{
+			name:       "modified first column",
+			columnName: "id",
+			status:     "modified",
+			expected:   "ALTER TABLE `Order` MODIFY COLUMN `id` int NOT NULL auto_increment FIRST;",
+		},
+		{
+			name:       "deleted",
+			columnName: "select",
+			status:     "deleted",
+			expected:   "ALTER TABLE `Order` DROP COLUMN `select`;",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queries, errOpt := GenerateSqlForColumns(firstSchema, secondSchema, "mysql", test.columnName, "Order", test.status)
+			if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+
+			if sql := strings.Join(queries, "\n"); sql != test.expected {
+				t.Errorf("got\n%s\nexpected\n%s", sql, test.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateSqlForColumnsMissingColumn(t *testing.T) {
+	firstSchema := testutil.NewSchema("mysql", testutil.NewTable("users", &schema.Column{Name: "id", Type: "int"}))
+
+	_, errOpt := GenerateSqlForColumns(firstSchema, firstSchema, "mysql", "email", "users", "created")
+	if errOpt.IsNone() {
+		t.Fatal("expected an error for a column that isn't in the schema")
+	}
+}
+
+func TestGenerateSqlForColumnsPostgresModified(t *testing.T) {
+	previousColumn := schema.Column{Name: "email", Type: "character varying(100)", IsNullable: true, Default: testutil.StringPointer("''::character varying")}
+
+	tests := []struct {
+		name     string
+		modify   func(column *schema.Column)
+		expected string
+	}{
+		{
+			name:     "comment only",
+			modify:   func(column *schema.Column) { column.Comment = "Login" },
+			expected: `COMMENT ON COLUMN "users"."email" IS 'Login';`,
+		},
+		{
+			name:     "default only",
+			modify:   func(column *schema.Column) { column.Default = nil },
+			expected: "ALTER TABLE \"users\"\n\tALTER COLUMN \"email\" DROP DEFAULT;",
+		},
+		{
+			name:     "nullable only",
+			modify:   func(column *schema.Column) { column.IsNullable = false },
+			expected: "ALTER TABLE \"users\"\n\tALTER COLUMN \"email\" SET NOT NULL;",
+		},
+		{
+			name:     "type only",
+			modify:   func(column *schema.Column) { column.Type = "text" },
+			expected: "ALTER TABLE \"users\"\n\tALTER COLUMN \"email\" TYPE text;",
+		},
+		{
+			name: "type and comment",
+			modify: func(column *schema.Column) {
+				column.Type = "text"
+				column.Comment = "Login"
+			},
+			expected: "ALTER TABLE \"users\"\n\tALTER COLUMN \"email\" TYPE text;\nCOMMENT ON COLUMN \"users\".\"email\" IS 'Login';",
+		},
+		{
+			name: "becomes an identity column",
+			modify: func(column *schema.Column) {
+				column.Type = "integer"
+				column.Default = nil
+				column.Extra = "GENERATED BY DEFAULT AS IDENTITY"
+			},
+			expected: "ALTER TABLE \"users\"\n\tALTER COLUMN \"email\" TYPE integer,\n\tALTER COLUMN \"email\" DROP DEFAULT,\n\tALTER COLUMN \"email\" ADD GENERATED BY DEFAULT AS IDENTITY;",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			column := previousColumn
+			test.modify(&column)
+			secondColumn := previousColumn
+
+			firstSchema := testutil.NewSchema("postgres", testutil.NewTable("users", &column))
+			secondSchema := testutil.NewSchema("postgres", testutil.NewTable("users", &secondColumn))
+
+			queries, errOpt := GenerateSqlForColumns(firstSchema, secondSchema, "postgres", "email", "users", "modified")
+			if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+
+			if sql := strings.Join(queries, "\n"); sql != test.expected {
+				t.Errorf("got\n%s\nexpected\n%s", sql, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package sequelizer
+
+import (
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// GenerateSqlForConstraints generates the SQL for a constraint based on it's status (created, deleted or modified.)
+// Deleted constraints only exist in the second schema, which is where their type is looked up from since Mysql
+// uses a different `DROP` clause for each type of constraint.
+func GenerateSqlForConstraints(firstSchema *schema.Schema, secondSchema *schema.Schema, dialect string, constraintName string, tableName string, status string) ([]string, safego.Option[string]) {
+	ret := []string{}
+	errOpt := safego.None[string]()
+
+	if dialect == "mysql" || dialect == "mariadb" {
+		ret, errOpt = generateSqlForConstraintsMysql(firstSchema, secondSchema, dialect, constraintName, tableName, status)
+	} else if dialect == "postgres" || dialect == "cockroachdb" {
+		ret, errOpt = generateSqlForConstraintsPostgres(firstSchema, constraintName, tableName, status)
+	} else if dialect == "sqlite" {
+		ret, errOpt = generateSqlForConstraintsSqlite(firstSchema, secondSchema, tableName)
+	}
+
+	return ret, errOpt
+}
+
+// generateSqlForConstraintsMysql is responsible for generating SQL for constraints in Mysql.
+func generateSqlForConstraintsMysql(firstSchema *schema.Schema, secondSchema *schema.Schema, dialect string, constraintName string, tableName string, status string) ([]string, safego.Option[string]) {
+	alterTable := "ALTER TABLE " + quoteMysqlIdentifier(tableName) + " "
+
+	if status == "deleted" {
+		oldConstraint, errOpt := getConstraintFromSchema(secondSchema, constraintName, tableName)
+		if errOpt.IsSome() {
+			return []string{}, errOpt
+		}
+
+		return []string{alterTable + getMysqlDropConstraintClause(dialect, oldConstraint.Type, constraintName) + ";"}, safego.None[string]()
+	}
+
+	constraint, errOpt := getConstraintFromSchema(firstSchema, constraintName, tableName)
+	if errOpt.IsSome() {
+		return []string{}, errOpt
+	}
+
+	addClause := "ADD " + getMysqlConstraintDefinition(constraint)
+
+	if status == "modified" {
+		// The constraint type could have changed as well, so the old one is dropped based on its type in the second schema.
+		oldConstraint, errOpt := getConstraintFromSchema(secondSchema, constraintName, tableName)
+		if errOpt.IsSome() {
+			return []string{}, errOpt
+		}
+
+		// Foreign keys can't be dropped and added back in the same statement.
+		if oldConstraint.Type == "FOREIGN KEY" || constraint.Type == "FOREIGN KEY" {
+			return []string{alterTable + getMysqlDropConstraintClause(dialect, oldConstraint.Type, constraintName) + ";", alterTable + addClause + ";"}, safego.None[string]()
+		}
+
+		return []string{alterTable + getMysqlDropConstraintClause(dialect, oldConstraint.Type, constraintName) + ", " + addClause + ";"}, safego.None[string]()
+	}
+
+	return []string{alterTable + addClause + ";"}, safego.None[string]()
+}
+
+// getMysqlDropConstraintClause returns the `DROP ...` clause of an `ALTER TABLE` statement for the given constraint type.
+func getMysqlDropConstraintClause(dialect string, constraintType string, constraintName string) string {
+	if constraintType == "PRIMARY KEY" {
+		return "DROP PRIMARY KEY"
+	} else if constraintType == "FOREIGN KEY" {
+		return "DROP FOREIGN KEY " + quoteMysqlIdentifier(constraintName)
+	} else if constraintType == "UNIQUE" {
+		return "DROP INDEX " + quoteMysqlIdentifier(constraintName)
+	} else if constraintType == "CHECK" && dialect != "mariadb" {
+		return "DROP CHECK " + quoteMysqlIdentifier(constraintName)
+	}
+
+	return "DROP CONSTRAINT " + quoteMysqlIdentifier(constraintName)
+}
+
+// getMysqlConstraintDefinition returns the definition of a constraint as it would appear after `ADD` in an
+// `ALTER TABLE` statement.
+func getMysqlConstraintDefinition(constraint *schema.Constraint) string {
+	columns := []string{}
+	for _, columnName := range constraint.Columns {
+		columns = append(columns, quoteMysqlIdentifier(columnName))
+	}
+
+	referencedColumns := []string{}
+	for _, columnName := range constraint.ReferencedColumns {
+		referencedColumns = append(referencedColumns, quoteMysqlIdentifier(columnName))
+	}
+
+	var ret string
+	if constraint.Type == "PRIMARY KEY" {
+		ret = "PRIMARY KEY (" + strings.Join(columns, ", ") + ")"
+	} else if constraint.Type == "UNIQUE" {
+		ret = "CONSTRAINT " + quoteMysqlIdentifier(constraint.Name) + " UNIQUE (" + strings.Join(columns, ", ") + ")"
+	} else if constraint.Type == "FOREIGN KEY" {
+		ret = "CONSTRAINT " + quoteMysqlIdentifier(constraint.Name) + " FOREIGN KEY (" + strings.Join(columns, ", ") + ") " +
+			"REFERENCES " + quoteMysqlIdentifier(constraint.ReferencedTableName) + " (" + strings.Join(referencedColumns, ", ") + ") " +
+			"ON DELETE " + constraint.DeleteRule + " ON UPDATE " + constraint.UpdateRule
+	} else if constraint.Type == "CHECK" {
+		ret = "CONSTRAINT " + quoteMysqlIdentifier(constraint.Name) + " CHECK (" + constraint.CheckClause + ")"
+	}
+
+	return ret
+}
+
+// generateSqlForConstraintsPostgres is responsible for generating SQL for constraints in Postgres.
+func generateSqlForConstraintsPostgres(firstSchema *schema.Schema, constraintName string, tableName string, status string) ([]string, safego.Option[string]) {
+	alterTable := "ALTER TABLE " + quotePostgresIdentifier(tableName) + " "
+	dropClause := "DROP CONSTRAINT IF EXISTS " + quotePostgresIdentifier(constraintName)
+
+	if status == "deleted" {
+		return []string{alterTable + dropClause + ";"}, safego.None[string]()
+	}
+
+	constraint, errOpt := getConstraintFromSchema(firstSchema, constraintName, tableName)
+	if errOpt.IsSome() {
+		return []string{}, errOpt
+	}
+
+	addClause := "ADD CONSTRAINT " + quotePostgresIdentifier(constraintName) + " " + constraint.Definition
+
+	if status == "modified" {
+		return []string{alterTable + dropClause + ", " + addClause + ";"}, safego.None[string]()
+	}
+
+	return []string{alterTable + addClause + ";"}, safego.None[string]()
+}
+
+// generateSqlForConstraintsSqlite is responsible for generating SQL for constraints in Sqlite, which can't add or drop
+// constraints on their own. The table is rebuilt instead, whatever the status of the constraint is.
+func generateSqlForConstraintsSqlite(firstSchema *schema.Schema, secondSchema *schema.Schema, tableName string) ([]string, safego.Option[string]) {
+	secondTable, ok := secondSchema.Tables[tableName]
+	if !ok {
+		return []string{}, safego.Some("Table " + tableName + " was not found")
+	}
+
+	existingColumnNames := []string{}
+	for _, column := range secondTable.Columns {
+		existingColumnNames = append(existingColumnNames, column.Name)
+	}
+
+	return generateSqliteTableRebuild(firstSchema, tableName, existingColumnNames)
+}
+
+// getConstraintFromSchema looks up a constraint of a table in the schema.
+func getConstraintFromSchema(dbSchema *schema.Schema, constraintName string, tableName string) (*schema.Constraint, safego.Option[string]) {
+	table, ok := dbSchema.Tables[tableName]
+	if !ok {
+		return nil, safego.Some("Table " + tableName + " was not found")
+	}
+
+	constraint, ok := table.Constraints[constraintName]
+	if !ok {
+		return nil, safego.Some("Constraint " + constraintName + " was not found on table " + tableName)
+	}
+
+	return constraint, safego.None[string]()
+}
@@ -0,0 +1,95 @@
+package sequelizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+func TestGenerateSqlForConstraintsMysql(t *testing.T) {
+	newConstraintSchema := func(constraint *schema.Constraint) *schema.Schema {
+		ret := testutil.NewSchema("mysql", testutil.NewTable("orders", &schema.Column{Name: "user_id", Type: "int"}))
+		ret.Tables["orders"].Constraints[constraint.Name] = constraint
+
+		return ret
+	}
+
+	foreignKey := &schema.Constraint{
+		Name:                "fk_user",
+		Type:                "FOREIGN KEY",
+		Columns:             []string{"user_id"},
+		ReferencedTableName: "users",
+		ReferencedColumns:   []string{"id"},
+		UpdateRule:          "NO ACTION",
+		DeleteRule:          "CASCADE",
+	}
+	uniqueKey := &schema.Constraint{Name: "fk_user", Type: "UNIQUE", Columns: []string{"user_id"}}
+	checkConstraint := &schema.Constraint{Name: "chk_user", Type: "CHECK", CheckClause: "(`user_id` > 0)"}
+
+	tests := []struct {
+		name             string
+		dialect          string
+		firstConstraint  *schema.Constraint
+		secondConstraint *schema.Constraint
+		status           string
+		expected         string
+	}{
+		{
+			name:            "created foreign key",
+			dialect:         "mysql",
+			firstConstraint: foreignKey,
+			status:          "created",
+			expected:        "ALTER TABLE `orders` ADD CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE NO ACTION;",
+		},
+		{
+			name:             "unique key turned into a foreign key is dropped in its own statement",
+			dialect:          "mysql",
+			firstConstraint:  foreignKey,
+			secondConstraint: uniqueKey,
+			status:           "modified",
+			expected:         "ALTER TABLE `orders` DROP INDEX `fk_user`;\nALTER TABLE `orders` ADD CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE ON UPDATE NO ACTION;",
+		},
+		{
+			name:             "modified check",
+			dialect:          "mysql",
+			firstConstraint:  checkConstraint,
+			secondConstraint: checkConstraint,
+			status:           "modified",
+			expected:         "ALTER TABLE `orders` DROP CHECK `chk_user`, ADD CONSTRAINT `chk_user` CHECK ((`user_id` > 0));",
+		},
+		{
+			name:             "deleted check in mariadb",
+			dialect:          "mariadb",
+			secondConstraint: checkConstraint,
+			status:           "deleted",
+			expected:         "ALTER TABLE `orders` DROP CONSTRAINT `chk_user`;",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			firstSchema := testutil.NewSchema("mysql", testutil.NewTable("orders"))
+			secondSchema := testutil.NewSchema("mysql", testutil.NewTable("orders"))
+			constraintName := ""
+			if test.firstConstraint != nil {
+				firstSchema = newConstraintSchema(test.firstConstraint)
+				constraintName = test.firstConstraint.Name
+			}
+			if test.secondConstraint != nil {
+				secondSchema = newConstraintSchema(test.secondConstraint)
+				constraintName = test.secondConstraint.Name
+			}
+
+			queries, errOpt := GenerateSqlForConstraints(firstSchema, secondSchema, test.dialect, constraintName, "orders", test.status)
+			if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+
+			if sql := strings.Join(queries, "\n"); sql != test.expected {
+				t.Errorf("got\n%s\nexpected\n%s", sql, test.expected)
+			}
+		})
+	}
+}
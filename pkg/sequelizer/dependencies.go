@@ -0,0 +1,291 @@
+package sequelizer
+
+import (
+	"container/heap"
+	"slices"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// entityDependencies holds what the entities of a database depend on. It is used to order the generated statements so
+// that nothing is created before the things it needs, and nothing is dropped while something still needs it.
+type entityDependencies struct {
+	// foreignKeys maps a `table → constraint` name to the table that the foreign key references.
+	foreignKeys map[string]string
+	// views maps a view name to the tables and views it selects from.
+	views map[string][]string
+	// triggers maps a trigger name to the table it is defined on.
+	triggers map[string]string
+}
+
+// getEntityDependencies collects the dependencies between the entities of a schema.
+func getEntityDependencies(dbSchema *schema.Schema) entityDependencies {
+	ret := entityDependencies{
+		foreignKeys: map[string]string{},
+		views:       map[string][]string{},
+		triggers:    map[string]string{},
+	}
+
+	for tableName, table := range dbSchema.Tables {
+		for constraintName, constraint := range table.Constraints {
+			if constraint.Type == "FOREIGN KEY" && constraint.ReferencedTableName != "" {
+				ret.foreignKeys[tableName+" → "+constraintName] = constraint.ReferencedTableName
+			}
+		}
+	}
+
+	for viewName, view := range dbSchema.Views {
+		ret.views[viewName] = view.Dependencies
+	}
+
+	for triggerName, trigger := range dbSchema.Triggers {
+		ret.triggers[triggerName] = trigger.TableName
+	}
+
+	return ret
+}
+
+// getViewsToRecreate returns the views that Postgres won't let a migration change in place. Dropping a column or
+// changing its type fails while a view selects from its table, and a view whose own columns change can't be replaced.
+// Such views, and the views that depend on them in turn, are dropped before the change and created again after it.
+// Views that are deleted anyway are left out. The names are sorted so that the migration is stable between runs.
+func getViewsToRecreate(firstSchema *schema.Schema, secondSchema *schema.Schema, dialect string, schemaDiff difftool.SchemaDiff) []string {
+	if dialect != "postgres" && dialect != "cockroachdb" {
+		return []string{}
+	}
+
+	changedRelationNames := map[string]bool{}
+	for _, diff := range schemaDiff.Columns {
+		isRetyped := slices.Contains(diff.ModifiedProperties, "type") || slices.Contains(diff.ModifiedProperties, "collation")
+		if diff.DiffType == difftool.Deleted || (diff.DiffType == difftool.Modified && isRetyped) {
+			changedRelationNames[diff.TableName] = true
+		}
+	}
+
+	deletedViewNames := map[string]bool{}
+	for _, diff := range schemaDiff.Views {
+		if diff.DiffType == difftool.Deleted {
+			deletedViewNames[diff.ViewName] = true
+		} else if diff.DiffType == difftool.Modified && !canReplaceView(firstSchema.Views[diff.ViewName], secondSchema.Views[diff.ViewName]) {
+			changedRelationNames[diff.ViewName] = true
+		}
+	}
+
+	ret := []string{}
+	for viewName := range getDependentViews(secondSchema, changedRelationNames) {
+		if !deletedViewNames[viewName] {
+			ret = append(ret, viewName)
+		}
+	}
+	slices.Sort(ret)
+
+	return ret
+}
+
+// getDependentViews returns the views among the given relations (tables or views), along with the views of the schema
+// that select from any of them, directly or through other views.
+func getDependentViews(dbSchema *schema.Schema, relationNames map[string]bool) map[string]bool {
+	ret := map[string]bool{}
+	for relationName := range relationNames {
+		if _, ok := dbSchema.Views[relationName]; ok {
+			ret[relationName] = true
+		}
+	}
+
+	for progressed := true; progressed; {
+		progressed = false
+
+		for viewName, view := range dbSchema.Views {
+			if ret[viewName] {
+				continue
+			}
+
+			for _, dependency := range view.Dependencies {
+				if relationNames[dependency] || ret[dependency] {
+					ret[viewName] = true
+					progressed = true
+					break
+				}
+			}
+		}
+	}
+
+	return ret
+}
+
+// getStatementKeys returns the keys that a statement provides to the statements that depend on it. Tables and views
+// share the same namespace, so both provide a `relations:` key.
+func getStatementKeys(statement Statement) []string {
+	if statement.EntityType == "tables" || statement.EntityType == "views" {
+		return []string{"relations:" + statement.EntityName}
+	} else if statement.EntityType == "columns" {
+		tableName, _, _ := strings.Cut(statement.EntityName, " → ")
+		return []string{"columns:" + tableName}
+	}
+
+	return []string{statement.EntityType + ":" + statement.EntityName}
+}
+
+// getStatementRequirements returns the keys of the statements that a statement depends on. Requirements that no
+// statement provides are entities that already exist, and they are simply ignored.
+func getStatementRequirements(statement Statement, deps entityDependencies) []string {
+	ret := []string{}
+
+	// Relations (tables or views) and their columns.
+	requireRelation := func(relationName string) {
+		ret = append(ret, "relations:"+relationName, "columns:"+relationName)
+	}
+
+	if statement.EntityType == "tables" {
+		for constraintName, referencedTableName := range deps.foreignKeys {
+			tableName, _, _ := strings.Cut(constraintName, " → ")
+			if tableName == statement.EntityName && referencedTableName != statement.EntityName {
+				ret = append(ret, "relations:"+referencedTableName)
+			}
+		}
+	} else if statement.EntityType == "columns" || statement.EntityType == "indexes" {
+		tableName, _, _ := strings.Cut(statement.EntityName, " → ")
+		ret = append(ret, "relations:"+tableName)
+
+		if statement.EntityType == "indexes" {
+			ret = append(ret, "columns:"+tableName)
+		}
+	} else if statement.EntityType == "constraints" {
+		tableName, _, _ := strings.Cut(statement.EntityName, " → ")
+		requireRelation(tableName)
+
+		if referencedTableName, ok := deps.foreignKeys[statement.EntityName]; ok {
+			requireRelation(referencedTableName)
+		}
+	} else if statement.EntityType == "views" {
+		for _, relationName := range deps.views[statement.EntityName] {
+			requireRelation(relationName)
+		}
+	} else if statement.EntityType == "triggers" {
+		if tableName, ok := deps.triggers[statement.EntityName]; ok {
+			requireRelation(tableName)
+		}
+	}
+
+	return ret
+}
+
+// orderStatementsByDependencies sorts statements topologically with Kahn's algorithm so that every statement comes
+// after the statements it depends on. Statements that don't depend on each other keep their original order. A
+// dependency cycle is reported as an error that names every entity in the cycle.
+func orderStatementsByDependencies(statements []Statement, deps entityDependencies) ([]Statement, safego.Option[string]) {
+	// providers maps each key to the indices of the statements that provide it.
+	providers := map[string][]int{}
+	for i, statement := range statements {
+		for _, key := range getStatementKeys(statement) {
+			providers[key] = append(providers[key], i)
+		}
+	}
+
+	// edges maps each statement to the statements it depends on, and dependents the other way around. inDegrees holds
+	// the number of dependencies of each statement that aren't placed yet.
+	edges := make([][]int, len(statements))
+	dependents := make([][]int, len(statements))
+	inDegrees := make([]int, len(statements))
+	for i, statement := range statements {
+		for _, requirement := range getStatementRequirements(statement, deps) {
+			for _, provider := range providers[requirement] {
+				if provider != i {
+					edges[i] = append(edges[i], provider)
+					dependents[provider] = append(dependents[provider], i)
+					inDegrees[i] += 1
+				}
+			}
+		}
+	}
+
+	// The statements that are ready are kept in a min-heap of their indices to always pick the earliest one.
+	ready := &indexHeap{}
+	for i := range statements {
+		if inDegrees[i] == 0 {
+			heap.Push(ready, i)
+		}
+	}
+
+	ret := []Statement{}
+	done := make([]bool, len(statements))
+	for ready.Len() != 0 {
+		i := heap.Pop(ready).(int)
+		ret = append(ret, statements[i])
+		done[i] = true
+
+		for _, dependent := range dependents[i] {
+			inDegrees[dependent] -= 1
+			if inDegrees[dependent] == 0 {
+				heap.Push(ready, dependent)
+			}
+		}
+	}
+
+	if len(ret) < len(statements) {
+		return ret, safego.Some("Dependency cycle detected (each entity depends on the next one): " + describeDependencyCycle(statements, edges, done))
+	}
+
+	return ret, safego.None[string]()
+}
+
+// indexHeap is a min-heap of statement indices, for use with container/heap.
+type indexHeap []int
+
+func (self indexHeap) Len() int           { return len(self) }
+func (self indexHeap) Less(i, j int) bool { return self[i] < self[j] }
+func (self indexHeap) Swap(i, j int)      { self[i], self[j] = self[j], self[i] }
+
+func (self *indexHeap) Push(value any) {
+	*self = append(*self, value.(int))
+}
+
+func (self *indexHeap) Pop() any {
+	old := *self
+	ret := old[len(old)-1]
+	*self = old[:len(old)-1]
+
+	return ret
+}
+
+// describeDependencyCycle walks the remaining statements until it runs into one it has already visited, and returns
+// the cycle it found. e.g. `tables a → tables b → tables a`.
+func describeDependencyCycle(statements []Statement, edges [][]int, done []bool) string {
+	current := -1
+	for i := range statements {
+		if !done[i] {
+			current = i
+			break
+		}
+	}
+
+	visitedAt := map[int]int{}
+	path := []int{}
+	for {
+		if at, ok := visitedAt[current]; ok {
+			path = append(path[at:], current)
+			break
+		}
+
+		visitedAt[current] = len(path)
+		path = append(path, current)
+
+		// Every remaining statement has at least one remaining dependency, otherwise it would have been picked.
+		for _, dependency := range edges[current] {
+			if !done[dependency] {
+				current = dependency
+				break
+			}
+		}
+	}
+
+	names := []string{}
+	for _, i := range path {
+		names = append(names, statements[i].EntityType+" "+statements[i].EntityName)
+	}
+
+	return strings.Join(names, " → ")
+}
@@ -0,0 +1,153 @@
+package sequelizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+// getEntityNames returns the `type name` of each statement, in order.
+func getEntityNames(statements []Statement) []string {
+	ret := []string{}
+	for _, statement := range statements {
+		ret = append(ret, statement.EntityType+" "+statement.EntityName)
+	}
+
+	return ret
+}
+
+func TestOrderStatementsByDependencies(t *testing.T) {
+	deps := entityDependencies{
+		foreignKeys: map[string]string{"orders → fk_user": "users", "order_items → fk_order": "orders"},
+		views:       map[string][]string{"order_totals": {"orders", "order_items"}},
+		triggers:    map[string]string{"trg_orders": "orders"},
+	}
+
+	statements := []Statement{
+		{EntityType: "views", EntityName: "order_totals"},
+		{EntityType: "triggers", EntityName: "trg_orders"},
+		{EntityType: "tables", EntityName: "order_items"},
+		{EntityType: "tables", EntityName: "logs"},
+		{EntityType: "tables", EntityName: "orders"},
+		{EntityType: "columns", EntityName: "orders → total"},
+		{EntityType: "tables", EntityName: "users"},
+	}
+
+	ordered, errOpt := orderStatementsByDependencies(statements, deps)
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+
+	expected := []string{
+		"tables logs",
+		"tables users",
+		"tables orders",
+		"tables order_items",
+		"columns orders → total",
+		"views order_totals",
+		"triggers trg_orders",
+	}
+	if got := getEntityNames(ordered); strings.Join(got, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("got\n%v\nexpected\n%v", got, expected)
+	}
+}
+
+func TestOrderStatementsByDependenciesKeepsIndependentStatementsInOrder(t *testing.T) {
+	statements := []Statement{
+		{EntityType: "tables", EntityName: "c"},
+		{EntityType: "tables", EntityName: "a"},
+		{EntityType: "tables", EntityName: "b"},
+	}
+
+	ordered, errOpt := orderStatementsByDependencies(statements, getEntityDependencies(schema.NewSchema("mysql", "", "")))
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+
+	if got := strings.Join(getEntityNames(ordered), ", "); got != "tables c, tables a, tables b" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestOrderStatementsByDependenciesReportsCycles(t *testing.T) {
+	deps := entityDependencies{
+		foreignKeys: map[string]string{"a → fk_b": "b", "b → fk_c": "c", "c → fk_a": "a"},
+		views:       map[string][]string{},
+		triggers:    map[string]string{},
+	}
+
+	statements := []Statement{
+		{EntityType: "tables", EntityName: "independent"},
+		{EntityType: "tables", EntityName: "a"},
+		{EntityType: "tables", EntityName: "b"},
+		{EntityType: "tables", EntityName: "c"},
+	}
+
+	_, errOpt := orderStatementsByDependencies(statements, deps)
+	if errOpt.IsNone() {
+		t.Fatal("expected a dependency cycle error")
+	}
+
+	if message := errOpt.Unwrap(); !strings.HasSuffix(message, "tables a → tables b → tables c → tables a") {
+		t.Errorf("got %s", message)
+	}
+}
+
+func TestGenerateMigrationRecreatesViewsAroundColumnChanges(t *testing.T) {
+	newSchema := func(columns ...*schema.Column) *schema.Schema {
+		ret := testutil.NewSchema("postgres", testutil.NewTable("orders", columns...))
+		ret.Views["order_totals"] = &schema.View{
+			Name:         "order_totals",
+			Definition:   `CREATE OR REPLACE VIEW "order_totals" AS SELECT id, total FROM orders`,
+			Dependencies: []string{"orders"},
+		}
+		ret.Views["big_orders"] = &schema.View{
+			Name:         "big_orders",
+			Definition:   `CREATE OR REPLACE VIEW "big_orders" AS SELECT id FROM order_totals WHERE total > 100`,
+			Dependencies: []string{"order_totals"},
+		}
+
+		return ret
+	}
+
+	firstSchema := newSchema(&schema.Column{Name: "id", Type: "integer"}, &schema.Column{Name: "total", Type: "numeric(12,2)"})
+	secondSchema := newSchema(&schema.Column{Name: "id", Type: "integer"}, &schema.Column{Name: "total", Type: "integer"}, &schema.Column{Name: "note", Type: "text"})
+
+	schemaDiff := difftool.GetSchemaDiff(firstSchema, secondSchema, nil)
+
+	statements, errOpt := GenerateMigration(firstSchema, secondSchema, "postgres", schemaDiff)
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+
+	got := []string{}
+	for _, statement := range statements {
+		got = append(got, statement.Status+" "+statement.EntityType+" "+statement.EntityName)
+	}
+
+	expected := []string{
+		"deleted views big_orders",
+		"deleted views order_totals",
+		"deleted columns orders → note",
+		"modified columns orders → total",
+		"created views order_totals",
+		"created views big_orders",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+
+	// Mysql doesn't block column changes under a view, so the views are left alone.
+	statements, errOpt = GenerateMigration(firstSchema, secondSchema, "mysql", schemaDiff)
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+	for _, statement := range statements {
+		if statement.EntityType == "views" {
+			t.Errorf("unexpected statement for view %s", statement.EntityName)
+		}
+	}
+}
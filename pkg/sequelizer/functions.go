@@ -1,46 +1,47 @@
 package sequelizer
 
 import (
-	"database/sql"
-
-	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/safego"
 )
 
-// GenerateSqlForFunctions is the interface for generating SQL for functions in general.
-func GenerateSqlForFunctions(firstDb *sql.DB, dialect string, functionName string, status string) string {
-	var ret string
-
+// GenerateSqlForFunctions is the interface for generating SQL for functions in general. In Postgres, the function name
+// is expected to contain its identity arguments. e.g. `add(a integer, b integer)`. Modified functions are replaced in
+// place with `CREATE OR REPLACE`, so that the views and triggers that depend on them don't stop the migration, unless
+// the second schema tells that their arguments or return type changed. Mysql can't replace them, so they are dropped
+// and created again.
+func GenerateSqlForFunctions(firstSchema *schema.Schema, secondSchema *schema.Schema, dialect string, functionName string, status string) ([]string, safego.Option[string]) {
+	var dropStatement string
 	if dialect == "mysql" || dialect == "mariadb" {
-		ret = generateSqlForFunctionsMysql(firstDb, functionName, status)
+		dropStatement = "DROP FUNCTION IF EXISTS " + quoteMysqlIdentifier(functionName) + ";"
 	} else if dialect == "postgres" || dialect == "cockroachdb" {
-		utils.AbortTui("UNIMPLEMENTED")
+		dropStatement = "DROP FUNCTION IF EXISTS " + quotePostgresRoutineSignature(functionName) + ";"
 	}
 
-	return ret
-}
+	if status == "deleted" {
+		return []string{dropStatement}, safego.None[string]()
+	}
 
-// generateSqlForFunctionsMysql is responsible for generating SQL for functions in Mysql.
-func generateSqlForFunctionsMysql(firstDb *sql.DB, functionName string, status string) string {
-	var ret string
+	function, ok := firstSchema.Functions[functionName]
+	if !ok {
+		return []string{}, safego.Some("Function " + functionName + " was not found")
+	}
 
-	if status == "created" {
-		rows, err := firstDb.Query("SHOW CREATE FUNCTION " + functionName)
-		if err != nil {
-			utils.AbortTui("Error getting create function statement: " + err.Error())
-		}
+	createStatement := function.Definition + ";"
+	if dialect == "mysql" || dialect == "mariadb" {
+		createStatement = difftool.RemoveDefiner(function.Definition) + ";"
+	}
 
-		for rows.Next() {
-			var void string
-			err = rows.Scan(&void, &void, &ret, &void, &void, &void)
-			if err != nil {
-				utils.AbortTui("Error getting create function statement: " + err.Error())
-			}
+	if status == "modified" {
+		isPostgres := dialect == "postgres" || dialect == "cockroachdb"
+		secondFunction, ok := secondSchema.Functions[functionName]
+		if isPostgres && ok && canReplacePostgresRoutine(function.Definition, secondFunction.Definition) {
+			return []string{toPostgresCreateOrReplace(function.Definition) + ";"}, safego.None[string]()
 		}
 
-		ret += ";"
-	} else if status == "deleted" {
-		ret = "DROP FUNCTION IF EXISTS `" + functionName + "`;"
+		return []string{dropStatement, createStatement}, safego.None[string]()
 	}
 
-	return ret
+	return []string{createStatement}, safego.None[string]()
 }
@@ -0,0 +1,139 @@
+package sequelizer
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// GenerateSqlForIndexes generates the SQL for an index based on it's status (created, deleted or modified.)
+// Modified indexes can't be altered in place, so they are dropped and created again.
+func GenerateSqlForIndexes(firstSchema *schema.Schema, dialect string, indexName string, tableName string, status string) ([]string, safego.Option[string]) {
+	ret := []string{}
+	errOpt := safego.None[string]()
+
+	if dialect == "mysql" || dialect == "mariadb" {
+		ret, errOpt = generateSqlForIndexesMysql(firstSchema, indexName, tableName, status)
+	} else if dialect == "postgres" || dialect == "cockroachdb" {
+		ret, errOpt = generateSqlForIndexesPostgres(firstSchema, indexName, tableName, status)
+	} else if dialect == "sqlite" {
+		ret, errOpt = generateSqlForIndexesSqlite(firstSchema, indexName, tableName, status)
+	}
+
+	return ret, errOpt
+}
+
+// generateSqlForIndexesMysql is responsible for generating SQL for indexes in Mysql.
+func generateSqlForIndexesMysql(firstSchema *schema.Schema, indexName string, tableName string, status string) ([]string, safego.Option[string]) {
+	dropStatement := "DROP INDEX " + quoteMysqlIdentifier(indexName) + " ON " + quoteMysqlIdentifier(tableName) + ";"
+
+	if status == "deleted" {
+		return []string{dropStatement}, safego.None[string]()
+	}
+
+	index, errOpt := getIndexFromSchema(firstSchema, indexName, tableName)
+	if errOpt.IsSome() {
+		return []string{}, errOpt
+	}
+
+	keyParts := []string{}
+	for _, column := range index.Columns {
+		var keyPart string
+		if column.Expression != "" {
+			keyPart = "(" + column.Expression + ")"
+		} else {
+			keyPart = quoteMysqlIdentifier(column.ColumnName)
+			if column.SubPart != 0 {
+				keyPart += "(" + strconv.FormatInt(column.SubPart, 10) + ")"
+			}
+		}
+
+		if column.IsDesc {
+			keyPart += " DESC"
+		}
+
+		keyParts = append(keyParts, keyPart)
+	}
+
+	createStatement := "CREATE "
+	if index.Type == "FULLTEXT" || index.Type == "SPATIAL" {
+		createStatement += index.Type + " "
+	} else if index.IsUnique {
+		createStatement += "UNIQUE "
+	}
+	createStatement += "INDEX " + quoteMysqlIdentifier(indexName) + " ON " + quoteMysqlIdentifier(tableName) + " (" + strings.Join(keyParts, ", ") + ")"
+	if index.Type == "HASH" {
+		createStatement += " USING HASH"
+	}
+	if !index.IsVisible {
+		createStatement += " INVISIBLE"
+	}
+	createStatement += ";"
+
+	if status == "modified" {
+		return []string{dropStatement, createStatement}, safego.None[string]()
+	}
+
+	return []string{createStatement}, safego.None[string]()
+}
+
+// generateSqlForIndexesPostgres is responsible for generating SQL for indexes in Postgres.
+func generateSqlForIndexesPostgres(firstSchema *schema.Schema, indexName string, tableName string, status string) ([]string, safego.Option[string]) {
+	dropStatement := "DROP INDEX IF EXISTS " + quotePostgresIdentifier(indexName) + ";"
+
+	if status == "deleted" {
+		return []string{dropStatement}, safego.None[string]()
+	}
+
+	index, errOpt := getIndexFromSchema(firstSchema, indexName, tableName)
+	if errOpt.IsSome() {
+		return []string{}, errOpt
+	}
+
+	createStatement := index.Definition + ";"
+
+	if status == "modified" {
+		return []string{dropStatement, createStatement}, safego.None[string]()
+	}
+
+	return []string{createStatement}, safego.None[string]()
+}
+
+// generateSqlForIndexesSqlite is responsible for generating SQL for indexes in Sqlite.
+func generateSqlForIndexesSqlite(firstSchema *schema.Schema, indexName string, tableName string, status string) ([]string, safego.Option[string]) {
+	dropStatement := "DROP INDEX IF EXISTS " + quoteSqliteIdentifier(indexName) + ";"
+
+	if status == "deleted" {
+		return []string{dropStatement}, safego.None[string]()
+	}
+
+	index, errOpt := getIndexFromSchema(firstSchema, indexName, tableName)
+	if errOpt.IsSome() {
+		return []string{}, errOpt
+	}
+
+	createStatement := addSqliteIfNotExists(index.Definition) + ";"
+
+	if status == "modified" {
+		return []string{dropStatement, createStatement}, safego.None[string]()
+	}
+
+	return []string{createStatement}, safego.None[string]()
+}
+
+// getIndexFromSchema looks up an index of a table in the schema.
+func getIndexFromSchema(firstSchema *schema.Schema, indexName string, tableName string) (*schema.Index, safego.Option[string]) {
+	table, ok := firstSchema.Tables[tableName]
+	if !ok {
+		return nil, safego.Some("Table " + tableName + " was not found")
+	}
+
+	index, ok := table.Indexes[indexName]
+	if !ok {
+		return nil, safego.Some("Index " + indexName + " was not found on table " + tableName)
+	}
+
+	return index, safego.None[string]()
+}
@@ -0,0 +1,71 @@
+package sequelizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+func TestGenerateSqlForIndexes(t *testing.T) {
+	newIndexSchema := func(dialect string, index *schema.Index) *schema.Schema {
+		ret := testutil.NewSchema(dialect, testutil.NewTable("users", &schema.Column{Name: "email", Type: "varchar(255)"}))
+		ret.Tables["users"].Indexes[index.Name] = index
+
+		return ret
+	}
+
+	tests := []struct {
+		name     string
+		dialect  string
+		index    *schema.Index
+		status   string
+		expected string
+	}{
+		{
+			name:    "mysql prefix and descending columns",
+			dialect: "mysql",
+			index: &schema.Index{Name: "idx_email", Type: "BTREE", IsVisible: true, Columns: []schema.IndexColumn{
+				{ColumnName: "email", SubPart: 10},
+				{Expression: "lower(`name`)", IsDesc: true},
+			}},
+			status:   "created",
+			expected: "CREATE INDEX `idx_email` ON `users` (`email`(10), (lower(`name`)) DESC);",
+		},
+		{
+			name:     "mysql invisible fulltext index is recreated",
+			dialect:  "mysql",
+			index:    &schema.Index{Name: "ft_email", Type: "FULLTEXT", Columns: []schema.IndexColumn{{ColumnName: "email"}}},
+			status:   "modified",
+			expected: "DROP INDEX `ft_email` ON `users`;\nCREATE FULLTEXT INDEX `ft_email` ON `users` (`email`) INVISIBLE;",
+		},
+		{
+			name:     "mysql deleted",
+			dialect:  "mysql",
+			index:    &schema.Index{Name: "idx_email"},
+			status:   "deleted",
+			expected: "DROP INDEX `idx_email` ON `users`;",
+		},
+		{
+			name:     "postgres modified",
+			dialect:  "postgres",
+			index:    &schema.Index{Name: "Idx_Email", Definition: `CREATE INDEX "Idx_Email" ON public.users USING gin (email)`},
+			status:   "modified",
+			expected: "DROP INDEX IF EXISTS \"Idx_Email\";\nCREATE INDEX \"Idx_Email\" ON public.users USING gin (email);",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queries, errOpt := GenerateSqlForIndexes(newIndexSchema(test.dialect, test.index), test.dialect, test.index.Name, "users", test.status)
+			if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+
+			if sql := strings.Join(queries, "\n"); sql != test.expected {
+				t.Errorf("got\n%s\nexpected\n%s", sql, test.expected)
+			}
+		})
+	}
+}
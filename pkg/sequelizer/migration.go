@@ -0,0 +1,364 @@
+package sequelizer
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// Statement is a piece of generated SQL along with the entity it was generated for.
+type Statement struct {
+	// EntityType is the type of the entity (tables, columns, indexes, ..etc.)
+	EntityType string
+	// EntityName is the name of the entity as shown in the TUI. e.g. `users` or `users → email`.
+	EntityName string
+	// Status is the status of the entity (created, deleted, modified or renamed.)
+	Status string
+	// Queries are the queries the statement is made of, which are run one at a time. e.g. a modified index is dropped
+	// and created again. Sql is the same queries joined by new lines.
+	Queries []string
+	Sql     string
+	// RollbackQueries are the queries that undo Queries. They are generated from the second schema, which is the only
+	// place that knows what a dropped or modified entity looked like before the migration.
+	RollbackQueries []string
+	RollbackSql     string
+}
+
+// newStatement creates a statement out of its queries and the queries that undo them.
+func newStatement(entityType string, entityName string, status string, queries []string, rollbackQueries []string) Statement {
+	return Statement{
+		EntityType:      entityType,
+		EntityName:      entityName,
+		Status:          status,
+		Queries:         queries,
+		Sql:             strings.Join(queries, "\n"),
+		RollbackQueries: rollbackQueries,
+		RollbackSql:     strings.Join(rollbackQueries, "\n"),
+	}
+}
+
+// GenerateMigration generates the SQL for every entity in a schema diff. Everything that is deleted is dropped first,
+// then everything that is created or modified follows. Both groups are ordered by the dependencies between entities
+// (foreign keys, views and triggers), so nothing is created before what it needs and nothing is dropped while
+// something still needs it. Views that would block a change to the columns of their tables are dropped along with the
+// deleted entities and created again after the change.
+func GenerateMigration(firstSchema *schema.Schema, secondSchema *schema.Schema, dialect string, schemaDiff difftool.SchemaDiff) ([]Statement, safego.Option[string]) {
+	statements := []Statement{}
+
+	if dialect == "sqlite" {
+		if errOpt := checkSqliteRenames(schemaDiff); errOpt.IsSome() {
+			return statements, errOpt
+		}
+	}
+
+	appendStatement := func(entityType string, tableName string, entityName string, diffType difftool.DiffType) safego.Option[string] {
+		status := diffType.String()
+
+		queries, errOpt := generateSqlForEntity(firstSchema, secondSchema, dialect, entityType, tableName, entityName, status)
+		if errOpt.IsSome() {
+			return errOpt
+		}
+
+		// The rollback is the same migration in the opposite direction: the schemas swap places, and what was
+		// created is now deleted and vice versa.
+		rollbackQueries, errOpt := generateSqlForEntity(secondSchema, firstSchema, dialect, entityType, tableName, entityName, invertStatus(status))
+		if errOpt.IsSome() {
+			return errOpt
+		}
+
+		if tableName != "" {
+			entityName = tableName + " → " + entityName
+		}
+
+		statements = append(statements, newStatement(entityType, entityName, status, queries, rollbackQueries))
+
+		return safego.None[string]()
+	}
+
+	var errOpt safego.Option[string]
+	for _, diff := range schemaDiff.Tables {
+		if errOpt = appendStatement("tables", "", diff.TableName, diff.DiffType); errOpt.IsSome() {
+			return statements, errOpt
+		}
+	}
+	for _, diff := range schemaDiff.Columns {
+		if errOpt = appendStatement("columns", diff.TableName, diff.ColumnName, diff.DiffType); errOpt.IsSome() {
+			return statements, errOpt
+		}
+	}
+	for _, diff := range schemaDiff.Indexes {
+		if errOpt = appendStatement("indexes", diff.TableName, diff.IndexName, diff.DiffType); errOpt.IsSome() {
+			return statements, errOpt
+		}
+	}
+	for _, diff := range schemaDiff.Constraints {
+		if errOpt = appendStatement("constraints", diff.TableName, diff.ConstraintName, diff.DiffType); errOpt.IsSome() {
+			return statements, errOpt
+		}
+	}
+	// Views that have to be recreated around a change to their tables are dropped and created, instead of being
+	// modified in place.
+	recreatedViewNames := getViewsToRecreate(firstSchema, secondSchema, dialect, schemaDiff)
+	for _, diff := range schemaDiff.Views {
+		if diff.DiffType == difftool.Modified && slices.Contains(recreatedViewNames, diff.ViewName) {
+			continue
+		}
+
+		if errOpt = appendStatement("views", "", diff.ViewName, diff.DiffType); errOpt.IsSome() {
+			return statements, errOpt
+		}
+	}
+	for _, viewName := range recreatedViewNames {
+		if errOpt = appendStatement("views", "", viewName, difftool.Deleted); errOpt.IsSome() {
+			return statements, errOpt
+		}
+		if errOpt = appendStatement("views", "", viewName, difftool.Created); errOpt.IsSome() {
+			return statements, errOpt
+		}
+	}
+	for _, diff := range schemaDiff.Procedures {
+		if errOpt = appendStatement("procedures", "", diff.ProcedureName, diff.DiffType); errOpt.IsSome() {
+			return statements, errOpt
+		}
+	}
+	for _, diff := range schemaDiff.Functions {
+		if errOpt = appendStatement("functions", "", diff.FunctionName, diff.DiffType); errOpt.IsSome() {
+			return statements, errOpt
+		}
+	}
+	for _, diff := range schemaDiff.Triggers {
+		if errOpt = appendStatement("triggers", "", diff.TriggerName, diff.DiffType); errOpt.IsSome() {
+			return statements, errOpt
+		}
+	}
+
+	for _, rename := range schemaDiff.Renames {
+		queries, errOpt := GenerateSqlForRename(firstSchema, secondSchema, dialect, rename)
+		if errOpt.IsSome() {
+			return statements, errOpt
+		}
+
+		rollbackQueries, errOpt := GenerateSqlForRename(secondSchema, firstSchema, dialect, invertRename(rename))
+		if errOpt.IsSome() {
+			return statements, errOpt
+		}
+
+		entityName := rename.NewName
+		if rename.TableName != "" {
+			entityName = rename.TableName + " → " + rename.NewName
+		}
+
+		statements = append(statements, newStatement(rename.EntityType, entityName, "renamed", queries, rollbackQueries))
+	}
+
+	// The dependencies of dropped entities can only be found in the second schema since that is where they exist.
+	firstSchemaDependencies := getEntityDependencies(firstSchema)
+	secondSchemaDependencies := getEntityDependencies(secondSchema)
+
+	dropStatements := []Statement{}
+	createStatements := []Statement{}
+	for _, statement := range statements {
+		if statement.Status == "deleted" {
+			dropStatements = append(dropStatements, statement)
+		} else {
+			createStatements = append(createStatements, statement)
+		}
+	}
+
+	dropStatements, errOpt = orderStatementsByDependencies(dropStatements, secondSchemaDependencies)
+	if errOpt.IsSome() {
+		return statements, errOpt
+	}
+
+	createStatements, errOpt = orderStatementsByDependencies(createStatements, firstSchemaDependencies)
+	if errOpt.IsSome() {
+		return statements, errOpt
+	}
+
+	// Drops go in the reverse order of their dependencies. e.g. a view is dropped before the table it selects from.
+	ret := []Statement{}
+	for i := len(dropStatements) - 1; i >= 0; i -= 1 {
+		ret = append(ret, dropStatements[i])
+	}
+	ret = append(ret, createStatements...)
+
+	if dialect == "sqlite" {
+		ret = mergeSqliteTableRebuilds(ret)
+	}
+
+	return ret, safego.None[string]()
+}
+
+// checkSqliteRenames makes sure that no table with a renamed column has to be rebuilt. A rebuild only copies the
+// columns that have the same name in both schemas, so the rows of the renamed column would be lost.
+func checkSqliteRenames(schemaDiff difftool.SchemaDiff) safego.Option[string] {
+	renamedColumns := map[string]string{}
+	for _, rename := range schemaDiff.Renames {
+		if rename.EntityType == "columns" {
+			renamedColumns[rename.TableName] = rename.NewName
+		}
+	}
+
+	changedTableNames := []string{}
+	for _, diff := range schemaDiff.Columns {
+		changedTableNames = append(changedTableNames, diff.TableName)
+	}
+	for _, diff := range schemaDiff.Constraints {
+		changedTableNames = append(changedTableNames, diff.TableName)
+	}
+
+	for _, tableName := range changedTableNames {
+		if columnName, ok := renamedColumns[tableName]; ok {
+			return safego.Some("Column " + tableName + " → " + columnName + " is renamed along with other changes to its table, which Sqlite makes by rebuilding the table. Apply the rename on its own first")
+		}
+	}
+
+	return safego.None[string]()
+}
+
+// mergeSqliteTableRebuilds keeps a single rebuild of each table. A rebuild brings the whole table to its definition in
+// the other schema, so the first one makes every change to the table, and the last rollback, which runs first, undoes
+// all of them. The statements whose rebuild is left out are kept with a comment so that they still show up in the
+// migration, without any queries to run.
+func mergeSqliteTableRebuilds(statements []Statement) []Statement {
+	isRebuild := func(queries []string) bool {
+		return len(queries) != 0 && queries[0] == "PRAGMA legacy_alter_table = ON;"
+	}
+
+	seenSql := map[string]bool{}
+	for i := range statements {
+		tableName, _, _ := strings.Cut(statements[i].EntityName, " → ")
+
+		if isRebuild(statements[i].Queries) {
+			if seenSql[statements[i].Sql] {
+				statements[i].Queries = []string{}
+				statements[i].Sql = "-- Made by the rebuild of table " + tableName + " above."
+			}
+			seenSql[statements[i].Sql] = true
+		}
+	}
+
+	seenRollbackSql := map[string]bool{}
+	for i := len(statements) - 1; i >= 0; i -= 1 {
+		tableName, _, _ := strings.Cut(statements[i].EntityName, " → ")
+
+		if isRebuild(statements[i].RollbackQueries) {
+			if seenRollbackSql[statements[i].RollbackSql] {
+				statements[i].RollbackQueries = []string{}
+				statements[i].RollbackSql = "-- Undone by the rebuild of table " + tableName + " above."
+			}
+			seenRollbackSql[statements[i].RollbackSql] = true
+		}
+	}
+
+	return statements
+}
+
+// GetRollbackStatements returns the statements that undo a migration. Reversing the order of the migration keeps the
+// dependencies intact: whatever was created last is dropped first, and whatever was dropped first is created last.
+func GetRollbackStatements(statements []Statement) []Statement {
+	ret := []Statement{}
+
+	for i := len(statements) - 1; i >= 0; i -= 1 {
+		ret = append(ret, Statement{
+			EntityType:      statements[i].EntityType,
+			EntityName:      statements[i].EntityName,
+			Status:          invertStatus(statements[i].Status),
+			Queries:         statements[i].RollbackQueries,
+			Sql:             statements[i].RollbackSql,
+			RollbackQueries: statements[i].Queries,
+			RollbackSql:     statements[i].Sql,
+		})
+	}
+
+	return ret
+}
+
+// generateSqlForEntity calls the generator for the given type of entity. The definitions of created and modified
+// entities are read from firstSchema. tableName is only used by entities that belong to a table (columns, indexes, etc.)
+func generateSqlForEntity(firstSchema *schema.Schema, secondSchema *schema.Schema, dialect string, entityType string, tableName string, entityName string, status string) ([]string, safego.Option[string]) {
+	if entityType == "tables" {
+		return GenerateSqlForTables(firstSchema, dialect, entityName, status)
+	} else if entityType == "columns" {
+		return GenerateSqlForColumns(firstSchema, secondSchema, dialect, entityName, tableName, status)
+	} else if entityType == "indexes" {
+		return GenerateSqlForIndexes(firstSchema, dialect, entityName, tableName, status)
+	} else if entityType == "constraints" {
+		return GenerateSqlForConstraints(firstSchema, secondSchema, dialect, entityName, tableName, status)
+	} else if entityType == "views" {
+		return GenerateSqlForViews(firstSchema, secondSchema, dialect, entityName, status)
+	} else if entityType == "procedures" {
+		return GenerateSqlForProcedures(firstSchema, secondSchema, dialect, entityName, status)
+	} else if entityType == "functions" {
+		return GenerateSqlForFunctions(firstSchema, secondSchema, dialect, entityName, status)
+	} else if entityType == "triggers" {
+		return GenerateSqlForTriggers(firstSchema, dialect, entityName, status)
+	}
+
+	return []string{}, safego.Some("Unknown entity type " + entityType)
+}
+
+// GetEntityDefinition returns the SQL that creates an entity as it exists in the given schema, or None if the entity
+// doesn't exist in it. tableName is only used by entities that belong to a table (columns, indexes, etc.)
+func GetEntityDefinition(dbSchema *schema.Schema, entityType string, tableName string, entityName string) safego.Option[string] {
+	queries, errOpt := generateSqlForEntity(dbSchema, dbSchema, dbSchema.Dialect, entityType, tableName, entityName, "created")
+	if errOpt.IsSome() {
+		return safego.None[string]()
+	}
+
+	return safego.Some(strings.Join(queries, "\n"))
+}
+
+// invertStatus returns the status an entity has when a migration is run in the opposite direction.
+func invertStatus(status string) string {
+	if status == "created" {
+		return "deleted"
+	} else if status == "deleted" {
+		return "created"
+	}
+
+	return status
+}
+
+// FormatMigrationScript joins the statements into a single script that can be run by the database's CLI client. The
+// header is added at the top of the script as SQL comments.
+func FormatMigrationScript(statements []Statement, dialect string, header []string) string {
+	var script strings.Builder
+
+	for _, line := range header {
+		script.WriteString("-- " + line + "\n")
+	}
+
+	if len(statements) == 0 {
+		script.WriteString("\n-- No differences found.\n")
+	}
+
+	for _, statement := range statements {
+		script.WriteString("\n")
+
+		// Routines and triggers contain semicolons in their bodies, which the Mysql client treats as the end of the
+		// statement unless the delimiter is changed.
+		isMysqlCompoundStatement := (dialect == "mysql" || dialect == "mariadb") &&
+			statement.Status != "deleted" &&
+			(statement.EntityType == "procedures" || statement.EntityType == "functions" || statement.EntityType == "triggers")
+
+		if isMysqlCompoundStatement && len(statement.Queries) != 0 {
+			// Modified routines and triggers start with a `DROP` that runs with the default delimiter.
+			createSql := statement.Queries[len(statement.Queries)-1]
+			for _, dropSql := range statement.Queries[:len(statement.Queries)-1] {
+				script.WriteString(dropSql + "\n")
+			}
+
+			script.WriteString("DELIMITER $$\n")
+			script.WriteString(strings.TrimSuffix(createSql, ";") + "$$\n")
+			script.WriteString("DELIMITER ;\n")
+		} else {
+			script.WriteString(statement.Sql + "\n")
+		}
+	}
+
+	return script.String()
+}
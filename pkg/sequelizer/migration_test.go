@@ -0,0 +1,170 @@
+package sequelizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+func TestGetRollbackStatements(t *testing.T) {
+	statements := []Statement{
+		newStatement("tables", "orders", "deleted", []string{"DROP TABLE orders;"}, []string{"CREATE TABLE orders (id int);"}),
+		newStatement("tables", "users", "created", []string{"CREATE TABLE users (id int);"}, []string{"DROP TABLE users;"}),
+		newStatement("indexes", "users → users_id", "modified", []string{"DROP INDEX a;", "CREATE INDEX a (id);"}, []string{"DROP INDEX a;", "CREATE INDEX a (id, name);"}),
+		newStatement("columns", "users → name", "renamed", []string{"RENAME name;"}, []string{"RENAME full_name;"}),
+	}
+
+	expected := []Statement{
+		newStatement("columns", "users → name", "renamed", []string{"RENAME full_name;"}, []string{"RENAME name;"}),
+		newStatement("indexes", "users → users_id", "modified", []string{"DROP INDEX a;", "CREATE INDEX a (id, name);"}, []string{"DROP INDEX a;", "CREATE INDEX a (id);"}),
+		newStatement("tables", "users", "deleted", []string{"DROP TABLE users;"}, []string{"CREATE TABLE users (id int);"}),
+		newStatement("tables", "orders", "created", []string{"CREATE TABLE orders (id int);"}, []string{"DROP TABLE orders;"}),
+	}
+
+	rollbackStatements := GetRollbackStatements(statements)
+	if len(rollbackStatements) != len(expected) {
+		t.Fatalf("got %d statements, expected %d", len(rollbackStatements), len(expected))
+	}
+
+	for i, statement := range rollbackStatements {
+		t.Run(expected[i].EntityName, func(t *testing.T) {
+			if statement.EntityType != expected[i].EntityType || statement.EntityName != expected[i].EntityName || statement.Status != expected[i].Status {
+				t.Errorf("got %s %s %s, expected %s %s %s", statement.EntityType, statement.EntityName, statement.Status, expected[i].EntityType, expected[i].EntityName, expected[i].Status)
+			}
+			if statement.Sql != expected[i].Sql || strings.Join(statement.Queries, "|") != strings.Join(expected[i].Queries, "|") {
+				t.Errorf("got\n%s\nexpected\n%s", statement.Sql, expected[i].Sql)
+			}
+			if statement.RollbackSql != expected[i].RollbackSql || strings.Join(statement.RollbackQueries, "|") != strings.Join(expected[i].RollbackQueries, "|") {
+				t.Errorf("got rollback\n%s\nexpected\n%s", statement.RollbackSql, expected[i].RollbackSql)
+			}
+		})
+	}
+}
+
+func TestGenerateMigrationRollbackSql(t *testing.T) {
+	firstSchema := testutil.NewSchema("mysql", testutil.NewTable("users",
+		&schema.Column{Name: "id", Type: "int"},
+		&schema.Column{Name: "email", Type: "varchar(255)"},
+		&schema.Column{Name: "created_at", Type: "datetime", IsNullable: true},
+	))
+	firstSchema.Tables["orders"] = &schema.Table{Name: "orders", Definition: "CREATE TABLE `orders` (\n  `id` int NOT NULL\n)"}
+
+	secondSchema := testutil.NewSchema("mysql", testutil.NewTable("users",
+		&schema.Column{Name: "id", Type: "int"},
+		&schema.Column{Name: "email", Type: "varchar(100)", IsNullable: true},
+		&schema.Column{Name: "nickname", Type: "varchar(20)", IsNullable: true},
+	))
+
+	statements, errOpt := GenerateMigration(firstSchema, secondSchema, "mysql", difftool.GetSchemaDiff(firstSchema, secondSchema, nil))
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+
+	tests := []struct {
+		name             string
+		entityName       string
+		expectedStatus   string
+		expectedRollback string
+	}{
+		{
+			name:             "created table is dropped",
+			entityName:       "orders",
+			expectedStatus:   "created",
+			expectedRollback: "DROP TABLE IF EXISTS `orders`;",
+		},
+		{
+			name:             "created column is dropped",
+			entityName:       "users → created_at",
+			expectedStatus:   "created",
+			expectedRollback: "ALTER TABLE `users` DROP COLUMN `created_at`;",
+		},
+		{
+			name:             "deleted column is added back from the second schema",
+			entityName:       "users → nickname",
+			expectedStatus:   "deleted",
+			expectedRollback: "ALTER TABLE `users` ADD COLUMN `nickname` varchar(20) NULL AFTER `email`;",
+		},
+		{
+			name:             "modified column is restored to the second schema",
+			entityName:       "users → email",
+			expectedStatus:   "modified",
+			expectedRollback: "ALTER TABLE `users` MODIFY COLUMN `email` varchar(100) NULL AFTER `id`;",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, statement := range statements {
+				if statement.EntityName != test.entityName {
+					continue
+				}
+
+				if statement.Status != test.expectedStatus {
+					t.Errorf("got status %s, expected %s", statement.Status, test.expectedStatus)
+				}
+				if statement.RollbackSql != test.expectedRollback {
+					t.Errorf("got\n%s\nexpected\n%s", statement.RollbackSql, test.expectedRollback)
+				}
+
+				return
+			}
+
+			t.Fatalf("no statement was generated for %s", test.entityName)
+		})
+	}
+}
+
+func TestFormatMigrationScript(t *testing.T) {
+	header := []string{"Migration of production", "Generated at: 2026-10-18T00:00:00Z"}
+
+	tests := []struct {
+		name       string
+		dialect    string
+		statements []Statement
+		expected   string
+	}{
+		{
+			name:       "empty diff",
+			dialect:    "postgres",
+			statements: []Statement{},
+			expected:   "-- Migration of production\n-- Generated at: 2026-10-18T00:00:00Z\n\n-- No differences found.\n",
+		},
+		{
+			name:    "statements keep their order",
+			dialect: "postgres",
+			statements: []Statement{
+				newStatement("tables", "orders", "deleted", []string{"DROP TABLE orders;"}, []string{}),
+				newStatement("tables", "users", "created", []string{"CREATE TABLE users (id int);", "CREATE INDEX users_id ON users (id);"}, []string{}),
+				newStatement("functions", "one()", "modified", []string{"CREATE OR REPLACE FUNCTION one() RETURNS int AS $$SELECT 1;$$;"}, []string{}),
+			},
+			expected: "-- Migration of production\n-- Generated at: 2026-10-18T00:00:00Z\n" +
+				"\nDROP TABLE orders;\n" +
+				"\nCREATE TABLE users (id int);\nCREATE INDEX users_id ON users (id);\n" +
+				"\nCREATE OR REPLACE FUNCTION one() RETURNS int AS $$SELECT 1;$$;\n",
+		},
+		{
+			name:    "mysql routines change the delimiter",
+			dialect: "mysql",
+			statements: []Statement{
+				newStatement("procedures", "old", "deleted", []string{"DROP PROCEDURE IF EXISTS `old`;"}, []string{}),
+				newStatement("procedures", "touch", "modified", []string{"DROP PROCEDURE IF EXISTS `touch`;", "CREATE PROCEDURE `touch`()\nBEGIN\n\tSELECT 1;\nEND;"}, []string{}),
+				newStatement("triggers", "audit", "created", []string{"CREATE TRIGGER `audit` AFTER INSERT ON `t` FOR EACH ROW SET @a = 1;"}, []string{}),
+			},
+			expected: "-- Migration of production\n-- Generated at: 2026-10-18T00:00:00Z\n" +
+				"\nDROP PROCEDURE IF EXISTS `old`;\n" +
+				"\nDROP PROCEDURE IF EXISTS `touch`;\nDELIMITER $$\nCREATE PROCEDURE `touch`()\nBEGIN\n\tSELECT 1;\nEND$$\nDELIMITER ;\n" +
+				"\nDELIMITER $$\nCREATE TRIGGER `audit` AFTER INSERT ON `t` FOR EACH ROW SET @a = 1$$\nDELIMITER ;\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if script := FormatMigrationScript(test.statements, test.dialect, header); script != test.expected {
+				t.Errorf("got\n%s\nexpected\n%s", script, test.expected)
+			}
+		})
+	}
+}
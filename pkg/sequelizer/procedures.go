@@ -1,46 +1,47 @@
 package sequelizer
 
 import (
-	"database/sql"
-
-	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/safego"
 )
 
-// GenerateSqlForProcedures is the interface for generating SQL for procedures in general.
-func GenerateSqlForProcedures(firstDb *sql.DB, dialect string, procedureName string, status string) string {
-	var ret string
-
+// GenerateSqlForProcedures is the interface for generating SQL for procedures in general. In Postgres, the procedure
+// name is expected to contain its identity arguments. e.g. `add(a integer, b integer)`. Modified procedures are
+// replaced in place with `CREATE OR REPLACE`, so that the views and triggers that depend on them don't stop the
+// migration, unless the second schema tells that their arguments or return type changed. Mysql can't replace them, so
+// they are dropped and created again.
+func GenerateSqlForProcedures(firstSchema *schema.Schema, secondSchema *schema.Schema, dialect string, procedureName string, status string) ([]string, safego.Option[string]) {
+	var dropStatement string
 	if dialect == "mysql" || dialect == "mariadb" {
-		ret = generateSqlForProceduresMysql(firstDb, procedureName, status)
+		dropStatement = "DROP PROCEDURE IF EXISTS " + quoteMysqlIdentifier(procedureName) + ";"
 	} else if dialect == "postgres" || dialect == "cockroachdb" {
-		utils.AbortTui("UNIMPLEMENTED")
+		dropStatement = "DROP PROCEDURE IF EXISTS " + quotePostgresRoutineSignature(procedureName) + ";"
 	}
 
-	return ret
-}
+	if status == "deleted" {
+		return []string{dropStatement}, safego.None[string]()
+	}
 
-// generateSqlForProceduresMysql is responsible for generating SQL for procedures in Mysql.
-func generateSqlForProceduresMysql(firstDb *sql.DB, procedureName string, status string) string {
-	var ret string
+	procedure, ok := firstSchema.Procedures[procedureName]
+	if !ok {
+		return []string{}, safego.Some("Procedure " + procedureName + " was not found")
+	}
 
-	if status == "created" {
-		rows, err := firstDb.Query("SHOW CREATE PROCEDURE " + procedureName)
-		if err != nil {
-			utils.AbortTui("Error getting create procedure statement: " + err.Error())
-		}
+	createStatement := procedure.Definition + ";"
+	if dialect == "mysql" || dialect == "mariadb" {
+		createStatement = difftool.RemoveDefiner(procedure.Definition) + ";"
+	}
 
-		for rows.Next() {
-			var void string
-			err = rows.Scan(&void, &void, &ret, &void, &void, &void)
-			if err != nil {
-				utils.AbortTui("Error getting create procedure statement: " + err.Error())
-			}
+	if status == "modified" {
+		isPostgres := dialect == "postgres" || dialect == "cockroachdb"
+		secondProcedure, ok := secondSchema.Procedures[procedureName]
+		if isPostgres && ok && canReplacePostgresRoutine(procedure.Definition, secondProcedure.Definition) {
+			return []string{toPostgresCreateOrReplace(procedure.Definition) + ";"}, safego.None[string]()
 		}
 
-		ret += ";"
-	} else if status == "deleted" {
-		ret = "DROP PROCEDURE IF EXISTS `" + procedureName + "`;"
+		return []string{dropStatement, createStatement}, safego.None[string]()
 	}
 
-	return ret
+	return []string{createStatement}, safego.None[string]()
 }
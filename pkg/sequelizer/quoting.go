@@ -0,0 +1,49 @@
+package sequelizer
+
+import "strings"
+
+// quoteMysqlString wraps a string in single quotes, escaping any quotes or backslashes inside of it.
+func quoteMysqlString(str string) string {
+	str = strings.ReplaceAll(str, "\\", "\\\\")
+	str = strings.ReplaceAll(str, "'", "''")
+
+	return "'" + str + "'"
+}
+
+// quotePostgresString wraps a string in single quotes, escaping any quotes inside of it.
+func quotePostgresString(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", "''") + "'"
+}
+
+// quoteMysqlIdentifier wraps an identifier (table name, column name, etc.) in backticks, escaping any backticks inside of it.
+func quoteMysqlIdentifier(identifier string) string {
+	return "`" + strings.ReplaceAll(identifier, "`", "``") + "`"
+}
+
+// quotePostgresIdentifier wraps an identifier (table name, column name, etc.) in double quotes so that names with upper
+// case letters or reserved words survive being sent back to Postgres.
+func quotePostgresIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// quoteSqliteIdentifier wraps an identifier in double quotes, which Sqlite takes just like Postgres.
+func quoteSqliteIdentifier(identifier string) string {
+	return quotePostgresIdentifier(identifier)
+}
+
+// quotePostgresRoutineSignature quotes the name part of a routine signature. e.g. `add(a integer)` -> `"add"(a integer)`.
+func quotePostgresRoutineSignature(signature string) string {
+	openingParenIndex := strings.Index(signature, "(")
+	if openingParenIndex == -1 {
+		return quotePostgresIdentifier(signature)
+	}
+
+	return quotePostgresIdentifier(signature[:openingParenIndex]) + signature[openingParenIndex:]
+}
+
+// splitPostgresTriggerName splits a Postgres trigger name in the form of `trigger ON table` into its two parts.
+func splitPostgresTriggerName(name string) (string, string) {
+	triggerName, tableName, _ := strings.Cut(name, " ON ")
+
+	return triggerName, tableName
+}
@@ -0,0 +1,83 @@
+package sequelizer
+
+import (
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// GenerateSqlForRename generates the SQL that renames a table or a column. A renamed column whose properties changed
+// as well is modified right after it is renamed, based on its definition in the first schema.
+func GenerateSqlForRename(firstSchema *schema.Schema, secondSchema *schema.Schema, dialect string, rename difftool.RenameCandidate) ([]string, safego.Option[string]) {
+	ret := []string{}
+
+	if rename.EntityType == "tables" {
+		if dialect == "mysql" || dialect == "mariadb" {
+			ret = []string{"RENAME TABLE " + quoteMysqlIdentifier(rename.OldName) + " TO " + quoteMysqlIdentifier(rename.NewName) + ";"}
+		} else if dialect == "postgres" || dialect == "cockroachdb" {
+			ret = []string{"ALTER TABLE " + quotePostgresIdentifier(rename.OldName) + " RENAME TO " + quotePostgresIdentifier(rename.NewName) + ";"}
+		} else if dialect == "sqlite" {
+			ret = []string{"ALTER TABLE " + quoteSqliteIdentifier(rename.OldName) + " RENAME TO " + quoteSqliteIdentifier(rename.NewName) + ";"}
+		}
+	} else if rename.EntityType == "columns" {
+		if dialect == "mysql" || dialect == "mariadb" {
+			ret = []string{"ALTER TABLE " + quoteMysqlIdentifier(rename.TableName) + " RENAME COLUMN " + quoteMysqlIdentifier(rename.OldName) + " TO " + quoteMysqlIdentifier(rename.NewName) + ";"}
+		} else if dialect == "postgres" || dialect == "cockroachdb" {
+			ret = []string{"ALTER TABLE " + quotePostgresIdentifier(rename.TableName) + " RENAME COLUMN " + quotePostgresIdentifier(rename.OldName) + " TO " + quotePostgresIdentifier(rename.NewName) + ";"}
+		} else if dialect == "sqlite" {
+			ret = []string{"ALTER TABLE " + quoteSqliteIdentifier(rename.TableName) + " RENAME COLUMN " + quoteSqliteIdentifier(rename.OldName) + " TO " + quoteSqliteIdentifier(rename.NewName) + ";"}
+		}
+
+		if len(rename.ModifiedProperties) != 0 && dialect == "sqlite" {
+			// The table is rebuilt after the column is renamed, so the column is copied over by its new name.
+			secondTable, ok := secondSchema.Tables[rename.TableName]
+			if !ok {
+				return []string{}, safego.Some("Table " + rename.TableName + " was not found")
+			}
+
+			existingColumnNames := []string{}
+			for _, column := range secondTable.Columns {
+				existingColumnNames = append(existingColumnNames, utils.Ternary(column.Name == rename.OldName, rename.NewName, column.Name))
+			}
+
+			rebuildSql, errOpt := generateSqliteTableRebuild(firstSchema, rename.TableName, existingColumnNames)
+			if errOpt.IsSome() {
+				return []string{}, errOpt
+			}
+
+			ret = append(ret, rebuildSql...)
+		} else if len(rename.ModifiedProperties) != 0 && (dialect == "postgres" || dialect == "cockroachdb") {
+			// The column has its old name in the second schema.
+			column, _, errOpt := getColumnFromSchema(firstSchema, rename.NewName, rename.TableName)
+			if errOpt.IsSome() {
+				return []string{}, errOpt
+			}
+
+			previousColumn, _, errOpt := getColumnFromSchema(secondSchema, rename.OldName, rename.TableName)
+			if errOpt.IsSome() {
+				return []string{}, errOpt
+			}
+
+			ret = append(ret, generatePostgresColumnAlterations(rename.TableName, column, previousColumn)...)
+		} else if len(rename.ModifiedProperties) != 0 {
+			modifySql, errOpt := GenerateSqlForColumns(firstSchema, secondSchema, dialect, rename.NewName, rename.TableName, "modified")
+			if errOpt.IsSome() {
+				return []string{}, errOpt
+			}
+
+			ret = append(ret, modifySql...)
+		}
+	} else {
+		return []string{}, safego.Some("Renaming " + rename.EntityType + " is not supported")
+	}
+
+	return ret, safego.None[string]()
+}
+
+// invertRename returns the rename that undoes the given one.
+func invertRename(rename difftool.RenameCandidate) difftool.RenameCandidate {
+	rename.OldName, rename.NewName = rename.NewName, rename.OldName
+
+	return rename
+}
@@ -0,0 +1,56 @@
+package sequelizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+func TestGenerateSqlForRename(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  string
+		rename   difftool.RenameCandidate
+		expected string
+	}{
+		{
+			name:     "mysql table",
+			dialect:  "mysql",
+			rename:   difftool.RenameCandidate{EntityType: "tables", OldName: "user`s", NewName: "members"},
+			expected: "RENAME TABLE `user``s` TO `members`;",
+		},
+		{
+			name:     "mysql column",
+			dialect:  "mariadb",
+			rename:   difftool.RenameCandidate{EntityType: "columns", TableName: "user`s", OldName: "na`me", NewName: "full_name"},
+			expected: "ALTER TABLE `user``s` RENAME COLUMN `na``me` TO `full_name`;",
+		},
+		{
+			name:     "postgres table",
+			dialect:  "postgres",
+			rename:   difftool.RenameCandidate{EntityType: "tables", OldName: `user"s`, NewName: "members"},
+			expected: `ALTER TABLE "user""s" RENAME TO "members";`,
+		},
+		{
+			name:     "sqlite column",
+			dialect:  "sqlite",
+			rename:   difftool.RenameCandidate{EntityType: "columns", TableName: "users", OldName: "name", NewName: "full_name"},
+			expected: `ALTER TABLE "users" RENAME COLUMN "name" TO "full_name";`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queries, errOpt := GenerateSqlForRename(&schema.Schema{}, &schema.Schema{}, test.dialect, test.rename)
+			if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+
+			if sql := strings.Join(queries, "\n"); sql != test.expected {
+				t.Errorf("got\n%s\nexpected\n%s", sql, test.expected)
+			}
+		})
+	}
+}
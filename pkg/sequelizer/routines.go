@@ -0,0 +1,32 @@
+package sequelizer
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	postgresCreateRoutineRegex = regexp.MustCompile(`^CREATE (OR REPLACE )?(FUNCTION|PROCEDURE)`)
+	// postgresRoutineReturnsRegex matches the line of the return type in the definition given by pg_get_functiondef.
+	postgresRoutineReturnsRegex = regexp.MustCompile(`(?m)^\s*RETURNS\s.*$`)
+)
+
+// canReplacePostgresRoutine tells whether a routine can be replaced in place with `CREATE OR REPLACE`, which Postgres
+// only allows when its arguments, along with their names and defaults, and its return type stay the same.
+func canReplacePostgresRoutine(firstDefinition string, secondDefinition string) bool {
+	return getPostgresRoutineSignature(firstDefinition) == getPostgresRoutineSignature(secondDefinition)
+}
+
+// getPostgresRoutineSignature returns the arguments and the return type of a routine as they are written in its
+// definition, where pg_get_functiondef puts them on the first line and on the RETURNS line.
+func getPostgresRoutineSignature(definition string) string {
+	firstLine, _, _ := strings.Cut(definition, "\n")
+	firstLine = postgresCreateRoutineRegex.ReplaceAllString(strings.TrimSpace(firstLine), "")
+
+	return strings.TrimSpace(firstLine) + " " + strings.TrimSpace(postgresRoutineReturnsRegex.FindString(definition))
+}
+
+// toPostgresCreateOrReplace makes the definition of a routine replace the existing one.
+func toPostgresCreateOrReplace(definition string) string {
+	return postgresCreateRoutineRegex.ReplaceAllString(strings.TrimSpace(definition), "CREATE OR REPLACE $2")
+}
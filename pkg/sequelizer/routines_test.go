@@ -0,0 +1,149 @@
+package sequelizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+func TestGenerateSqlForModifiedRoutines(t *testing.T) {
+	const addBody = "\n LANGUAGE sql\nAS $function$SELECT a + b$function$"
+
+	tests := []struct {
+		name             string
+		dialect          string
+		entityType       string
+		routineName      string
+		firstDefinition  string
+		secondDefinition string
+		expected         string
+	}{
+		{
+			name:             "postgres function with a new body",
+			dialect:          "postgres",
+			entityType:       "functions",
+			routineName:      "add(a integer, b integer)",
+			firstDefinition:  "CREATE OR REPLACE FUNCTION public.add(a integer, b integer)\n RETURNS integer" + addBody,
+			secondDefinition: "CREATE OR REPLACE FUNCTION public.add(a integer, b integer)\n RETURNS integer\n LANGUAGE sql\nAS $function$SELECT b + a$function$",
+			expected:         "CREATE OR REPLACE FUNCTION public.add(a integer, b integer)\n RETURNS integer" + addBody + ";",
+		},
+		{
+			name:             "postgres function with a new return type",
+			dialect:          "postgres",
+			entityType:       "functions",
+			routineName:      "add(a integer, b integer)",
+			firstDefinition:  "CREATE OR REPLACE FUNCTION public.add(a integer, b integer)\n RETURNS bigint" + addBody,
+			secondDefinition: "CREATE OR REPLACE FUNCTION public.add(a integer, b integer)\n RETURNS integer" + addBody,
+			expected:         "DROP FUNCTION IF EXISTS \"add\"(a integer, b integer);\nCREATE OR REPLACE FUNCTION public.add(a integer, b integer)\n RETURNS bigint" + addBody + ";",
+		},
+		{
+			name:             "postgres function with a new default",
+			dialect:          "postgres",
+			entityType:       "functions",
+			routineName:      "add(a integer, b integer)",
+			firstDefinition:  "CREATE OR REPLACE FUNCTION public.add(a integer, b integer DEFAULT 1)\n RETURNS integer" + addBody,
+			secondDefinition: "CREATE OR REPLACE FUNCTION public.add(a integer, b integer)\n RETURNS integer" + addBody,
+			expected:         "DROP FUNCTION IF EXISTS \"add\"(a integer, b integer);\nCREATE OR REPLACE FUNCTION public.add(a integer, b integer DEFAULT 1)\n RETURNS integer" + addBody + ";",
+		},
+		{
+			name:             "cockroach function",
+			dialect:          "cockroachdb",
+			entityType:       "functions",
+			routineName:      "one()",
+			firstDefinition:  "CREATE FUNCTION public.one()\n\tRETURNS INT8\n\tLANGUAGE SQL\n\tAS $$SELECT 1;$$",
+			secondDefinition: "CREATE FUNCTION public.one()\n\tRETURNS INT8\n\tLANGUAGE SQL\n\tAS $$SELECT 2;$$",
+			expected:         "CREATE OR REPLACE FUNCTION public.one()\n\tRETURNS INT8\n\tLANGUAGE SQL\n\tAS $$SELECT 1;$$;",
+		},
+		{
+			name:             "postgres procedure",
+			dialect:          "postgres",
+			entityType:       "procedures",
+			routineName:      "reset(id integer)",
+			firstDefinition:  "CREATE OR REPLACE PROCEDURE public.reset(id integer)\n LANGUAGE sql\nAS $procedure$DELETE FROM users$procedure$",
+			secondDefinition: "CREATE OR REPLACE PROCEDURE public.reset(id integer)\n LANGUAGE sql\nAS $procedure$TRUNCATE users$procedure$",
+			expected:         "CREATE OR REPLACE PROCEDURE public.reset(id integer)\n LANGUAGE sql\nAS $procedure$DELETE FROM users$procedure$;",
+		},
+		{
+			name:             "mysql function",
+			dialect:          "mysql",
+			entityType:       "functions",
+			routineName:      "odd`name",
+			firstDefinition:  "CREATE DEFINER=`root`@`%` FUNCTION `odd``name`() RETURNS int RETURN 1",
+			secondDefinition: "CREATE DEFINER=`root`@`%` FUNCTION `odd``name`() RETURNS int RETURN 2",
+			expected:         "DROP FUNCTION IF EXISTS `odd``name`;\nCREATE FUNCTION `odd``name`() RETURNS int RETURN 1;",
+		},
+		{
+			name:             "mysql procedure",
+			dialect:          "mysql",
+			entityType:       "procedures",
+			routineName:      "reset",
+			firstDefinition:  "CREATE PROCEDURE `reset`() DELETE FROM users",
+			secondDefinition: "CREATE PROCEDURE `reset`() TRUNCATE users",
+			expected:         "DROP PROCEDURE IF EXISTS `reset`;\nCREATE PROCEDURE `reset`() DELETE FROM users;",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			firstSchema := schema.NewSchema(test.dialect, "first", "test")
+			secondSchema := schema.NewSchema(test.dialect, "second", "test")
+
+			generate := GenerateSqlForFunctions
+			routines := [2]map[string]*schema.Routine{firstSchema.Functions, secondSchema.Functions}
+			if test.entityType == "procedures" {
+				generate = GenerateSqlForProcedures
+				routines = [2]map[string]*schema.Routine{firstSchema.Procedures, secondSchema.Procedures}
+			}
+			routines[0][test.routineName] = &schema.Routine{Name: test.routineName, Definition: test.firstDefinition}
+			routines[1][test.routineName] = &schema.Routine{Name: test.routineName, Definition: test.secondDefinition}
+
+			queries, errMsgOpt := generate(firstSchema, secondSchema, test.dialect, test.routineName, "modified")
+			if errMsgOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errMsgOpt.Unwrap())
+			}
+
+			if sql := strings.Join(queries, "\n"); sql != test.expected {
+				t.Errorf("got\n%s\nexpected\n%s", sql, test.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateSqlForDeletedMysqlTrigger(t *testing.T) {
+	queries, errMsgOpt := GenerateSqlForTriggers(schema.NewSchema("mysql", "first", "test"), "mysql", "odd`trigger", "deleted")
+	if errMsgOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errMsgOpt.Unwrap())
+	}
+
+	if sql, expected := strings.Join(queries, "\n"), "DROP TRIGGER IF EXISTS `odd``trigger`;"; sql != expected {
+		t.Errorf("got\n%s\nexpected\n%s", sql, expected)
+	}
+}
+
+func TestGenerateMigrationKeepsTheQueriesOfModifiedMysqlRoutinesApart(t *testing.T) {
+	const firstBody = "CREATE PROCEDURE `touch`()\nBEGIN\n\tSELECT 1;\n\tSELECT 2;\nEND"
+	firstSchema := schema.NewSchema("mysql", "first", "test")
+	firstSchema.Procedures["touch"] = &schema.Routine{Name: "touch", Definition: firstBody}
+	secondSchema := schema.NewSchema("mysql", "second", "test")
+	secondSchema.Procedures["touch"] = &schema.Routine{Name: "touch", Definition: "CREATE PROCEDURE `touch`()\nBEGIN\n\tSELECT 1;\nEND"}
+
+	statements, errMsgOpt := GenerateMigration(firstSchema, secondSchema, "mysql", difftool.GetSchemaDiff(firstSchema, secondSchema, nil))
+	if errMsgOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errMsgOpt.Unwrap())
+	}
+	if len(statements) != 1 {
+		t.Fatalf("expected a single statement, got %+v", statements)
+	}
+
+	expected := []string{"DROP PROCEDURE IF EXISTS `touch`;", firstBody + ";"}
+	if queries := statements[0].Queries; strings.Join(queries, "|") != strings.Join(expected, "|") {
+		t.Errorf("got %q, expected %q", queries, expected)
+	}
+
+	expectedRollback := []string{"DROP PROCEDURE IF EXISTS `touch`;", secondSchema.Procedures["touch"].Definition + ";"}
+	if queries := statements[0].RollbackQueries; strings.Join(queries, "|") != strings.Join(expectedRollback, "|") {
+		t.Errorf("got %q, expected %q", queries, expectedRollback)
+	}
+}
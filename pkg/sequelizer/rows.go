@@ -0,0 +1,111 @@
+package sequelizer
+
+import (
+	"encoding/hex"
+	"slices"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+// GenerateSqlForRow generates the statement that brings a row of the second database in line with the first one: an
+// `INSERT` for created rows, an `UPDATE` of the modified columns for modified rows and a `DELETE` for deleted rows.
+// The table is looked up in the first schema for the types of its columns. It is empty for a modified row that only
+// differs by its generated columns.
+func GenerateSqlForRow(firstSchema *schema.Schema, dialect string, rowDiff difftool.RowDiff) string {
+	table := firstSchema.Tables[rowDiff.TableName]
+
+	quoteIdentifier := quotePostgresIdentifier
+	if dialect == "mysql" || dialect == "mariadb" {
+		quoteIdentifier = quoteMysqlIdentifier
+	}
+
+	whereConditions := []string{}
+	for i, columnName := range rowDiff.PrimaryKeyColumns {
+		whereConditions = append(whereConditions, quoteIdentifier(columnName)+" = "+formatRowValue(table, dialect, columnName, &rowDiff.PrimaryKey[i]))
+	}
+	whereClause := " WHERE " + strings.Join(whereConditions, " AND ")
+
+	if rowDiff.DiffType == difftool.Deleted {
+		return "DELETE FROM " + quoteIdentifier(rowDiff.TableName) + whereClause + ";"
+	}
+
+	columns, values := []string{}, []string{}
+	isOverridingIdentity := false
+	for i, columnName := range rowDiff.Columns {
+		columnOpt := table.GetColumn(columnName)
+		column := columnOpt.Unwrap()
+
+		// Generated columns are computed by the database, and can't be written to.
+		if strings.Contains(column.Extra, "VIRTUAL GENERATED") || strings.Contains(column.Extra, "STORED GENERATED") {
+			continue
+		}
+		if rowDiff.DiffType == difftool.Modified && !slices.Contains(rowDiff.ModifiedColumns, columnName) {
+			continue
+		}
+
+		isOverridingIdentity = isOverridingIdentity || column.Extra == "GENERATED ALWAYS AS IDENTITY"
+
+		columns = append(columns, quoteIdentifier(columnName))
+		values = append(values, formatRowValue(table, dialect, columnName, rowDiff.Values[i]))
+	}
+
+	if rowDiff.DiffType == difftool.Modified {
+		assignments := []string{}
+		for i := range columns {
+			assignments = append(assignments, columns[i]+" = "+values[i])
+		}
+
+		// Only generated columns differ, which follow the other columns on their own.
+		if len(assignments) == 0 {
+			return ""
+		}
+
+		return "UPDATE " + quoteIdentifier(rowDiff.TableName) + " SET " + strings.Join(assignments, ", ") + whereClause + ";"
+	}
+
+	// Postgres only takes values for `GENERATED ALWAYS` identity columns when it is told to.
+	overridingClause := ""
+	if isOverridingIdentity && (dialect == "postgres" || dialect == "cockroachdb") {
+		overridingClause = " OVERRIDING SYSTEM VALUE"
+	}
+
+	return "INSERT INTO " + quoteIdentifier(rowDiff.TableName) + " (" + strings.Join(columns, ", ") + ")" + overridingClause + " VALUES (" + strings.Join(values, ", ") + ");"
+}
+
+// formatRowValue formats a value read from a column as a literal. Binary values are written in hex since they can hold
+// bytes that aren't valid in a string literal. Every other value is written as a string literal, which Mysql and
+// Postgres cast to the type of the column, and Sqlite converts based on the affinity of the column.
+func formatRowValue(table *schema.Table, dialect string, columnName string, value *string) string {
+	if value == nil {
+		return "NULL"
+	}
+
+	columnType := ""
+	if columnOpt := table.GetColumn(columnName); columnOpt.IsSome() {
+		columnType = strings.ToLower(columnOpt.Unwrap().Type)
+	}
+
+	if dialect == "mysql" || dialect == "mariadb" {
+		if strings.Contains(columnType, "blob") || strings.Contains(columnType, "binary") || strings.HasPrefix(columnType, "bit") {
+			return "X'" + hex.EncodeToString([]byte(*value)) + "'"
+		}
+
+		return quoteMysqlString(*value)
+	} else if dialect == "postgres" || dialect == "cockroachdb" {
+		if columnType == "bytea" {
+			return "'\\x" + hex.EncodeToString([]byte(*value)) + "'"
+		}
+
+		return quotePostgresString(*value)
+	} else if dialect == "sqlite" {
+		if strings.Contains(columnType, "blob") {
+			return "X'" + hex.EncodeToString([]byte(*value)) + "'"
+		}
+
+		return quotePostgresString(*value)
+	}
+
+	return *value
+}
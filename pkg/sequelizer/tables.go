@@ -1,134 +1,388 @@
 package sequelizer
 
 import (
-	"database/sql"
 	"fmt"
-	"log"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 
-	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/safego"
 )
 
 // GenerateSqlForTables is the interface for generating SQL for tables in general.
-func GenerateSqlForTables(firstDb *sql.DB, dialect string, entityName string, status string) string {
-	var ret string
+func GenerateSqlForTables(firstSchema *schema.Schema, dialect string, entityName string, status string) ([]string, safego.Option[string]) {
+	ret := []string{}
+	errOpt := safego.None[string]()
 
 	if dialect == "mysql" || dialect == "mariadb" {
-		ret = generateSqlForTablesMysql(firstDb, entityName, status)
+		ret, errOpt = generateSqlForTablesMysql(firstSchema, entityName, status)
 	} else if dialect == "postgres" || dialect == "cockroachdb" {
-		utils.AbortTui("UNIMPLEMENTED")
+		ret, errOpt = generateSqlForTablesPostgres(firstSchema, dialect, entityName, status)
+	} else if dialect == "sqlite" {
+		ret, errOpt = generateSqlForTablesSqlite(firstSchema, entityName, status)
 	}
 
-	return ret
+	return ret, errOpt
 }
 
 // generateSqlForTablesMysql is responsible for generating SQL for tables in Mysql.
-func generateSqlForTablesMysql(firstDb *sql.DB, entityName string, status string) string {
-	var ret string
+func generateSqlForTablesMysql(firstSchema *schema.Schema, entityName string, status string) ([]string, safego.Option[string]) {
+	ret := []string{}
 
 	if status == "created" {
-		rows, err := firstDb.Query("SHOW CREATE TABLE " + entityName)
-		if err != nil {
-			utils.AbortTui("Error getting create table statement: " + err.Error())
+		table, ok := firstSchema.Tables[entityName]
+		if !ok {
+			return []string{}, safego.Some("Table " + entityName + " was not found")
 		}
 
-		for rows.Next() {
-			err = rows.Scan(&entityName, &ret)
-			if err != nil {
-				utils.AbortTui("Error getting create table statement: " + err.Error())
-			}
+		if table.Definition != "" {
+			ret = []string{table.Definition + ";"}
+		} else {
+			ret = buildMysqlTableDefinition(firstSchema, table)
 		}
-
-		ret += ";"
 	} else if status == "deleted" {
-		ret = "DROP TABLE IF EXISTS `" + entityName + "`;"
+		ret = []string{"DROP TABLE IF EXISTS " + quoteMysqlIdentifier(entityName) + ";"}
+	}
+
+	return ret, safego.None[string]()
+}
+
+// buildMysqlTableDefinition puts a `CREATE TABLE` statement together from the columns, constraints and indexes of a
+// table, for tables that don't come with one, like those translated from Postgres. Indexes are created right after
+// the table.
+func buildMysqlTableDefinition(firstSchema *schema.Schema, table *schema.Table) []string {
+	var definitions []string
+	for _, column := range table.Columns {
+		definitions = append(definitions, quoteMysqlIdentifier(column.Name)+" "+buildMysqlColumnDefinition(column))
+	}
+
+	constraints := []*schema.Constraint{}
+	for _, constraint := range table.Constraints {
+		constraints = append(constraints, constraint)
+	}
+	sort.Slice(constraints, func(i, j int) bool {
+		if getPostgresConstraintOrder(constraints[i]) != getPostgresConstraintOrder(constraints[j]) {
+			return getPostgresConstraintOrder(constraints[i]) < getPostgresConstraintOrder(constraints[j])
+		}
+
+		return constraints[i].Name < constraints[j].Name
+	})
+
+	for _, constraint := range constraints {
+		definitions = append(definitions, getMysqlConstraintDefinition(constraint))
+	}
+
+	ret := []string{fmt.Sprintf("CREATE TABLE %s (\n\t%s\n);", quoteMysqlIdentifier(table.Name), strings.Join(definitions, ",\n\t"))}
+
+	indexNames := []string{}
+	for indexName := range table.Indexes {
+		indexNames = append(indexNames, indexName)
+	}
+	sort.Strings(indexNames)
+
+	for _, indexName := range indexNames {
+		indexQueries, _ := generateSqlForIndexesMysql(firstSchema, indexName, table.Name, "created")
+		ret = append(ret, indexQueries...)
 	}
 
 	return ret
 }
 
-// generateSqlForTablesPostgres AI GENERATED DRAFT THAT ISN'T COMPLETE/CORRECT.
-func generateSqlForTablesPostgres(firstDb *sql.DB, entityName string, status string) string {
-	var ret string
+// generateSqlForTablesPostgres is responsible for generating SQL for tables in Postgres. Unlike Mysql, Postgres has no
+// `SHOW CREATE TABLE`, so the statement is put together from the columns, constraints and indexes of the table.
+func generateSqlForTablesPostgres(firstSchema *schema.Schema, dialect string, entityName string, status string) ([]string, safego.Option[string]) {
+	ret := []string{}
 
 	if status == "created" {
-		// Query to retrieve column and key information
-		rows, err := firstDb.Query(`
-		SELECT columns.column_name, data_type, character_maximum_length, is_nullable,
-		       column_default, columns.ordinal_position,
-		       constraint_type, constraint_name, referenced_table_name, referenced_column_name
-		FROM information_schema.columns
-		LEFT JOIN information_schema.key_column_usage
-		ON columns.column_name = key_column_usage.column_name
-		WHERE table_name = $1
-		ORDER BY ordinal_position;
-	`, entityName)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer rows.Close()
-
-		// Collect column and key information
-		var columns []string
-		var primaryKeyCols []string
-		var foreignKeyCols []string
-
-		for rows.Next() {
-			var columnName, dataType, isNullable, columnDefault, constraintType, constraintName sql.NullString
-			var characterMaximumLength, ordinalPosition sql.NullInt64
-			var referencedTableName, referencedColumnName sql.NullString
-
-			if err := rows.Scan(&columnName, &dataType, &characterMaximumLength, &isNullable,
-				&columnDefault, &ordinalPosition, &constraintType, &constraintName,
-				&referencedTableName, &referencedColumnName); err != nil {
-				log.Fatal(err)
-			}
+		table, ok := firstSchema.Tables[entityName]
+		if !ok {
+			return []string{}, safego.Some("Table " + entityName + " was not found")
+		}
 
-			columnDef := fmt.Sprintf("%s %s", columnName.String, dataType.String)
-			if characterMaximumLength.Valid {
-				columnDef += fmt.Sprintf("(%d)", characterMaximumLength.Int64)
-			}
-			if isNullable.String == "NO" {
-				columnDef += " NOT NULL"
-			}
-			if columnDefault.Valid {
-				columnDef += fmt.Sprintf(" DEFAULT %s", columnDefault.String)
+		var definitions []string
+		for _, column := range table.Columns {
+			definitions = append(definitions, buildPostgresColumnDefinition(dialect, entityName, column))
+		}
+
+		// Primary keys come first, then unique keys, checks and finally foreign keys.
+		constraints := []*schema.Constraint{}
+		for _, constraint := range table.Constraints {
+			constraints = append(constraints, constraint)
+		}
+		sort.Slice(constraints, func(i, j int) bool {
+			if getPostgresConstraintOrder(constraints[i]) != getPostgresConstraintOrder(constraints[j]) {
+				return getPostgresConstraintOrder(constraints[i]) < getPostgresConstraintOrder(constraints[j])
 			}
 
-			columns = append(columns, columnDef)
+			return constraints[i].Name < constraints[j].Name
+		})
+
+		for _, constraint := range constraints {
+			definitions = append(definitions, "CONSTRAINT "+quotePostgresIdentifier(constraint.Name)+" "+constraint.Definition)
+		}
+
+		ret = getPostgresSequenceStatements(dialect, entityName, table.Columns)
+		ret = append(ret, fmt.Sprintf("CREATE TABLE %s (\n\t%s\n);", quotePostgresIdentifier(entityName), strings.Join(definitions, ",\n\t")))
 
-			if constraintType.Valid {
-				if constraintType.String == "PRIMARY KEY" {
-					primaryKeyCols = append(primaryKeyCols, columnName.String)
-				} else if constraintType.String == "FOREIGN KEY" {
-					foreignKeyCols = append(foreignKeyCols, fmt.Sprintf("%s REFERENCES %s(%s)",
-						columnName.String, referencedTableName.String, referencedColumnName.String))
-				}
+		// Comments aren't part of the table definition either.
+		for _, column := range table.Columns {
+			if column.Comment != "" {
+				ret = append(ret, "COMMENT ON COLUMN "+quotePostgresIdentifier(entityName)+"."+quotePostgresIdentifier(column.Name)+" IS "+quotePostgresString(column.Comment)+";")
 			}
 		}
 
-		// Check for errors from iterating over rows.
-		if err := rows.Err(); err != nil {
-			log.Fatal(err)
+		// Indexes that don't back a constraint aren't part of the table definition in Postgres, so they are created
+		// right after the table.
+		indexNames := []string{}
+		for indexName := range table.Indexes {
+			indexNames = append(indexNames, indexName)
 		}
+		sort.Strings(indexNames)
 
-		// Create the CREATE TABLE statement
-		createTableStatement := fmt.Sprintf("CREATE TABLE %s (\n\t%s", entityName, strings.Join(columns, ",\n\t"))
+		for _, indexName := range indexNames {
+			ret = append(ret, table.Indexes[indexName].Definition+";")
+		}
+	} else if status == "deleted" {
+		ret = []string{"DROP TABLE IF EXISTS " + quotePostgresIdentifier(entityName) + ";"}
+	}
+
+	return ret, safego.None[string]()
+}
+
+// getPostgresConstraintOrder returns the position of a constraint in a `CREATE TABLE` statement based on its type.
+func getPostgresConstraintOrder(constraint *schema.Constraint) int {
+	if constraint.Type == "PRIMARY KEY" {
+		return 0
+	} else if constraint.Type == "UNIQUE" {
+		return 1
+	} else if constraint.Type == "CHECK" {
+		return 2
+	}
 
-		// Add primary key constraint
-		if len(primaryKeyCols) > 0 {
-			createTableStatement += fmt.Sprintf(",\n\tPRIMARY KEY (%s)", strings.Join(primaryKeyCols, ", "))
+	return 3
+}
+
+// postgresSequenceDefaultRegex matches the default of a column that takes its values from a sequence, e.g.
+// `nextval('users_id_seq'::regclass)`, and captures the name of the sequence.
+var postgresSequenceDefaultRegex = regexp.MustCompile(`^nextval\('(.+)'::regclass\)$`)
+
+// postgresSerialTypes are the serial types of the integer types, which create the sequence of the column along with it.
+var postgresSerialTypes = map[string]string{"smallint": "smallserial", "integer": "serial", "bigint": "bigserial"}
+
+// getPostgresColumnSequence returns the name of the sequence the default of a column takes its values from, as it is
+// written in the default.
+func getPostgresColumnSequence(column *schema.Column) safego.Option[string] {
+	if column.Default == nil {
+		return safego.None[string]()
+	}
+
+	matches := postgresSequenceDefaultRegex.FindStringSubmatch(*column.Default)
+	if matches == nil {
+		return safego.None[string]()
+	}
+
+	return safego.Some(matches[1])
+}
+
+// isPostgresSerialColumn tells whether a column is written as a serial column, which is the case for integer columns
+// that take their values from the sequence Postgres names after them (e.g. `users_id_seq`). Cockroach gives serial
+// columns another default, so its columns are left as they are.
+func isPostgresSerialColumn(dialect string, tableName string, column *schema.Column) bool {
+	sequenceNameOpt := getPostgresColumnSequence(column)
+	if dialect != "postgres" || sequenceNameOpt.IsNone() {
+		return false
+	}
+
+	if _, ok := postgresSerialTypes[column.Type]; !ok {
+		return false
+	}
+
+	sequenceName := sequenceNameOpt.Unwrap()
+	if _, unqualifiedName, ok := strings.Cut(sequenceName, "."); ok {
+		sequenceName = unqualifiedName
+	}
+
+	return strings.Trim(sequenceName, `"`) == tableName+"_"+column.Name+"_seq"
+}
+
+// getPostgresSequenceStatements creates the sequences that the defaults of the columns take their values from, apart
+// from the ones of serial columns. Sequences aren't compared, so they are only created if they are missing.
+func getPostgresSequenceStatements(dialect string, tableName string, columns []*schema.Column) []string {
+	ret := []string{}
+	for _, column := range columns {
+		sequenceNameOpt := getPostgresColumnSequence(column)
+		if sequenceNameOpt.IsSome() && !isPostgresSerialColumn(dialect, tableName, column) {
+			ret = append(ret, "CREATE SEQUENCE IF NOT EXISTS "+sequenceNameOpt.Unwrap()+";")
 		}
+	}
 
-		// Add foreign key constraints
-		if len(foreignKeyCols) > 0 {
-			createTableStatement += fmt.Sprintf(",\n\t%s", strings.Join(foreignKeyCols, ",\n\t"))
+	return ret
+}
+
+// buildPostgresColumnDefinition builds the definition of a column as it would appear in a `CREATE TABLE` or an
+// `ADD COLUMN` statement. Columns that take their values from the sequence named after them are written as serial
+// columns, so that the sequence is created along with them.
+func buildPostgresColumnDefinition(dialect string, tableName string, column *schema.Column) string {
+	columnType := column.Type
+	columnDefault := column.Default
+	if isPostgresSerialColumn(dialect, tableName, column) {
+		columnType = postgresSerialTypes[column.Type]
+		columnDefault = nil
+	}
+
+	ret := quotePostgresIdentifier(column.Name) + " " + columnType
+
+	if column.Collation != nil {
+		ret += " COLLATE " + quotePostgresIdentifier(*column.Collation)
+	}
+
+	if !column.IsNullable {
+		ret += " NOT NULL"
+	}
+
+	// Identity columns get their values from a sequence and can't have a default.
+	if column.Extra != "" {
+		ret += " " + column.Extra
+	} else if columnDefault != nil {
+		ret += " DEFAULT " + *columnDefault
+	}
+
+	return ret
+}
+
+// generateSqlForTablesSqlite is responsible for generating SQL for tables in Sqlite. Like in Postgres, the indexes of
+// the table aren't part of its `CREATE TABLE` statement, so they are created right after it.
+func generateSqlForTablesSqlite(firstSchema *schema.Schema, entityName string, status string) ([]string, safego.Option[string]) {
+	ret := []string{}
+
+	if status == "created" {
+		table, ok := firstSchema.Tables[entityName]
+		if !ok {
+			return []string{}, safego.Some("Table " + entityName + " was not found")
 		}
 
-		createTableStatement += "\n);"
+		ret = append([]string{table.Definition + ";"}, getSqliteIndexStatements(table)...)
 	} else if status == "deleted" {
-		ret = "DROP TABLE IF EXISTS `" + entityName + "`;"
+		ret = []string{"DROP TABLE IF EXISTS " + quoteSqliteIdentifier(entityName) + ";"}
+	}
+
+	return ret, safego.None[string]()
+}
+
+// generateSqliteTableRebuild generates the statements that rebuild a table to its definition in the first schema. It
+// is how Sqlite changes anything about a table that `ALTER TABLE` can't: the table is created again under a temporary
+// name, the rows are copied over, and it takes the place of the old table. The indexes and triggers of the old table
+// are dropped along with it, so they are created again.
+//
+// existingColumnNames are the columns the table has before the rebuild. Only the ones the table keeps are copied.
+// Foreign keys must not be enforced, or dropping the old table would delete the rows that reference it. The migrator
+// turns them off while it applies a migration.
+func generateSqliteTableRebuild(firstSchema *schema.Schema, tableName string, existingColumnNames []string) ([]string, safego.Option[string]) {
+	table, ok := firstSchema.Tables[tableName]
+	if !ok {
+		return []string{}, safego.Some("Table " + tableName + " was not found")
+	}
+
+	openingParenIndex := strings.Index(table.Definition, "(")
+	if openingParenIndex == -1 {
+		return []string{}, safego.Some("Table " + tableName + " can't be rebuilt since its definition has no columns")
+	}
+
+	quotedTableName := quoteSqliteIdentifier(tableName)
+	quotedTemporaryTableName := quoteSqliteIdentifier("_patchi_new_" + tableName)
+
+	// Generated columns are computed again by the new table.
+	copiedColumns := []string{}
+	for _, column := range table.Columns {
+		isGenerated := strings.Contains(column.Extra, "VIRTUAL GENERATED") || strings.Contains(column.Extra, "STORED GENERATED")
+		if !isGenerated && slices.Contains(existingColumnNames, column.Name) {
+			copiedColumns = append(copiedColumns, quoteSqliteIdentifier(column.Name))
+		}
+	}
+
+	// The legacy behavior of `RENAME TO` leaves the views and triggers that use the table untouched. Otherwise, Sqlite
+	// would fail on the views that select from the old table while it is dropped.
+	statements := []string{
+		"PRAGMA legacy_alter_table = ON;",
+		"CREATE TABLE " + quotedTemporaryTableName + " " + table.Definition[openingParenIndex:] + ";",
+	}
+	if len(copiedColumns) != 0 {
+		statements = append(statements, "INSERT INTO "+quotedTemporaryTableName+" ("+strings.Join(copiedColumns, ", ")+") SELECT "+strings.Join(copiedColumns, ", ")+" FROM "+quotedTableName+";")
+	}
+	statements = append(statements,
+		"DROP TABLE "+quotedTableName+";",
+		"ALTER TABLE "+quotedTemporaryTableName+" RENAME TO "+quotedTableName+";",
+	)
+	statements = append(statements, getSqliteIndexStatements(table)...)
+
+	triggerNames := []string{}
+	for triggerName, trigger := range firstSchema.Triggers {
+		if trigger.TableName == tableName {
+			triggerNames = append(triggerNames, triggerName)
+		}
+	}
+	sort.Strings(triggerNames)
+
+	for _, triggerName := range triggerNames {
+		statements = append(statements, addSqliteIfNotExists(firstSchema.Triggers[triggerName].Definition)+";")
+	}
+	statements = append(statements, "PRAGMA legacy_alter_table = OFF;")
+
+	return statements, safego.None[string]()
+}
+
+// needsSqliteTableRebuild tells whether two versions of a table differ by more than columns that can be added with
+// `ADD COLUMN`. When they do, every change to the table is made by rebuilding it, since a column added on its own
+// after the table was rebuilt would be added twice.
+func needsSqliteTableRebuild(firstTable *schema.Table, secondTable *schema.Table) bool {
+	for _, secondColumn := range secondTable.Columns {
+		if firstColumnOpt := firstTable.GetColumn(secondColumn.Name); firstColumnOpt.IsNone() {
+			return true
+		}
+	}
+
+	for _, firstColumn := range firstTable.Columns {
+		secondColumnOpt := secondTable.GetColumn(firstColumn.Name)
+		if secondColumnOpt.IsNone() {
+			if !canAddSqliteColumn(firstColumn) {
+				return true
+			}
+			continue
+		}
+
+		secondColumn := secondColumnOpt.Unwrap()
+		if firstColumn.Type != secondColumn.Type || firstColumn.IsNullable != secondColumn.IsNullable || firstColumn.Extra != secondColumn.Extra || !reflect.DeepEqual(firstColumn.Default, secondColumn.Default) {
+			return true
+		}
+	}
+
+	return !reflect.DeepEqual(firstTable.Constraints, secondTable.Constraints)
+}
+
+// getSqliteIndexStatements returns the statements that create the indexes of a table, ordered by their names.
+func getSqliteIndexStatements(table *schema.Table) []string {
+	indexNames := []string{}
+	for indexName := range table.Indexes {
+		indexNames = append(indexNames, indexName)
+	}
+	sort.Strings(indexNames)
+
+	ret := []string{}
+	for _, indexName := range indexNames {
+		ret = append(ret, addSqliteIfNotExists(table.Indexes[indexName].Definition)+";")
 	}
 
 	return ret
 }
+
+// sqliteCreateRegex matches the start of a `CREATE INDEX` or a `CREATE TRIGGER` statement.
+var sqliteCreateRegex = regexp.MustCompile(`(?i)^\s*CREATE\s+(UNIQUE\s+INDEX|INDEX|TEMP\s+TRIGGER|TEMPORARY\s+TRIGGER|TRIGGER)\s+(IF\s+NOT\s+EXISTS\s+)?`)
+
+// addSqliteIfNotExists adds `IF NOT EXISTS` to the definition of an index or a trigger. A table rebuild creates the
+// indexes and triggers of the table again, so they might already exist by the time their own statements run.
+func addSqliteIfNotExists(definition string) string {
+	return sqliteCreateRegex.ReplaceAllString(definition, "CREATE $1 IF NOT EXISTS ")
+}
@@ -0,0 +1,117 @@
+package sequelizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+func TestGenerateSqlForTablesPostgres(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  string
+		columns  []*schema.Column
+		expected string
+	}{
+		{
+			name:    "serial columns",
+			dialect: "postgres",
+			columns: []*schema.Column{
+				{Name: "id", Type: "integer", Default: testutil.StringPointer("nextval('users_id_seq'::regclass)")},
+				{Name: "version", Type: "bigint", Default: testutil.StringPointer(`nextval('public."users_version_seq"'::regclass)`)},
+			},
+			expected: "CREATE TABLE \"users\" (\n\t\"id\" serial NOT NULL,\n\t\"version\" bigserial NOT NULL\n);",
+		},
+		{
+			name:    "shared sequence",
+			dialect: "postgres",
+			columns: []*schema.Column{
+				{Name: "id", Type: "integer", Default: testutil.StringPointer("nextval('ids'::regclass)")},
+			},
+			expected: "CREATE SEQUENCE IF NOT EXISTS ids;\nCREATE TABLE \"users\" (\n\t\"id\" integer NOT NULL DEFAULT nextval('ids'::regclass)\n);",
+		},
+		{
+			name:    "sequence of a non integer column",
+			dialect: "postgres",
+			columns: []*schema.Column{
+				{Name: "id", Type: "numeric", Default: testutil.StringPointer("nextval('users_id_seq'::regclass)")},
+			},
+			expected: "CREATE SEQUENCE IF NOT EXISTS users_id_seq;\nCREATE TABLE \"users\" (\n\t\"id\" numeric NOT NULL DEFAULT nextval('users_id_seq'::regclass)\n);",
+		},
+		{
+			name:    "cockroach sequence",
+			dialect: "cockroachdb",
+			columns: []*schema.Column{
+				{Name: "id", Type: "integer", Default: testutil.StringPointer("nextval('users_id_seq'::regclass)")},
+			},
+			expected: "CREATE SEQUENCE IF NOT EXISTS users_id_seq;\nCREATE TABLE \"users\" (\n\t\"id\" integer NOT NULL DEFAULT nextval('users_id_seq'::regclass)\n);",
+		},
+		{
+			name:    "column comments",
+			dialect: "postgres",
+			columns: []*schema.Column{
+				{Name: "id", Type: "integer", Comment: "it's"},
+				{Name: "name", Type: "text", IsNullable: true},
+			},
+			expected: "CREATE TABLE \"users\" (\n\t\"id\" integer NOT NULL,\n\t\"name\" text\n);\nCOMMENT ON COLUMN \"users\".\"id\" IS 'it''s';",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			firstSchema := testutil.NewSchema(test.dialect, testutil.NewTable("users", test.columns...))
+
+			queries, errOpt := GenerateSqlForTables(firstSchema, test.dialect, "users", "created")
+			if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+
+			if sql := strings.Join(queries, "\n"); sql != test.expected {
+				t.Errorf("got\n%s\nexpected\n%s", sql, test.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateSqlForColumnsPostgresSequences(t *testing.T) {
+	firstSchema := testutil.NewSchema("postgres", testutil.NewTable("users",
+		&schema.Column{Name: "id", Type: "integer", Default: testutil.StringPointer("nextval('users_id_seq'::regclass)")},
+		&schema.Column{Name: "rank", Type: "integer", Default: testutil.StringPointer("nextval('ranks'::regclass)")},
+	))
+	secondSchema := testutil.NewSchema("postgres", testutil.NewTable("users", &schema.Column{Name: "rank", Type: "integer"}))
+
+	tests := []struct {
+		name       string
+		columnName string
+		status     string
+		expected   string
+	}{
+		{
+			name:       "created serial column",
+			columnName: "id",
+			status:     "created",
+			expected:   "ALTER TABLE \"users\" ADD COLUMN \"id\" serial NOT NULL;",
+		},
+		{
+			name:       "modified default",
+			columnName: "rank",
+			status:     "modified",
+			expected:   "CREATE SEQUENCE IF NOT EXISTS ranks;\nALTER TABLE \"users\"\n\tALTER COLUMN \"rank\" SET DEFAULT nextval('ranks'::regclass);",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queries, errOpt := GenerateSqlForColumns(firstSchema, secondSchema, "postgres", test.columnName, "users", test.status)
+			if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+
+			if sql := strings.Join(queries, "\n"); sql != test.expected {
+				t.Errorf("got\n%s\nexpected\n%s", sql, test.expected)
+			}
+		})
+	}
+}
@@ -1,46 +1,45 @@
 package sequelizer
 
 import (
-	"database/sql"
-
-	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/safego"
 )
 
 // GenerateSqlForTriggers is the interface for generating SQL for triggers in general.
-func GenerateSqlForTriggers(firstDb *sql.DB, dialect string, triggerName string, status string) string {
-	var ret string
-
+// Modified triggers are dropped and created again.
+// In Postgres, the trigger name is expected to be in the form of `trigger ON table`.
+func GenerateSqlForTriggers(firstSchema *schema.Schema, dialect string, triggerName string, status string) ([]string, safego.Option[string]) {
+	var dropStatement string
 	if dialect == "mysql" || dialect == "mariadb" {
-		ret = generateSqlForTriggersMysql(firstDb, triggerName, status)
+		dropStatement = "DROP TRIGGER IF EXISTS " + quoteMysqlIdentifier(triggerName) + ";"
 	} else if dialect == "postgres" || dialect == "cockroachdb" {
-		utils.AbortTui("UNIMPLEMENTED")
+		triggerNameWithoutTable, tableName := splitPostgresTriggerName(triggerName)
+		dropStatement = "DROP TRIGGER IF EXISTS " + quotePostgresIdentifier(triggerNameWithoutTable) + " ON " + quotePostgresIdentifier(tableName) + ";"
+	} else if dialect == "sqlite" {
+		dropStatement = "DROP TRIGGER IF EXISTS " + quoteSqliteIdentifier(triggerName) + ";"
 	}
 
-	return ret
-}
-
-// generateSqlForTriggersMysql is responsible for generating SQL for triggers in Mysql.
-func generateSqlForTriggersMysql(firstDb *sql.DB, triggerName string, status string) string {
-	var ret string
+	if status == "deleted" {
+		return []string{dropStatement}, safego.None[string]()
+	}
 
-	if status == "created" {
-		rows, err := firstDb.Query("SHOW CREATE TRIGGER " + triggerName)
-		if err != nil {
-			utils.AbortTui("Error getting create trigger statement: " + err.Error())
-		}
+	trigger, ok := firstSchema.Triggers[triggerName]
+	if !ok {
+		return []string{}, safego.Some("Trigger " + triggerName + " was not found")
+	}
 
-		for rows.Next() {
-			var void string
-			err = rows.Scan(&void, &void, &ret, &void, &void, &void, &void)
-			if err != nil {
-				utils.AbortTui("Error getting create trigger statement: " + err.Error())
-			}
-		}
+	createStatement := trigger.Definition + ";"
+	if dialect == "mysql" || dialect == "mariadb" {
+		createStatement = difftool.RemoveDefiner(trigger.Definition) + ";"
+	} else if dialect == "sqlite" {
+		// The trigger might have been created already by the rebuild of its table.
+		createStatement = addSqliteIfNotExists(trigger.Definition) + ";"
+	}
 
-		ret += ";"
-	} else if status == "deleted" {
-		ret = "DROP TRIGGER IF EXISTS `" + triggerName + "`;"
+	if status == "modified" {
+		return []string{dropStatement, createStatement}, safego.None[string]()
 	}
 
-	return ret
+	return []string{createStatement}, safego.None[string]()
 }
@@ -1,46 +1,76 @@
 package sequelizer
 
 import (
-	"database/sql"
+	"strings"
 
-	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+	"github.com/Okira-E/patchi/safego"
 )
 
-// GenerateSqlForViews is the interface for generating SQL for views in general.
-func GenerateSqlForViews(firstDb *sql.DB, dialect string, viewName string, status string) string {
-	var ret string
+// GenerateSqlForViews is the interface for generating SQL for views in general. Modified views are replaced in place
+// with `CREATE OR REPLACE VIEW`, apart from Sqlite where they are dropped and created again. So are Postgres views
+// whose columns can't be replaced, which the second schema is used to tell.
+func GenerateSqlForViews(firstSchema *schema.Schema, secondSchema *schema.Schema, dialect string, viewName string, status string) ([]string, safego.Option[string]) {
+	ret := []string{}
 
+	var dropStatement string
 	if dialect == "mysql" || dialect == "mariadb" {
-		ret = generateSqlForViewsMysql(firstDb, viewName, status)
+		dropStatement = "DROP VIEW IF EXISTS " + quoteMysqlIdentifier(viewName) + ";"
 	} else if dialect == "postgres" || dialect == "cockroachdb" {
-		utils.AbortTui("UNIMPLEMENTED")
+		dropStatement = "DROP VIEW IF EXISTS " + quotePostgresIdentifier(viewName) + ";"
+	} else if dialect == "sqlite" {
+		dropStatement = "DROP VIEW IF EXISTS " + quoteSqliteIdentifier(viewName) + ";"
 	}
 
-	return ret
-}
+	if status == "deleted" {
+		return []string{dropStatement}, safego.None[string]()
+	}
 
-// generateSqlForViewsMysql is responsible for generating SQL for views in Mysql.
-func generateSqlForViewsMysql(firstDb *sql.DB, viewName string, status string) string {
-	var ret string
+	view, ok := firstSchema.Views[viewName]
+	if !ok {
+		return []string{}, safego.Some("View " + viewName + " was not found")
+	}
+
+	definition := view.Definition
+	if dialect == "mysql" || dialect == "mariadb" {
+		definition = difftool.RemoveDefiner(definition)
+	}
 
 	if status == "created" {
-		rows, err := firstDb.Query("SHOW CREATE VIEW " + viewName)
-		if err != nil {
-			utils.AbortTui("Error getting create view statement: " + err.Error())
+		ret = []string{definition + ";"}
+	} else if status == "modified" {
+		// Postgres definitions already start with `CREATE OR REPLACE VIEW`, while Mysql ones start with
+		// `CREATE ALGORITHM=...`.
+		if dialect == "sqlite" || !canReplaceView(view, secondSchema.Views[viewName]) {
+			ret = []string{dropStatement, definition + ";"}
+		} else if !strings.HasPrefix(strings.ToUpper(definition), "CREATE OR REPLACE ") {
+			ret = []string{"CREATE OR REPLACE " + strings.TrimPrefix(definition, "CREATE ") + ";"}
+		} else {
+			ret = []string{definition + ";"}
 		}
+	}
 
-		for rows.Next() {
-			var void string
-			err = rows.Scan(&viewName, &ret, &void, &void)
-			if err != nil {
-				utils.AbortTui("Error getting create view statement: " + err.Error())
-			}
-		}
+	return ret, safego.None[string]()
+}
+
+// canReplaceView tells whether `CREATE OR REPLACE VIEW` can turn the previous version of a view into the new one.
+// Postgres only allows it when the columns of the previous version are kept as they are, with new columns added at
+// the end. Views without known columns are assumed to be replaceable.
+func canReplaceView(view *schema.View, previousView *schema.View) bool {
+	if previousView == nil || len(previousView.Columns) == 0 || len(view.Columns) == 0 {
+		return true
+	}
 
-		ret += ";"
-	} else if status == "deleted" {
-		ret = "DROP view IF EXISTS `" + viewName + "`;"
+	if len(previousView.Columns) > len(view.Columns) {
+		return false
+	}
+
+	for i, previousColumn := range previousView.Columns {
+		if previousColumn.Name != view.Columns[i].Name || previousColumn.Type != view.Columns[i].Type {
+			return false
+		}
 	}
 
-	return ret
+	return true
 }
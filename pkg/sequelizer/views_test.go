@@ -0,0 +1,120 @@
+package sequelizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Okira-E/patchi/internal/testutil"
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/schema"
+)
+
+func TestGenerateSqlForViewsMysqlDropsDefiner(t *testing.T) {
+	firstSchema := schema.NewSchema("mysql", "test", "test")
+	firstSchema.Views["v"] = &schema.View{Name: "v", Definition: "CREATE ALGORITHM=UNDEFINED DEFINER=`root`@`%` SQL SECURITY DEFINER VIEW `v` AS select 1"}
+
+	tests := []struct {
+		status   string
+		expected string
+	}{
+		{"created", "CREATE ALGORITHM=UNDEFINED SQL SECURITY DEFINER VIEW `v` AS select 1;"},
+		{"modified", "CREATE OR REPLACE ALGORITHM=UNDEFINED SQL SECURITY DEFINER VIEW `v` AS select 1;"},
+		{"deleted", "DROP VIEW IF EXISTS `v`;"},
+	}
+
+	for _, test := range tests {
+		queries, errOpt := GenerateSqlForViews(firstSchema, firstSchema, "mysql", "v", test.status)
+		if errOpt.IsSome() {
+			t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+		}
+
+		if sql := strings.Join(queries, "\n"); sql != test.expected {
+			t.Errorf("%s: got\n%s\nexpected\n%s", test.status, sql, test.expected)
+		}
+	}
+}
+
+// newPostgresViewSchema builds a Postgres schema with a `users` table and a view over it with the given columns.
+func newPostgresViewSchema(definition string, columns ...*schema.Column) *schema.Schema {
+	ret := testutil.NewSchema("postgres", testutil.NewTable("users", &schema.Column{Name: "id", Type: "integer"}, &schema.Column{Name: "email", Type: "text"}))
+	ret.Views["user_emails"] = &schema.View{Name: "user_emails", Definition: definition, Dependencies: []string{"users"}, Columns: columns}
+	ret.Views["admin_emails"] = &schema.View{
+		Name:         "admin_emails",
+		Definition:   `CREATE OR REPLACE VIEW "admin_emails" AS SELECT id FROM user_emails`,
+		Dependencies: []string{"user_emails"},
+		Columns:      []*schema.Column{{Name: "id", Type: "integer"}},
+	}
+
+	return ret
+}
+
+func TestGenerateSqlForViewsPostgres(t *testing.T) {
+	secondSchema := newPostgresViewSchema(`CREATE OR REPLACE VIEW "user_emails" AS SELECT id, email FROM users`,
+		&schema.Column{Name: "id", Type: "integer"}, &schema.Column{Name: "email", Type: "text"})
+
+	tests := []struct {
+		name     string
+		schema   *schema.Schema
+		expected string
+	}{
+		{
+			name: "columns added at the end are replaced in place",
+			schema: newPostgresViewSchema(`CREATE OR REPLACE VIEW "user_emails" AS SELECT id, email, 1 AS one FROM users`,
+				&schema.Column{Name: "id", Type: "integer"}, &schema.Column{Name: "email", Type: "text"}, &schema.Column{Name: "one", Type: "integer"}),
+			expected: `CREATE OR REPLACE VIEW "user_emails" AS SELECT id, email, 1 AS one FROM users;`,
+		},
+		{
+			name: "dropped columns are dropped and created",
+			schema: newPostgresViewSchema(`CREATE OR REPLACE VIEW "user_emails" AS SELECT id FROM users`,
+				&schema.Column{Name: "id", Type: "integer"}),
+			expected: "DROP VIEW IF EXISTS \"user_emails\";\nCREATE OR REPLACE VIEW \"user_emails\" AS SELECT id FROM users;",
+		},
+		{
+			name: "retyped columns are dropped and created",
+			schema: newPostgresViewSchema(`CREATE OR REPLACE VIEW "user_emails" AS SELECT id::bigint AS id, email FROM users`,
+				&schema.Column{Name: "id", Type: "bigint"}, &schema.Column{Name: "email", Type: "text"}),
+			expected: "DROP VIEW IF EXISTS \"user_emails\";\nCREATE OR REPLACE VIEW \"user_emails\" AS SELECT id::bigint AS id, email FROM users;",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queries, errOpt := GenerateSqlForViews(test.schema, secondSchema, "postgres", "user_emails", "modified")
+			if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+
+			if sql := strings.Join(queries, "\n"); sql != test.expected {
+				t.Errorf("got\n%s\nexpected\n%s", sql, test.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateMigrationRecreatesViewsWhoseColumnsChange(t *testing.T) {
+	firstSchema := newPostgresViewSchema(`CREATE OR REPLACE VIEW "user_emails" AS SELECT id FROM users`,
+		&schema.Column{Name: "id", Type: "integer"})
+	secondSchema := newPostgresViewSchema(`CREATE OR REPLACE VIEW "user_emails" AS SELECT email, id FROM users`,
+		&schema.Column{Name: "email", Type: "text"}, &schema.Column{Name: "id", Type: "integer"})
+
+	statements, errOpt := GenerateMigration(firstSchema, secondSchema, "postgres", difftool.GetSchemaDiff(firstSchema, secondSchema, nil))
+	if errOpt.IsSome() {
+		t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+	}
+
+	got := []string{}
+	for _, statement := range statements {
+		got = append(got, statement.Status+" "+statement.EntityName)
+	}
+
+	// The view that selects from the modified one has to be dropped first, and created again after it.
+	expected := []string{
+		"deleted admin_emails",
+		"deleted user_emails",
+		"created user_emails",
+		"created admin_emails",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
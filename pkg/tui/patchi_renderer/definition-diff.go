@@ -0,0 +1,182 @@
+package patchi_renderer
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// definitionViewMode is what the right-hand pane shows.
+type definitionViewMode int8
+
+const (
+	// sqlView shows the generated SQL.
+	sqlView definitionViewMode = 0
+	// sideBySideView shows the definitions of the selected entity in both databases next to each other.
+	sideBySideView definitionViewMode = 1
+	// unifiedView shows the definitions of the selected entity in both databases as a unified diff.
+	unifiedView definitionViewMode = 2
+)
+
+// lineDiffType is the side of the comparison a line of a definition belongs to.
+type lineDiffType int8
+
+const (
+	unchangedLine lineDiffType = 0
+	// firstOnlyLine is a line that only exists in the definition from the first database.
+	firstOnlyLine lineDiffType = 1
+	// secondOnlyLine is a line that only exists in the definition from the second database.
+	secondOnlyLine lineDiffType = 2
+)
+
+type lineDiff struct {
+	DiffType lineDiffType
+	Text     string
+}
+
+// diffLines compares two definitions line by line based on their longest common subsequence. Within a changed block,
+// the lines of the second definition come before the lines of the first one, like in a unified diff.
+func diffLines(firstDefinition string, secondDefinition string) []lineDiff {
+	firstLines := splitDefinitionLines(firstDefinition)
+	secondLines := splitDefinitionLines(secondDefinition)
+
+	// lcsLengths[i][j] is the length of the longest common subsequence of firstLines[i:] and secondLines[j:].
+	lcsLengths := make([][]int, len(firstLines)+1)
+	for i := range lcsLengths {
+		lcsLengths[i] = make([]int, len(secondLines)+1)
+	}
+	for i := len(firstLines) - 1; i >= 0; i -= 1 {
+		for j := len(secondLines) - 1; j >= 0; j -= 1 {
+			if firstLines[i] == secondLines[j] {
+				lcsLengths[i][j] = lcsLengths[i+1][j+1] + 1
+			} else if lcsLengths[i+1][j] >= lcsLengths[i][j+1] {
+				lcsLengths[i][j] = lcsLengths[i+1][j]
+			} else {
+				lcsLengths[i][j] = lcsLengths[i][j+1]
+			}
+		}
+	}
+
+	ret := []lineDiff{}
+
+	i, j := 0, 0
+	for i < len(firstLines) || j < len(secondLines) {
+		if i < len(firstLines) && j < len(secondLines) && firstLines[i] == secondLines[j] {
+			ret = append(ret, lineDiff{DiffType: unchangedLine, Text: firstLines[i]})
+			i += 1
+			j += 1
+		} else if j < len(secondLines) && (i == len(firstLines) || lcsLengths[i][j+1] >= lcsLengths[i+1][j]) {
+			ret = append(ret, lineDiff{DiffType: secondOnlyLine, Text: secondLines[j]})
+			j += 1
+		} else {
+			ret = append(ret, lineDiff{DiffType: firstOnlyLine, Text: firstLines[i]})
+			i += 1
+		}
+	}
+
+	return ret
+}
+
+// splitDefinitionLines splits a definition into lines. Tabs are expanded so that the columns of the side by side view
+// stay aligned.
+func splitDefinitionLines(definition string) []string {
+	if definition == "" {
+		return []string{}
+	}
+
+	definition = strings.ReplaceAll(definition, "\r\n", "\n")
+	definition = strings.ReplaceAll(definition, "\t", "    ")
+
+	return strings.Split(definition, "\n")
+}
+
+// formatUnifiedDiff formats the diff of two definitions as the rows of a unified diff. Lines that only exist in the
+// first database are green and prefixed with `+`, and lines that only exist in the second database are red and
+// prefixed with `-`, since the migration turns the second definition into the first one.
+func formatUnifiedDiff(lines []lineDiff) []string {
+	ret := []string{}
+
+	for _, line := range lines {
+		if line.DiffType == firstOnlyLine {
+			ret = append(ret, colorizeDiffLine("+ "+line.Text, "green"))
+		} else if line.DiffType == secondOnlyLine {
+			ret = append(ret, colorizeDiffLine("- "+line.Text, "red"))
+		} else {
+			ret = append(ret, "  "+line.Text)
+		}
+	}
+
+	return ret
+}
+
+// formatSideBySideDiff formats the diff of two definitions as rows with the first definition on the left and the second
+// one on the right. Changed blocks are paired up line by line. Each side is cut or padded to columnWidth.
+func formatSideBySideDiff(lines []lineDiff, firstTitle string, secondTitle string, columnWidth int) []string {
+	const separator = " │ "
+
+	ret := []string{
+		fitToWidth(firstTitle, columnWidth) + separator + secondTitle,
+		strings.Repeat("─", columnWidth) + "─┼─" + strings.Repeat("─", columnWidth),
+	}
+
+	for i := 0; i < len(lines); {
+		if lines[i].DiffType == unchangedLine {
+			ret = append(ret, fitToWidth(lines[i].Text, columnWidth)+separator+lines[i].Text)
+			i += 1
+			continue
+		}
+
+		// Collect the whole changed block so that the lines removed from one side line up with the ones added to the
+		// other side.
+		firstLines := []string{}
+		secondLines := []string{}
+		for ; i < len(lines) && lines[i].DiffType != unchangedLine; i += 1 {
+			if lines[i].DiffType == firstOnlyLine {
+				firstLines = append(firstLines, lines[i].Text)
+			} else {
+				secondLines = append(secondLines, lines[i].Text)
+			}
+		}
+
+		for k := 0; k < len(firstLines) || k < len(secondLines); k += 1 {
+			left := strings.Repeat(" ", columnWidth)
+			if k < len(firstLines) {
+				left = colorizeDiffLine(fitToWidth(firstLines[k], columnWidth), "green")
+			}
+
+			right := ""
+			if k < len(secondLines) {
+				right = colorizeDiffLine(secondLines[k], "red")
+			}
+
+			ret = append(ret, left+separator+right)
+		}
+	}
+
+	return ret
+}
+
+// fitToWidth cuts or pads a line to exactly width characters.
+func fitToWidth(line string, width int) string {
+	length := utf8.RuneCountInString(line)
+	if length > width {
+		if width <= 1 {
+			return string([]rune(line)[:width])
+		}
+
+		return string([]rune(line)[:width-1]) + "…"
+	}
+
+	return line + strings.Repeat(" ", width-length)
+}
+
+// colorizeDiffLine wraps a line in termui's style markup. Square brackets would end the markup early, so they are
+// swapped with similar looking characters.
+func colorizeDiffLine(line string, color string) string {
+	if strings.TrimSpace(line) == "" {
+		return line
+	}
+
+	line = strings.NewReplacer("[", "⁅", "]", "⁆").Replace(line)
+
+	return "[" + line + "](fg:" + color + ")"
+}
@@ -0,0 +1,125 @@
+package patchi_renderer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name             string
+		firstDefinition  string
+		secondDefinition string
+		expected         []lineDiff
+	}{
+		{
+			name:             "equal",
+			firstDefinition:  "a\nb",
+			secondDefinition: "a\nb",
+			expected:         []lineDiff{{unchangedLine, "a"}, {unchangedLine, "b"}},
+		},
+		{
+			name:             "insertion",
+			firstDefinition:  "a\nb\nc",
+			secondDefinition: "a\nc",
+			expected:         []lineDiff{{unchangedLine, "a"}, {firstOnlyLine, "b"}, {unchangedLine, "c"}},
+		},
+		{
+			name:             "deletion",
+			firstDefinition:  "a\nc",
+			secondDefinition: "a\nb\nc",
+			expected:         []lineDiff{{unchangedLine, "a"}, {secondOnlyLine, "b"}, {unchangedLine, "c"}},
+		},
+		{
+			name:             "changed line puts the second definition first",
+			firstDefinition:  "a\nnew\nc",
+			secondDefinition: "a\nold\nc",
+			expected:         []lineDiff{{unchangedLine, "a"}, {secondOnlyLine, "old"}, {firstOnlyLine, "new"}, {unchangedLine, "c"}},
+		},
+		{
+			name:             "unequal lengths",
+			firstDefinition:  "a\nb\nc\nd",
+			secondDefinition: "x",
+			expected:         []lineDiff{{secondOnlyLine, "x"}, {firstOnlyLine, "a"}, {firstOnlyLine, "b"}, {firstOnlyLine, "c"}, {firstOnlyLine, "d"}},
+		},
+		{
+			name:             "empty first definition",
+			firstDefinition:  "",
+			secondDefinition: "a\n\tb",
+			expected:         []lineDiff{{secondOnlyLine, "a"}, {secondOnlyLine, "    b"}},
+		},
+		{
+			name:             "carriage returns",
+			firstDefinition:  "a\r\nb",
+			secondDefinition: "a\nb",
+			expected:         []lineDiff{{unchangedLine, "a"}, {unchangedLine, "b"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			lines := diffLines(test.firstDefinition, test.secondDefinition)
+			if len(lines) != len(test.expected) {
+				t.Fatalf("got %+v, expected %+v", lines, test.expected)
+			}
+
+			for i := range lines {
+				if lines[i] != test.expected[i] {
+					t.Errorf("got %+v, expected %+v", lines, test.expected)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestFormatSideBySideDiff(t *testing.T) {
+	const header = "A    │ B\n─────┼─────"
+
+	tests := []struct {
+		name             string
+		firstDefinition  string
+		secondDefinition string
+		expected         string
+	}{
+		{
+			name:             "unchanged lines are on both sides",
+			firstDefinition:  "a\nb",
+			secondDefinition: "a\nb",
+			expected:         header + "\na    │ a\nb    │ b",
+		},
+		{
+			name:             "insertion is only on the left",
+			firstDefinition:  "a\nb",
+			secondDefinition: "a",
+			expected:         header + "\na    │ a\n[b   ](fg:green) │ ",
+		},
+		{
+			name:             "deletion is only on the right",
+			firstDefinition:  "a",
+			secondDefinition: "a\nb",
+			expected:         header + "\na    │ a\n     │ [b](fg:red)",
+		},
+		{
+			name:             "changed blocks of unequal lengths are paired up",
+			firstDefinition:  "x\ny\nz",
+			secondDefinition: "old",
+			expected:         header + "\n[x   ](fg:green) │ [old](fg:red)\n[y   ](fg:green) │ \n[z   ](fg:green) │ ",
+		},
+		{
+			name:             "long lines are cut and brackets are swapped",
+			firstDefinition:  "abcdefgh",
+			secondDefinition: "[a]",
+			expected:         header + "\n[abc…](fg:green) │ [⁅a⁆](fg:red)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows := formatSideBySideDiff(diffLines(test.firstDefinition, test.secondDefinition), "A", "B", 4)
+			if got := strings.Join(rows, "\n"); got != test.expected {
+				t.Errorf("got\n%s\nexpected\n%s", got, test.expected)
+			}
+		})
+	}
+}
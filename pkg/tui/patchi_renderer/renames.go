@@ -0,0 +1,142 @@
+package patchi_renderer
+
+import (
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/sequelizer"
+	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// getRenameCandidates returns the tables and columns that look renamed. They are detected once and kept until the
+// diffs are reset.
+func (self *PatchiRenderer) getRenameCandidates() []difftool.RenameCandidate {
+	if self.renameCandidates.IsNone() {
+		self.renameCandidates = safego.Some(difftool.GetRenameCandidates(self.params.FirstSchema, self.params.SecondSchema, self.params.IgnoreRules))
+	}
+
+	return self.renameCandidates.Unwrap()
+}
+
+// getConfirmedRenames returns the rename candidates that the user confirmed.
+func (self *PatchiRenderer) getConfirmedRenames() []difftool.RenameCandidate {
+	ret := []difftool.RenameCandidate{}
+
+	for _, candidate := range self.getRenameCandidates() {
+		if self.confirmedRenames[candidate.Key()] {
+			ret = append(ret, candidate)
+		}
+	}
+
+	return ret
+}
+
+// getRenameRowName returns the name a rename candidate is shown with in DiffWidget. e.g. `users → name ⇢ full_name (renamed?)`.
+func (self *PatchiRenderer) getRenameRowName(candidate difftool.RenameCandidate) string {
+	ret := candidate.OldName + " ⇢ " + candidate.NewName + utils.Ternary(self.confirmedRenames[candidate.Key()], " (renamed)", " (renamed?)")
+	if candidate.TableName != "" {
+		ret = candidate.TableName + " → " + ret
+	}
+
+	return ret
+}
+
+// getRenameRows returns the rows of the rename candidates of a type of entity. Unconfirmed candidates are magenta and
+// confirmed ones are cyan. It also returns the names of the rows the candidates replace, which are the deleted and
+// created entities they are made of.
+func (self *PatchiRenderer) getRenameRows(entityType string) ([]string, map[string]bool) {
+	rows := []string{}
+	replacedNames := map[string]bool{}
+
+	for _, candidate := range self.getRenameCandidates() {
+		if candidate.EntityType != entityType {
+			continue
+		}
+
+		rows = append(rows, "["+self.getRenameRowName(candidate)+"]"+utils.Ternary(self.confirmedRenames[candidate.Key()], "(fg:cyan)", "(fg:magenta)"))
+
+		if candidate.TableName != "" {
+			replacedNames[candidate.TableName+" → "+candidate.OldName] = true
+			replacedNames[candidate.TableName+" → "+candidate.NewName] = true
+		} else {
+			replacedNames[candidate.OldName] = true
+			replacedNames[candidate.NewName] = true
+		}
+	}
+
+	return rows, replacedNames
+}
+
+// getRenameCandidateForRow returns the rename candidate shown in a row of DiffWidget, if it is one.
+func (self *PatchiRenderer) getRenameCandidateForRow(entityType string, entityRow string) safego.Option[difftool.RenameCandidate] {
+	extractedExpressions := utils.ExtractExpressions(entityRow, "\\[(.*?)\\]")
+	if len(extractedExpressions) == 0 {
+		return safego.None[difftool.RenameCandidate]()
+	}
+
+	for _, candidate := range self.getRenameCandidates() {
+		if candidate.EntityType == entityType && self.getRenameRowName(candidate) == extractedExpressions[0] {
+			return safego.Some(candidate)
+		}
+	}
+
+	return safego.None[difftool.RenameCandidate]()
+}
+
+// ToggleRenameConfirmation confirms the rename candidate selected in DiffWidget, or takes the confirmation back.
+// Confirmed renames are generated as renames. Unconfirmed ones are dropped and created again.
+func (self *PatchiRenderer) ToggleRenameConfirmation() {
+	if self.FocusedWidget != self.DiffWidget || self.DiffWidget.SelectedRow >= len(self.DiffWidget.Rows) {
+		return
+	}
+
+	entityType := getTabNameBasedOnIndex(self.TabPaneWidget.ActiveTabIndex)
+
+	candidateOpt := self.getRenameCandidateForRow(entityType, self.DiffWidget.Rows[self.DiffWidget.SelectedRow])
+	if candidateOpt.IsNone() {
+		self.alert("The selected row is not a possible rename.")
+		return
+	}
+	candidate := candidateOpt.Unwrap()
+
+	self.confirmedRenames[candidate.Key()] = !self.confirmedRenames[candidate.Key()]
+
+	if self.confirmedRenames[candidate.Key()] {
+		self.alertMsg = safego.Some("[Confirmed " + candidate.OldName + " ⇢ " + candidate.NewName + " as a rename.](fg:cyan)")
+	} else {
+		self.alertMsg = safego.Some("[" + candidate.OldName + " ⇢ " + candidate.NewName + " will be dropped and created again.](fg:magenta)")
+	}
+
+	// Emptying the rows of the tab makes RenderWidgets build them again with the new state of the candidate.
+	self.tabsData[self.TabPaneWidget.ActiveTabIndex].data = []string{}
+	self.DiffWidget.Rows = []string{}
+}
+
+// generateSqlForRename generates the SQL of a rename candidate. Unconfirmed candidates are dropped and created again.
+func (self *PatchiRenderer) generateSqlForRename(candidate difftool.RenameCandidate) (string, safego.Option[string]) {
+	firstSchema := self.params.FirstSchema
+	dialect := firstSchema.Dialect
+
+	if self.confirmedRenames[candidate.Key()] {
+		queries, errOpt := sequelizer.GenerateSqlForRename(firstSchema, self.params.SecondSchema, dialect, candidate)
+
+		return strings.Join(queries, "\n"), errOpt
+	}
+
+	var dropQueries, createQueries []string
+	var errOpt safego.Option[string]
+	if candidate.EntityType == "tables" {
+		dropQueries, errOpt = sequelizer.GenerateSqlForTables(firstSchema, dialect, candidate.OldName, "deleted")
+		if errOpt.IsNone() {
+			createQueries, errOpt = sequelizer.GenerateSqlForTables(firstSchema, dialect, candidate.NewName, "created")
+		}
+	} else {
+		dropQueries, errOpt = sequelizer.GenerateSqlForColumns(firstSchema, self.params.SecondSchema, dialect, candidate.OldName, candidate.TableName, "deleted")
+		if errOpt.IsNone() {
+			createQueries, errOpt = sequelizer.GenerateSqlForColumns(firstSchema, self.params.SecondSchema, dialect, candidate.NewName, candidate.TableName, "created")
+		}
+	}
+
+	return strings.Join(append(dropQueries, createQueries...), "\n"), errOpt
+}
@@ -1,10 +1,13 @@
 package patchi_renderer
 
 import (
+	"context"
 	"strconv"
 	"strings"
 
 	"github.com/Okira-E/patchi/pkg/difftool"
+	"github.com/Okira-E/patchi/pkg/migrator"
+	"github.com/Okira-E/patchi/pkg/schema"
 	"github.com/Okira-E/patchi/pkg/sequelizer"
 	"github.com/Okira-E/patchi/pkg/utils"
 	"github.com/atotto/clipboard"
@@ -36,6 +39,9 @@ type PatchiRenderer struct {
 	// SqlWidget is the widget that holds the sql formulated from the diff.
 	SqlWidget *widgets.Paragraph
 
+	// DefinitionWidget takes the place of SqlWidget and shows the definitions of the selected entity in both databases.
+	DefinitionWidget *widgets.List
+
 	// MessageBarWidget is the widget that holds the messages that are shown to the user.
 	MessageBarWidget *widgets.Paragraph
 
@@ -58,36 +64,58 @@ type PatchiRenderer struct {
 	alertMsg safego.Option[string]
 
 	// tabsData holds the data for each tab.
-	tabsData [6]tabData
+	tabsData [8]tabData
 
 	// params holds the data parameters that are passed to the PatchiRenderer.
 	params *PatchiRendererParams
 
 	// alreadyRenderedEntities Makes sure that we don't generate SQL for an entity twice.
 	alreadyRenderedEntities map[string]map[string]bool
+
+	// pendingMigration holds the statements that are waiting for the user to confirm applying them.
+	pendingMigration safego.Option[[]sequelizer.Statement]
+
+	// definitionViewMode is what the right-hand pane shows: the generated SQL or the definitions of the selected entity.
+	definitionViewMode definitionViewMode
+
+	// renameCandidates holds the tables and columns that look renamed.
+	renameCandidates safego.Option[[]difftool.RenameCandidate]
+
+	// confirmedRenames holds the keys of the rename candidates the user confirmed.
+	confirmedRenames map[string]bool
+
+	// rowCounts holds the estimated number of rows of the tables of the second database, once they are read.
+	rowCounts safego.Option[map[string]int64]
+
+	// renderedDefinitionKey identifies the entity, mode and width DefinitionWidget was last filled for. The rows are only
+	// built again when it changes so that scrolling through them isn't reset on every render.
+	renderedDefinitionKey string
 }
 
 // NewPatchiRenderer creates a new instance of CompareRootRenderer.
 func NewPatchiRenderer(params *PatchiRendererParams) *PatchiRenderer {
 	patchiRenderer := &PatchiRenderer{
-		TabPaneWidget:           widgets.NewTabPane("Tables", "Columns", "Views", "Procedures", "Functions", "Triggers"),
+		TabPaneWidget:           widgets.NewTabPane("Tables", "Columns", "Indexes", "Constraints", "Views", "Procedures", "Functions", "Triggers"),
 		DiffWidget:              widgets.NewList(),
 		SqlWidget:               widgets.NewParagraph(),
+		DefinitionWidget:        widgets.NewList(),
 		MessageBarWidget:        widgets.NewParagraph(),
 		HelpWidget:              widgets.NewList(),
 		confirmationWidget:      widgets.NewParagraph(),
 		alertMsg:                safego.None[string](),
 		params:                  params,
 		alreadyRenderedEntities: map[string]map[string]bool{},
+		pendingMigration:        safego.None[[]sequelizer.Statement](),
+		renameCandidates:        safego.None[[]difftool.RenameCandidate](),
+		confirmedRenames:        map[string]bool{},
+		rowCounts:               safego.None[map[string]int64](),
 	}
 
-	// Initialize the map of maps with default values because an empty map is nil in Go for some reason.
-	patchiRenderer.alreadyRenderedEntities["tables"] = map[string]bool{}
-	patchiRenderer.alreadyRenderedEntities["columns"] = map[string]bool{}
-	patchiRenderer.alreadyRenderedEntities["views"] = map[string]bool{}
-	patchiRenderer.alreadyRenderedEntities["procedures"] = map[string]bool{}
-	patchiRenderer.alreadyRenderedEntities["functions"] = map[string]bool{}
-	patchiRenderer.alreadyRenderedEntities["triggers"] = map[string]bool{}
+	patchiRenderer.resetAlreadyRenderedEntities()
+
+	if len(params.DialectMismatches) != 0 {
+		patchiRenderer.alertMsg = safego.Some("[The schemas are of different dialects, and " + strconv.Itoa(len(params.DialectMismatches)) + " things don't translate exactly. Run `patchi diff` to list them.](fg:yellow)")
+	}
 
 	patchiRenderer.FocusedWidget = patchiRenderer.DiffWidget
 
@@ -104,6 +132,10 @@ func NewPatchiRenderer(params *PatchiRendererParams) *PatchiRenderer {
 	patchiRenderer.SqlWidget.PaddingLeft = 2
 	patchiRenderer.SqlWidget.Text = ""
 
+	patchiRenderer.DefinitionWidget.TextStyle = termui.NewStyle(termui.ColorWhite)
+	patchiRenderer.DefinitionWidget.SelectedRowStyle = termui.NewStyle(termui.ColorBlack, termui.ColorWhite)
+	patchiRenderer.DefinitionWidget.Rows = []string{}
+
 	patchiRenderer.MessageBarWidget.Border = false
 	patchiRenderer.MessageBarWidget.Text = defaultBarMsg
 
@@ -118,7 +150,10 @@ func NewPatchiRenderer(params *PatchiRendererParams) *PatchiRenderer {
 		`<] | Right>` + "\t \t \t \t to move to the next tab.",
 		`[<Tab>](fg:green)` + "\t \t \t \t \t \t \t \t \t \t to move between the diff and sql widgets.",
 		`[<Enter>](fg:green)` + "\t \t \t \t \t \t \t \t on the SQL widget to copy the SQL.",
-		`[<a>](fg:green)` + "\t \t \t \t \t \t \t \t \t \t \t \t on any tab to generate all the SQL at once.",
+		`[<a>](fg:green)` + "\t \t \t \t \t \t \t \t \t \t \t \t on any tab to generate the SQL of all tabs at once, in dependency order.",
+		`[<x>](fg:green)` + "\t \t \t \t \t \t \t \t \t \t \t \t to apply the migration to the second database. Press it twice to confirm.",
+		`[<r>](fg:green)` + "\t \t \t \t \t \t \t \t \t \t \t \t on a possible rename (magenta) to confirm it. Confirmed renames (cyan) are renamed instead of dropped and created.",
+		`[<d>](fg:green)` + "\t \t \t \t \t \t \t \t \t \t \t \t to switch between the SQL and a side by side or unified diff of the selected entity's definitions.",
 	}
 
 	patchiRenderer.confirmationWidget.BorderTop = false
@@ -147,6 +182,7 @@ func (self *PatchiRenderer) ResizeWidgets(width int, height int) {
 	self.TabPaneWidget.SetRect(0, 0, width/2, height/tabPaneHeight)
 	self.DiffWidget.SetRect(0, height/tabPaneHeight, width/2, height-(messageBarHeight))
 	self.SqlWidget.SetRect(width/2, 0, width, height-(messageBarHeight))
+	self.DefinitionWidget.SetRect(width/2, 0, width, height-(messageBarHeight))
 	self.MessageBarWidget.SetRect(0, height-(messageBarHeight), width, height)
 
 	self.width = width
@@ -157,6 +193,89 @@ func (self *PatchiRenderer) ResizeWidgets(width int, height int) {
 func (self *PatchiRenderer) ClearBorderStyles() {
 	self.DiffWidget.BorderStyle = termui.NewStyle(termui.ColorClear)
 	self.SqlWidget.BorderStyle = termui.NewStyle(termui.ColorClear)
+	self.DefinitionWidget.BorderStyle = termui.NewStyle(termui.ColorClear)
+}
+
+// GetRightPaneWidget returns the widget shown on the right-hand side, which is either SqlWidget or DefinitionWidget.
+func (self *PatchiRenderer) GetRightPaneWidget() any {
+	if self.definitionViewMode == sqlView {
+		return self.SqlWidget
+	}
+
+	return self.DefinitionWidget
+}
+
+// ToggleDefinitionView cycles the right-hand pane between the generated SQL, a side by side diff and a unified diff of
+// the definitions of the selected entity.
+func (self *PatchiRenderer) ToggleDefinitionView() {
+	isRightPaneFocused := self.FocusedWidget == self.GetRightPaneWidget()
+
+	if self.definitionViewMode == sqlView {
+		self.definitionViewMode = sideBySideView
+	} else if self.definitionViewMode == sideBySideView {
+		self.definitionViewMode = unifiedView
+	} else {
+		self.definitionViewMode = sqlView
+	}
+
+	if isRightPaneFocused {
+		self.FocusedWidget = self.GetRightPaneWidget()
+	}
+}
+
+// renderDefinitionDiff fills DefinitionWidget with the diff of the definitions of the entity selected in DiffWidget.
+func (self *PatchiRenderer) renderDefinitionDiff() {
+	self.DefinitionWidget.Title = utils.Ternary(self.definitionViewMode == sideBySideView, "Definitions (side by side)", "Definitions (unified)")
+
+	entityType := getTabNameBasedOnIndex(self.TabPaneWidget.ActiveTabIndex)
+
+	var entityRow string
+	if !self.tabsData[self.TabPaneWidget.ActiveTabIndex].ShowConfirmation && self.DiffWidget.SelectedRow < len(self.DiffWidget.Rows) {
+		entityRow = self.DiffWidget.Rows[self.DiffWidget.SelectedRow]
+	}
+
+	columnWidth := (self.DefinitionWidget.Inner.Dx() - 3) / 2
+	if columnWidth < 1 {
+		columnWidth = 1
+	}
+	key := entityType + "\n" + entityRow + "\n" + strconv.Itoa(int(self.definitionViewMode)) + "\n" + strconv.Itoa(columnWidth)
+	if key == self.renderedDefinitionKey {
+		return
+	}
+	self.renderedDefinitionKey = key
+
+	self.DefinitionWidget.SelectedRow = 0
+
+	extractedExpressions := utils.ExtractExpressions(entityRow, "\\[(.*?)\\]")
+	if len(extractedExpressions) == 0 {
+		self.DefinitionWidget.Rows = []string{"Select an entity to see its definitions."}
+		return
+	}
+
+	// Entities that belong to a table follow the "tableName → entityName" pattern.
+	var tableName string
+	entityName := extractedExpressions[0]
+	if entityType == "columns" || entityType == "indexes" || entityType == "constraints" {
+		fields := strings.Fields(entityName)
+		tableName, entityName = fields[0], fields[2]
+	}
+
+	// Rename candidates are compared under their old name in the second database and their new one in the first.
+	firstEntityName, secondEntityName := entityName, entityName
+	if candidateOpt := self.getRenameCandidateForRow(entityType, entityRow); candidateOpt.IsSome() {
+		firstEntityName, secondEntityName = candidateOpt.Unwrap().NewName, candidateOpt.Unwrap().OldName
+	}
+
+	firstDefinition := sequelizer.GetEntityDefinition(self.params.FirstSchema, entityType, tableName, firstEntityName)
+	secondDefinition := sequelizer.GetEntityDefinition(self.params.SecondSchema, entityType, tableName, secondEntityName)
+
+	lines := diffLines(firstDefinition.UnwrapOr(""), secondDefinition.UnwrapOr(""))
+
+	if self.definitionViewMode == sideBySideView {
+		self.DefinitionWidget.Rows = formatSideBySideDiff(lines, self.params.FirstSchema.ConnectionName, self.params.SecondSchema.ConnectionName, columnWidth)
+	} else {
+		self.DefinitionWidget.Rows = formatUnifiedDiff(lines)
+	}
 }
 
 // ToggleHelpWidget toggles the help widget.
@@ -177,23 +296,30 @@ func (self *PatchiRenderer) ToggleHelpWidget() {
 
 // generateSqlFor is responsible for generating SQL for anything in the database (tables, columns, etc.)
 func (self *PatchiRenderer) generateSqlFor(entityType string, entityRow string) string {
-	// This is the status of the entity (created, deleted, modified)
-	entityStatus := utils.ExtractExpressions(entityRow, "fg:(.*?)\\)")[0]
-	if entityStatus == "green" {
-		entityStatus = "created"
-	} else if entityStatus == "red" {
-		entityStatus = "deleted"
+	// Rename candidates are rows of their own that replace the deleted and created rows they are made of.
+	if candidateOpt := self.getRenameCandidateForRow(entityType, entityRow); candidateOpt.IsSome() {
+		generatedSql, errMsg := self.generateSqlForRename(candidateOpt.Unwrap())
+		if errMsg.IsSome() {
+			self.alert(errMsg.Unwrap())
+		}
+
+		return generatedSql
 	}
 
-	dialect := self.params.FirstDb.Info.Dialect
+	// This is the status of the entity (created, deleted, modified)
+	entityStatus := getRowStatus(entityRow)
+
+	firstSchema := self.params.FirstSchema
+	dialect := firstSchema.Dialect
 
 	// Extract the name from the row. `[users](fg:green)` -> `users` .
 	entityName := utils.ExtractExpressions(entityRow, "\\[(.*?)\\]")[0]
 
-	var generatedSql string
+	var generatedQueries []string
+	var errMsg safego.Option[string]
 	if entityType == "tables" {
 
-		generatedSql = sequelizer.GenerateSqlForTables(self.params.FirstDb.SqlConnection, dialect, entityName, entityStatus)
+		generatedQueries, errMsg = sequelizer.GenerateSqlForTables(firstSchema, dialect, entityName, entityStatus)
 
 	} else if entityType == "columns" {
 
@@ -203,72 +329,176 @@ func (self *PatchiRenderer) generateSqlFor(entityType string, entityRow string)
 		tableName := extractedExpressions[0]
 		columnName := extractedExpressions[2]
 
-		var errMsg safego.Option[string]
-		generatedSql, errMsg = sequelizer.GenerateSqlForColumns(self.params.FirstDb, dialect, columnName, tableName, entityStatus)
-		if errMsg.IsSome() {
-			self.alert(errMsg.Unwrap())
-		}
+		generatedQueries, errMsg = sequelizer.GenerateSqlForColumns(firstSchema, self.params.SecondSchema, dialect, columnName, tableName, entityStatus)
+
+	} else if entityType == "indexes" {
+
+		// Indexes follow the same "tableName → indexName" pattern as columns.
+		extractedExpressions := strings.Fields(entityName)
+		tableName := extractedExpressions[0]
+		indexName := extractedExpressions[2]
+
+		generatedQueries, errMsg = sequelizer.GenerateSqlForIndexes(firstSchema, dialect, indexName, tableName, entityStatus)
+
+	} else if entityType == "constraints" {
+
+		// Constraints follow the same "tableName → constraintName" pattern as columns.
+		extractedExpressions := strings.Fields(entityName)
+		tableName := extractedExpressions[0]
+		constraintName := extractedExpressions[2]
+
+		generatedQueries, errMsg = sequelizer.GenerateSqlForConstraints(firstSchema, self.params.SecondSchema, dialect, constraintName, tableName, entityStatus)
 
 	} else if entityType == "views" {
 
-		generatedSql = sequelizer.GenerateSqlForViews(self.params.FirstDb.SqlConnection, dialect, entityName, entityStatus)
+		generatedQueries, errMsg = sequelizer.GenerateSqlForViews(firstSchema, self.params.SecondSchema, dialect, entityName, entityStatus)
 
 	} else if entityType == "procedures" {
 
-		generatedSql = sequelizer.GenerateSqlForProcedures(self.params.FirstDb.SqlConnection, dialect, entityName, entityStatus)
+		generatedQueries, errMsg = sequelizer.GenerateSqlForProcedures(firstSchema, self.params.SecondSchema, dialect, entityName, entityStatus)
 
 	} else if entityType == "functions" {
 
-		generatedSql = sequelizer.GenerateSqlForFunctions(self.params.FirstDb.SqlConnection, dialect, entityName, entityStatus)
+		generatedQueries, errMsg = sequelizer.GenerateSqlForFunctions(firstSchema, self.params.SecondSchema, dialect, entityName, entityStatus)
 
 	} else if entityType == "triggers" {
 
-		generatedSql = sequelizer.GenerateSqlForTriggers(self.params.FirstDb.SqlConnection, dialect, entityName, entityStatus)
+		generatedQueries, errMsg = sequelizer.GenerateSqlForTriggers(firstSchema, dialect, entityName, entityStatus)
 
 	}
 
-	return generatedSql
+	if errMsg.IsSome() {
+		self.alert(errMsg.Unwrap())
+	}
+
+	return strings.Join(generatedQueries, "\n")
 }
 
+// GenerateSqlForAllEntities generates the SQL for every entity across all tabs at once. The statements are ordered by
+// the dependencies between entities, so they replace any SQL that was generated one entity at a time before.
 func (self *PatchiRenderer) GenerateSqlForAllEntities() {
-	var generatedSql string
-
-	if len(self.DiffWidget.Rows) == 0 {
+	statements, errMsgOpt := self.generateMigration()
+	if errMsgOpt.IsSome() {
+		self.alert(errMsgOpt.Unwrap())
 		return
 	}
 
-	if len(self.DiffWidget.Rows) <= self.DiffWidget.SelectedRow {
-		return
+	generatedSql := []string{}
+	for _, statement := range statements {
+		generatedSql = append(generatedSql, statement.Sql)
+
+		self.alreadyRenderedEntities[statement.EntityType][statement.EntityName] = true
 	}
 
-	if len(utils.ExtractExpressions(self.DiffWidget.Rows[self.DiffWidget.SelectedRow], "\\[(.*?)\\]")) == 0 {
+	risks := self.analyzeMigration(statements)
+
+	self.SqlWidget.Text = strings.Join(generatedSql, "\n\n")
+	self.alertMsg = safego.Some("[Generated " + strconv.Itoa(len(statements)) + " statements across all tabs." + formatRisksCount(risks) + "](fg:" + getRisksColor(risks, "green") + ")")
+}
+
+// HandleApply applies the migration of all tabs to the second database. The first call only asks the user to confirm
+// by showing the number of statements, and calling it again applies them. Any other key cancels through CancelApply.
+func (self *PatchiRenderer) HandleApply() {
+	if self.params.SecondDb.IsNone() {
+		self.alert("The second side of the comparison is a snapshot. There is no database to apply the migration to.")
 		return
 	}
 
-	currentlySelectedTab := getTabNameBasedOnIndex(self.TabPaneWidget.ActiveTabIndex) // tables, columns, views, ..
-	for i, entityRow := range self.DiffWidget.Rows {
-		entityNameExtracted := utils.ExtractExpressions(entityRow, "\\[(.*?)\\]")[0]
+	secondDb := self.params.SecondDb.Unwrap()
 
-		if ok, _ := self.alreadyRenderedEntities[currentlySelectedTab][entityNameExtracted]; !ok {
-			leftMargin := "\n\n"
+	if self.pendingMigration.IsNone() {
+		statements, errMsgOpt := self.generateMigration()
+		if errMsgOpt.IsSome() {
+			self.alert(errMsgOpt.Unwrap())
+			return
+		}
 
-			if i == 0 && len(self.SqlWidget.Text) == 0 {
-				leftMargin = ""
-			}
+		if len(statements) == 0 {
+			self.alertMsg = safego.Some("[No differences found. Nothing to apply.](fg:green)")
+			return
+		}
+
+		risks := self.analyzeMigration(statements)
+
+		self.pendingMigration = safego.Some(statements)
+		self.alertMsg = safego.Some("[Press <x> again to apply " + strconv.Itoa(len(statements)) + " statements to " + secondDb.Info.Name + "." + formatRisksCount(risks) + " Any other key cancels.](fg:" + getRisksColor(risks, "yellow") + ")")
+
+		return
+	}
+
+	statements := self.pendingMigration.Unwrap()
+	self.pendingMigration = safego.None[[]sequelizer.Statement]()
+
+	appliedStatements, errOpt := migrator.ApplyMigration(context.Background(), secondDb, statements)
 
-			generatedSql += leftMargin + self.generateSqlFor(currentlySelectedTab, entityRow)
+	// Whatever was applied makes the current diff stale, so the second schema is read again and everything has to be
+	// fetched again.
+	if len(appliedStatements) != 0 {
+		self.resetDiffs()
 
-			self.alreadyRenderedEntities[currentlySelectedTab][entityNameExtracted] = true
+		secondSchema, loadErrOpt := difftool.LoadSchema(context.Background(), secondDb)
+		if loadErrOpt.IsSome() {
+			self.alert("The migration was applied, but reading the schema of " + secondDb.Info.Name + " again failed: " + loadErrOpt.Unwrap().Error())
+			return
 		}
+
+		self.params.SecondSchema = secondSchema
 	}
 
-	if self.SqlWidget.Text != "" {
-		self.SqlWidget.Text += "\n\n" + generatedSql
-	} else {
-		self.SqlWidget.Text += generatedSql
+	if errOpt.IsSome() {
+		self.alert(errOpt.Unwrap().Error() + " (" + strconv.Itoa(len(appliedStatements)) + " statements were applied before the failure.)")
+		return
+	}
+
+	self.alertMsg = safego.Some("[Applied " + strconv.Itoa(len(appliedStatements)) + " statements to " + secondDb.Info.Name + ". Press Enter to fetch the changes again.](fg:green)")
+}
+
+// generateMigration generates the statements of every tab at once, in dependency order.
+func (self *PatchiRenderer) generateMigration() ([]sequelizer.Statement, safego.Option[string]) {
+	schemaDiff := difftool.GetSchemaDiff(self.params.FirstSchema, self.params.SecondSchema, self.params.IgnoreRules)
+	schemaDiff.ConfirmRenames(self.getConfirmedRenames())
+
+	return sequelizer.GenerateMigration(self.params.FirstSchema, self.params.SecondSchema, self.params.FirstSchema.Dialect, schemaDiff)
+}
+
+// CancelApply cancels a migration that is waiting for the user's confirmation.
+func (self *PatchiRenderer) CancelApply() {
+	if self.pendingMigration.IsSome() {
+		self.pendingMigration = safego.None[[]sequelizer.Statement]()
+		self.alertMsg = safego.Some("[Cancelled. Nothing was applied.](fg:yellow)")
 	}
 }
 
+// resetDiffs throws away the diff of every tab and the generated SQL so that they are fetched again.
+func (self *PatchiRenderer) resetDiffs() {
+	for i := 0; i < len(self.tabsData); i += 1 {
+		self.tabsData[i].ShowConfirmation = true
+		self.tabsData[i].data = []string{}
+	}
+
+	self.DiffWidget.Rows = []string{}
+	self.DiffWidget.SelectedRow = 0
+	self.SqlWidget.Text = ""
+	self.resetAlreadyRenderedEntities()
+
+	self.renameCandidates = safego.None[[]difftool.RenameCandidate]()
+	self.confirmedRenames = map[string]bool{}
+	self.rowCounts = safego.None[map[string]int64]()
+}
+
+// resetAlreadyRenderedEntities empties the bookkeeping of the entities that SQL was generated for.
+func (self *PatchiRenderer) resetAlreadyRenderedEntities() {
+	// Initialize the map of maps with default values because an empty map is nil in Go for some reason.
+	self.alreadyRenderedEntities["tables"] = map[string]bool{}
+	self.alreadyRenderedEntities["columns"] = map[string]bool{}
+	self.alreadyRenderedEntities["indexes"] = map[string]bool{}
+	self.alreadyRenderedEntities["constraints"] = map[string]bool{}
+	self.alreadyRenderedEntities["views"] = map[string]bool{}
+	self.alreadyRenderedEntities["procedures"] = map[string]bool{}
+	self.alreadyRenderedEntities["functions"] = map[string]bool{}
+	self.alreadyRenderedEntities["triggers"] = map[string]bool{}
+}
+
 // HandleActionOnEnter Handle every case scenario of pressing the "action button" in any state of the app.
 // It may sound obvious but this doesn't render anything. It just changes the state that the Render method relies on.
 func (self *PatchiRenderer) HandleActionOnEnter() {
@@ -302,6 +532,11 @@ func (self *PatchiRenderer) HandleActionOnEnter() {
 
 			generatedSql := self.generateSqlFor(currentlySelectedTab, self.DiffWidget.Rows[self.DiffWidget.SelectedRow]) // Give the full row.
 
+			// Errors take precedence over the risks of the generated SQL in the message bar.
+			if self.alertMsg.IsNone() {
+				self.alertMsg = formatRisksMsg(self.analyzeRow(currentlySelectedTab, self.DiffWidget.Rows[self.DiffWidget.SelectedRow]))
+			}
+
 			if self.SqlWidget.Text != "" {
 				self.SqlWidget.Text += "\n\n" + generatedSql
 			} else {
@@ -353,15 +588,21 @@ func (self *PatchiRenderer) RenderWidgets(userPrompt safego.Option[string]) {
 
 		if self.TabPaneWidget.ActiveTabIndex == 0 { // Tables
 			// Get the diff data for the current tab that we're on.
-			diffResult := difftool.GetTablesDiff(self.params.FirstDb, self.params.SecondDb, self.params.FirstDb.Info.Dialect)
+			diffResult := difftool.GetTablesDiff(self.params.FirstSchema, self.params.SecondSchema, self.params.IgnoreRules)
 
-			numberOfChangesForEachTabToBeLoggedToUser = len(diffResult)
+			renameRows, replacedNames := self.getRenameRows("tables")
+
+			numberOfChangesForEachTabToBeLoggedToUser = len(diffResult) - len(replacedNames) + len(renameRows)
 
-			for _, tableDiff := range diffResult {
-				text := "[" + tableDiff.TableName + "]"
-				if tableDiff.DiffType == 1 {
+			for _, TableDiff := range diffResult {
+				if replacedNames[TableDiff.TableName] {
+					continue
+				}
+
+				text := "[" + TableDiff.TableName + "]"
+				if TableDiff.DiffType == difftool.Created {
 					text += "(fg:green)"
-				} else if tableDiff.DiffType == 0 {
+				} else if TableDiff.DiffType == difftool.Deleted {
 					text += "(fg:red)"
 				}
 
@@ -369,93 +610,151 @@ func (self *PatchiRenderer) RenderWidgets(userPrompt safego.Option[string]) {
 				self.tabsData[self.TabPaneWidget.ActiveTabIndex].data = self.DiffWidget.Rows
 			}
 
+			self.DiffWidget.Rows = append(self.DiffWidget.Rows, renameRows...)
+			self.tabsData[self.TabPaneWidget.ActiveTabIndex].data = self.DiffWidget.Rows
+
 		} else if self.TabPaneWidget.ActiveTabIndex == 1 { // Columns
 
-			diffResult, errOpt := difftool.GetColumnsDiff(self.params.FirstDb, self.params.SecondDb, self.params.FirstDb.Info.Dialect)
-			if errOpt.IsSome() {
-				self.alert("An error occurred while fetching the diff for the columns: " + errOpt.Unwrap().Error())
-			} else {
+			diffResult := difftool.GetColumnsDiff(self.params.FirstSchema, self.params.SecondSchema, self.params.IgnoreRules)
 
-				numberOfChangesForEachTabToBeLoggedToUser = len(diffResult)
+			renameRows, replacedNames := self.getRenameRows("columns")
 
-				for _, columnDiff := range diffResult {
-					text := "[" + columnDiff.TableName + " → " + columnDiff.ColumnName + "]"
-					if columnDiff.DiffType == 1 {
-						text += "(fg:green)"
-					} else if columnDiff.DiffType == 0 {
-						text += "(fg:red)"
-					}
+			numberOfChangesForEachTabToBeLoggedToUser = len(diffResult) - len(replacedNames) + len(renameRows)
 
-					self.DiffWidget.Rows = append(self.DiffWidget.Rows, text)
-					self.tabsData[self.TabPaneWidget.ActiveTabIndex].data = self.DiffWidget.Rows
+			for _, ColumnDiff := range diffResult {
+				if replacedNames[ColumnDiff.TableName+" → "+ColumnDiff.ColumnName] {
+					continue
 				}
+
+				text := "[" + ColumnDiff.TableName + " → " + ColumnDiff.ColumnName + "]"
+				if ColumnDiff.DiffType == difftool.Created {
+					text += "(fg:green)"
+				} else if ColumnDiff.DiffType == difftool.Deleted {
+					text += "(fg:red)"
+				} else if ColumnDiff.DiffType == difftool.Modified {
+					text += "(fg:yellow)"
+				}
+
+				self.DiffWidget.Rows = append(self.DiffWidget.Rows, text)
+				self.tabsData[self.TabPaneWidget.ActiveTabIndex].data = self.DiffWidget.Rows
 			}
 
-		} else if self.TabPaneWidget.ActiveTabIndex == 2 { // Views
+			self.DiffWidget.Rows = append(self.DiffWidget.Rows, renameRows...)
+			self.tabsData[self.TabPaneWidget.ActiveTabIndex].data = self.DiffWidget.Rows
+
+		} else if self.TabPaneWidget.ActiveTabIndex == 2 { // Indexes
 
-			diffResult := difftool.GetViewsDiff(self.params.FirstDb, self.params.SecondDb, self.params.FirstDb.Info.Dialect)
+			diffResult := difftool.GetIndexesDiff(self.params.FirstSchema, self.params.SecondSchema, self.params.IgnoreRules)
 
 			numberOfChangesForEachTabToBeLoggedToUser = len(diffResult)
 
-			for _, viewDiff := range diffResult {
-				text := "[" + viewDiff.ViewName + "]"
-				if viewDiff.DiffType == 1 {
+			for _, IndexDiff := range diffResult {
+				text := "[" + IndexDiff.TableName + " → " + IndexDiff.IndexName + "]"
+				if IndexDiff.DiffType == difftool.Created {
 					text += "(fg:green)"
-				} else if viewDiff.DiffType == 0 {
+				} else if IndexDiff.DiffType == difftool.Deleted {
 					text += "(fg:red)"
+				} else if IndexDiff.DiffType == difftool.Modified {
+					text += "(fg:yellow)"
 				}
 
 				self.DiffWidget.Rows = append(self.DiffWidget.Rows, text)
 				self.tabsData[self.TabPaneWidget.ActiveTabIndex].data = self.DiffWidget.Rows
 			}
 
-		} else if self.TabPaneWidget.ActiveTabIndex == 3 { // Procedures
+		} else if self.TabPaneWidget.ActiveTabIndex == 3 { // Constraints
 
-			diffResult := difftool.GetProceduresDiff(self.params.FirstDb, self.params.SecondDb, self.params.FirstDb.Info.Dialect)
+			diffResult := difftool.GetConstraintsDiff(self.params.FirstSchema, self.params.SecondSchema, self.params.IgnoreRules)
 
 			numberOfChangesForEachTabToBeLoggedToUser = len(diffResult)
 
-			for _, viewDiff := range diffResult {
-				text := "[" + viewDiff.ProcedureName + "]"
-				if viewDiff.DiffType == 1 {
+			for _, ConstraintDiff := range diffResult {
+				text := "[" + ConstraintDiff.TableName + " → " + ConstraintDiff.ConstraintName + "]"
+				if ConstraintDiff.DiffType == difftool.Created {
 					text += "(fg:green)"
-				} else if viewDiff.DiffType == 0 {
+				} else if ConstraintDiff.DiffType == difftool.Deleted {
 					text += "(fg:red)"
+				} else if ConstraintDiff.DiffType == difftool.Modified {
+					text += "(fg:yellow)"
 				}
 
 				self.DiffWidget.Rows = append(self.DiffWidget.Rows, text)
 				self.tabsData[self.TabPaneWidget.ActiveTabIndex].data = self.DiffWidget.Rows
 			}
 
-		} else if self.TabPaneWidget.ActiveTabIndex == 4 { // Functions
+		} else if self.TabPaneWidget.ActiveTabIndex == 4 { // Views
 
-			diffResult := difftool.GetFunctionsDiff(self.params.FirstDb, self.params.SecondDb, self.params.FirstDb.Info.Dialect)
+			diffResult := difftool.GetViewsDiff(self.params.FirstSchema, self.params.SecondSchema, self.params.IgnoreRules)
 
 			numberOfChangesForEachTabToBeLoggedToUser = len(diffResult)
 
-			for _, viewDiff := range diffResult {
-				text := "[" + viewDiff.FunctionName + "]"
-				if viewDiff.DiffType == 1 {
+			for _, ViewDiff := range diffResult {
+				text := "[" + ViewDiff.ViewName + "]"
+				if ViewDiff.DiffType == difftool.Created {
 					text += "(fg:green)"
-				} else if viewDiff.DiffType == 0 {
+				} else if ViewDiff.DiffType == difftool.Deleted {
 					text += "(fg:red)"
+				} else if ViewDiff.DiffType == difftool.Modified {
+					text += "(fg:yellow)"
 				}
 
 				self.DiffWidget.Rows = append(self.DiffWidget.Rows, text)
 				self.tabsData[self.TabPaneWidget.ActiveTabIndex].data = self.DiffWidget.Rows
 			}
 
-		} else if self.TabPaneWidget.ActiveTabIndex == 5 { // Triggers
-			diffResult := difftool.GetTriggersDiff(self.params.FirstDb, self.params.SecondDb, self.params.FirstDb.Info.Dialect)
+		} else if self.TabPaneWidget.ActiveTabIndex == 5 { // Procedures
+
+			diffResult := difftool.GetProceduresDiff(self.params.FirstSchema, self.params.SecondSchema, self.params.IgnoreRules)
 
 			numberOfChangesForEachTabToBeLoggedToUser = len(diffResult)
 
-			for _, triggerDiff := range diffResult {
-				text := "[" + triggerDiff.TriggerName + "]"
-				if triggerDiff.DiffType == 1 {
+			for _, ViewDiff := range diffResult {
+				text := "[" + ViewDiff.ProcedureName + "]"
+				if ViewDiff.DiffType == difftool.Created {
 					text += "(fg:green)"
-				} else if triggerDiff.DiffType == 0 {
+				} else if ViewDiff.DiffType == difftool.Deleted {
 					text += "(fg:red)"
+				} else if ViewDiff.DiffType == difftool.Modified {
+					text += "(fg:yellow)"
+				}
+
+				self.DiffWidget.Rows = append(self.DiffWidget.Rows, text)
+				self.tabsData[self.TabPaneWidget.ActiveTabIndex].data = self.DiffWidget.Rows
+			}
+
+		} else if self.TabPaneWidget.ActiveTabIndex == 6 { // Functions
+
+			diffResult := difftool.GetFunctionsDiff(self.params.FirstSchema, self.params.SecondSchema, self.params.IgnoreRules)
+
+			numberOfChangesForEachTabToBeLoggedToUser = len(diffResult)
+
+			for _, ViewDiff := range diffResult {
+				text := "[" + ViewDiff.FunctionName + "]"
+				if ViewDiff.DiffType == difftool.Created {
+					text += "(fg:green)"
+				} else if ViewDiff.DiffType == difftool.Deleted {
+					text += "(fg:red)"
+				} else if ViewDiff.DiffType == difftool.Modified {
+					text += "(fg:yellow)"
+				}
+
+				self.DiffWidget.Rows = append(self.DiffWidget.Rows, text)
+				self.tabsData[self.TabPaneWidget.ActiveTabIndex].data = self.DiffWidget.Rows
+			}
+
+		} else if self.TabPaneWidget.ActiveTabIndex == 7 { // Triggers
+			diffResult := difftool.GetTriggersDiff(self.params.FirstSchema, self.params.SecondSchema, self.params.IgnoreRules)
+
+			numberOfChangesForEachTabToBeLoggedToUser = len(diffResult)
+
+			for _, TriggerDiff := range diffResult {
+				text := "[" + TriggerDiff.TriggerName + "]"
+				if TriggerDiff.DiffType == difftool.Created {
+					text += "(fg:green)"
+				} else if TriggerDiff.DiffType == difftool.Deleted {
+					text += "(fg:red)"
+				} else if TriggerDiff.DiffType == difftool.Modified {
+					text += "(fg:yellow)"
 				}
 
 				self.DiffWidget.Rows = append(self.DiffWidget.Rows, text)
@@ -513,11 +812,17 @@ func (self *PatchiRenderer) RenderWidgets(userPrompt safego.Option[string]) {
 		self.alertMsg = safego.None[string]()
 	}
 
+	var rightPaneWidget termui.Drawable = self.SqlWidget
+	if self.definitionViewMode != sqlView {
+		self.renderDefinitionDiff()
+		rightPaneWidget = self.DefinitionWidget
+	}
+
 	// Render the widgets.
 	termui.Render(
 		self.TabPaneWidget,
 		self.DiffWidget,
-		self.SqlWidget,
+		rightPaneWidget,
 		self.MessageBarWidget,
 		self.confirmationWidget,
 		self.HelpWidget,
@@ -525,8 +830,16 @@ func (self *PatchiRenderer) RenderWidgets(userPrompt safego.Option[string]) {
 }
 
 type PatchiRendererParams struct {
-	FirstDb  types.DbConnection
-	SecondDb types.DbConnection
+	FirstSchema  *schema.Schema
+	SecondSchema *schema.Schema
+	// SecondDb is the live connection to the second database, which the migration is applied to. It is None when the
+	// second side of the comparison is read from a snapshot file.
+	SecondDb safego.Option[types.DbConnection]
+	// IgnoreRules decides which entities are left out of the diff.
+	IgnoreRules *difftool.IgnoreRules
+	// DialectMismatches holds what didn't translate exactly when the first schema was translated into the dialect of
+	// the second one. It is empty when both are of the same dialect.
+	DialectMismatches []difftool.DialectMismatch
 }
 
 type tabData struct {
@@ -540,12 +853,16 @@ func getTabNameBasedOnIndex(index int) string {
 	} else if index == 1 {
 		return "columns"
 	} else if index == 2 {
-		return "views"
+		return "indexes"
 	} else if index == 3 {
-		return "procedures"
+		return "constraints"
 	} else if index == 4 {
-		return "functions"
+		return "views"
 	} else if index == 5 {
+		return "procedures"
+	} else if index == 6 {
+		return "functions"
+	} else if index == 7 {
 		return "triggers"
 	}
 
@@ -0,0 +1,117 @@
+package patchi_renderer
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Okira-E/patchi/pkg/safety"
+	"github.com/Okira-E/patchi/pkg/sequelizer"
+	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/safego"
+)
+
+// getRowCounts returns the estimated number of rows of the tables of the second database. They are read once, and
+// are nil when the second side is a snapshot or the counts can't be read, in which case every table is assumed to
+// hold data.
+func (self *PatchiRenderer) getRowCounts() map[string]int64 {
+	if self.rowCounts.IsNone() {
+		var rowCounts map[string]int64
+		if self.params.SecondDb.IsSome() {
+			rowCounts, _ = safety.LoadRowCounts(context.Background(), self.params.SecondDb.Unwrap())
+		}
+
+		self.rowCounts = safego.Some(rowCounts)
+	}
+
+	return self.rowCounts.Unwrap()
+}
+
+// analyzeRow returns the risks of the SQL generated for a row of DiffWidget. Unconfirmed rename candidates are
+// analyzed as the drop and the create they are generated as.
+func (self *PatchiRenderer) analyzeRow(entityType string, entityRow string) []safety.Risk {
+	statements := []sequelizer.Statement{}
+
+	if candidateOpt := self.getRenameCandidateForRow(entityType, entityRow); candidateOpt.IsSome() {
+		candidate := candidateOpt.Unwrap()
+		if self.confirmedRenames[candidate.Key()] {
+			return []safety.Risk{}
+		}
+
+		oldName, newName := candidate.OldName, candidate.NewName
+		if candidate.TableName != "" {
+			oldName, newName = candidate.TableName+" → "+oldName, candidate.TableName+" → "+newName
+		}
+
+		statements = append(statements,
+			sequelizer.Statement{EntityType: entityType, EntityName: oldName, Status: "deleted"},
+			sequelizer.Statement{EntityType: entityType, EntityName: newName, Status: "created"},
+		)
+	} else {
+		statements = append(statements, sequelizer.Statement{
+			EntityType: entityType,
+			EntityName: utils.ExtractExpressions(entityRow, "\\[(.*?)\\]")[0],
+			Status:     getRowStatus(entityRow),
+		})
+	}
+
+	return safety.AnalyzeMigration(statements, self.params.FirstSchema, self.params.SecondSchema, self.getRowCounts())
+}
+
+// getRowStatus returns the status of the entity of a row of DiffWidget (created, deleted or modified) based on its color.
+func getRowStatus(entityRow string) string {
+	ret := utils.ExtractExpressions(entityRow, "fg:(.*?)\\)")[0]
+	if ret == "green" {
+		ret = "created"
+	} else if ret == "red" {
+		ret = "deleted"
+	} else if ret == "yellow" {
+		ret = "modified"
+	}
+
+	return ret
+}
+
+// formatRisksMsg formats the risks of generated SQL for the message bar. Only the first risk is spelled out.
+func formatRisksMsg(risks []safety.Risk) safego.Option[string] {
+	if len(risks) == 0 {
+		return safego.None[string]()
+	}
+
+	ret := strings.ToUpper(risks[0].Level.String()) + ": " + risks[0].Message
+	if len(risks) > 1 {
+		ret += " (+" + strconv.Itoa(len(risks)-1) + " more)"
+	}
+
+	// The messages can hold types with square brackets, such as Postgres arrays.
+	return safego.Some(colorizeDiffLine(ret, getRisksColor(risks, "yellow")))
+}
+
+// getRisksColor returns red if any of the risks loses data, yellow if there are only warnings and defaultColor if
+// there are no risks at all.
+func getRisksColor(risks []safety.Risk, defaultColor string) string {
+	if safety.CountDestructive(risks) != 0 {
+		return "red"
+	} else if len(risks) != 0 {
+		return "yellow"
+	}
+
+	return defaultColor
+}
+
+// formatRisksCount describes how many of the statements of a migration are risky. e.g. ` 2 destructive, 3 warnings.`
+// It is empty when none are.
+func formatRisksCount(risks []safety.Risk) string {
+	if len(risks) == 0 {
+		return ""
+	}
+
+	destructiveCount := safety.CountDestructive(risks)
+
+	return " " + strconv.Itoa(destructiveCount) + " destructive, " + strconv.Itoa(len(risks)-destructiveCount) + " warnings."
+}
+
+// analyzeMigration returns the risks of the statements of every tab.
+func (self *PatchiRenderer) analyzeMigration(statements []sequelizer.Statement) []safety.Risk {
+	return safety.AnalyzeMigration(statements, self.params.FirstSchema, self.params.SecondSchema, self.getRowCounts())
+}
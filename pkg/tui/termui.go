@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/Okira-E/patchi/pkg/tui/patchi_renderer"
-	"github.com/Okira-E/patchi/pkg/utils"
 	"github.com/Okira-E/patchi/safego"
 	"github.com/gizak/termui/v3"
 	"github.com/gizak/termui/v3/widgets"
@@ -19,9 +18,10 @@ import (
 // functionality can be found in patchiRenderer.go which gives this function the widgets to render.
 // Things related to how widgets look and behave can be found in the patchiRenderer.go file. But things related to
 // handling events, keyboard events, and the event loop can be found in this file.
-func RenderTui(params *patchi_renderer.PatchiRendererParams) {
+// Errors that happen while the TUI is running are shown in its message bar. Only a failure to start it is returned.
+func RenderTui(params *patchi_renderer.PatchiRendererParams) safego.Option[error] {
 	if err := termui.Init(); err != nil {
-		utils.Abort(fmt.Sprintf("failed to initialize termui: %v", err))
+		return safego.Some(fmt.Errorf("failed to initialize termui: %w", err))
 	}
 	defer termui.Close()
 
@@ -35,6 +35,11 @@ func RenderTui(params *patchi_renderer.PatchiRendererParams) {
 
 	for event := range termui.PollEvents() {
 
+		// Any key other than the one that confirms applying the migration cancels it.
+		if event.Type == termui.KeyboardEvent && event.ID != "x" {
+			patchiRenderer.CancelApply()
+		}
+
 		if event.Type == termui.KeyboardEvent && (event.ID == "<Escape>") {
 			patchiRenderer.ToggleHelpWidget()
 
@@ -83,7 +88,7 @@ func RenderTui(params *patchi_renderer.PatchiRendererParams) {
 		}
 		if event.Type == termui.KeyboardEvent && (event.ID == "<Tab>") {
 			if patchiRenderer.FocusedWidget == patchiRenderer.DiffWidget {
-				patchiRenderer.FocusedWidget = patchiRenderer.SqlWidget
+				patchiRenderer.FocusedWidget = patchiRenderer.GetRightPaneWidget()
 			} else {
 				patchiRenderer.FocusedWidget = patchiRenderer.DiffWidget
 			}
@@ -97,6 +102,21 @@ func RenderTui(params *patchi_renderer.PatchiRendererParams) {
 
 			patchiRenderer.RenderWidgets(safego.None[string]())
 		}
+		if event.Type == termui.KeyboardEvent && (event.ID == "r") {
+			patchiRenderer.ToggleRenameConfirmation()
+
+			patchiRenderer.RenderWidgets(safego.None[string]())
+		}
+		if event.Type == termui.KeyboardEvent && (event.ID == "d") {
+			patchiRenderer.ToggleDefinitionView()
+
+			patchiRenderer.RenderWidgets(safego.None[string]())
+		}
+		if event.Type == termui.KeyboardEvent && (event.ID == "x") {
+			patchiRenderer.HandleApply()
+
+			patchiRenderer.RenderWidgets(safego.None[string]())
+		}
 		if event.Type == termui.KeyboardEvent && (event.ID == "<Enter>") {
 			patchiRenderer.HandleActionOnEnter()
 		}
@@ -160,4 +180,6 @@ func RenderTui(params *patchi_renderer.PatchiRendererParams) {
 			}
 		}
 	}
+
+	return safego.None[error]()
 }
@@ -1,48 +1,348 @@
 package types
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/Okira-E/patchi/pkg/utils"
 	"github.com/Okira-E/patchi/safego"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
 	"strconv"
+	"strings"
 
 	_ "github.com/cockroachdb/cockroach-go/v2/crdb"
-	_ "github.com/go-sql-driver/mysql"
-	_ "github.com/lib/pq"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
+// DbConnectionInfo is a stored connection. Its string fields, options and TLS settings can reference environment
+// variables, e.g. `${DB_HOST}`, which are resolved when connecting.
 type DbConnectionInfo struct {
-	Dialect      string `json:"dialect,omitempty"`
-	Name         string `json:"name,omitempty"`
-	Host         string `json:"host,omitempty"`
-	Port         int    `json:"port,omitempty"`
-	User         string `json:"user,omitempty"`
-	Password     string `json:"password,omitempty"`
+	Dialect string `json:"dialect,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Host    string `json:"host,omitempty"`
+	Port    int    `json:"port,omitempty"`
+	User    string `json:"user,omitempty"`
+	// Password is stored in plain text only by configs that predate encryption, or when it references an environment
+	// variable. Otherwise it is only filled in memory, once EncryptedPassword is decrypted.
+	Password string `json:"password,omitempty"`
+	// EncryptedPassword is the password encrypted with the key derived from the master passphrase of the config.
+	EncryptedPassword string `json:"encrypted_password,omitempty"`
+	// PasswordCmd is a command whose output is the password, e.g. `pass show db/prod`. It is run when connecting and
+	// takes precedence over the other passwords.
+	PasswordCmd  string `json:"password_cmd,omitempty"`
 	DatabaseName string `json:"database,omitempty"`
+	// FilePath is the path to the database file of Sqlite connections, which have no host, port or user.
+	FilePath string `json:"file_path,omitempty"`
+	// Options are extra parameters that are passed to the driver as they are, e.g. `connect_timeout` or `parseTime`.
+	Options map[string]string `json:"options,omitempty"`
+	// Tls is nil for connections that aren't encrypted.
+	Tls *TlsSettings `json:"tls,omitempty"`
+	// SshTunnel is nil for connections that are made directly to the host.
+	SshTunnel *SshTunnelSettings `json:"ssh_tunnel,omitempty"`
+}
+
+// envReferenceRegex matches the references to environment variables in the fields of a connection, e.g. `${DB_HOST}`.
+var envReferenceRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// HasEnvReference tells whether a value references an environment variable.
+func HasEnvReference(value string) bool {
+	return envReferenceRegex.MatchString(value)
+}
+
+// expandEnvReferences replaces the references to environment variables in a value with their values. It returns an
+// error if a variable isn't set.
+func expandEnvReferences(value string) (string, safego.Option[error]) {
+	var errOpt safego.Option[error]
+
+	ret := envReferenceRegex.ReplaceAllStringFunc(value, func(reference string) string {
+		name := envReferenceRegex.FindStringSubmatch(reference)[1]
+
+		envValue, ok := os.LookupEnv(name)
+		if !ok && errOpt.IsNone() {
+			errOpt = safego.Some(fmt.Errorf("environment variable %s is not set", name))
+		}
+
+		return envValue
+	})
+
+	return ret, errOpt
+}
+
+// Resolve returns a copy of the connection info in which the references to environment variables are replaced with
+// their values, and the password is the output of PasswordCmd if there is one. The stored connection is left as it
+// is, so the references are what gets listed and saved.
+func (self *DbConnectionInfo) Resolve() (*DbConnectionInfo, safego.Option[error]) {
+	ret := *self
+
+	for _, field := range []*string{&ret.Host, &ret.User, &ret.Password, &ret.DatabaseName, &ret.FilePath} {
+		value, errOpt := expandEnvReferences(*field)
+		if errOpt.IsSome() {
+			return nil, errOpt
+		}
+
+		*field = value
+	}
+
+	if self.Tls != nil {
+		tlsSettings := *self.Tls
+		for _, field := range []*string{&tlsSettings.CaCert, &tlsSettings.ClientCert, &tlsSettings.ClientKey, &tlsSettings.ServerName} {
+			value, errOpt := expandEnvReferences(*field)
+			if errOpt.IsSome() {
+				return nil, errOpt
+			}
+
+			*field = value
+		}
+		ret.Tls = &tlsSettings
+	}
+
+	if self.SshTunnel != nil {
+		sshTunnelSettings := *self.SshTunnel
+		for _, field := range []*string{&sshTunnelSettings.Host, &sshTunnelSettings.User, &sshTunnelSettings.KeyPath, &sshTunnelSettings.KnownHostsPath} {
+			value, errOpt := expandEnvReferences(*field)
+			if errOpt.IsSome() {
+				return nil, errOpt
+			}
+
+			*field = value
+		}
+		ret.SshTunnel = &sshTunnelSettings
+	}
+
+	ret.Options = make(map[string]string, len(self.Options))
+	for key, value := range self.Options {
+		expandedValue, errOpt := expandEnvReferences(value)
+		if errOpt.IsSome() {
+			return nil, errOpt
+		}
+
+		ret.Options[key] = expandedValue
+	}
+
+	if ret.PasswordCmd != "" {
+		var command *exec.Cmd
+		if runtime.GOOS == "windows" {
+			command = exec.Command("cmd", "/C", ret.PasswordCmd)
+		} else {
+			command = exec.Command("sh", "-c", ret.PasswordCmd)
+		}
+
+		var stderr bytes.Buffer
+		command.Stderr = &stderr
+
+		output, err := command.Output()
+		if err != nil {
+			if stderr.Len() != 0 {
+				err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+			}
+
+			return nil, safego.Some(fmt.Errorf("error running the password command of %s: %w", ret.Name, err))
+		}
+
+		// Only the line break that ends the output is dropped, since the password itself may end with spaces.
+		ret.Password = strings.TrimSuffix(strings.TrimSuffix(string(output), "\n"), "\r")
+	}
+
+	return &ret, safego.None[error]()
+}
+
+// getMysqlTlsConfigName returns the name the TLS config of the connection is registered under with the Mysql driver.
+// The registry is global to the driver, so the name is derived from everything the config is built from, and
+// connections with different settings don't replace each other's config whether they are named or not.
+func (self *DbConnectionInfo) getMysqlTlsConfigName() string {
+	serverName := utils.Ternary(self.Tls.ServerName != "", self.Tls.ServerName, self.Host)
+
+	hash := sha256.Sum256([]byte(strings.Join([]string{serverName, self.Tls.Mode, self.Tls.CaCert, self.Tls.ClientCert, self.Tls.ClientKey}, "\x00")))
+
+	return "patchi-" + hex.EncodeToString(hash[:8])
 }
 
 // GetConnectionString retrieves the valid sql connection string for the current dialect. It returns
 // an error if the dialect isn't supported.
 func (self *DbConnectionInfo) GetConnectionString() (string, safego.Option[string]) {
+	options := url.Values{}
+	for key, value := range self.Options {
+		options.Set(key, value)
+	}
+
 	if self.Dialect == "mysql" || self.Dialect == "mariadb" {
-		return self.User + ":" + self.Password + "@tcp(" + self.Host + ":" + strconv.Itoa(self.Port) + ")/" + self.DatabaseName, safego.None[string]()
+		// The TLS config of the connection is registered with the driver under this name by Connect.
+		if self.Tls.IsEnabled() {
+			options.Set("tls", self.getMysqlTlsConfigName())
+		}
+
+		ret := self.User + ":" + self.Password + "@tcp(" + net.JoinHostPort(self.Host, strconv.Itoa(self.Port)) + ")/" + self.DatabaseName
+		if len(options) != 0 {
+			ret += "?" + options.Encode()
+		}
+
+		return ret, safego.None[string]()
 	} else if self.Dialect == "postgres" || self.Dialect == "cockroachdb" {
-		return "postgresql://" + self.User + ":" + self.Password + "@" + self.Host + ":" + strconv.Itoa(self.Port) + "/" + self.DatabaseName + "?sslmode=disable", safego.None[string]()
+		if self.Tls != nil && self.Tls.Mode != "" {
+			options.Set("sslmode", self.Tls.Mode)
+
+			for key, value := range map[string]string{"sslrootcert": self.Tls.CaCert, "sslcert": self.Tls.ClientCert, "sslkey": self.Tls.ClientKey} {
+				if value != "" {
+					options.Set(key, value)
+				}
+			}
+		} else if !options.Has("sslmode") {
+			options.Set("sslmode", "disable")
+		}
+
+		connectionUrl := url.URL{
+			Scheme:   "postgresql",
+			User:     utils.Ternary(self.Password == "", url.User(self.User), url.UserPassword(self.User, self.Password)),
+			Host:     net.JoinHostPort(self.Host, strconv.Itoa(self.Port)),
+			Path:     "/" + self.DatabaseName,
+			RawQuery: options.Encode(),
+		}
+
+		return connectionUrl.String(), safego.None[string]()
+	} else if self.Dialect == "sqlite" {
+		// mode=rw fails on a missing file instead of creating an empty database.
+		options.Set("mode", "rw")
+
+		// Sqlite decodes the path of a `file:` URI, so characters like `?`, `#` or `%` in it are escaped.
+		connectionUrl := url.URL{
+			Scheme:   "file",
+			Opaque:   (&url.URL{Path: self.FilePath}).EscapedPath(),
+			RawQuery: options.Encode(),
+		}
+
+		return connectionUrl.String(), safego.None[string]()
 	}
 
 	return "", safego.Some[string]("Invalid dialect")
 }
 
-// Connect connects to the database and returns the sql.DB object.
+// Connect connects to the database and returns the sql.DB object. Connections with an SSH tunnel are made through a
+// local port forward, which is closed along with the sql.DB.
 func (self *DbConnectionInfo) Connect() (*sql.DB, safego.Option[error]) {
-	connStr, errOpt := self.GetConnectionString()
+	resolved, errOpt := self.Resolve()
 	if errOpt.IsSome() {
-		return nil, safego.Some(errors.New(errOpt.Unwrap()))
+		return nil, errOpt
+	}
+
+	if resolved.Tls != nil {
+		errOpt = resolved.Tls.Validate()
+		if errOpt.IsSome() {
+			return nil, errOpt
+		}
+	}
+
+	if (self.Dialect == "mysql" || self.Dialect == "mariadb") && resolved.Tls.IsEnabled() {
+		tlsConfig, errOpt := resolved.Tls.getMysqlTlsConfig(resolved.Host)
+		if errOpt.IsSome() {
+			return nil, errOpt
+		}
+
+		if err := mysql.RegisterTLSConfig(resolved.getMysqlTlsConfigName(), tlsConfig); err != nil {
+			return nil, safego.Some(err)
+		}
+	}
+
+	address := net.JoinHostPort(resolved.Host, strconv.Itoa(resolved.Port))
+
+	var tunnel *sshTunnel
+	if resolved.SshTunnel != nil && self.Dialect != "sqlite" {
+		errOpt = resolved.SshTunnel.Validate()
+		if errOpt.IsSome() {
+			return nil, errOpt
+		}
+
+		tunnel, errOpt = openSshTunnel(resolved.SshTunnel, address)
+		if errOpt.IsSome() {
+			return nil, errOpt
+		}
+
+		address = tunnel.getLocalAddress()
+	}
+
+	db, errOpt := resolved.open(address, tunnel)
+	if errOpt.IsSome() && tunnel != nil {
+		_ = tunnel.Close()
+	}
+
+	return db, errOpt
+}
+
+// open opens the database, whose server is reached at the given address. It differs from the host and port of the
+// connection when it goes through an SSH tunnel.
+func (self *DbConnectionInfo) open(address string, tunnel *sshTunnel) (*sql.DB, safego.Option[error]) {
+	var connector driver.Connector
+
+	// The Postgres driver checks the certificate against the host of the connection string, so the host is kept, or
+	// replaced with the server name, and the connection is dialed to the address.
+	hasServerName := self.Tls.IsEnabled() && self.Tls.ServerName != ""
+	if (self.Dialect == "postgres" || self.Dialect == "cockroachdb") && (tunnel != nil || hasServerName) {
+		if hasServerName {
+			self.Host = self.Tls.ServerName
+		}
+
+		connStr, errMsgOpt := self.GetConnectionString()
+		if errMsgOpt.IsSome() {
+			return nil, safego.Some(errors.New(errMsgOpt.Unwrap()))
+		}
+
+		pqConnector, err := pq.NewConnector(connStr)
+		if err != nil {
+			return nil, safego.Some(err)
+		}
+		pqConnector.Dialer(addressDialer{address: address})
+
+		connector = pqConnector
+	} else if (self.Dialect == "mysql" || self.Dialect == "mariadb") && tunnel != nil {
+		// The TLS config was registered with the actual host, so it is kept as the server name, which the name of the
+		// config is derived from, and only the address changes.
+		if self.Tls.IsEnabled() && self.Tls.ServerName == "" {
+			self.Tls.ServerName = self.Host
+		}
+
+		host, port, _ := net.SplitHostPort(address)
+		self.Host = host
+		self.Port, _ = strconv.Atoi(port)
+
+		connStr, errMsgOpt := self.GetConnectionString()
+		if errMsgOpt.IsSome() {
+			return nil, safego.Some(errors.New(errMsgOpt.Unwrap()))
+		}
+
+		mysqlConnector, err := mysql.MySQLDriver{}.OpenConnector(connStr)
+		if err != nil {
+			return nil, safego.Some(err)
+		}
+
+		connector = mysqlConnector
+	}
+
+	if connector != nil {
+		if tunnel != nil {
+			connector = tunnelConnector{Connector: connector, tunnel: tunnel}
+		}
+
+		return sql.OpenDB(connector), safego.None[error]()
+	}
+
+	connStr, errMsgOpt := self.GetConnectionString()
+	if errMsgOpt.IsSome() {
+		return nil, safego.Some(errors.New(errMsgOpt.Unwrap()))
 	}
 
 	driverName := utils.Ternary(self.Dialect == "cockroachdb", "postgres", self.Dialect)
 	driverName = utils.Ternary(self.Dialect == "mariadb", "mysql", driverName)
+	driverName = utils.Ternary(self.Dialect == "sqlite", "sqlite3", driverName)
 
 	db, err := sql.Open(driverName, connStr)
 	if err != nil {
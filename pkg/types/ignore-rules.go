@@ -0,0 +1,30 @@
+package types
+
+// IgnoreRules holds the patterns of the entities that are left out of every diff, per type of entity. A pattern is
+// either a glob (e.g. `tmp_*`) or a regular expression wrapped in slashes (e.g. `/^_backup_\d+$/`).
+// Patterns of entities that belong to a table (columns, indexes, constraints and triggers) can be qualified with the
+// table's name, e.g. `audit_*.created_by`. Unqualified ones match the entity in every table.
+type IgnoreRules struct {
+	Tables      []string `json:"tables,omitempty"`
+	Columns     []string `json:"columns,omitempty"`
+	Indexes     []string `json:"indexes,omitempty"`
+	Constraints []string `json:"constraints,omitempty"`
+	Views       []string `json:"views,omitempty"`
+	Procedures  []string `json:"procedures,omitempty"`
+	Functions   []string `json:"functions,omitempty"`
+	Triggers    []string `json:"triggers,omitempty"`
+}
+
+// Merge returns the rules of both sets combined.
+func (self IgnoreRules) Merge(other IgnoreRules) IgnoreRules {
+	return IgnoreRules{
+		Tables:      append(append([]string{}, self.Tables...), other.Tables...),
+		Columns:     append(append([]string{}, self.Columns...), other.Columns...),
+		Indexes:     append(append([]string{}, self.Indexes...), other.Indexes...),
+		Constraints: append(append([]string{}, self.Constraints...), other.Constraints...),
+		Views:       append(append([]string{}, self.Views...), other.Views...),
+		Procedures:  append(append([]string{}, self.Procedures...), other.Procedures...),
+		Functions:   append(append([]string{}, self.Functions...), other.Functions...),
+		Triggers:    append(append([]string{}, self.Triggers...), other.Triggers...),
+	}
+}
@@ -0,0 +1,213 @@
+package types
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Okira-E/patchi/pkg/utils"
+	"github.com/Okira-E/patchi/safego"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SshTunnelSettings are the settings of the SSH tunnel a connection goes through, e.g. to reach a database behind a
+// bastion host.
+type SshTunnelSettings struct {
+	Host string `json:"host"`
+	// Port is 22 when it isn't given.
+	Port int    `json:"port,omitempty"`
+	User string `json:"user"`
+	// KeyPath is the path to the private key. Without one, the keys of the SSH agent are used.
+	KeyPath string `json:"key_path,omitempty"`
+	// KnownHostsPath is the path to the known_hosts file the key of the host is checked against, `~/.ssh/known_hosts`
+	// by default.
+	KnownHostsPath string `json:"known_hosts,omitempty"`
+}
+
+// Validate returns an error if the host or the user is missing.
+func (self *SshTunnelSettings) Validate() safego.Option[error] {
+	if self.Host == "" {
+		return safego.Some(errors.New("the SSH host cannot be empty"))
+	} else if self.User == "" {
+		return safego.Some(errors.New("the SSH user cannot be empty"))
+	} else if self.Port < 0 || self.Port > 65535 {
+		return safego.Some(errors.New("the SSH port must be between 0 and 65535"))
+	}
+
+	return safego.None[error]()
+}
+
+// String describes the tunnel as `user@host:port`.
+func (self *SshTunnelSettings) String() string {
+	return self.User + "@" + self.getAddress()
+}
+
+func (self *SshTunnelSettings) getAddress() string {
+	return net.JoinHostPort(self.Host, strconv.Itoa(utils.Ternary(self.Port == 0, 22, self.Port)))
+}
+
+// getAuthMethods returns the private key if there is one, or the keys of the SSH agent otherwise. It also returns a
+// function that closes the connection to the agent, to call once the SSH connection is made.
+func (self *SshTunnelSettings) getAuthMethods() ([]ssh.AuthMethod, func(), safego.Option[error]) {
+	if self.KeyPath != "" {
+		key, err := os.ReadFile(expandHomeDir(self.KeyPath))
+		if err != nil {
+			return nil, nil, safego.Some(fmt.Errorf("error reading the SSH key: %w", err))
+		}
+
+		signer, err := ssh.ParsePrivateKey(key)
+		if _, ok := err.(*ssh.PassphraseMissingError); ok {
+			return nil, nil, safego.Some(fmt.Errorf("the SSH key %s is protected by a passphrase. Add it to the SSH agent and leave out its path instead", self.KeyPath))
+		} else if err != nil {
+			return nil, nil, safego.Some(fmt.Errorf("error parsing the SSH key: %w", err))
+		}
+
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, func() {}, safego.None[error]()
+	}
+
+	agentSocket := os.Getenv("SSH_AUTH_SOCK")
+	if agentSocket == "" {
+		return nil, nil, safego.Some(errors.New("no SSH key was given and no SSH agent is running"))
+	}
+
+	agentConnection, err := net.Dial("unix", agentSocket)
+	if err != nil {
+		return nil, nil, safego.Some(fmt.Errorf("error connecting to the SSH agent: %w", err))
+	}
+
+	return []ssh.AuthMethod{ssh.PublicKeysCallback(agent.NewClient(agentConnection).Signers)}, func() { _ = agentConnection.Close() }, safego.None[error]()
+}
+
+// sshTunnel forwards the connections made to a local port to a remote address, through an SSH connection.
+type sshTunnel struct {
+	client   *ssh.Client
+	listener net.Listener
+	// closeOnce makes closing the tunnel safe to repeat.
+	closeOnce sync.Once
+}
+
+// openSshTunnel connects to the SSH host and starts forwarding a random local port to remoteAddress.
+func openSshTunnel(settings *SshTunnelSettings, remoteAddress string) (*sshTunnel, safego.Option[error]) {
+	authMethods, closeAgentConnection, errOpt := settings.getAuthMethods()
+	if errOpt.IsSome() {
+		return nil, errOpt
+	}
+	defer closeAgentConnection()
+
+	knownHostsPath := settings.KnownHostsPath
+	if knownHostsPath == "" {
+		knownHostsPath = "~/.ssh/known_hosts"
+	}
+
+	hostKeyCallback, err := knownhosts.New(expandHomeDir(knownHostsPath))
+	if err != nil {
+		return nil, safego.Some(fmt.Errorf("error reading the known hosts of SSH: %w", err))
+	}
+
+	client, err := ssh.Dial("tcp", settings.getAddress(), &ssh.ClientConfig{
+		User:            settings.User,
+		Auth:            authMethods,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         15 * time.Second,
+	})
+	if err != nil {
+		return nil, safego.Some(fmt.Errorf("error connecting to %s over SSH: %w", settings, err))
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		_ = client.Close()
+		return nil, safego.Some(fmt.Errorf("error opening the local end of the SSH tunnel: %w", err))
+	}
+
+	ret := &sshTunnel{client: client, listener: listener}
+	go ret.acceptConnections(remoteAddress)
+
+	return ret, safego.None[error]()
+}
+
+// acceptConnections forwards every local connection until the tunnel is closed.
+func (self *sshTunnel) acceptConnections(remoteAddress string) {
+	for {
+		localConnection, err := self.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go self.forward(localConnection, remoteAddress)
+	}
+}
+
+// forward copies the data of a local connection to the remote address and back, until either side closes.
+func (self *sshTunnel) forward(localConnection net.Conn, remoteAddress string) {
+	defer localConnection.Close()
+
+	remoteConnection, err := self.client.Dial("tcp", remoteAddress)
+	if err != nil {
+		return
+	}
+	defer remoteConnection.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(remoteConnection, localConnection)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(localConnection, remoteConnection)
+		done <- struct{}{}
+	}()
+
+	<-done
+}
+
+// getLocalAddress returns the address the connections to the remote address are made to.
+func (self *sshTunnel) getLocalAddress() string {
+	return self.listener.Addr().String()
+}
+
+// Close stops forwarding and closes the SSH connection, along with the connections that go through it.
+func (self *sshTunnel) Close() error {
+	var err error
+	self.closeOnce.Do(func() {
+		_ = self.listener.Close()
+		err = self.client.Close()
+	})
+
+	return err
+}
+
+// tunnelConnector closes the SSH tunnel of a connection when the sql.DB it was opened with is closed, since
+// `sql.DB.Close` closes connectors that implement `io.Closer`.
+type tunnelConnector struct {
+	driver.Connector
+	tunnel *sshTunnel
+}
+
+func (self tunnelConnector) Close() error {
+	return self.tunnel.Close()
+}
+
+// expandHomeDir replaces a leading `~` of a path with the home directory of the user.
+func expandHomeDir(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,337 @@
+package types
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// newTestSshKey generates an ed25519 key and writes its private half to a file in the OpenSSH format.
+func newTestSshKey(t *testing.T) (ssh.Signer, string) {
+	t.Helper()
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	return signer, keyPath
+}
+
+// newTestSshServer starts an SSH server that accepts any public key and forwards `direct-tcpip` channels, and returns
+// its address.
+func newTestSshServer(t *testing.T, hostKey ssh.Signer) string {
+	t.Helper()
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) { return nil, nil },
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			connection, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveTestSshConnection(connection, config)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func serveTestSshConnection(connection net.Conn, config *ssh.ServerConfig) {
+	serverConnection, channels, requests, err := ssh.NewServerConn(connection, config)
+	if err != nil {
+		connection.Close()
+		return
+	}
+	defer serverConnection.Close()
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+
+		remoteConnection, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			remoteConnection.Close()
+			continue
+		}
+		go ssh.DiscardRequests(channelRequests)
+
+		go func() {
+			defer channel.Close()
+			defer remoteConnection.Close()
+
+			go func() {
+				_, _ = io.Copy(remoteConnection, channel)
+			}()
+			_, _ = io.Copy(channel, remoteConnection)
+		}()
+	}
+}
+
+// newTestKnownHosts writes a known_hosts file that holds the given key for the address.
+func newTestKnownHosts(t *testing.T, address string, hostKey ssh.PublicKey) string {
+	t.Helper()
+
+	content := ""
+	if hostKey != nil {
+		content = knownhosts.Line([]string{address}, hostKey) + "\n"
+	}
+
+	knownHostsPath := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(knownHostsPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	return knownHostsPath
+}
+
+func TestSshTunnelSettingsJson(t *testing.T) {
+	content := `{
+		"dialect": "postgres",
+		"host": "10.0.0.5",
+		"ssh_tunnel": {"host": "bastion.example.com", "port": 2222, "user": "deploy", "key_path": "~/.ssh/id_ed25519", "known_hosts": "/etc/ssh/known_hosts"}
+	}`
+
+	var info DbConnectionInfo
+	if err := json.Unmarshal([]byte(content), &info); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := SshTunnelSettings{Host: "bastion.example.com", Port: 2222, User: "deploy", KeyPath: "~/.ssh/id_ed25519", KnownHostsPath: "/etc/ssh/known_hosts"}
+	if info.SshTunnel == nil || *info.SshTunnel != expected {
+		t.Fatalf("got %+v, expected %+v", info.SshTunnel, expected)
+	}
+
+	var directInfo DbConnectionInfo
+	if err := json.Unmarshal([]byte(`{"dialect": "postgres", "host": "10.0.0.5"}`), &directInfo); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if directInfo.SshTunnel != nil {
+		t.Errorf("got %+v, expected no SSH tunnel", directInfo.SshTunnel)
+	}
+}
+
+func TestSshTunnelSettingsValidate(t *testing.T) {
+	tests := []struct {
+		name          string
+		settings      SshTunnelSettings
+		expectedError string
+	}{
+		{name: "valid", settings: SshTunnelSettings{Host: "bastion", User: "deploy"}},
+		{name: "missing host", settings: SshTunnelSettings{User: "deploy"}, expectedError: "the SSH host cannot be empty"},
+		{name: "missing user", settings: SshTunnelSettings{Host: "bastion"}, expectedError: "the SSH user cannot be empty"},
+		{name: "negative port", settings: SshTunnelSettings{Host: "bastion", User: "deploy", Port: -1}, expectedError: "the SSH port must be between 0 and 65535"},
+		{name: "port out of range", settings: SshTunnelSettings{Host: "bastion", User: "deploy", Port: 65536}, expectedError: "the SSH port must be between 0 and 65535"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errOpt := test.settings.Validate()
+			if test.expectedError == "" {
+				if errOpt.IsSome() {
+					t.Errorf("unexpected error: %s", errOpt.Unwrap())
+				}
+			} else if errOpt.IsNone() || errOpt.Unwrap().Error() != test.expectedError {
+				t.Errorf("got %v, expected %s", errOpt, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestSshTunnelSettingsString(t *testing.T) {
+	tests := []struct {
+		settings SshTunnelSettings
+		expected string
+	}{
+		{SshTunnelSettings{Host: "bastion", User: "deploy"}, "deploy@bastion:22"},
+		{SshTunnelSettings{Host: "bastion", User: "deploy", Port: 2222}, "deploy@bastion:2222"},
+		{SshTunnelSettings{Host: "::1", User: "deploy"}, "deploy@[::1]:22"},
+	}
+
+	for _, test := range tests {
+		if got := test.settings.String(); got != test.expected {
+			t.Errorf("got %s, expected %s", got, test.expected)
+		}
+	}
+}
+
+func TestGetAuthMethodsErrors(t *testing.T) {
+	notAKeyPath := filepath.Join(t.TempDir(), "not-a-key")
+	if err := os.WriteFile(notAKeyPath, []byte("not a key"), 0o600); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tests := []struct {
+		name          string
+		keyPath       string
+		expectedError string
+	}{
+		{name: "missing key", keyPath: filepath.Join(t.TempDir(), "missing"), expectedError: "error reading the SSH key"},
+		{name: "invalid key", keyPath: notAKeyPath, expectedError: "error parsing the SSH key"},
+		{name: "no key and no agent", keyPath: "", expectedError: "no SSH key was given and no SSH agent is running"},
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := SshTunnelSettings{Host: "bastion", User: "deploy", KeyPath: test.keyPath}
+
+			_, _, errOpt := settings.getAuthMethods()
+			if errOpt.IsNone() || !strings.Contains(errOpt.Unwrap().Error(), test.expectedError) {
+				t.Errorf("got %v, expected %s", errOpt, test.expectedError)
+			}
+		})
+	}
+}
+
+func TestOpenSshTunnel(t *testing.T) {
+	hostKey, _ := newTestSshKey(t)
+	otherHostKey, _ := newTestSshKey(t)
+	_, clientKeyPath := newTestSshKey(t)
+
+	address := newTestSshServer(t, hostKey)
+	host, port, _ := net.SplitHostPort(address)
+	portNumber, _ := strconv.Atoi(port)
+
+	echoListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	t.Cleanup(func() { echoListener.Close() })
+	go func() {
+		for {
+			connection, err := echoListener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer connection.Close()
+				_, _ = io.Copy(connection, connection)
+			}()
+		}
+	}()
+
+	tests := []struct {
+		name          string
+		knownHostKey  ssh.PublicKey
+		expectedError string
+	}{
+		{name: "known host", knownHostKey: hostKey.PublicKey()},
+		{name: "unknown host", knownHostKey: nil, expectedError: "knownhosts: key is unknown"},
+		{name: "changed host key", knownHostKey: otherHostKey.PublicKey(), expectedError: "knownhosts: key mismatch"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := &SshTunnelSettings{
+				Host:           host,
+				Port:           portNumber,
+				User:           "deploy",
+				KeyPath:        clientKeyPath,
+				KnownHostsPath: newTestKnownHosts(t, address, test.knownHostKey),
+			}
+
+			tunnel, errOpt := openSshTunnel(settings, echoListener.Addr().String())
+			if test.expectedError != "" {
+				if errOpt.IsNone() {
+					tunnel.Close()
+					t.Fatalf("expected the host key to be rejected")
+				}
+				if !strings.Contains(errOpt.Unwrap().Error(), test.expectedError) {
+					t.Errorf("got %s, expected %s", errOpt.Unwrap(), test.expectedError)
+				}
+
+				return
+			}
+
+			if errOpt.IsSome() {
+				t.Fatalf("unexpected error: %s", errOpt.Unwrap())
+			}
+			defer tunnel.Close()
+
+			connection, err := net.Dial("tcp", tunnel.getLocalAddress())
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer connection.Close()
+
+			if _, err := connection.Write([]byte("ping")); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			reply := make([]byte, 4)
+			if _, err := io.ReadFull(connection, reply); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(reply) != "ping" {
+				t.Errorf("got %s, expected ping", reply)
+			}
+
+			if err := tunnel.Close(); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if err := tunnel.Close(); err != nil {
+				t.Errorf("expected closing the tunnel again to be a no-op, got %s", err)
+			}
+		})
+	}
+}
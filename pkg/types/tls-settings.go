@@ -0,0 +1,137 @@
+package types
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Okira-E/patchi/safego"
+)
+
+// TlsModes are the TLS modes of a connection, named after the `sslmode` of Postgres.
+var TlsModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+// TlsSettings are the TLS settings of a connection. Certificates and keys are paths to PEM files.
+type TlsSettings struct {
+	// Mode is one of TlsModes. `require` encrypts the connection without checking the certificate of the server, unless
+	// CaCert is given, in which case it is the same as `verify-ca` (like in libpq). `verify-ca` checks that the
+	// certificate is signed by CaCert, or by a CA of the system, and `verify-full` also checks that it was issued for
+	// the host, or for ServerName if it is given.
+	Mode       string `json:"mode,omitempty"`
+	CaCert     string `json:"ca_cert,omitempty"`
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+	ServerName string `json:"server_name,omitempty"`
+}
+
+// IsEnabled tells whether the connection is encrypted.
+func (self *TlsSettings) IsEnabled() bool {
+	return self != nil && self.Mode != "" && self.Mode != "disable"
+}
+
+// Validate returns an error if the mode isn't one of TlsModes, or if only one of the client certificate and key is given.
+func (self *TlsSettings) Validate() safego.Option[error] {
+	if self.Mode != "" && !slices.Contains(TlsModes, self.Mode) {
+		return safego.Some(fmt.Errorf("TLS mode must be one of: %s", strings.Join(TlsModes, ", ")))
+	}
+
+	if (self.ClientCert == "") != (self.ClientKey == "") {
+		return safego.Some(errors.New("a TLS client certificate and its key must be given together"))
+	}
+
+	return safego.None[error]()
+}
+
+// getMysqlTlsConfig builds the TLS config that is registered with the Mysql driver for a connection to the given host.
+func (self *TlsSettings) getMysqlTlsConfig(host string) (*tls.Config, safego.Option[error]) {
+	ret := &tls.Config{ServerName: host}
+	if self.ServerName != "" {
+		ret.ServerName = self.ServerName
+	}
+
+	if self.ClientCert != "" {
+		certificate, err := tls.LoadX509KeyPair(self.ClientCert, self.ClientKey)
+		if err != nil {
+			return nil, safego.Some(fmt.Errorf("error loading the TLS client certificate: %w", err))
+		}
+
+		ret.Certificates = []tls.Certificate{certificate}
+	}
+
+	// A nil pool verifies against the CAs of the system.
+	var rootCertificates *x509.CertPool
+	if self.CaCert != "" {
+		caCert, err := os.ReadFile(self.CaCert)
+		if err != nil {
+			return nil, safego.Some(fmt.Errorf("error reading the TLS CA certificate: %w", err))
+		}
+
+		rootCertificates = x509.NewCertPool()
+		if !rootCertificates.AppendCertsFromPEM(caCert) {
+			return nil, safego.Some(fmt.Errorf("no certificate found in %s", self.CaCert))
+		}
+	}
+
+	if self.Mode == "verify-full" {
+		ret.RootCAs = rootCertificates
+
+		return ret, safego.None[error]()
+	}
+
+	// Go either checks the whole certificate or nothing, so the chain is checked by hand when the host isn't.
+	ret.InsecureSkipVerify = true
+	if self.Mode == "verify-ca" || self.CaCert != "" {
+		ret.VerifyPeerCertificate = func(rawCertificates [][]byte, _ [][]*x509.Certificate) error {
+			return verifyCertificateChain(rawCertificates, rootCertificates)
+		}
+	}
+
+	return ret, safego.None[error]()
+}
+
+// verifyCertificateChain checks that the certificate of a server is signed by one of the root certificates, without
+// checking its host name.
+func verifyCertificateChain(rawCertificates [][]byte, rootCertificates *x509.CertPool) error {
+	certificates := []*x509.Certificate{}
+	for _, rawCertificate := range rawCertificates {
+		certificate, err := x509.ParseCertificate(rawCertificate)
+		if err != nil {
+			return err
+		}
+
+		certificates = append(certificates, certificate)
+	}
+
+	if len(certificates) == 0 {
+		return errors.New("the server sent no certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+
+	_, err := certificates[0].Verify(x509.VerifyOptions{Roots: rootCertificates, Intermediates: intermediates})
+
+	return err
+}
+
+// addressDialer dials a fixed address whatever the address it is asked for. It lets the Postgres driver check the
+// certificate of the server against a server name that isn't the host it connects to.
+type addressDialer struct {
+	address string
+}
+
+func (self addressDialer) Dial(network string, _ string) (net.Conn, error) {
+	return net.Dial(network, self.address)
+}
+
+func (self addressDialer) DialTimeout(network string, _ string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout(network, self.address, timeout)
+}
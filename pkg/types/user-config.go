@@ -4,13 +4,25 @@ import "fmt"
 
 type UserConfig struct {
 	DbConnections map[string]*DbConnectionInfo `json:"db_connections,omitempty"`
+	// IgnoreRules are applied to every comparison, on top of the rules of the `.patchiignore` file of the project.
+	IgnoreRules IgnoreRules `json:"ignore_rules,omitempty"`
+	// Encryption is set once a master passphrase is chosen to encrypt the passwords of the connections.
+	Encryption *ConfigEncryption `json:"encryption,omitempty"`
+}
+
+// ConfigEncryption holds what is needed to derive the key of the stored passwords from the master passphrase with
+// scrypt. Check is a known value encrypted with the key, which tells whether a passphrase is the right one.
+type ConfigEncryption struct {
+	Salt  string `json:"salt"`
+	LogN  int    `json:"log_n"`
+	Check string `json:"check"`
 }
 
 func (uc *UserConfig) String() string {
 	var str string
 
 	for _, dbConfig := range uc.DbConnections {
-		str += fmt.Sprintf("%s\n", dbConfig)
+		str += fmt.Sprintf("%v\n", dbConfig)
 	}
 
 	return str
@@ -21,6 +21,9 @@ func ReadJSONFile(filePath string, valRef any) safego.Option[error] {
 // WriteToJSONFile writes a value to a JSON file.
 func WriteToJSONFile(filePath string, content any) safego.Option[error] {
 	file, err := os.Create(filePath)
+	if err != nil {
+		return safego.Some(err)
+	}
 	defer file.Close()
 
 	fileContent, err := json.MarshalIndent(content, "", "\t")
@@ -1,11 +1,8 @@
 package utils
 
 import (
-	"fmt"
 	"os"
 
-	"github.com/gizak/termui/v3"
-
 	"github.com/Okira-E/patchi/pkg/vars/colors"
 )
 
@@ -14,19 +11,3 @@ func Abort(message string) {
 	PrintInColor(colors.Red, message, true)
 	os.Exit(1)
 }
-
-// AbortTui stops termui from rendering with a message to stderr.
-func AbortTui(message string) {
-	termui.Close()
-
-	PrintInColor(colors.Red, message, true)
-	os.Exit(1)
-}
-
-// LogTui stops termui from rendering with a message to be logged.
-func LogTui(msg ...any) {
-	termui.Close()
-
-	fmt.Println(msg...)
-	os.Exit(0)
-}
@@ -1,3 +1,3 @@
 package vars
 
-var SupportedDatabases = []string{"mysql", "mariadb", "postgres", "cockroachdb"}
+var SupportedDatabases = []string{"mysql", "mariadb", "postgres", "cockroachdb", "sqlite"}